@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS stop_times;
+DROP TABLE IF EXISTS shapes;
+DROP TABLE IF EXISTS trips;
+DROP TABLE IF EXISTS routes;
+
+DROP INDEX IF EXISTS idx_bus_stations_gtfs_stop_id;
+
+ALTER TABLE bus_stations
+    DROP COLUMN IF EXISTS code,
+    DROP COLUMN IF EXISTS gtfs_stop_id;
@@ -0,0 +1,51 @@
+ALTER TABLE bus_stations
+    ADD COLUMN IF NOT EXISTS gtfs_stop_id VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS code VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bus_stations_gtfs_stop_id
+    ON bus_stations (gtfs_stop_id);
+
+CREATE TABLE IF NOT EXISTS routes (
+    route_id    VARCHAR(64) PRIMARY KEY,
+    agency_id   VARCHAR(64),
+    short_name  VARCHAR(50),
+    long_name   VARCHAR(255),
+    route_type  INT NOT NULL DEFAULT 3,
+    color       VARCHAR(6),
+    text_color  VARCHAR(6),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS trips (
+    trip_id       VARCHAR(64) PRIMARY KEY,
+    route_id      VARCHAR(64) NOT NULL REFERENCES routes (route_id) ON DELETE CASCADE,
+    service_id    VARCHAR(64) NOT NULL,
+    headsign      VARCHAR(255),
+    direction_id  SMALLINT,
+    shape_id      VARCHAR(64),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_route_id ON trips (route_id);
+
+CREATE TABLE IF NOT EXISTS shapes (
+    shape_id       VARCHAR(64) NOT NULL,
+    sequence       INT NOT NULL,
+    latitude       DOUBLE PRECISION NOT NULL,
+    longitude      DOUBLE PRECISION NOT NULL,
+    dist_traveled  DOUBLE PRECISION,
+    PRIMARY KEY (shape_id, sequence)
+);
+
+CREATE TABLE IF NOT EXISTS stop_times (
+    trip_id         VARCHAR(64) NOT NULL REFERENCES trips (trip_id) ON DELETE CASCADE,
+    stop_sequence   INT NOT NULL,
+    station_id      INT NOT NULL REFERENCES bus_stations (id) ON DELETE CASCADE,
+    arrival_time    VARCHAR(8),
+    departure_time  VARCHAR(8),
+    PRIMARY KEY (trip_id, stop_sequence)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stop_times_station_id ON stop_times (station_id);
+
+COMMENT ON COLUMN stop_times.arrival_time IS 'GTFS HH:MM:SS, may exceed 24:00:00 for trips past midnight';
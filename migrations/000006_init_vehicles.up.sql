@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS vehicles (
+    vehicle_id     VARCHAR(50) PRIMARY KEY,
+    label          VARCHAR(100),
+    license_plate  VARCHAR(20),
+    route_id       VARCHAR(64) REFERENCES routes (route_id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicles_route_id ON vehicles (route_id);
+
+COMMENT ON COLUMN vehicles.route_id IS 'Route (corridor) the vehicle is currently assigned to';
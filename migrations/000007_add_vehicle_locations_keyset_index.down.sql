@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_vehicle_locations_vehicle_timestamp_id;
@@ -0,0 +1,4 @@
+-- keyset pagination orders by (timestamp, id); the id tie-breaker keeps pages stable
+-- when a device reports several points within the same second
+CREATE INDEX IF NOT EXISTS idx_vehicle_locations_vehicle_timestamp_id
+    ON vehicle_locations (vehicle_id, timestamp, id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS vehicle_trips;
+
+ALTER TABLE vehicle_locations
+    DROP COLUMN IF EXISTS ignition;
@@ -0,0 +1,26 @@
+ALTER TABLE vehicle_locations
+    ADD COLUMN IF NOT EXISTS ignition BOOLEAN;
+
+COMMENT ON COLUMN vehicle_locations.ignition IS 'Ignition state reported by the device, NULL when the tracker has no ignition input';
+
+CREATE TABLE IF NOT EXISTS vehicle_trips (
+    id                BIGSERIAL PRIMARY KEY,
+    vehicle_id        VARCHAR(50) NOT NULL,
+    start_time        BIGINT NOT NULL,
+    end_time          BIGINT NOT NULL,
+    start_latitude    DOUBLE PRECISION NOT NULL,
+    start_longitude   DOUBLE PRECISION NOT NULL,
+    end_latitude      DOUBLE PRECISION NOT NULL,
+    end_longitude     DOUBLE PRECISION NOT NULL,
+    start_station_id  INT REFERENCES bus_stations (id) ON DELETE SET NULL,
+    end_station_id    INT REFERENCES bus_stations (id) ON DELETE SET NULL,
+    distance_m        DOUBLE PRECISION NOT NULL,
+    duration_s        BIGINT NOT NULL,
+    max_speed_kmh     DOUBLE PRECISION NOT NULL,
+    point_count       INT NOT NULL,
+    end_reason        VARCHAR(20) NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_trips_vehicle_start
+    ON vehicle_trips (vehicle_id, start_time DESC);
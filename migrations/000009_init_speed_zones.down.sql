@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS speed_zones;
+
+ALTER TABLE vehicle_locations
+    DROP COLUMN IF EXISTS speed;
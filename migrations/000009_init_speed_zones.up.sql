@@ -0,0 +1,20 @@
+ALTER TABLE vehicle_locations
+    ADD COLUMN IF NOT EXISTS speed DOUBLE PRECISION;
+
+COMMENT ON COLUMN vehicle_locations.speed IS 'Speed in km/h reported by the device, NULL when not reported';
+
+CREATE TABLE IF NOT EXISTS speed_zones (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    station_id  INT REFERENCES bus_stations (id) ON DELETE CASCADE,
+    latitude    DOUBLE PRECISION,
+    longitude   DOUBLE PRECISION,
+    radius_m    DOUBLE PRECISION NOT NULL,
+    limit_kmh   DOUBLE PRECISION NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT speed_zones_center CHECK (
+        station_id IS NOT NULL OR (latitude IS NOT NULL AND longitude IS NOT NULL)
+    )
+);
+
+COMMENT ON TABLE speed_zones IS 'Circular speed limit zones, centered on a bus station or on explicit coordinates';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS alert_rules;
+
+DROP INDEX IF EXISTS idx_vehicles_group_name;
+
+ALTER TABLE vehicles
+    DROP COLUMN IF EXISTS group_name;
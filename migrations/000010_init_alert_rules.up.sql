@@ -0,0 +1,21 @@
+ALTER TABLE vehicles
+    ADD COLUMN IF NOT EXISTS group_name VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_vehicles_group_name ON vehicles (group_name);
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id           SERIAL PRIMARY KEY,
+    key          VARCHAR(50) NOT NULL UNIQUE,
+    name         VARCHAR(100) NOT NULL,
+    description  TEXT,
+    severity     VARCHAR(10) NOT NULL DEFAULT 'warning',
+    enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+    conditions   JSONB NOT NULL,
+    duration_s   INT NOT NULL DEFAULT 0,
+    cooldown_s   INT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN alert_rules.key IS 'Published as routing key alert.<key> on fleet.events';
+COMMENT ON COLUMN alert_rules.conditions IS 'JSON array of conditions, all of which must hold';
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS notification_subscribers (
+    id                 SERIAL PRIMARY KEY,
+    name               VARCHAR(100) NOT NULL,
+    channel            VARCHAR(10) NOT NULL,
+    target             TEXT NOT NULL,
+    secret             TEXT,
+    event_types        JSONB NOT NULL DEFAULT '["#"]',
+    vehicle_ids        JSONB NOT NULL DEFAULT '[]',
+    rate_limit_per_min INT NOT NULL DEFAULT 0,
+    enabled            BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_notification_subscribers_channel CHECK (channel IN ('webhook', 'email', 'sms'))
+);
+
+COMMENT ON COLUMN notification_subscribers.target IS 'Webhook URL, e-mail address or phone number depending on channel';
+COMMENT ON COLUMN notification_subscribers.secret IS 'HMAC-SHA256 key for webhook signatures';
+COMMENT ON COLUMN notification_subscribers.event_types IS 'Routing key patterns on fleet.events (* = one word, # = zero or more)';
+COMMENT ON COLUMN notification_subscribers.vehicle_ids IS 'Only these vehicles; empty means all';
+COMMENT ON COLUMN notification_subscribers.rate_limit_per_min IS '0 = unlimited';
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id            BIGSERIAL PRIMARY KEY,
+    delivery_id   VARCHAR(32) NOT NULL,
+    subscriber_id INT NOT NULL REFERENCES notification_subscribers (id) ON DELETE CASCADE,
+    channel       VARCHAR(10) NOT NULL,
+    routing_key   VARCHAR(100) NOT NULL,
+    vehicle_id    VARCHAR(50),
+    attempt       INT NOT NULL,
+    status        VARCHAR(20) NOT NULL,
+    status_code   INT,
+    error         TEXT,
+    duration_ms   INT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_subscriber
+    ON notification_deliveries (subscriber_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_delivery_id
+    ON notification_deliveries (delivery_id);
+
+COMMENT ON TABLE notification_deliveries IS 'One row per delivery attempt';
+COMMENT ON COLUMN notification_deliveries.status IS 'sent, retrying, failed or rate_limited';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notification_subscribers_channel;
+
+ALTER TABLE notification_subscribers
+    DROP COLUMN IF EXISTS disabled_reason,
+    DROP COLUMN IF EXISTS disabled_at,
+    DROP COLUMN IF EXISTS consecutive_failures,
+    DROP COLUMN IF EXISTS route_ids;
@@ -0,0 +1,11 @@
+ALTER TABLE notification_subscribers
+    ADD COLUMN IF NOT EXISTS route_ids            JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS consecutive_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS disabled_at          TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS disabled_reason      TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notification_subscribers_channel ON notification_subscribers (channel);
+
+COMMENT ON COLUMN notification_subscribers.route_ids IS 'Only vehicles assigned to these routes; empty means all';
+COMMENT ON COLUMN notification_subscribers.consecutive_failures IS 'Deliveries that failed after all retries since the last success';
+COMMENT ON COLUMN notification_subscribers.disabled_reason IS 'Set when the notifier disables a failing subscriber';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS station_visits;
+
+DROP TABLE IF EXISTS geofence_events;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS geofence_events (
+    id          BIGSERIAL PRIMARY KEY,
+    vehicle_id  VARCHAR(50) NOT NULL,
+    station_id  INT NOT NULL REFERENCES bus_stations (id) ON DELETE CASCADE,
+    event_type  VARCHAR(10) NOT NULL,
+    timestamp   BIGINT NOT NULL,
+    latitude    DOUBLE PRECISION NOT NULL,
+    longitude   DOUBLE PRECISION NOT NULL,
+    distance_m  DOUBLE PRECISION NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_geofence_events_type CHECK (event_type IN ('entry', 'exit'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_geofence_events_vehicle_ts
+    ON geofence_events (vehicle_id, timestamp, id);
+CREATE INDEX IF NOT EXISTS idx_geofence_events_station_ts
+    ON geofence_events (station_id, timestamp, id);
+
+CREATE TABLE IF NOT EXISTS station_visits (
+    id              BIGSERIAL PRIMARY KEY,
+    vehicle_id      VARCHAR(50) NOT NULL,
+    station_id      INT NOT NULL REFERENCES bus_stations (id) ON DELETE CASCADE,
+    arrival_time    BIGINT NOT NULL,
+    departure_time  BIGINT,
+    dwell_s         BIGINT,
+    min_distance_m  DOUBLE PRECISION NOT NULL,
+    point_count     INT NOT NULL DEFAULT 1,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_station_visits_vehicle_arrival
+    ON station_visits (vehicle_id, arrival_time, id);
+CREATE INDEX IF NOT EXISTS idx_station_visits_station_arrival
+    ON station_visits (station_id, arrival_time, id);
+-- a vehicle is at most once inside a station at a time
+CREATE UNIQUE INDEX IF NOT EXISTS uq_station_visits_open
+    ON station_visits (vehicle_id, station_id) WHERE departure_time IS NULL;
+
+COMMENT ON COLUMN station_visits.departure_time IS 'NULL while the vehicle is still at the station';
+COMMENT ON COLUMN station_visits.min_distance_m IS 'Closest approach to the station during the visit';
@@ -0,0 +1,3 @@
+ALTER TABLE notification_subscribers
+    DROP CONSTRAINT IF EXISTS chk_notification_subscribers_payload_format,
+    DROP COLUMN IF EXISTS payload_format;
@@ -0,0 +1,6 @@
+ALTER TABLE notification_subscribers
+    ADD COLUMN IF NOT EXISTS payload_format VARCHAR(20) NOT NULL DEFAULT 'envelope',
+    ADD CONSTRAINT chk_notification_subscribers_payload_format
+        CHECK (payload_format IN ('envelope', 'cloudevents', 'cloudevents-binary'));
+
+COMMENT ON COLUMN notification_subscribers.payload_format IS 'Webhook body: envelope, cloudevents (structured) or cloudevents-binary';
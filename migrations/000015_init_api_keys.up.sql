@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           SERIAL PRIMARY KEY,
+    name         VARCHAR(100) NOT NULL,
+    key_prefix   VARCHAR(16) NOT NULL UNIQUE,
+    key_hash     CHAR(64) NOT NULL,
+    role         VARCHAR(20) NOT NULL,
+    group_names  JSONB NOT NULL DEFAULT '[]',
+    route_ids    JSONB NOT NULL DEFAULT '[]',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_by   VARCHAR(100),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_api_keys_role CHECK (role IN ('admin', 'dispatcher', 'partner', 'read-only'))
+);
+
+COMMENT ON COLUMN api_keys.key_prefix IS 'Public part of the key, used to look it up';
+COMMENT ON COLUMN api_keys.key_hash IS 'Hex SHA-256 of the full key; the key itself is never stored';
+COMMENT ON COLUMN api_keys.group_names IS 'Fleets (vehicles.group_name) the key may see; empty together with route_ids means all';
+COMMENT ON COLUMN api_keys.route_ids IS 'Routes the key may see; empty together with group_names means all';
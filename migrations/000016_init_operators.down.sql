@@ -0,0 +1,18 @@
+ALTER TABLE notification_subscribers DROP COLUMN IF EXISTS operator_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS operator_id;
+
+DROP INDEX IF EXISTS idx_station_visits_operator;
+DROP INDEX IF EXISTS idx_geofence_events_operator;
+DROP INDEX IF EXISTS idx_vehicle_locations_operator_timestamp;
+
+ALTER TABLE station_visits DROP COLUMN IF EXISTS operator_id;
+ALTER TABLE geofence_events DROP COLUMN IF EXISTS operator_id;
+ALTER TABLE vehicle_trips DROP COLUMN IF EXISTS operator_id;
+ALTER TABLE vehicle_locations DROP COLUMN IF EXISTS operator_id;
+
+ALTER TABLE bus_stations DROP COLUMN IF EXISTS operator_id;
+
+DROP INDEX IF EXISTS idx_vehicles_operator_id;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS operator_id;
+
+DROP TABLE IF EXISTS operators;
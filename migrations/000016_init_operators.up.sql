@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS operators (
+    operator_id VARCHAR(50) PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_operators_operator_id CHECK (operator_id ~ '^[a-z0-9_-]+$')
+);
+
+COMMENT ON TABLE operators IS 'Bus operators (tenants); each only sees its own vehicles';
+COMMENT ON COLUMN operators.operator_id IS 'Also the tenant segment of MQTT topics /fleet/<operator_id>/vehicle/+/location';
+
+ALTER TABLE vehicles
+    ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50) REFERENCES operators (operator_id);
+CREATE INDEX IF NOT EXISTS idx_vehicles_operator_id ON vehicles (operator_id);
+
+ALTER TABLE bus_stations
+    ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50) REFERENCES operators (operator_id);
+COMMENT ON COLUMN bus_stations.operator_id IS 'Owning operator; NULL for stations shared by all operators';
+
+-- denormalized so tenant filters don't need a join to vehicles
+ALTER TABLE vehicle_locations ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
+ALTER TABLE vehicle_trips ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
+ALTER TABLE geofence_events ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
+ALTER TABLE station_visits ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_locations_operator_timestamp ON vehicle_locations (operator_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_geofence_events_operator ON geofence_events (operator_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_station_visits_operator ON station_visits (operator_id, arrival_time);
+
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50) REFERENCES operators (operator_id);
+COMMENT ON COLUMN api_keys.operator_id IS 'Tenant of the key; NULL for platform keys that see every operator';
+
+ALTER TABLE notification_subscribers
+    ADD COLUMN IF NOT EXISTS operator_id VARCHAR(50) REFERENCES operators (operator_id);
+COMMENT ON COLUMN notification_subscribers.operator_id IS 'Only events of this operator''s vehicles; NULL means all';
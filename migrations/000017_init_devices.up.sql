@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS devices (
+    id            SERIAL PRIMARY KEY,
+    vehicle_id    VARCHAR(50) NOT NULL REFERENCES vehicles (vehicle_id) ON DELETE CASCADE,
+    username      VARCHAR(64) NOT NULL UNIQUE,
+    password_hash CHAR(64) NOT NULL,
+    name          VARCHAR(100),
+    last_auth_at  TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ,
+    created_by    VARCHAR(100),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_vehicle_id ON devices (vehicle_id);
+
+COMMENT ON TABLE devices IS 'MQTT credentials of trackers; a device may only publish for its vehicle';
+COMMENT ON COLUMN devices.username IS 'MQTT username, generated';
+COMMENT ON COLUMN devices.password_hash IS 'Hex SHA-256 of the generated MQTT password; the password itself is never stored';
@@ -0,0 +1,3 @@
+ALTER TABLE api_keys
+    DROP CONSTRAINT IF EXISTS chk_api_keys_rate_limit,
+    DROP COLUMN IF EXISTS rate_limit_per_min;
@@ -0,0 +1,5 @@
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS rate_limit_per_min INT,
+    ADD CONSTRAINT chk_api_keys_rate_limit CHECK (rate_limit_per_min >= 0);
+
+COMMENT ON COLUMN api_keys.rate_limit_per_min IS 'Requests per minute; NULL = RATE_LIMIT_KEY_PER_MIN, 0 = unlimited';
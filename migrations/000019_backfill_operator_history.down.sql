@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS trg_vehicles_backfill_operator ON vehicles;
+DROP FUNCTION IF EXISTS backfill_vehicle_operator();
+
+-- the backfilled operator_id values are kept, they can't be told apart from ingested ones
@@ -0,0 +1,33 @@
+-- history stored before its vehicle had an operator; tenant filters match on operator_id
+UPDATE vehicle_locations l SET operator_id = v.operator_id
+FROM vehicles v WHERE l.vehicle_id = v.vehicle_id AND v.operator_id IS NOT NULL AND l.operator_id IS NULL;
+
+UPDATE vehicle_trips t SET operator_id = v.operator_id
+FROM vehicles v WHERE t.vehicle_id = v.vehicle_id AND v.operator_id IS NOT NULL AND t.operator_id IS NULL;
+
+UPDATE geofence_events e SET operator_id = v.operator_id
+FROM vehicles v WHERE e.vehicle_id = v.vehicle_id AND v.operator_id IS NOT NULL AND e.operator_id IS NULL;
+
+UPDATE station_visits s SET operator_id = v.operator_id
+FROM vehicles v WHERE s.vehicle_id = v.vehicle_id AND v.operator_id IS NOT NULL AND s.operator_id IS NULL;
+
+-- vehicles assigned later (claimed on a tenant topic, registered with a device, set by hand)
+-- take their earlier history along
+CREATE OR REPLACE FUNCTION backfill_vehicle_operator() RETURNS trigger AS $$
+BEGIN
+    IF NEW.operator_id IS NULL OR (TG_OP = 'UPDATE' AND OLD.operator_id IS NOT NULL) THEN
+        RETURN NULL;
+    END IF;
+
+    UPDATE vehicle_locations SET operator_id = NEW.operator_id WHERE vehicle_id = NEW.vehicle_id AND operator_id IS NULL;
+    UPDATE vehicle_trips SET operator_id = NEW.operator_id WHERE vehicle_id = NEW.vehicle_id AND operator_id IS NULL;
+    UPDATE geofence_events SET operator_id = NEW.operator_id WHERE vehicle_id = NEW.vehicle_id AND operator_id IS NULL;
+    UPDATE station_visits SET operator_id = NEW.operator_id WHERE vehicle_id = NEW.vehicle_id AND operator_id IS NULL;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_vehicles_backfill_operator
+    AFTER INSERT OR UPDATE OF operator_id ON vehicles
+    FOR EACH ROW EXECUTE FUNCTION backfill_vehicle_operator();
@@ -11,9 +11,9 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
-
-func Connect() error {
+// Connect opens the Postgres pool. Services pass the handle down from main, there is no
+// package-level connection.
+func Connect() (*gorm.DB, error) {
 	gormLogger := logger.New(
 		log.New(os.Stdout, "GORM ", log.LstdFlags),
 		logger.Config{
@@ -26,7 +26,7 @@ func Connect() error {
 		Logger: gormLogger,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sqlDB, _ := db.DB()
@@ -35,9 +35,17 @@ func Connect() error {
 	sqlDB.SetMaxOpenConns(20)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	DB = db
-
 	log.Println("PostgreSQL connected")
 
-	return nil
+	return db, nil
+}
+
+// Close closes the pool once the service stopped using it.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
 }
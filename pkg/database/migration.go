@@ -9,9 +9,10 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"gorm.io/gorm"
 )
 
-func RunMigrations(migrationsPath string) error {
+func RunMigrations(db *gorm.DB, migrationsPath string) error {
 	absPath, err := filepath.Abs(migrationsPath)
 	if err != nil {
 		return fmt.Errorf("could not resolve absolute path: %w", err)
@@ -22,7 +23,7 @@ func RunMigrations(migrationsPath string) error {
 
 	log.Printf("Migrations path: %s", absPath)
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("could not get sql.DB from gorm: %w", err)
 	}
@@ -49,13 +50,13 @@ func RunMigrations(migrationsPath string) error {
 	return nil
 }
 
-func RollbackMigration(migrationsPath string, steps int) error {
+func RollbackMigration(db *gorm.DB, migrationsPath string, steps int) error {
 	absPath, err := filepath.Abs(migrationsPath)
 	if err != nil {
 		return fmt.Errorf("could not resolve absolute path: %w", err)
 	}
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("could not get sql.DB from gorm: %w", err)
 	}
@@ -78,40 +79,39 @@ func RollbackMigration(migrationsPath string, steps int) error {
 		return fmt.Errorf("could not rollback migrations: %w", err)
 	}
 
-	log.Println("Rolled back %d migration(s)", steps)
+	log.Printf("Rolled back %d migration(s)", steps)
 	return nil
 }
 
-func ForceMigrationVersion(migrationsPath string, version uint) error {
-    absPath, err := filepath.Abs(migrationsPath)
-    if err != nil {
-        return fmt.Errorf("could not resolve absolute path: %w", err)
-    }
-
-    sqlDB, err := DB.DB()
-    if err != nil {
-        return fmt.Errorf("could not get sql.DB from gorm: %w", err)
-    }
-
-    driver, err := postgres.WithInstance(sqlDB, &postgres.Config{})
-    if err != nil {
-        return fmt.Errorf("could not create postgres driver: %w", err)
-    }
-
-    sourceURL := fmt.Sprintf("file://%s", filepath.ToSlash(absPath))
-    m, err := migrate.NewWithDatabaseInstance(
-        sourceURL,
-        "postgres",
-        driver,
-    )
-    if err != nil {
-        return fmt.Errorf("could not create migrate instance: %w", err)
-    }
-    if err := m.Force(int(version)); err != nil {
-        return fmt.Errorf("could not force migration version: %w", err)
-    }
-
-    log.Printf("Forced migration version to %d (dirty reset)", version)
-    return nil
-}
+func ForceMigrationVersion(db *gorm.DB, migrationsPath string, version uint) error {
+	absPath, err := filepath.Abs(migrationsPath)
+	if err != nil {
+		return fmt.Errorf("could not resolve absolute path: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("could not get sql.DB from gorm: %w", err)
+	}
+
+	driver, err := postgres.WithInstance(sqlDB, &postgres.Config{})
+	if err != nil {
+		return fmt.Errorf("could not create postgres driver: %w", err)
+	}
 
+	sourceURL := fmt.Sprintf("file://%s", filepath.ToSlash(absPath))
+	m, err := migrate.NewWithDatabaseInstance(
+		sourceURL,
+		"postgres",
+		driver,
+	)
+	if err != nil {
+		return fmt.Errorf("could not create migrate instance: %w", err)
+	}
+	if err := m.Force(int(version)); err != nil {
+		return fmt.Errorf("could not force migration version: %w", err)
+	}
+
+	log.Printf("Forced migration version to %d (dirty reset)", version)
+	return nil
+}
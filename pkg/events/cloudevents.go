@@ -0,0 +1,177 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	// content type of a structured-mode CloudEvent
+	CloudEventsContentType = "application/cloudevents+json"
+
+	cloudEventsTypePrefix   = "fleet."
+	cloudEventsSourcePrefix = "/fleet/"
+)
+
+// CloudEvent is the CloudEvents 1.0 form of an Envelope: type becomes "fleet.<type>", the
+// schema version is carried by dataschema and the correlation id by an extension attribute.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	CorrelationId   string          `json:"correlationid,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// SchemaURI identifies the schema of one event type version; it is also the schema $id.
+func SchemaURI(eventType string, version int) string {
+	return fmt.Sprintf("urn:fleet-events:%s:v%d", eventType, version)
+}
+
+func parseSchemaURI(uri string) (string, int, error) {
+	rest, ok := strings.CutPrefix(uri, "urn:fleet-events:")
+	if !ok {
+		return "", 0, fmt.Errorf("unknown dataschema %q", uri)
+	}
+	i := strings.LastIndex(rest, ":v")
+	if i < 0 {
+		return "", 0, fmt.Errorf("unknown dataschema %q", uri)
+	}
+	version, err := strconv.Atoi(rest[i+2:])
+	if err != nil {
+		return "", 0, fmt.Errorf("unknown dataschema %q", uri)
+	}
+
+	return rest[:i], version, nil
+}
+
+// ToCloudEvent converts an envelope. The subject is the vehicle id when the payload has one.
+func ToCloudEvent(env *Envelope) *CloudEvent {
+	var subject struct {
+		VehicleId string `json:"vehicle_id"`
+	}
+	_ = json.Unmarshal(env.Data, &subject)
+
+	ce := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Id:              env.Id,
+		Source:          cloudEventsSourcePrefix + env.Source,
+		Type:            cloudEventsTypePrefix + env.Type,
+		Subject:         subject.VehicleId,
+		Time:            env.ProducedAt,
+		DataContentType: "application/json",
+		CorrelationId:   env.CorrelationId,
+		Data:            env.Data,
+	}
+	if env.Version > 0 {
+		ce.DataSchema = SchemaURI(env.Type, env.Version)
+	}
+
+	return ce
+}
+
+// FromCloudEvent converts back to an envelope.
+func FromCloudEvent(ce *CloudEvent) (*Envelope, error) {
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("unsupported specversion %q", ce.SpecVersion)
+	}
+	eventType, ok := strings.CutPrefix(ce.Type, cloudEventsTypePrefix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected cloudevent type %q", ce.Type)
+	}
+	if len(ce.Data) == 0 {
+		return nil, errors.New("cloudevent has no data")
+	}
+
+	env := &Envelope{
+		Id:            ce.Id,
+		Type:          eventType,
+		ProducedAt:    ce.Time,
+		Source:        strings.TrimPrefix(ce.Source, cloudEventsSourcePrefix),
+		CorrelationId: ce.CorrelationId,
+		Data:          ce.Data,
+	}
+	if ce.DataSchema != "" {
+		schemaType, version, err := parseSchemaURI(ce.DataSchema)
+		if err != nil {
+			return nil, err
+		}
+		if schemaType != eventType {
+			return nil, fmt.Errorf("dataschema %q does not match type %q", ce.DataSchema, ce.Type)
+		}
+		env.Version = version
+	}
+
+	return env, nil
+}
+
+// Attributes returns the context attributes for binary content mode, where they travel as
+// transport headers (prefixed by the binding) and the body is only the data.
+// datacontenttype is left out: bindings map it to their own content-type.
+func (ce *CloudEvent) Attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": ce.SpecVersion,
+		"id":          ce.Id,
+		"source":      ce.Source,
+		"type":        ce.Type,
+		"time":        ce.Time.Format(time.RFC3339Nano),
+	}
+	if ce.Subject != "" {
+		attrs["subject"] = ce.Subject
+	}
+	if ce.DataSchema != "" {
+		attrs["dataschema"] = ce.DataSchema
+	}
+	if ce.CorrelationId != "" {
+		attrs["correlationid"] = ce.CorrelationId
+	}
+
+	return attrs
+}
+
+// CloudEventFromAttributes rebuilds a binary-mode event from its headers and body.
+func CloudEventFromAttributes(attrs map[string]string, contentType string, data []byte) (*CloudEvent, error) {
+	ce := &CloudEvent{
+		SpecVersion:     attrs["specversion"],
+		Id:              attrs["id"],
+		Source:          attrs["source"],
+		Type:            attrs["type"],
+		Subject:         attrs["subject"],
+		DataContentType: contentType,
+		DataSchema:      attrs["dataschema"],
+		CorrelationId:   attrs["correlationid"],
+		Data:            data,
+	}
+	if ce.Id == "" || ce.Source == "" || ce.Type == "" {
+		return nil, errors.New("cloudevent is missing id, source or type")
+	}
+	if t := attrs["time"]; t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cloudevent time %q", t)
+		}
+		ce.Time = parsed
+	}
+
+	return ce, nil
+}
+
+// DecodeStructured reads a structured-mode CloudEvent body into an envelope.
+func DecodeStructured(body []byte) (*Envelope, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(body, &ce); err != nil {
+		return nil, err
+	}
+
+	return FromCloudEvent(&ce)
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Services that produce events, used as Envelope.Source.
+const (
+	SourceSubscriber = "subscriber"
+	SourceWorker     = "worker"
+)
+
+// Envelope wraps every message on the fleet.events exchange.
+type Envelope struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	// when the producing service built the event, not when the underlying point was recorded
+	ProducedAt time.Time `json:"produced_at"`
+	Source     string    `json:"source"`
+	// id of the location.raw event a derived event was computed from
+	CorrelationId string          `json:"correlation_id,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// New wraps e in an envelope with a fresh id and the current schema version of its type.
+func New(source string, e Event, correlationId string) (*Envelope, error) {
+	version, ok := versions[e.EventType()]
+	if !ok {
+		return nil, fmt.Errorf("unregistered event type %q", e.EventType())
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Id:            uuid.NewString(),
+		Type:          e.EventType(),
+		Version:       version,
+		ProducedAt:    time.Now().UTC(),
+		Source:        source,
+		CorrelationId: correlationId,
+		Data:          data,
+	}, nil
+}
+
+// Decode reads an enveloped message or a structured-mode CloudEvent. Bare payloads from
+// producers that predate the envelope are wrapped as version 0 with the type taken from the
+// routing key.
+func Decode(routingKey string, body []byte) (*Envelope, error) {
+	var env struct {
+		Envelope
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, err
+	}
+	if env.SpecVersion != "" {
+		return DecodeStructured(body)
+	}
+	if env.Type != "" && len(env.Data) > 0 {
+		return &env.Envelope, nil
+	}
+
+	if !json.Valid(body) || len(body) == 0 || body[0] != '{' {
+		return nil, errors.New("payload is neither an envelope nor a JSON object")
+	}
+
+	return &Envelope{Type: TypeForRoutingKey(routingKey), Data: json.RawMessage(body)}, nil
+}
+
+// DecodeData unmarshals the payload into v.
+func (env *Envelope) DecodeData(v interface{}) error {
+	return json.Unmarshal(env.Data, v)
+}
+
+// Correlation is the correlation id for events derived from this one: the root event's id,
+// carried along the chain.
+func (env *Envelope) Correlation() string {
+	if env.CorrelationId != "" {
+		return env.CorrelationId
+	}
+
+	return env.Id
+}
+
+// TypeForRoutingKey maps a routing key back to its event type (alert.<key> -> alert).
+func TypeForRoutingKey(routingKey string) string {
+	if strings.HasPrefix(routingKey, TypeAlert+".") {
+		return TypeAlert
+	}
+
+	return routingKey
+}
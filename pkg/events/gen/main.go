@@ -0,0 +1,40 @@
+// Command gen writes the JSON Schema of every event type to -out, one
+// <type>.v<version>.json file each. Run it through go generate in pkg/events.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"tj/pkg/events"
+)
+
+func main() {
+	out := flag.String("out", "schemas/events", "output directory")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatalf("create %s: %v", *out, err)
+	}
+
+	for _, t := range events.Types() {
+		s, err := events.Schema(t)
+		if err != nil {
+			log.Fatalf("schema %s: %v", t, err)
+		}
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			log.Fatalf("marshal %s: %v", t, err)
+		}
+
+		path := filepath.Join(*out, fmt.Sprintf("%s.v%d.json", t, events.Version(t)))
+		if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+		log.Printf("wrote %s", path)
+	}
+}
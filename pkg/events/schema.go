@@ -0,0 +1,59 @@
+package events
+
+//go:generate go run ./gen -out ../../schemas/events
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/invopop/jsonschema"
+)
+
+// samples maps each type to a zero value used for schema reflection.
+var samples = map[string]Event{
+	TypeLocationRaw:     &LocationRaw{},
+	TypeGeofenceEntry:   &GeofenceEntry{},
+	TypeGeofenceExit:    &GeofenceExit{},
+	TypeVehicleStopped:  &VehicleStopped{},
+	TypeVehicleMoving:   &VehicleMoving{},
+	TypeVehicleSpeeding: &VehicleSpeeding{},
+	TypeVehicleOffline:  &VehicleOffline{},
+	TypeVehicleOnline:   &VehicleOnline{},
+	TypeAlert:           &Alert{},
+}
+
+// Types lists every event type in a stable order.
+func Types() []string {
+	types := make([]string, 0, len(samples))
+	for t := range samples {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// Schema returns the JSON Schema of a full envelope carrying eventType, with type and
+// version pinned.
+func Schema(eventType string) (*jsonschema.Schema, error) {
+	sample, ok := samples[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	version := versions[eventType]
+
+	r := &jsonschema.Reflector{ExpandedStruct: true, DoNotReference: true}
+
+	data := r.Reflect(sample)
+	data.Version = ""
+
+	s := r.Reflect(&Envelope{})
+	s.ID = jsonschema.ID(SchemaURI(eventType, version))
+	s.Title = eventType
+	s.Description = fmt.Sprintf("%s event, schema version %d", eventType, version)
+	s.Properties.Set("type", &jsonschema.Schema{Type: "string", Const: eventType})
+	s.Properties.Set("version", &jsonschema.Schema{Type: "integer", Const: version})
+	s.Properties.Set("data", data)
+
+	return s, nil
+}
@@ -0,0 +1,175 @@
+package events
+
+// Event types. Apart from alerts the type is also the routing key.
+const (
+	TypeLocationRaw     = "location.raw"
+	TypeGeofenceEntry   = "geofence.entry"
+	TypeGeofenceExit    = "geofence.exit"
+	TypeVehicleStopped  = "vehicle.stopped"
+	TypeVehicleMoving   = "vehicle.moving"
+	TypeVehicleSpeeding = "vehicle.speeding"
+	TypeVehicleOffline  = "vehicle.offline"
+	TypeVehicleOnline   = "vehicle.online"
+	// published as alert.<rule key>
+	TypeAlert = "alert"
+)
+
+// versions is the current schema version of each type. Bump it on any change a consumer
+// could notice, and document the change in the README. go generate only writes the current
+// version, the schema files of earlier ones stay as published.
+var versions = map[string]int{
+	// v2: operator_id
+	TypeLocationRaw:     2,
+	TypeGeofenceEntry:   1,
+	TypeGeofenceExit:    1,
+	TypeVehicleStopped:  1,
+	TypeVehicleMoving:   1,
+	TypeVehicleSpeeding: 1,
+	TypeVehicleOffline:  1,
+	TypeVehicleOnline:   1,
+	TypeAlert:           1,
+}
+
+type Event interface {
+	EventType() string
+}
+
+// RoutingKey returns the key e is published under on fleet.events.
+func RoutingKey(e Event) string {
+	if a, ok := e.(*Alert); ok {
+		return TypeAlert + "." + a.Rule.Key
+	}
+
+	return e.EventType()
+}
+
+// Version returns the current schema version of an event type, 0 if unknown.
+func Version(eventType string) int {
+	return versions[eventType]
+}
+
+type Position struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type StationRef struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// LocationRaw is every GPS point stored by the subscriber.
+type LocationRaw struct {
+	VehicleId string   `json:"vehicle_id"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Timestamp int64    `json:"timestamp"`
+	Ignition  *bool    `json:"ignition,omitempty"`
+	Speed     *float64 `json:"speed,omitempty" jsonschema:"description=km/h as reported by the device"`
+	// operator (tenant) the vehicle reported under, absent for vehicles without one; since v2
+	OperatorId string `json:"operator_id,omitempty"`
+}
+
+func (*LocationRaw) EventType() string { return TypeLocationRaw }
+
+type GeofenceEntry struct {
+	VehicleId string     `json:"vehicle_id"`
+	EventId   int64      `json:"event_id" jsonschema:"description=geofence_events.id"`
+	Location  Position   `json:"location"`
+	Timestamp int64      `json:"timestamp"`
+	Station   StationRef `json:"station"`
+	DistanceM float64    `json:"distance_m"`
+}
+
+func (*GeofenceEntry) EventType() string { return TypeGeofenceEntry }
+
+type GeofenceExit struct {
+	VehicleId     string     `json:"vehicle_id"`
+	EventId       int64      `json:"event_id" jsonschema:"description=geofence_events.id"`
+	Location      Position   `json:"location"`
+	Timestamp     int64      `json:"timestamp"`
+	Station       StationRef `json:"station"`
+	ArrivalTime   int64      `json:"arrival_time"`
+	DepartureTime int64      `json:"departure_time"`
+	DwellS        int64      `json:"dwell_s"`
+}
+
+func (*GeofenceExit) EventType() string { return TypeGeofenceExit }
+
+// StopTransition is the payload shared by vehicle.stopped and vehicle.moving.
+type StopTransition struct {
+	VehicleId      string      `json:"vehicle_id"`
+	Classification string      `json:"classification" jsonschema:"enum=at_station,enum=unscheduled"`
+	Station        *StationRef `json:"station" jsonschema:"nullable"`
+	Location       Position    `json:"location"`
+	StoppedSince   int64       `json:"stopped_since"`
+	DurationS      int64       `json:"duration_s"`
+	EngineOnS      int64       `json:"engine_on_s"`
+	Ignition       *bool       `json:"ignition" jsonschema:"nullable"`
+	// engine running while stopped away from a station
+	Idling    bool  `json:"idling"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+type VehicleStopped struct {
+	StopTransition
+}
+
+func (*VehicleStopped) EventType() string { return TypeVehicleStopped }
+
+type VehicleMoving struct {
+	StopTransition
+}
+
+func (*VehicleMoving) EventType() string { return TypeVehicleMoving }
+
+type VehicleSpeeding struct {
+	VehicleId string   `json:"vehicle_id"`
+	Start     int64    `json:"start"`
+	End       int64    `json:"end"`
+	DurationS int64    `json:"duration_s"`
+	PeakKmh   float64  `json:"peak_kmh"`
+	LimitKmh  float64  `json:"limit_kmh"`
+	Zone      string   `json:"zone" jsonschema:"description=speed_zones.name or global"`
+	Location  Position `json:"location"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+func (*VehicleSpeeding) EventType() string { return TypeVehicleSpeeding }
+
+// Connectivity is the payload shared by vehicle.offline and vehicle.online.
+type Connectivity struct {
+	VehicleId string `json:"vehicle_id"`
+	LastSeen  int64  `json:"last_seen"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type VehicleOffline struct {
+	Connectivity
+}
+
+func (*VehicleOffline) EventType() string { return TypeVehicleOffline }
+
+type VehicleOnline struct {
+	Connectivity
+}
+
+func (*VehicleOnline) EventType() string { return TypeVehicleOnline }
+
+type AlertRuleRef struct {
+	Id       int64  `json:"id"`
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Severity string `json:"severity" jsonschema:"enum=info,enum=warning,enum=critical"`
+}
+
+type Alert struct {
+	VehicleId string       `json:"vehicle_id"`
+	Rule      AlertRuleRef `json:"rule"`
+	Location  Position     `json:"location"`
+	SpeedKmh  *float64     `json:"speed_kmh" jsonschema:"nullable"`
+	Since     int64        `json:"since"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+func (*Alert) EventType() string { return TypeAlert }
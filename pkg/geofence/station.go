@@ -0,0 +1,22 @@
+package geofence
+
+import (
+	model "tj/pkg/model"
+)
+
+// NearestStation returns the closest station within maxDist meters, or nil if none is that close.
+func NearestStation(stations []model.BusStation, lat, lon, maxDist float64) (*model.BusStation, float64) {
+	var (
+		nearest *model.BusStation
+		best    = maxDist
+	)
+
+	for i := range stations {
+		d := HaversineMeters(lat, lon, stations[i].Latitude, stations[i].Longitude)
+		if d <= best {
+			nearest, best = &stations[i], d
+		}
+	}
+
+	return nearest, best
+}
@@ -0,0 +1,219 @@
+package geofence
+
+import (
+	model "tj/pkg/model"
+)
+
+type VisitConfig struct {
+	// a point this close to a station starts a visit
+	EnterRadiusM float64
+	// the visit ends at the first point farther than this; the margin keeps GPS jitter
+	// around the edge from producing entry/exit pairs
+	ExitRadiusM float64
+	// no points for longer than this closes open visits at the last point seen inside
+	MaxGapSec int64
+}
+
+func DefaultVisitConfig() VisitConfig {
+	return VisitConfig{
+		EnterRadiusM: 50,
+		ExitRadiusM:  60,
+		MaxGapSec:    15 * 60,
+	}
+}
+
+// Visit is a vehicle's stay inside one station's radius.
+type Visit struct {
+	VehicleId    string
+	StationId    int64
+	StationName  string
+	Arrival      int64
+	LastInside   int64
+	MinDistanceM float64
+	Points       int
+}
+
+type VisitEvent struct {
+	Type  string // model.GeofenceEntry or model.GeofenceExit
+	Visit Visit
+	// the point that caused the event; for exits after a gap, the last point inside
+	Timestamp int64
+	Latitude  float64
+	Longitude float64
+	DistanceM float64
+}
+
+type visitPoint struct {
+	timestamp int64
+	latitude  float64
+	longitude float64
+	distance  float64
+}
+
+type vehicleVisits struct {
+	last int64
+	open map[int64]*Visit
+	// last point inside each open visit, for exits after a gap
+	lastInside map[int64]visitPoint
+}
+
+// VisitTracker turns a location stream into station entry/exit events. Points must arrive
+// in timestamp order per vehicle; older ones are ignored. Not safe for concurrent use.
+type VisitTracker struct {
+	cfg      VisitConfig
+	vehicles map[string]*vehicleVisits
+}
+
+func NewVisitTracker(cfg VisitConfig) *VisitTracker {
+	return &VisitTracker{cfg: cfg, vehicles: make(map[string]*vehicleVisits)}
+}
+
+func (t *VisitTracker) state(vehicleId string) *vehicleVisits {
+	st, ok := t.vehicles[vehicleId]
+	if !ok {
+		st = &vehicleVisits{open: make(map[int64]*Visit), lastInside: make(map[int64]visitPoint)}
+		t.vehicles[vehicleId] = st
+	}
+
+	return st
+}
+
+// Restore re-opens a visit that was still open when the process stopped, so a restart
+// doesn't report a second entry for a vehicle already at the station. The downtime isn't
+// treated as a reporting gap: the next point decides whether the vehicle is still there.
+func (t *VisitTracker) Restore(v Visit) {
+	st := t.state(v.VehicleId)
+	st.open[v.StationId] = &v
+}
+
+// Checkpoint is a copy of one vehicle's visits, taken before a point is added.
+type Checkpoint struct {
+	vehicleId string
+	state     *vehicleVisits
+}
+
+// Checkpoint saves the vehicle's state, so Rollback can undo the next Add when its events
+// couldn't be stored and the point will be handled again.
+func (t *VisitTracker) Checkpoint(vehicleId string) Checkpoint {
+	st, ok := t.vehicles[vehicleId]
+	if !ok {
+		return Checkpoint{vehicleId: vehicleId}
+	}
+
+	cp := &vehicleVisits{
+		last:       st.last,
+		open:       make(map[int64]*Visit, len(st.open)),
+		lastInside: make(map[int64]visitPoint, len(st.lastInside)),
+	}
+	for id, v := range st.open {
+		v := *v
+		cp.open[id] = &v
+	}
+	for id, p := range st.lastInside {
+		cp.lastInside[id] = p
+	}
+
+	return Checkpoint{vehicleId: vehicleId, state: cp}
+}
+
+// Rollback puts back the state saved by Checkpoint.
+func (t *VisitTracker) Rollback(c Checkpoint) {
+	if c.state == nil {
+		delete(t.vehicles, c.vehicleId)
+		return
+	}
+	t.vehicles[c.vehicleId] = c.state
+}
+
+// Add feeds one point and returns the entries and exits it caused.
+func (t *VisitTracker) Add(vehicleId string, stations []model.BusStation, lat, lon float64, ts int64) []VisitEvent {
+	st := t.state(vehicleId)
+	if st.last != 0 && ts <= st.last {
+		return nil
+	}
+
+	byId := make(map[int64]*model.BusStation, len(stations))
+	for i := range stations {
+		byId[stations[i].Id] = &stations[i]
+	}
+
+	var events []VisitEvent
+
+	if st.last != 0 && ts-st.last > t.cfg.MaxGapSec {
+		for id, v := range st.open {
+			events = append(events, t.exit(st, v, st.lastInside[id]))
+		}
+	}
+	st.last = ts
+
+	for id, v := range st.open {
+		station, ok := byId[id]
+		if !ok {
+			// station was deleted while the vehicle was inside
+			events = append(events, t.exit(st, v, visitPoint{timestamp: ts, latitude: lat, longitude: lon}))
+			continue
+		}
+
+		d := HaversineMeters(lat, lon, station.Latitude, station.Longitude)
+		p := visitPoint{timestamp: ts, latitude: lat, longitude: lon, distance: d}
+		if d > t.cfg.ExitRadiusM {
+			events = append(events, t.exit(st, v, p))
+			continue
+		}
+
+		v.LastInside = ts
+		v.Points++
+		if d < v.MinDistanceM {
+			v.MinDistanceM = d
+		}
+		st.lastInside[id] = p
+	}
+
+	for i := range stations {
+		station := &stations[i]
+		if _, ok := st.open[station.Id]; ok {
+			continue
+		}
+
+		d := HaversineMeters(lat, lon, station.Latitude, station.Longitude)
+		if d > t.cfg.EnterRadiusM {
+			continue
+		}
+
+		v := &Visit{
+			VehicleId:    vehicleId,
+			StationId:    station.Id,
+			StationName:  station.Name,
+			Arrival:      ts,
+			LastInside:   ts,
+			MinDistanceM: d,
+			Points:       1,
+		}
+		st.open[station.Id] = v
+		st.lastInside[station.Id] = visitPoint{timestamp: ts, latitude: lat, longitude: lon, distance: d}
+		events = append(events, VisitEvent{
+			Type:      model.GeofenceEntry,
+			Visit:     *v,
+			Timestamp: ts,
+			Latitude:  lat,
+			Longitude: lon,
+			DistanceM: d,
+		})
+	}
+
+	return events
+}
+
+func (t *VisitTracker) exit(st *vehicleVisits, v *Visit, p visitPoint) VisitEvent {
+	delete(st.open, v.StationId)
+	delete(st.lastInside, v.StationId)
+
+	return VisitEvent{
+		Type:      model.GeofenceExit,
+		Visit:     *v,
+		Timestamp: p.timestamp,
+		Latitude:  p.latitude,
+		Longitude: p.longitude,
+		DistanceM: p.distance,
+	}
+}
@@ -0,0 +1,157 @@
+package gtfs
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// GTFS-Realtime is encoded by hand with protowire so the services don't need generated
+// bindings for a handful of messages. Field numbers follow gtfs-realtime.proto 2.0.
+
+const RealtimeVersion = "2.0"
+
+const (
+	feedMessageHeader = 1
+	feedMessageEntity = 2
+
+	feedHeaderVersion        = 1
+	feedHeaderIncrementality = 2
+	feedHeaderTimestamp      = 3
+
+	feedEntityId      = 1
+	feedEntityVehicle = 4
+
+	vehiclePositionTrip      = 1
+	vehiclePositionPosition  = 2
+	vehiclePositionTimestamp = 5
+	vehiclePositionStopId    = 7
+	vehiclePositionVehicle   = 8
+
+	tripDescriptorTripId  = 1
+	tripDescriptorRouteId = 5
+
+	positionLatitude  = 1
+	positionLongitude = 2
+	positionBearing   = 3
+	positionSpeed     = 5
+
+	vehicleDescriptorId    = 1
+	vehicleDescriptorLabel = 2
+)
+
+// FeedHeader.Incrementality
+const (
+	FullDataset  = 0
+	Differential = 1
+)
+
+type FeedHeader struct {
+	Version        string `json:"gtfs_realtime_version"`
+	Incrementality int    `json:"incrementality"`
+	Timestamp      uint64 `json:"timestamp"`
+}
+
+type VehiclePosition struct {
+	EntityId  string   `json:"id"`
+	VehicleId string   `json:"vehicle_id"`
+	Label     string   `json:"label,omitempty"`
+	TripId    string   `json:"trip_id,omitempty"`
+	RouteId   string   `json:"route_id,omitempty"`
+	StopId    string   `json:"stop_id,omitempty"`
+	Latitude  float32  `json:"latitude"`
+	Longitude float32  `json:"longitude"`
+	Bearing   *float32 `json:"bearing,omitempty"`
+	Speed     *float32 `json:"speed,omitempty"` // meters per second, per the spec
+	Timestamp uint64   `json:"timestamp"`
+}
+
+type VehiclePositionsFeed struct {
+	Header   FeedHeader        `json:"header"`
+	Entities []VehiclePosition `json:"entity"`
+}
+
+// Marshal encodes the feed as a gtfs-realtime FeedMessage.
+func (f *VehiclePositionsFeed) Marshal() []byte {
+	var b []byte
+
+	b = appendMessage(b, feedMessageHeader, marshalHeader(f.Header))
+	for i := range f.Entities {
+		b = appendMessage(b, feedMessageEntity, marshalVehicleEntity(&f.Entities[i]))
+	}
+
+	return b
+}
+
+func marshalHeader(h FeedHeader) []byte {
+	var b []byte
+
+	version := h.Version
+	if version == "" {
+		version = RealtimeVersion
+	}
+	b = appendString(b, feedHeaderVersion, version)
+	b = appendVarint(b, feedHeaderIncrementality, uint64(h.Incrementality))
+	b = appendVarint(b, feedHeaderTimestamp, h.Timestamp)
+
+	return b
+}
+
+func marshalVehicleEntity(v *VehiclePosition) []byte {
+	var vp []byte
+
+	if v.TripId != "" || v.RouteId != "" {
+		var trip []byte
+		trip = appendString(trip, tripDescriptorTripId, v.TripId)
+		trip = appendString(trip, tripDescriptorRouteId, v.RouteId)
+		vp = appendMessage(vp, vehiclePositionTrip, trip)
+	}
+
+	var pos []byte
+	pos = appendFloat(pos, positionLatitude, v.Latitude)
+	pos = appendFloat(pos, positionLongitude, v.Longitude)
+	if v.Bearing != nil {
+		pos = appendFloat(pos, positionBearing, *v.Bearing)
+	}
+	if v.Speed != nil {
+		pos = appendFloat(pos, positionSpeed, *v.Speed)
+	}
+	vp = appendMessage(vp, vehiclePositionPosition, pos)
+
+	vp = appendVarint(vp, vehiclePositionTimestamp, v.Timestamp)
+	vp = appendString(vp, vehiclePositionStopId, v.StopId)
+
+	var vd []byte
+	vd = appendString(vd, vehicleDescriptorId, v.VehicleId)
+	vd = appendString(vd, vehicleDescriptorLabel, v.Label)
+	vp = appendMessage(vp, vehiclePositionVehicle, vd)
+
+	var e []byte
+	e = appendString(e, feedEntityId, v.EntityId)
+	e = appendMessage(e, feedEntityVehicle, vp)
+
+	return e
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFloat(b []byte, num protowire.Number, v float32) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+	return protowire.AppendFixed32(b, math.Float32bits(v))
+}
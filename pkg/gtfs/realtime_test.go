@@ -0,0 +1,63 @@
+package gtfs
+
+import (
+	"testing"
+
+	gtfsrt "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+)
+
+// The encoder is hand-written, so its output is checked against the official bindings.
+func TestVehiclePositionsDecodeWithTheBindings(t *testing.T) {
+	bearing, speed := float32(90), float32(12.5)
+	feed := VehiclePositionsFeed{
+		Header: FeedHeader{Incrementality: FullDataset, Timestamp: 1700000100},
+		Entities: []VehiclePosition{
+			{
+				EntityId: "bus-1", VehicleId: "bus-1", Label: "B 1234 XYZ",
+				TripId: "trip-7", RouteId: "1", StopId: "st-12",
+				Latitude: -6.2, Longitude: 106.8, Bearing: &bearing, Speed: &speed,
+				Timestamp: 1700000000,
+			},
+			// only the required fields
+			{EntityId: "bus-2", VehicleId: "bus-2", Latitude: -6.1, Longitude: 106.7, Timestamp: 1700000050},
+		},
+	}
+
+	var msg gtfsrt.FeedMessage
+	if err := proto.Unmarshal(feed.Marshal(), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	h := msg.GetHeader()
+	if h.GetGtfsRealtimeVersion() != RealtimeVersion || h.GetIncrementality() != gtfsrt.FeedHeader_FULL_DATASET || h.GetTimestamp() != 1700000100 {
+		t.Errorf("header %v", h)
+	}
+	if len(msg.GetEntity()) != 2 {
+		t.Fatalf("%d entities, want 2", len(msg.GetEntity()))
+	}
+
+	e := msg.GetEntity()[0]
+	vp := e.GetVehicle()
+	if e.GetId() != "bus-1" || vp.GetVehicle().GetId() != "bus-1" || vp.GetVehicle().GetLabel() != "B 1234 XYZ" {
+		t.Errorf("entity %v", e)
+	}
+	if vp.GetTrip().GetTripId() != "trip-7" || vp.GetTrip().GetRouteId() != "1" || vp.GetStopId() != "st-12" {
+		t.Errorf("trip %v stop %q", vp.GetTrip(), vp.GetStopId())
+	}
+	p := vp.GetPosition()
+	if p.GetLatitude() != -6.2 || p.GetLongitude() != 106.8 || p.GetBearing() != 90 || p.GetSpeed() != 12.5 {
+		t.Errorf("position %v", p)
+	}
+	if vp.GetTimestamp() != 1700000000 {
+		t.Errorf("timestamp %d", vp.GetTimestamp())
+	}
+
+	vp = msg.GetEntity()[1].GetVehicle()
+	if vp.Trip != nil || vp.StopId != nil || vp.GetPosition().Bearing != nil || vp.GetPosition().Speed != nil {
+		t.Errorf("unset fields encoded: %v", vp)
+	}
+	if vp.GetVehicle().GetId() != "bus-2" || vp.GetPosition().GetLatitude() != -6.1 {
+		t.Errorf("entity %v", vp)
+	}
+}
@@ -0,0 +1,415 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	model "tj/pkg/model"
+)
+
+const batchSize = 500
+
+type ImportStats struct {
+	Stops     int
+	Routes    int
+	Trips     int
+	Shapes    int
+	StopTimes int
+}
+
+// OpenFeed opens a GTFS static feed either from an extracted directory or a .zip archive.
+func OpenFeed(path string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(path), nopCloser{}, nil
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open gtfs zip: %w", err)
+	}
+
+	return zr, zr, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Import loads stops, routes, trips, shapes and stop_times into Postgres in a single transaction.
+// Stops are upserted into bus_stations keyed on gtfs_stop_id so existing geofences keep their ids.
+func Import(db *gorm.DB, feed fs.FS) (*ImportStats, error) {
+	stats := &ImportStats{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		stationIds, err := importStops(tx, feed, stats)
+		if err != nil {
+			return fmt.Errorf("stops.txt: %w", err)
+		}
+		if err := importRoutes(tx, feed, stats); err != nil {
+			return fmt.Errorf("routes.txt: %w", err)
+		}
+		if err := importTrips(tx, feed, stats); err != nil {
+			return fmt.Errorf("trips.txt: %w", err)
+		}
+		if err := importShapes(tx, feed, stats); err != nil {
+			return fmt.Errorf("shapes.txt: %w", err)
+		}
+		if err := importStopTimes(tx, feed, stationIds, stats); err != nil {
+			return fmt.Errorf("stop_times.txt: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func importStops(tx *gorm.DB, feed fs.FS, stats *ImportStats) (map[string]int64, error) {
+	err := eachRecord(feed, "stops.txt", true, func(r record) error {
+		// only boarding points and parent stations make sense as geofences
+		if lt := r.get("location_type"); lt != "" && lt != "0" && lt != "1" {
+			return nil
+		}
+
+		lat, err := r.float("stop_lat")
+		if err != nil {
+			return err
+		}
+		lon, err := r.float("stop_lon")
+		if err != nil {
+			return err
+		}
+
+		stopId := r.get("stop_id")
+		st := model.BusStation{
+			GtfsStopId: &stopId,
+			Code:       r.optional("stop_code"),
+			Name:       r.get("stop_name"),
+			Latitude:   lat,
+			Longitude:  lon,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "gtfs_stop_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"code", "name", "latitude", "longitude"}),
+		}).Create(&st).Error; err != nil {
+			return err
+		}
+
+		stats.Stops++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stations []model.BusStation
+	if err := tx.Where("gtfs_stop_id IS NOT NULL").Find(&stations).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int64, len(stations))
+	for _, st := range stations {
+		ids[*st.GtfsStopId] = st.Id
+	}
+
+	return ids, nil
+}
+
+func importRoutes(tx *gorm.DB, feed fs.FS, stats *ImportStats) error {
+	batch := make([]model.Route, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "route_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"agency_id", "short_name", "long_name", "route_type", "color", "text_color"}),
+		}).Create(&batch).Error
+		batch = batch[:0]
+		return err
+	}
+
+	err := eachRecord(feed, "routes.txt", true, func(r record) error {
+		routeType, err := r.intOr("route_type", 3)
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, model.Route{
+			RouteId:   r.get("route_id"),
+			AgencyId:  r.get("agency_id"),
+			ShortName: r.get("route_short_name"),
+			LongName:  r.get("route_long_name"),
+			RouteType: routeType,
+			Color:     r.get("route_color"),
+			TextColor: r.get("route_text_color"),
+		})
+		stats.Routes++
+
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func importTrips(tx *gorm.DB, feed fs.FS, stats *ImportStats) error {
+	batch := make([]model.Trip, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "trip_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"route_id", "service_id", "headsign", "direction_id", "shape_id"}),
+		}).Create(&batch).Error
+		batch = batch[:0]
+		return err
+	}
+
+	err := eachRecord(feed, "trips.txt", true, func(r record) error {
+		var direction *int
+		if v := r.get("direction_id"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid direction_id %q", r.line, v)
+			}
+			direction = &d
+		}
+
+		batch = append(batch, model.Trip{
+			TripId:      r.get("trip_id"),
+			RouteId:     r.get("route_id"),
+			ServiceId:   r.get("service_id"),
+			Headsign:    r.get("trip_headsign"),
+			DirectionId: direction,
+			ShapeId:     r.get("shape_id"),
+		})
+		stats.Trips++
+
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func importShapes(tx *gorm.DB, feed fs.FS, stats *ImportStats) error {
+	batch := make([]model.ShapePoint, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "shape_id"}, {Name: "sequence"}},
+			DoUpdates: clause.AssignmentColumns([]string{"latitude", "longitude", "dist_traveled"}),
+		}).Create(&batch).Error
+		batch = batch[:0]
+		return err
+	}
+
+	// shapes.txt is optional in GTFS
+	err := eachRecord(feed, "shapes.txt", false, func(r record) error {
+		lat, err := r.float("shape_pt_lat")
+		if err != nil {
+			return err
+		}
+		lon, err := r.float("shape_pt_lon")
+		if err != nil {
+			return err
+		}
+		seq, err := r.intOr("shape_pt_sequence", 0)
+		if err != nil {
+			return err
+		}
+
+		var dist *float64
+		if r.get("shape_dist_traveled") != "" {
+			d, err := r.float("shape_dist_traveled")
+			if err != nil {
+				return err
+			}
+			dist = &d
+		}
+
+		batch = append(batch, model.ShapePoint{
+			ShapeId:      r.get("shape_id"),
+			Sequence:     seq,
+			Latitude:     lat,
+			Longitude:    lon,
+			DistTraveled: dist,
+		})
+		stats.Shapes++
+
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func importStopTimes(tx *gorm.DB, feed fs.FS, stationIds map[string]int64, stats *ImportStats) error {
+	batch := make([]model.StopTime, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "trip_id"}, {Name: "stop_sequence"}},
+			DoUpdates: clause.AssignmentColumns([]string{"station_id", "arrival_time", "departure_time"}),
+		}).Create(&batch).Error
+		batch = batch[:0]
+		return err
+	}
+
+	err := eachRecord(feed, "stop_times.txt", true, func(r record) error {
+		stopId := r.get("stop_id")
+		stationId, ok := stationIds[stopId]
+		if !ok {
+			return fmt.Errorf("line %d: unknown stop_id %q", r.line, stopId)
+		}
+		seq, err := r.intOr("stop_sequence", 0)
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, model.StopTime{
+			TripId:        r.get("trip_id"),
+			StopSequence:  seq,
+			StationId:     stationId,
+			ArrivalTime:   r.get("arrival_time"),
+			DepartureTime: r.get("departure_time"),
+		})
+		stats.StopTimes++
+
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+type record struct {
+	line   int
+	header map[string]int
+	fields []string
+}
+
+func (r record) get(col string) string {
+	i, ok := r.header[col]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+
+	return strings.TrimSpace(r.fields[i])
+}
+
+func (r record) optional(col string) *string {
+	v := r.get(col)
+	if v == "" {
+		return nil
+	}
+
+	return &v
+}
+
+func (r record) float(col string) (float64, error) {
+	v, err := strconv.ParseFloat(r.get(col), 64)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s %q", r.line, col, r.get(col))
+	}
+
+	return v, nil
+}
+
+func (r record) intOr(col string, def int) (int, error) {
+	s := r.get(col)
+	if s == "" {
+		return def, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s %q", r.line, col, s)
+	}
+
+	return v, nil
+}
+
+func eachRecord(feed fs.FS, name string, required bool, fn func(record) error) error {
+	f, err := feed.Open(name)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	cols, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+
+	header := make(map[string]int, len(cols))
+	for i, col := range cols {
+		// GTFS files exported from Excel often carry a UTF-8 BOM
+		header[strings.TrimPrefix(strings.TrimSpace(col), "\ufeff")] = i
+	}
+
+	line := 1
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if err := fn(record{line: line, header: header, fields: fields}); err != nil {
+			return err
+		}
+	}
+}
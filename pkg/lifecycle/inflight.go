@@ -0,0 +1,61 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// InFlight counts handlers in progress, for work that arrives through callbacks rather
+// than a loop the service owns. Once Wait was called, Enter turns new work away.
+type InFlight struct {
+	mu     sync.Mutex
+	n      int
+	closed bool
+	idle   chan struct{}
+}
+
+// Enter reports whether the handler may run; if so it must call Leave when done.
+func (f *InFlight) Enter() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return false
+	}
+	f.n++
+
+	return true
+}
+
+func (f *InFlight) Leave() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.n--
+	if f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// Wait closes the gate and waits until the handlers that got in have left. It is a Hook.
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
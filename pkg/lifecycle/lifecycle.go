@@ -0,0 +1,179 @@
+// Package lifecycle takes a service from start to an ordered shutdown. The root context is
+// canceled on SIGINT/SIGTERM, then the hooks registered for each phase run in order under
+// one deadline, so a slow drain can't keep the process around forever.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type Phase int
+
+const (
+	// StopIntake stops taking new work: consumers, subscriptions, listeners.
+	StopIntake Phase = iota
+	// Drain waits for the work already taken. Tasks started with Go are waited for first.
+	Drain
+	// Flush writes out what is still buffered in memory.
+	Flush
+	// Close closes broker, cache and database connections.
+	Close
+)
+
+var phaseNames = [...]string{"stop intake", "drain", "flush", "close"}
+
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// Hook is one shutdown step. ctx expires at the shutdown deadline; hooks of later phases
+// still run after it did, connections are closed either way.
+type Hook func(ctx context.Context) error
+
+// Func adapts a step that can't fail, a Close or a Disconnect, to a Hook.
+func Func(fn func()) Hook {
+	return func(context.Context) error {
+		fn()
+		return nil
+	}
+}
+
+// ErrFunc adapts a step without a context to a Hook.
+func ErrFunc(fn func() error) Hook {
+	return func(context.Context) error {
+		return fn()
+	}
+}
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+type Lifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+
+	mu    sync.Mutex
+	hooks [Close + 1][]hook
+	tasks sync.WaitGroup
+}
+
+// New returns a lifecycle whose root context ends on SIGINT or SIGTERM. timeout bounds
+// the whole shutdown.
+func New(timeout time.Duration) *Lifecycle {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	l := &Lifecycle{ctx: ctx, cancel: cancel, timeout: timeout}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+
+		select {
+		case s := <-sig:
+			log.Printf("received %s, shutting down", s)
+			cancel(nil)
+		case <-ctx.Done():
+		}
+	}()
+
+	return l
+}
+
+// Context is the root context, canceled when shutdown starts.
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// OnShutdown registers a hook for a phase. Hooks of one phase run one after the other in
+// the order they were registered.
+func (l *Lifecycle) OnShutdown(p Phase, name string, fn Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks[p] = append(l.hooks[p], hook{name: name, fn: fn})
+}
+
+// Go runs a task on the root context, a loop or a server that returns once the context
+// is done. A task failing before that shuts the service down.
+func (l *Lifecycle) Go(name string, fn func(ctx context.Context) error) {
+	l.tasks.Add(1)
+	go func() {
+		defer l.tasks.Done()
+
+		if err := fn(l.ctx); err != nil && l.ctx.Err() == nil {
+			log.Printf("%s error: %v", name, err)
+			l.cancel(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+}
+
+// Stop starts the shutdown like a signal would.
+func (l *Lifecycle) Stop() {
+	l.cancel(nil)
+}
+
+// Wait blocks until shutdown starts and runs it. It returns the error of the task that
+// caused the shutdown, or else of the first hook that failed.
+func (l *Lifecycle) Wait() error {
+	<-l.ctx.Done()
+
+	cause := context.Cause(l.ctx)
+	if errors.Is(cause, context.Canceled) {
+		cause = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	start := time.Now()
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+
+	for p := StopIntake; p <= Close; p++ {
+		if p == Drain {
+			if err := wait(ctx, &l.tasks); err != nil {
+				log.Printf("shutdown: %s: tasks still running: %v", p, err)
+			}
+		}
+
+		for _, h := range hooks[p] {
+			if err := h.fn(ctx); err != nil {
+				log.Printf("shutdown: %s %s error: %v", p, h.name, err)
+				if cause == nil {
+					cause = fmt.Errorf("%s: %w", h.name, err)
+				}
+			}
+		}
+	}
+
+	log.Printf("shutdown finished in %s", time.Since(start).Round(time.Millisecond))
+
+	return cause
+}
+
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
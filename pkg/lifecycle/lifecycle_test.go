@@ -0,0 +1,104 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPhasesRunInOrderAfterTheTasks(t *testing.T) {
+	l := New(time.Second)
+
+	var mu sync.Mutex
+	var got []string
+	step := func(name string) Hook {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, name)
+			return nil
+		}
+	}
+
+	// registered out of order on purpose
+	l.OnShutdown(Close, "postgres", step("close postgres"))
+	l.OnShutdown(Drain, "handlers", step("drain"))
+	l.OnShutdown(StopIntake, "consumer", step("stop intake"))
+	l.OnShutdown(Close, "rabbitmq", step("close rabbitmq"))
+	l.OnShutdown(Flush, "queue", step("flush"))
+	l.Go("loop", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		step("task")(ctx)
+		return nil
+	})
+
+	l.Stop()
+	if err := l.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"stop intake", "task", "drain", "flush", "close postgres", "close rabbitmq"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
+
+func TestFailingTaskShutsDown(t *testing.T) {
+	l := New(time.Second)
+	boom := errors.New("boom")
+
+	closed := false
+	l.OnShutdown(Close, "db", Func(func() { closed = true }))
+	l.Go("server", func(context.Context) error { return boom })
+
+	if err := l.Wait(); !errors.Is(err, boom) {
+		t.Errorf("Wait = %v, want the task's error", err)
+	}
+	if !closed {
+		t.Error("close hook didn't run")
+	}
+}
+
+func TestCloseRunsPastTheDeadline(t *testing.T) {
+	l := New(20 * time.Millisecond)
+
+	closed := false
+	l.OnShutdown(Drain, "stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	l.OnShutdown(Close, "db", Func(func() { closed = true }))
+
+	l.Stop()
+	if err := l.Wait(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the stuck drain's deadline error", err)
+	}
+	if !closed {
+		t.Error("close hook skipped after the deadline")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	var f InFlight
+	if !f.Enter() {
+		t.Fatal("turned away before Wait")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := f.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v with a handler still in", err)
+	}
+	if f.Enter() {
+		t.Error("let in after Wait")
+	}
+
+	f.Leave()
+	if err := f.Wait(context.Background()); err != nil {
+		t.Errorf("Wait = %v once idle", err)
+	}
+}
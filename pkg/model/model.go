@@ -1,14 +1,21 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 type MQTTLocationStruct struct {
-	VehicleId string  `json:"vehicle_id" gorm:"column:vehicle_id"`
-	Latitude  float64 `json:"latitude" gorm:"column:latitude"`
-	Longitude float64 `json:"longitude" gorm:"column:longitude"`
-	Timestamp int64   `json:"timestamp" gorm:"column:timestamp"`
+	VehicleId string   `json:"vehicle_id" gorm:"column:vehicle_id"`
+	Latitude  float64  `json:"latitude" gorm:"column:latitude"`
+	Longitude float64  `json:"longitude" gorm:"column:longitude"`
+	Timestamp int64    `json:"timestamp" gorm:"column:timestamp"`
+	Ignition  *bool    `json:"ignition,omitempty" gorm:"column:ignition"`
+	Speed     *float64 `json:"speed,omitempty" gorm:"column:speed"` // km/h, as reported by the device
+	// set by the subscriber from the MQTT topic, never taken from the device payload
+	OperatorId *string `json:"operator_id,omitempty" gorm:"column:operator_id"`
 }
 
 type VehicleLocation struct {
@@ -21,14 +28,391 @@ func (MQTTLocationStruct) TableName() string {
 	return "vehicle_locations"
 }
 
+type Operator struct {
+	OperatorId string    `json:"operator_id" gorm:"column:operator_id;primaryKey"`
+	Name       string    `json:"name" gorm:"column:name"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (Operator) TableName() string {
+	return "operators"
+}
+
+type Vehicle struct {
+	VehicleId    string    `json:"vehicle_id" gorm:"column:vehicle_id;primaryKey"`
+	Label        *string   `json:"label" gorm:"column:label"`
+	LicensePlate *string   `json:"license_plate" gorm:"column:license_plate"`
+	RouteId      *string   `json:"route_id" gorm:"column:route_id"`
+	GroupName    *string   `json:"group_name" gorm:"column:group_name"`
+	OperatorId   *string   `json:"operator_id" gorm:"column:operator_id"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (Vehicle) TableName() string {
+	return "vehicles"
+}
+
 type BusStation struct {
-	Id        int64     `gorm:"column:id;primaryKey"`
-	Name      string    `gorm:"column:name"`
-	Latitude  float64   `gorm:"column:latitude"`
-	Longitude float64   `gorm:"column:longitude"`
-	CreatedAt time.Time `gorm:"column:created_at"`
+	Id         int64     `gorm:"column:id;primaryKey"`
+	GtfsStopId *string   `gorm:"column:gtfs_stop_id"`
+	Code       *string   `gorm:"column:code"`
+	Name       string    `gorm:"column:name"`
+	Latitude   float64   `gorm:"column:latitude"`
+	Longitude  float64   `gorm:"column:longitude"`
+	OperatorId *string   `gorm:"column:operator_id"` // nil: shared by all operators
+	CreatedAt  time.Time `gorm:"column:created_at"`
 }
 
 func (BusStation) TableName() string {
 	return "bus_stations"
 }
+
+type Route struct {
+	RouteId   string    `json:"route_id" gorm:"column:route_id;primaryKey"`
+	AgencyId  string    `json:"agency_id" gorm:"column:agency_id"`
+	ShortName string    `json:"short_name" gorm:"column:short_name"`
+	LongName  string    `json:"long_name" gorm:"column:long_name"`
+	RouteType int       `json:"route_type" gorm:"column:route_type"`
+	Color     string    `json:"color" gorm:"column:color"`
+	TextColor string    `json:"text_color" gorm:"column:text_color"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (Route) TableName() string {
+	return "routes"
+}
+
+type Trip struct {
+	TripId      string    `json:"trip_id" gorm:"column:trip_id;primaryKey"`
+	RouteId     string    `json:"route_id" gorm:"column:route_id"`
+	ServiceId   string    `json:"service_id" gorm:"column:service_id"`
+	Headsign    string    `json:"headsign" gorm:"column:headsign"`
+	DirectionId *int      `json:"direction_id" gorm:"column:direction_id"`
+	ShapeId     string    `json:"shape_id" gorm:"column:shape_id"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (Trip) TableName() string {
+	return "trips"
+}
+
+type ShapePoint struct {
+	ShapeId      string   `json:"shape_id" gorm:"column:shape_id;primaryKey"`
+	Sequence     int      `json:"sequence" gorm:"column:sequence;primaryKey"`
+	Latitude     float64  `json:"latitude" gorm:"column:latitude"`
+	Longitude    float64  `json:"longitude" gorm:"column:longitude"`
+	DistTraveled *float64 `json:"dist_traveled" gorm:"column:dist_traveled"`
+}
+
+func (ShapePoint) TableName() string {
+	return "shapes"
+}
+
+type StopTime struct {
+	TripId        string `json:"trip_id" gorm:"column:trip_id;primaryKey"`
+	StopSequence  int    `json:"stop_sequence" gorm:"column:stop_sequence;primaryKey"`
+	StationId     int64  `json:"station_id" gorm:"column:station_id"`
+	ArrivalTime   string `json:"arrival_time" gorm:"column:arrival_time"`
+	DepartureTime string `json:"departure_time" gorm:"column:departure_time"`
+}
+
+func (StopTime) TableName() string {
+	return "stop_times"
+}
+
+type VehicleTrip struct {
+	Id             int64     `json:"id" gorm:"column:id;primaryKey"`
+	VehicleId      string    `json:"vehicle_id" gorm:"column:vehicle_id"`
+	StartTime      int64     `json:"start_time" gorm:"column:start_time"`
+	EndTime        int64     `json:"end_time" gorm:"column:end_time"`
+	StartLatitude  float64   `json:"start_latitude" gorm:"column:start_latitude"`
+	StartLongitude float64   `json:"start_longitude" gorm:"column:start_longitude"`
+	EndLatitude    float64   `json:"end_latitude" gorm:"column:end_latitude"`
+	EndLongitude   float64   `json:"end_longitude" gorm:"column:end_longitude"`
+	StartStationId *int64    `json:"start_station_id" gorm:"column:start_station_id"`
+	EndStationId   *int64    `json:"end_station_id" gorm:"column:end_station_id"`
+	DistanceM      float64   `json:"distance_m" gorm:"column:distance_m"`
+	DurationS      int64     `json:"duration_s" gorm:"column:duration_s"`
+	MaxSpeedKmh    float64   `json:"max_speed_kmh" gorm:"column:max_speed_kmh"`
+	PointCount     int       `json:"point_count" gorm:"column:point_count"`
+	EndReason      string    `json:"end_reason" gorm:"column:end_reason"`
+	OperatorId     *string   `json:"operator_id,omitempty" gorm:"column:operator_id"`
+	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (VehicleTrip) TableName() string {
+	return "vehicle_trips"
+}
+
+type SpeedZone struct {
+	Id        int64     `json:"id" gorm:"column:id;primaryKey"`
+	Name      string    `json:"name" gorm:"column:name"`
+	StationId *int64    `json:"station_id" gorm:"column:station_id"`
+	Latitude  *float64  `json:"latitude" gorm:"column:latitude"`
+	Longitude *float64  `json:"longitude" gorm:"column:longitude"`
+	RadiusM   float64   `json:"radius_m" gorm:"column:radius_m"`
+	LimitKmh  float64   `json:"limit_kmh" gorm:"column:limit_kmh"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (SpeedZone) TableName() string {
+	return "speed_zones"
+}
+
+// Geofence event types.
+const (
+	GeofenceEntry = "entry"
+	GeofenceExit  = "exit"
+)
+
+type GeofenceEvent struct {
+	Id          int64     `json:"id" gorm:"column:id;primaryKey"`
+	VehicleId   string    `json:"vehicle_id" gorm:"column:vehicle_id"`
+	StationId   int64     `json:"station_id" gorm:"column:station_id"`
+	StationName string    `json:"station_name,omitempty" gorm:"->;column:station_name"`
+	EventType   string    `json:"event_type" gorm:"column:event_type"`
+	Timestamp   int64     `json:"timestamp" gorm:"column:timestamp"`
+	Latitude    float64   `json:"latitude" gorm:"column:latitude"`
+	Longitude   float64   `json:"longitude" gorm:"column:longitude"`
+	DistanceM   float64   `json:"distance_m" gorm:"column:distance_m"`
+	OperatorId  *string   `json:"operator_id,omitempty" gorm:"column:operator_id"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (GeofenceEvent) TableName() string {
+	return "geofence_events"
+}
+
+type StationVisit struct {
+	Id            int64     `json:"id" gorm:"column:id;primaryKey"`
+	VehicleId     string    `json:"vehicle_id" gorm:"column:vehicle_id"`
+	StationId     int64     `json:"station_id" gorm:"column:station_id"`
+	StationName   string    `json:"station_name,omitempty" gorm:"->;column:station_name"`
+	ArrivalTime   int64     `json:"arrival_time" gorm:"column:arrival_time"`
+	DepartureTime *int64    `json:"departure_time" gorm:"column:departure_time"`
+	DwellS        *int64    `json:"dwell_s" gorm:"column:dwell_s"`
+	MinDistanceM  float64   `json:"min_distance_m" gorm:"column:min_distance_m"`
+	PointCount    int       `json:"point_count" gorm:"column:point_count"`
+	OperatorId    *string   `json:"operator_id,omitempty" gorm:"column:operator_id"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (StationVisit) TableName() string {
+	return "station_visits"
+}
+
+// RuleCondition is one clause of an alert rule; which fields apply depends on Type.
+type RuleCondition struct {
+	Type string `json:"type"`
+
+	// geofence: inside (default) or outside any of the stations
+	StationIds []int64 `json:"station_ids,omitempty"`
+	RadiusM    float64 `json:"radius_m,omitempty"`
+	Inside     *bool   `json:"inside,omitempty"`
+
+	// time_of_day: HH:MM window in Timezone, may wrap past midnight
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+
+	// speed: compare km/h with Op (>, >=, <, <=)
+	Op    string   `json:"op,omitempty"`
+	Value *float64 `json:"value,omitempty"`
+
+	// vehicle_group / vehicle
+	Groups     []string `json:"groups,omitempty"`
+	VehicleIds []string `json:"vehicle_ids,omitempty"`
+
+	// ignition
+	Ignition *bool `json:"ignition,omitempty"`
+}
+
+type RuleConditions []RuleCondition
+
+func (c RuleConditions) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (c *RuleConditions) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case nil:
+		*c = nil
+		return nil
+	default:
+		return fmt.Errorf("unsupported conditions type %T", src)
+	}
+}
+
+type AlertRule struct {
+	Id          int64          `json:"id" gorm:"column:id;primaryKey"`
+	Key         string         `json:"key" gorm:"column:key"`
+	Name        string         `json:"name" gorm:"column:name"`
+	Description string         `json:"description" gorm:"column:description"`
+	Severity    string         `json:"severity" gorm:"column:severity"`
+	Enabled     bool           `json:"enabled" gorm:"column:enabled"`
+	Conditions  RuleConditions `json:"conditions" gorm:"column:conditions;type:jsonb"`
+	DurationS   int64          `json:"duration_s" gorm:"column:duration_s"`
+	CooldownS   int64          `json:"cooldown_s" gorm:"column:cooldown_s"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// StringList is a []string stored as a JSON array.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return fmt.Errorf("unsupported string list type %T", src)
+	}
+}
+
+// Notification channels.
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+	ChannelSMS     = "sms"
+)
+
+type NotificationSubscriber struct {
+	Id              int64      `json:"id" gorm:"column:id;primaryKey"`
+	Name            string     `json:"name" gorm:"column:name"`
+	Channel         string     `json:"channel" gorm:"column:channel"`
+	Target          string     `json:"target" gorm:"column:target"`
+	Secret          *string    `json:"-" gorm:"column:secret"`
+	EventTypes      StringList `json:"event_types" gorm:"column:event_types;type:jsonb"`
+	VehicleIds      StringList `json:"vehicle_ids" gorm:"column:vehicle_ids;type:jsonb"`
+	RouteIds        StringList `json:"route_ids" gorm:"column:route_ids;type:jsonb"`
+	RateLimitPerMin int        `json:"rate_limit_per_min" gorm:"column:rate_limit_per_min"`
+	PayloadFormat   string     `json:"payload_format" gorm:"column:payload_format"`
+	OperatorId      *string    `json:"operator_id" gorm:"column:operator_id"`
+	Enabled         bool       `json:"enabled" gorm:"column:enabled"`
+
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"column:consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" gorm:"column:disabled_at"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty" gorm:"column:disabled_reason"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (NotificationSubscriber) TableName() string {
+	return "notification_subscribers"
+}
+
+// Webhook payload formats.
+const (
+	PayloadEnvelope          = "envelope"
+	PayloadCloudEvents       = "cloudevents"
+	PayloadCloudEventsBinary = "cloudevents-binary"
+)
+
+// Delivery attempt outcomes.
+const (
+	DeliverySent        = "sent"
+	DeliveryRetrying    = "retrying"
+	DeliveryFailed      = "failed"
+	DeliveryRateLimited = "rate_limited"
+)
+
+type NotificationDelivery struct {
+	Id           int64     `json:"id" gorm:"column:id;primaryKey"`
+	DeliveryId   string    `json:"delivery_id" gorm:"column:delivery_id"`
+	SubscriberId int64     `json:"subscriber_id" gorm:"column:subscriber_id"`
+	Channel      string    `json:"channel" gorm:"column:channel"`
+	RoutingKey   string    `json:"routing_key" gorm:"column:routing_key"`
+	VehicleId    *string   `json:"vehicle_id,omitempty" gorm:"column:vehicle_id"`
+	Attempt      int       `json:"attempt" gorm:"column:attempt"`
+	Status       string    `json:"status" gorm:"column:status"`
+	StatusCode   *int      `json:"status_code,omitempty" gorm:"column:status_code"`
+	Error        *string   `json:"error,omitempty" gorm:"column:error"`
+	DurationMs   int64     `json:"duration_ms" gorm:"column:duration_ms"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// API key roles, from most to least privileged.
+const (
+	RoleAdmin      = "admin"
+	RoleDispatcher = "dispatcher"
+	RolePartner    = "partner"
+	RoleReadOnly   = "read-only"
+)
+
+type APIKey struct {
+	Id         int64      `json:"id" gorm:"column:id;primaryKey"`
+	Name       string     `json:"name" gorm:"column:name"`
+	KeyPrefix  string     `json:"key_prefix" gorm:"column:key_prefix"`
+	KeyHash    string     `json:"-" gorm:"column:key_hash"`
+	Role       string     `json:"role" gorm:"column:role"`
+	GroupNames StringList `json:"group_names" gorm:"column:group_names;type:jsonb"`
+	RouteIds   StringList `json:"route_ids" gorm:"column:route_ids;type:jsonb"`
+	OperatorId *string    `json:"operator_id" gorm:"column:operator_id"`
+	// nil: RATE_LIMIT_KEY_PER_MIN, 0: unlimited
+	RateLimitPerMin *int       `json:"rate_limit_per_min" gorm:"column:rate_limit_per_min"`
+	ExpiresAt       *time.Time `json:"expires_at" gorm:"column:expires_at"`
+	LastUsedAt      *time.Time `json:"last_used_at" gorm:"column:last_used_at"`
+	RevokedAt       *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	CreatedBy       *string    `json:"created_by" gorm:"column:created_by"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Device is a tracker allowed to connect to the MQTT broker and publish for one vehicle.
+type Device struct {
+	Id           int64      `json:"id" gorm:"column:id;primaryKey"`
+	VehicleId    string     `json:"vehicle_id" gorm:"column:vehicle_id"`
+	Username     string     `json:"username" gorm:"column:username"`
+	PasswordHash string     `json:"-" gorm:"column:password_hash"`
+	Name         *string    `json:"name" gorm:"column:name"`
+	LastAuthAt   *time.Time `json:"last_auth_at" gorm:"column:last_auth_at"`
+	RevokedAt    *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	CreatedBy    *string    `json:"created_by" gorm:"column:created_by"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (Device) TableName() string {
+	return "devices"
+}
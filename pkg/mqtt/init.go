@@ -11,11 +11,24 @@ import (
 	paho "github.com/eclipse/paho.mqtt.golang"
 )
 
+// Subscriber registers handlers for topic filters. *MQTTClient subscribes on the broker,
+// *MemoryBroker in-process.
+type Subscriber interface {
+	Subscribe(topic string, handler paho.MessageHandler) error
+	Unsubscribe(topics ...string) error
+}
+
 type MQTTClient struct {
 	Client paho.Client
 }
 
+// NewMQTTClient connects with the service account from MQTT_USERNAME / MQTT_PASSWORD.
 func NewMQTTClient(clientId string) (*MQTTClient, error) {
+	return NewMQTTClientAs(clientId, config.Cfg.MQTTUsername, config.Cfg.MQTTPassword)
+}
+
+// NewMQTTClientAs connects with the given credentials, anonymously when username is empty.
+func NewMQTTClientAs(clientId, username, password string) (*MQTTClient, error) {
 	opts := paho.NewClientOptions()
 	brokerURL := fmt.Sprintf("tcp://%s:%s", config.Cfg.MQTTBroker, config.Cfg.MQTTPort)
 
@@ -25,6 +38,10 @@ func NewMQTTClient(clientId string) (*MQTTClient, error) {
 	opts.SetConnectTimeout(5 * time.Second)
 	opts.SetKeepAlive(20 * time.Second)
 	opts.SetPingTimeout(3 * time.Second)
+	if username != "" {
+		opts.SetUsername(username)
+		opts.SetPassword(password)
+	}
 
 	if config.Cfg.MQTTUseTLS == "true" {
 		opts.SetTLSConfig(&tls.Config{
@@ -78,6 +95,18 @@ func (m *MQTTClient) Subscribe(topic string, handler paho.MessageHandler) error
 	return token.Error()
 }
 
+func (m *MQTTClient) Unsubscribe(topics ...string) error {
+	if !m.Client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	token := m.Client.Unsubscribe(topics...)
+
+	token.Wait()
+
+	return token.Error()
+}
+
 func (m *MQTTClient) Disconnect() {
 	if m.Client.IsConnected() {
 		m.Client.Disconnect(250)
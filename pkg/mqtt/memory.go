@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MemoryBroker is an in-process MQTT broker for tests. Publish runs the handlers of every
+// matching subscription before it returns, in subscription order; handlers get a nil
+// client.
+type MemoryBroker struct {
+	mu   sync.Mutex
+	subs []memorySubscription
+}
+
+type memorySubscription struct {
+	filter  string
+	handler paho.MessageHandler
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Subscribe(topic string, handler paho.MessageHandler) error {
+	if i := strings.Index(topic, "#"); i >= 0 && (i != len(topic)-1 || (i > 0 && topic[i-1] != '/')) {
+		return fmt.Errorf("invalid topic filter %q", topic)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs = append(b.subs, memorySubscription{filter: topic, handler: handler})
+
+	return nil
+}
+
+// Unsubscribe drops the subscriptions with exactly these filters, as on a broker.
+func (b *MemoryBroker) Unsubscribe(topics ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := b.subs[:0]
+	for _, s := range b.subs {
+		if !slices.Contains(topics, s.filter) {
+			kept = append(kept, s)
+		}
+	}
+	b.subs = kept
+
+	return nil
+}
+
+// Publish has the signature of MQTTClient.Publish, so it can stand in for a device.
+func (b *MemoryBroker) Publish(topic string, payload []byte) error {
+	if strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("invalid topic %q: wildcards are for filters", topic)
+	}
+
+	b.mu.Lock()
+	var handlers []paho.MessageHandler
+	for _, s := range b.subs {
+		if MatchTopic(s.filter, topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, h := range handlers {
+		h(nil, &memoryMessage{topic: topic, payload: payload})
+	}
+
+	return nil
+}
+
+// memoryMessage is a QoS 1 message as the subscriber's handler sees it.
+type memoryMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *memoryMessage) Duplicate() bool   { return false }
+func (m *memoryMessage) Qos() byte         { return 1 }
+func (m *memoryMessage) Retained() bool    { return false }
+func (m *memoryMessage) Topic() string     { return m.topic }
+func (m *memoryMessage) MessageID() uint16 { return 0 }
+func (m *memoryMessage) Payload() []byte   { return m.payload }
+func (m *memoryMessage) Ack()              {}
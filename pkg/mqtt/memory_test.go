@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"testing"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{TenantLocationTopic, "/fleet/op1/vehicle/bus-1/location", true},
+		{TenantLocationTopic, "/fleet/vehicle/bus-1/location", false},
+		{LegacyLocationTopic, "/fleet/vehicle/bus-1/location", true},
+		{LegacyLocationTopic, "/fleet/vehicle/bus-1/location/extra", false},
+		{"/fleet/#", "/fleet/vehicle/bus-1/location", true},
+		{"/fleet/#", "/fleet", true},
+		{"#", "/fleet/vehicle", true},
+		{"#", "$SYS/broker/uptime", false},
+		{"+/broker/uptime", "$SYS/broker/uptime", false},
+		{"$SYS/#", "$SYS/broker/uptime", true},
+		{"/fleet/+", "/fleet/", true},
+		{"/fleet/+", "/fleet", false},
+	}
+
+	for _, c := range cases {
+		if got := MatchTopic(c.filter, c.topic); got != c.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", c.filter, c.topic, got, c.want)
+		}
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+
+	var got []string
+	record := func(name string) paho.MessageHandler {
+		return func(_ paho.Client, m paho.Message) { got = append(got, name+" "+m.Topic()+" "+string(m.Payload())) }
+	}
+	for filter, name := range map[string]string{TenantLocationTopic: "tenant", LegacyLocationTopic: "legacy"} {
+		if err := b.Subscribe(filter, record(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Subscribe("/fleet/#/location", record("bad")); err == nil {
+		t.Error("subscribed with # before the last level")
+	}
+
+	if err := b.Publish(LocationTopic("op1", "bus-1"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(LocationTopic("", "bus-2"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(TenantLocationTopic, nil); err == nil {
+		t.Error("published on a wildcard topic")
+	}
+
+	want := []string{"tenant /fleet/op1/vehicle/bus-1/location a", "legacy /fleet/vehicle/bus-2/location b"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+
+	got = nil
+	if err := b.Unsubscribe(TenantLocationTopic); err != nil {
+		t.Fatal(err)
+	}
+	b.Publish(LocationTopic("op1", "bus-1"), []byte("c"))
+	b.Publish(LocationTopic("", "bus-2"), []byte("d"))
+	if len(got) != 1 || got[0] != "legacy /fleet/vehicle/bus-2/location d" {
+		t.Errorf("handled %q after unsubscribing the tenant topic", got)
+	}
+}
@@ -0,0 +1,72 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Location topics. Devices publish under their operator; the legacy topic is still accepted
+// while trackers are reconfigured, the operator then comes from the vehicles table.
+const (
+	TenantLocationTopic = "/fleet/+/vehicle/+/location"
+	LegacyLocationTopic = "/fleet/vehicle/+/location"
+)
+
+// LocationTopic is the topic a vehicle publishes on; the legacy one when operatorId is empty.
+func LocationTopic(operatorId, vehicleId string) string {
+	if operatorId == "" {
+		return fmt.Sprintf("/fleet/vehicle/%s/location", vehicleId)
+	}
+
+	return fmt.Sprintf("/fleet/%s/vehicle/%s/location", operatorId, vehicleId)
+}
+
+// ParseLocationTopic returns the operator and vehicle of a location topic. operatorId is
+// empty for the legacy topic; ok is false for anything else, wildcards included.
+func ParseLocationTopic(topic string) (operatorId, vehicleId string, ok bool) {
+	parts := strings.Split(topic, "/")
+	switch {
+	case len(parts) == 6 && parts[3] == "vehicle":
+		operatorId, vehicleId = parts[2], parts[4]
+		if operatorId == "" || !literal(operatorId) {
+			return "", "", false
+		}
+	case len(parts) == 5 && parts[2] == "vehicle":
+		vehicleId = parts[3]
+	default:
+		return "", "", false
+	}
+	if parts[0] != "" || parts[1] != "fleet" || parts[len(parts)-1] != "location" {
+		return "", "", false
+	}
+	if vehicleId == "" || !literal(vehicleId) {
+		return "", "", false
+	}
+
+	return operatorId, vehicleId, true
+}
+
+// MatchTopic reports whether topic matches a subscription filter, where "+" stands for one
+// level and a trailing "#" for any number of levels, none included.
+func MatchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	// wildcards don't match the broker's own $SYS topics
+	if strings.HasPrefix(topic, "$") && (f[0] == "+" || f[0] == "#") {
+		return false
+	}
+
+	for i, level := range f {
+		if level == "#" {
+			return i == len(f)-1
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+
+	return len(f) == len(t)
+}
+
+func literal(segment string) bool {
+	return !strings.ContainsAny(segment, "+#")
+}
@@ -0,0 +1,120 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"tj/pkg/events"
+)
+
+// Headers carrying the event type and schema version, so consumers can route or reject
+// messages without parsing the body.
+const (
+	HeaderEventType    = "x-event-type"
+	HeaderEventVersion = "x-event-version"
+)
+
+// CloudEvents content modes.
+const (
+	CloudEventsOff        = ""
+	CloudEventsStructured = "structured"
+	CloudEventsBinary     = "binary"
+)
+
+// cloudEventsHeaderPrefix is the AMQP binding's prefix for context attributes in binary mode.
+const cloudEventsHeaderPrefix = "cloudEvents:"
+
+// EventFormat decides how PublishEvent puts events on the wire.
+type EventFormat struct {
+	CloudEventsMode string
+	// event types sent as CloudEvents when a mode is set; topic patterns allowed
+	CloudEventsTypes []string
+}
+
+func (f EventFormat) Validate() error {
+	switch f.CloudEventsMode {
+	case CloudEventsOff, CloudEventsStructured, CloudEventsBinary:
+		return nil
+	default:
+		return fmt.Errorf("invalid CLOUDEVENTS_MODE %q, want structured or binary", f.CloudEventsMode)
+	}
+}
+
+func (f EventFormat) modeFor(eventType string) string {
+	if f.CloudEventsMode == CloudEventsOff {
+		return CloudEventsOff
+	}
+	for _, pattern := range f.CloudEventsTypes {
+		if MatchRoutingKey(pattern, eventType) {
+			return f.CloudEventsMode
+		}
+	}
+
+	return CloudEventsOff
+}
+
+// PublishEvent publishes an event as a plain envelope, or as a CloudEvent when the publisher's
+// EventFormat selects one for its type. The type and version always go into the AMQP
+// properties and headers.
+func PublishEvent(p Publisher, exchange, routingKey string, env *events.Envelope) error {
+	msg := amqp.Publishing{
+		ContentType:   "application/json",
+		MessageId:     env.Id,
+		Type:          env.Type,
+		AppId:         env.Source,
+		CorrelationId: env.CorrelationId,
+		Timestamp:     env.ProducedAt,
+		Headers: amqp.Table{
+			HeaderEventType:    env.Type,
+			HeaderEventVersion: int32(env.Version),
+		},
+	}
+
+	var err error
+	switch p.Format().modeFor(env.Type) {
+	case CloudEventsStructured:
+		msg.ContentType = events.CloudEventsContentType
+		msg.Body, err = json.Marshal(events.ToCloudEvent(env))
+
+	case CloudEventsBinary:
+		ce := events.ToCloudEvent(env)
+		for k, v := range ce.Attributes() {
+			msg.Headers[cloudEventsHeaderPrefix+k] = v
+		}
+		msg.ContentType = ce.DataContentType
+		msg.Body = ce.Data
+
+	default:
+		msg.Body, err = json.Marshal(env)
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.Publish(exchange, routingKey, msg)
+}
+
+// DecodeEvent reads a consumed message in any of the formats PublishEvent produces; see
+// events.Decode for bare payloads.
+func DecodeEvent(d amqp.Delivery) (*events.Envelope, error) {
+	if _, ok := d.Headers[cloudEventsHeaderPrefix+"specversion"]; ok {
+		attrs := make(map[string]string, len(d.Headers))
+		for k, v := range d.Headers {
+			if name, ok := strings.CutPrefix(k, cloudEventsHeaderPrefix); ok {
+				if s, ok := v.(string); ok {
+					attrs[name] = s
+				}
+			}
+		}
+		ce, err := events.CloudEventFromAttributes(attrs, d.ContentType, d.Body)
+		if err != nil {
+			return nil, err
+		}
+		return events.FromCloudEvent(ce)
+	}
+
+	return events.Decode(d.RoutingKey, d.Body)
+}
@@ -0,0 +1,82 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"tj/pkg/events"
+)
+
+func TestEventsSurviveEveryFormat(t *testing.T) {
+	raw, err := events.New(events.SourceSubscriber, &events.LocationRaw{VehicleId: "bus-1", Latitude: -6.2, Longitude: 106.8, Timestamp: 1700000000, OperatorId: "op-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := events.New(events.SourceWorker, &events.GeofenceEntry{VehicleId: "bus-1", EventId: 3}, raw.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		mode string
+		// inspects the subject and correlationid attributes as sent
+		check func(t *testing.T, body []byte, headers map[string]any, corr string)
+	}{
+		{name: "envelope", mode: CloudEventsOff},
+		{
+			name: "structured", mode: CloudEventsStructured,
+			check: func(t *testing.T, body []byte, _ map[string]any, corr string) {
+				var ce events.CloudEvent
+				if err := json.Unmarshal(body, &ce); err != nil {
+					t.Fatal(err)
+				}
+				if ce.CorrelationId != corr || ce.Subject != "bus-1" {
+					t.Errorf("correlationid %q subject %q", ce.CorrelationId, ce.Subject)
+				}
+			},
+		},
+		{
+			name: "binary", mode: CloudEventsBinary,
+			check: func(t *testing.T, _ []byte, headers map[string]any, corr string) {
+				if got, _ := headers[cloudEventsHeaderPrefix+"correlationid"].(string); corr != "" && got != corr {
+					t.Errorf("correlationid header %q, want %q", got, corr)
+				}
+				if got, _ := headers[cloudEventsHeaderPrefix+"subject"].(string); got != "bus-1" {
+					t.Errorf("subject header %q", got)
+				}
+			},
+		},
+	} {
+		for _, env := range []*events.Envelope{raw, entry} {
+			t.Run(tc.name+"/"+env.Type, func(t *testing.T) {
+				b := NewMemoryBroker()
+				defer b.Close()
+				b.EventFormat = EventFormat{CloudEventsMode: tc.mode, CloudEventsTypes: []string{"#"}}
+				b.Bind("fleet.events", "q", "#")
+				msgs := consume(t, b, "q")
+
+				if err := PublishEvent(b, "fleet.events", env.Type, env); err != nil {
+					t.Fatal(err)
+				}
+				d := <-msgs
+				if tc.check != nil {
+					tc.check(t, d.Body, d.Headers, env.CorrelationId)
+				}
+
+				got, err := DecodeEvent(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Id != env.Id || got.Type != env.Type || got.Version != env.Version || got.Source != env.Source ||
+					got.CorrelationId != env.CorrelationId || !got.ProducedAt.Equal(env.ProducedAt) {
+					t.Errorf("decoded %+v, want %+v", got, env)
+				}
+				if !bytes.Equal(got.Data, env.Data) {
+					t.Errorf("data %s, want %s", got.Data, env.Data)
+				}
+			})
+		}
+	}
+}
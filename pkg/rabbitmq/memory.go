@@ -0,0 +1,242 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MemoryBroker is an in-process stand-in for RabbitMQ's topic exchanges, for tests. Queues
+// are bound with the same wildcards as QueueBind and buffer without limit; a message that
+// matches several bindings of one queue is delivered to it once, like on the broker.
+// Nacks and rejects with requeue put the message back at the head of its queue, marked
+// Redelivered; other acks are accepted and ignored.
+type MemoryBroker struct {
+	EventFormat EventFormat
+
+	mu       sync.Mutex
+	bindings []memoryBinding
+	queues   map[string]*memoryQueue
+	tag      uint64
+	closed   bool
+}
+
+type memoryBinding struct {
+	exchange string
+	pattern  string
+	queue    string
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// Bind declares the queue if needed and binds it to the exchange for each routing key
+// pattern, what SetupRMQ and BindRMQ do on the broker.
+func (b *MemoryBroker) Bind(exchange, queue string, patterns ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[queue]; !ok {
+		b.queues[queue] = newMemoryQueue()
+	}
+	for _, p := range patterns {
+		b.bindings = append(b.bindings, memoryBinding{exchange: exchange, pattern: p, queue: queue})
+	}
+}
+
+func (b *MemoryBroker) Publish(exchange, routingKey string, msg amqp.Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.ErrClosed
+	}
+
+	routed := make(map[string]bool)
+	for _, bd := range b.bindings {
+		if bd.exchange != exchange || routed[bd.queue] || !MatchRoutingKey(bd.pattern, routingKey) {
+			continue
+		}
+		routed[bd.queue] = true
+
+		b.tag++
+		b.queues[bd.queue].push(amqp.Delivery{
+			Headers:       msg.Headers,
+			ContentType:   msg.ContentType,
+			CorrelationId: msg.CorrelationId,
+			MessageId:     msg.MessageId,
+			Timestamp:     msg.Timestamp,
+			Type:          msg.Type,
+			AppId:         msg.AppId,
+			DeliveryTag:   b.tag,
+			Exchange:      exchange,
+			RoutingKey:    routingKey,
+			Body:          msg.Body,
+		})
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Format() EventFormat {
+	return b.EventFormat
+}
+
+// Consume starts delivering a bound queue. A queue has one consumer at a time.
+func (b *MemoryBroker) Consume(cfg RabbitConfig, autoAck bool) (<-chan amqp.Delivery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[cfg.QueueName]
+	if !ok {
+		return nil, fmt.Errorf("queue %q not declared", cfg.QueueName)
+	}
+	if q.consumed {
+		return nil, fmt.Errorf("queue %q already has a consumer", cfg.QueueName)
+	}
+	q.consumed = true
+	q.stop = make(chan struct{})
+
+	out := make(chan amqp.Delivery)
+	go q.deliver(out, q.stop)
+
+	return out, nil
+}
+
+// Cancel stops the consumer of the queue; messages published afterwards stay queued for
+// the next one.
+func (b *MemoryBroker) Cancel(cfg RabbitConfig) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[cfg.QueueName]
+	if !ok || !q.consumed {
+		return fmt.Errorf("queue %q has no consumer", cfg.QueueName)
+	}
+	q.consumed = false
+	close(q.stop)
+
+	return nil
+}
+
+// Close closes the consumers' channels and rejects further publishes. Messages still
+// queued are dropped.
+func (b *MemoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, q := range b.queues {
+		q.close()
+	}
+}
+
+type memoryQueue struct {
+	mu       sync.Mutex
+	pending  []amqp.Delivery
+	ready    chan struct{}
+	done     chan struct{}
+	consumed bool
+	stop     chan struct{}
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{ready: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+func (q *memoryQueue) push(d amqp.Delivery) {
+	q.mu.Lock()
+	q.pending = append(q.pending, d)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *memoryQueue) pop() (amqp.Delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return amqp.Delivery{}, false
+	}
+	d := q.pending[0]
+	q.pending = q.pending[1:]
+
+	return d, true
+}
+
+// unpop puts back a message its consumer was canceled before taking, or requeued.
+func (q *memoryQueue) unpop(d amqp.Delivery) {
+	q.mu.Lock()
+	q.pending = append([]amqp.Delivery{d}, q.pending...)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *memoryQueue) deliver(out chan<- amqp.Delivery, stop <-chan struct{}) {
+	defer close(out)
+
+	for {
+		d, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.ready:
+				continue
+			case <-q.done:
+				return
+			case <-stop:
+				return
+			}
+		}
+
+		d.Acknowledger = &memoryAcknowledger{q: q, d: d}
+		select {
+		case out <- d:
+		case <-q.done:
+			return
+		case <-stop:
+			q.unpop(d)
+			return
+		}
+	}
+}
+
+func (q *memoryQueue) close() {
+	select {
+	case <-q.done:
+	default:
+		close(q.done)
+	}
+}
+
+// memoryAcknowledger settles one delivery of a queue.
+type memoryAcknowledger struct {
+	q *memoryQueue
+	d amqp.Delivery
+}
+
+func (a *memoryAcknowledger) Ack(tag uint64, multiple bool) error { return nil }
+
+func (a *memoryAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *memoryAcknowledger) Reject(tag uint64, requeue bool) error {
+	if requeue {
+		d := a.d
+		d.Acknowledger = nil
+		d.Redelivered = true
+		a.q.unpop(d)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"tj/pkg/events"
+)
+
+func TestMemoryBrokerRoutesLikeATopicExchange(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	b.Bind("fleet.events", "geofence", "geofence.*")
+	b.Bind("fleet.events", "everything", "#", "alert.*")
+	b.Bind("other", "other", "#")
+
+	geofence := consume(t, b, "geofence")
+	everything := consume(t, b, "everything")
+	other := consume(t, b, "other")
+
+	for _, key := range []string{"geofence.entry", "alert.overspeed", "location.raw", "geofence.entry.late"} {
+		if err := PublishRMQ(b, "fleet.events", key, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectKeys(t, geofence, "geofence.entry")
+	// matched twice by alert.*, delivered once
+	expectKeys(t, everything, "geofence.entry", "alert.overspeed", "location.raw", "geofence.entry.late")
+	expectKeys(t, other)
+}
+
+func TestMemoryBrokerCarriesCloudEvents(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	b.EventFormat = EventFormat{CloudEventsMode: CloudEventsBinary, CloudEventsTypes: []string{"geofence.*"}}
+	b.Bind("fleet.events", "q", "#")
+	msgs := consume(t, b, "q")
+
+	env, err := events.New(events.SourceWorker, &events.GeofenceEntry{VehicleId: "bus-1", EventId: 3}, "corr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PublishEvent(b, "fleet.events", env.Type, env); err != nil {
+		t.Fatal(err)
+	}
+
+	d := <-msgs
+	if _, ok := d.Headers[cloudEventsHeaderPrefix+"specversion"]; !ok {
+		t.Fatalf("headers %v, want binary-mode CloudEvent attributes", d.Headers)
+	}
+	got, err := DecodeEvent(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry events.GeofenceEntry
+	if err := got.DecodeData(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != env.Id || got.CorrelationId != "corr" || entry.EventId != 3 {
+		t.Errorf("decoded %+v %+v", got, entry)
+	}
+}
+
+func TestMemoryBrokerClose(t *testing.T) {
+	b := NewMemoryBroker()
+	b.Bind("fleet.events", "q", "#")
+	msgs := consume(t, b, "q")
+
+	b.Close()
+	if _, ok := <-msgs; ok {
+		t.Error("delivery after Close")
+	}
+	if err := PublishRMQ(b, "fleet.events", "location.raw", nil); err != amqp.ErrClosed {
+		t.Errorf("publish after Close: %v, want %v", err, amqp.ErrClosed)
+	}
+}
+
+func TestMemoryBrokerCancelKeepsTheQueue(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	b.Bind("fleet.events", "q", "#")
+	msgs := consume(t, b, "q")
+
+	if err := b.Cancel(RabbitConfig{QueueName: "q"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Error("delivery after Cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delivery channel still open after Cancel")
+	}
+
+	if err := PublishRMQ(b, "fleet.events", "location.raw", nil); err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, consume(t, b, "q"), "location.raw")
+}
+
+func TestMemoryBrokerRequeuesNackedMessages(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	b.Bind("fleet.events", "q", "#")
+	msgs := consume(t, b, "q")
+
+	if err := PublishRMQ(b, "fleet.events", "location.raw", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-msgs
+	if err := first.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case again := <-msgs:
+		if again.RoutingKey != "location.raw" || !again.Redelivered {
+			t.Errorf("got %s (redelivered %v), want location.raw redelivered", again.RoutingKey, again.Redelivered)
+		}
+		if err := again.Reject(false); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nacked message not redelivered")
+	}
+	// rejected without requeue: gone
+	expectKeys(t, msgs)
+}
+
+func consume(t *testing.T, b *MemoryBroker, queue string) <-chan amqp.Delivery {
+	t.Helper()
+
+	msgs, err := b.Consume(RabbitConfig{QueueName: queue}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msgs
+}
+
+func expectKeys(t *testing.T, msgs <-chan amqp.Delivery, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		select {
+		case d := <-msgs:
+			if d.RoutingKey != key {
+				t.Errorf("got %s, want %s", d.RoutingKey, key)
+			}
+			if err := d.Ack(false); err != nil {
+				t.Errorf("ack: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no message, want %s", key)
+		}
+	}
+	select {
+	case d := <-msgs:
+		t.Errorf("unexpected %s", d.RoutingKey)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
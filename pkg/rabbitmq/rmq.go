@@ -9,8 +9,32 @@ import (
 )
 
 type RabbitClient struct {
-	Conn    *amqp.Connection
-	Channel *amqp.Channel
+	Conn        *amqp.Connection
+	Channel     *amqp.Channel
+	EventFormat EventFormat
+}
+
+// Publisher sends messages to an exchange. *RabbitClient publishes to the broker,
+// *MemoryBroker routes them in-process.
+type Publisher interface {
+	Publish(exchange, routingKey string, msg amqp.Publishing) error
+	// Format is the EventFormat PublishEvent encodes with.
+	Format() EventFormat
+}
+
+// Consumer delivers the messages of a queue declared beforehand, until it is closed or
+// the consumer canceled.
+type Consumer interface {
+	Consume(cfg RabbitConfig, autoAck bool) (<-chan amqp.Delivery, error)
+	// Cancel stops the consumer of cfg.ConsumerName; its delivery channel closes once the
+	// broker confirmed. Unacked messages go back to the queue.
+	Cancel(cfg RabbitConfig) error
+}
+
+// Broker is both ends, for services that consume one event and publish the ones it causes.
+type Broker interface {
+	Publisher
+	Consumer
 }
 
 type RabbitConfig struct {
@@ -33,8 +57,18 @@ func Connect() (*RabbitClient, error) {
 		return nil, err
 	}
 
+	format := EventFormat{
+		CloudEventsMode:  config.Cfg.CloudEventsMode,
+		CloudEventsTypes: config.Cfg.CloudEventsEvents,
+	}
+	if err := format.Validate(); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, err
+	}
+
 	log.Println("RabbitMQ connected")
-	return &RabbitClient{Conn: conn, Channel: ch}, nil
+	return &RabbitClient{Conn: conn, Channel: ch, EventFormat: format}, nil
 }
 
 func (rmq *RabbitClient) Close() {
@@ -46,6 +80,26 @@ func (rmq *RabbitClient) Close() {
 	}
 }
 
+func (rmq *RabbitClient) Publish(exchange, routingKey string, msg amqp.Publishing) error {
+	return rmq.Channel.Publish(exchange, routingKey, false, false, msg)
+}
+
+func (rmq *RabbitClient) Format() EventFormat {
+	return rmq.EventFormat
+}
+
+func (rmq *RabbitClient) Consume(cfg RabbitConfig, autoAck bool) (<-chan amqp.Delivery, error) {
+	return ConsumeRMQWithConfig(rmq, cfg, autoAck)
+}
+
+func (rmq *RabbitClient) Cancel(cfg RabbitConfig) error {
+	if cfg.ConsumerName == "" {
+		return fmt.Errorf("consumer of %q has no name to cancel", cfg.QueueName)
+	}
+
+	return rmq.Channel.Cancel(cfg.ConsumerName, false)
+}
+
 func SetupRMQ(rmq *RabbitClient, cfg RabbitConfig) error {
 	exType := cfg.ExchangeType
 	if exType == "" {
@@ -90,12 +144,10 @@ func SetupRMQ(rmq *RabbitClient, cfg RabbitConfig) error {
 	return nil
 }
 
-func PublishRMQ(rmq *RabbitClient, exchange, routingKey string, payload []byte) error {
-	return rmq.Channel.Publish(
+func PublishRMQ(p Publisher, exchange, routingKey string, payload []byte) error {
+	return p.Publish(
 		exchange,
 		routingKey,
-		false,
-		false,
 		amqp.Publishing{
 			ContentType: "application/json",
 			Body:        payload,
@@ -119,3 +171,58 @@ func ConsumeRMQWithConfig(rmq *RabbitClient, cfg RabbitConfig, autoAck bool) (<-
 		nil,
 	)
 }
+
+// SetupTransientQueue declares the exchange plus a server-named, exclusive, auto-delete queue
+// bound to every routing key. Used by fan-out consumers (e.g. one queue per API replica) that
+// must not share or outlive their queue. Returns the generated queue name.
+func SetupTransientQueue(rmq *RabbitClient, exchange string, routingKeys []string) (string, error) {
+	if err := rmq.Channel.ExchangeDeclare(
+		exchange,
+		"topic",
+		true,  // durable
+		false, // auto-delete
+		false, // internal
+		false, // no-wait
+		nil,
+	); err != nil {
+		return "", fmt.Errorf("exchange declare error: %w", err)
+	}
+
+	q, err := rmq.Channel.QueueDeclare(
+		"",
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return "", fmt.Errorf("queue declare error: %w", err)
+	}
+
+	for _, key := range routingKeys {
+		if err := rmq.Channel.QueueBind(q.Name, key, exchange, false, nil); err != nil {
+			return "", fmt.Errorf("queue bind error: %w", err)
+		}
+	}
+
+	log.Printf("RabbitMQ transient queue ready: exchange=%s queue=%s routing=%v",
+		exchange, q.Name, routingKeys)
+
+	return q.Name, nil
+}
+
+// BindRMQ adds extra routing keys to a queue declared by SetupRMQ, for consumers that
+// listen to more than one event family.
+func BindRMQ(rmq *RabbitClient, cfg RabbitConfig, routingKeys []string) error {
+	for _, key := range routingKeys {
+		if err := rmq.Channel.QueueBind(cfg.QueueName, key, cfg.ExchangeName, false, nil); err != nil {
+			return fmt.Errorf("queue bind error: %w", err)
+		}
+	}
+
+	log.Printf("RabbitMQ bindings added: exchange=%s queue=%s routing=%v",
+		cfg.ExchangeName, cfg.QueueName, routingKeys)
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package rabbitmq
+
+import "strings"
+
+// MatchRoutingKey reports whether key matches a topic-exchange binding pattern, where
+// "*" stands for exactly one word and "#" for zero or more words.
+func MatchRoutingKey(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchWords(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(key) == 0 {
+				return false
+			}
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, key = pattern[1:], key[1:]
+	}
+
+	return len(key) == 0
+}
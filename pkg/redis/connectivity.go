@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// LastSeenKey is a sorted set of vehicle_id scored by the unix time of the last ingested point.
+	LastSeenKey = "fleet:connectivity:last_seen"
+	// StatusKey is a hash of vehicle_id -> ConnectivityStatus (JSON).
+	StatusKey = "fleet:connectivity:status"
+)
+
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+type ConnectivityStatus struct {
+	Status string `json:"status"`
+	Since  int64  `json:"since"`
+}
+
+// setStatusIfChanged only writes when the status actually changes so several monitor replicas
+// never publish the same transition twice. Returns 0 unchanged, 1 changed, 2 first status.
+var setStatusIfChanged = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+local result = 2
+if cur then
+	local ok, decoded = pcall(cjson.decode, cur)
+	if ok and decoded.status == ARGV[2] then
+		return 0
+	end
+	result = 1
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return result
+`)
+
+func TouchLastSeen(ctx context.Context, rdb *redis.Client, vehicleId string, at time.Time) error {
+	return rdb.ZAdd(ctx, LastSeenKey, redis.Z{Score: float64(at.Unix()), Member: vehicleId}).Err()
+}
+
+// GetLastSeen returns vehicle_id -> unix time of the last ingested point.
+func GetLastSeen(ctx context.Context, rdb *redis.Client) (map[string]int64, error) {
+	zs, err := rdb.ZRangeWithScores(ctx, LastSeenKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]int64, len(zs))
+	for _, z := range zs {
+		seen[z.Member.(string)] = int64(z.Score)
+	}
+
+	return seen, nil
+}
+
+func GetVehicleLastSeen(ctx context.Context, rdb *redis.Client, vehicleId string) (int64, bool, error) {
+	score, err := rdb.ZScore(ctx, LastSeenKey, vehicleId).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	return int64(score), true, nil
+}
+
+// SetStatus records a status. changed is false if the vehicle already had that status;
+// first is true when the vehicle had no status before.
+func SetStatus(ctx context.Context, rdb *redis.Client, vehicleId string, st ConnectivityStatus) (changed, first bool, err error) {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return false, false, err
+	}
+
+	res, err := setStatusIfChanged.Run(ctx, rdb, []string{StatusKey}, vehicleId, st.Status, b).Int()
+	if err != nil {
+		return false, false, err
+	}
+
+	return res != 0, res == 2, nil
+}
+
+func GetStatuses(ctx context.Context, rdb *redis.Client) (map[string]ConnectivityStatus, error) {
+	vals, err := rdb.HGetAll(ctx, StatusKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]ConnectivityStatus, len(vals))
+	for id, v := range vals {
+		var st ConnectivityStatus
+		if err := json.Unmarshal([]byte(v), &st); err != nil {
+			continue
+		}
+		statuses[id] = st
+	}
+
+	return statuses, nil
+}
+
+func GetStatus(ctx context.Context, rdb *redis.Client, vehicleId string) (*ConnectivityStatus, error) {
+	v, err := rdb.HGet(ctx, StatusKey, vehicleId).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var st ConnectivityStatus
+	if err := json.Unmarshal([]byte(v), &st); err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	model "tj/pkg/model"
+)
+
+// LatestLocationsKey is a hash of vehicle_id -> latest MQTTLocationStruct (JSON).
+const LatestLocationsKey = "fleet:locations:latest"
+
+// only overwrite when the incoming point is newer, so late/out-of-order MQTT deliveries
+// can't move a vehicle backwards on the map
+var setIfNewer = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if cur then
+	local ok, decoded = pcall(cjson.decode, cur)
+	if ok and decoded.timestamp and tonumber(decoded.timestamp) > tonumber(ARGV[2]) then
+		return 0
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+func SetLatestLocation(ctx context.Context, rdb *redis.Client, loc model.MQTTLocationStruct) error {
+	b, err := json.Marshal(loc)
+	if err != nil {
+		return fmt.Errorf("marshal latest location: %w", err)
+	}
+
+	return setIfNewer.Run(ctx, rdb, []string{LatestLocationsKey}, loc.VehicleId, loc.Timestamp, b).Err()
+}
+
+// dropOlder deletes the entries whose point is older than ARGV[1] and returns how many
+var dropOlder = redis.NewScript(`
+local removed = 0
+local entries = redis.call('HGETALL', KEYS[1])
+for i = 1, #entries, 2 do
+	local ok, decoded = pcall(cjson.decode, entries[i + 1])
+	if not ok or (decoded.timestamp and tonumber(decoded.timestamp) < tonumber(ARGV[1])) then
+		redis.call('HDEL', KEYS[1], entries[i])
+		removed = removed + 1
+	end
+end
+return removed
+`)
+
+// TrimLatestLocations removes vehicles whose latest point is older than before (unix
+// seconds), so decommissioned or renamed vehicles don't stay in the snapshot forever.
+func TrimLatestLocations(ctx context.Context, rdb *redis.Client, before int64) (int64, error) {
+	return dropOlder.Run(ctx, rdb, []string{LatestLocationsKey}, before).Int64()
+}
+
+func GetLatestLocations(ctx context.Context, rdb *redis.Client) ([]model.MQTTLocationStruct, error) {
+	vals, err := rdb.HVals(ctx, LatestLocationsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	locs := make([]model.MQTTLocationStruct, 0, len(vals))
+	for _, v := range vals {
+		var loc model.MQTTLocationStruct
+		if err := json.Unmarshal([]byte(v), &loc); err != nil {
+			continue
+		}
+		locs = append(locs, loc)
+	}
+
+	return locs, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+type LocationRepository interface {
+	Create(ctx context.Context, loc *model.MQTTLocationStruct) error
+	// LatestSince returns each vehicle's newest point reported at or after since (unix seconds).
+	LatestSince(ctx context.Context, since int64) ([]model.MQTTLocationStruct, error)
+	// Track returns a vehicle's points reported in [from, to), oldest first.
+	Track(ctx context.Context, vehicleId string, from, to int64) ([]model.MQTTLocationStruct, error)
+
+	// Last returns the vehicle's newest stored point, ErrNotFound when it never reported.
+	Last(ctx context.Context, f Filter, vehicleId string) (*model.VehicleLocation, error)
+	// LastByVehicles returns the newest stored point of each vehicle that reported.
+	LastByVehicles(ctx context.Context, f Filter, vehicleIds []string) ([]model.VehicleLocation, error)
+	// History returns a page of a vehicle's points ordered by (timestamp, id).
+	History(ctx context.Context, f Filter, q HistoryQuery) ([]model.VehicleLocation, error)
+	// CountHistory counts the points of the range, ignoring After and Limit.
+	CountHistory(ctx context.Context, f Filter, q HistoryQuery) (int64, error)
+}
+
+type HistoryQuery struct {
+	VehicleId string
+	Range     TimeRange
+	Desc      bool
+	// continue after this row
+	After *Keyset
+	Limit int
+}
+
+type locations struct {
+	db *gorm.DB
+}
+
+func (r *locations) Create(ctx context.Context, loc *model.MQTTLocationStruct) error {
+	return r.db.WithContext(ctx).Create(loc).Error
+}
+
+func (r *locations) LatestSince(ctx context.Context, since int64) ([]model.MQTTLocationStruct, error) {
+	var rows []model.MQTTLocationStruct
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT DISTINCT ON (vehicle_id) vehicle_id, latitude, longitude, timestamp, ignition, speed, operator_id
+		FROM vehicle_locations
+		WHERE timestamp >= ?
+		ORDER BY vehicle_id, timestamp DESC`, since).
+		Scan(&rows).Error
+
+	return rows, err
+}
+
+func (r *locations) Track(ctx context.Context, vehicleId string, from, to int64) ([]model.MQTTLocationStruct, error) {
+	var rows []model.MQTTLocationStruct
+	err := r.db.WithContext(ctx).
+		Where("vehicle_id = ? AND timestamp >= ? AND timestamp < ?", vehicleId, from, to).
+		Order("timestamp ASC").
+		Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *locations) Last(ctx context.Context, f Filter, vehicleId string) (*model.VehicleLocation, error) {
+	var loc model.VehicleLocation
+	err := f.apply(r.db.WithContext(ctx), "vehicle_locations").
+		Where("vehicle_id = ?", vehicleId).
+		Order("timestamp DESC").
+		Limit(1).
+		Take(&loc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &loc, nil
+}
+
+func (r *locations) LastByVehicles(ctx context.Context, f Filter, vehicleIds []string) ([]model.VehicleLocation, error) {
+	var rows []model.VehicleLocation
+	err := f.apply(r.db.WithContext(ctx), "vehicle_locations").
+		Select("DISTINCT ON (vehicle_id) *").
+		Where("vehicle_id IN ?", vehicleIds).
+		Order("vehicle_id, timestamp DESC, id DESC").
+		Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *locations) history(ctx context.Context, f Filter, q HistoryQuery) *gorm.DB {
+	db := f.apply(r.db.WithContext(ctx).Model(&model.VehicleLocation{}), "vehicle_locations").
+		Where("vehicle_id = ?", q.VehicleId)
+
+	return q.Range.apply(db, "timestamp")
+}
+
+func (r *locations) History(ctx context.Context, f Filter, q HistoryQuery) ([]model.VehicleLocation, error) {
+	db := r.history(ctx, f, q)
+	if !q.Desc {
+		if q.After != nil {
+			db = db.Where("(timestamp, id) > (?, ?)", q.After.Timestamp, q.After.Id)
+		}
+		db = db.Order("timestamp ASC, id ASC")
+	} else {
+		if q.After != nil {
+			db = db.Where("(timestamp, id) < (?, ?)", q.After.Timestamp, q.After.Id)
+		}
+		db = db.Order("timestamp DESC, id DESC")
+	}
+
+	var rows []model.VehicleLocation
+	err := db.Limit(q.Limit).Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *locations) CountHistory(ctx context.Context, f Filter, q HistoryQuery) (int64, error) {
+	var n int64
+	err := r.history(ctx, f, q).Count(&n).Error
+
+	return n, err
+}
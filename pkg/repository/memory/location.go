@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+)
+
+type Locations struct {
+	t table[model.VehicleLocation]
+}
+
+func (r *Locations) Add(rows ...model.MQTTLocationStruct) {
+	for i := range rows {
+		r.t.insert(&model.VehicleLocation{MQTTLocationStruct: rows[i]}, setLocationId)
+	}
+}
+
+func (r *Locations) All() []model.MQTTLocationStruct {
+	rows := r.t.all()
+	out := make([]model.MQTTLocationStruct, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.MQTTLocationStruct)
+	}
+
+	return out
+}
+
+func setLocationId(l *model.VehicleLocation, id int64) { l.Id = id }
+
+func (r *Locations) Create(ctx context.Context, loc *model.MQTTLocationStruct) error {
+	r.t.insert(&model.VehicleLocation{MQTTLocationStruct: *loc}, setLocationId)
+	return nil
+}
+
+func (r *Locations) LatestSince(ctx context.Context, since int64) ([]model.MQTTLocationStruct, error) {
+	latest := make(map[string]model.MQTTLocationStruct)
+	for _, loc := range r.All() {
+		if loc.Timestamp < since {
+			continue
+		}
+		if cur, ok := latest[loc.VehicleId]; !ok || loc.Timestamp > cur.Timestamp {
+			latest[loc.VehicleId] = loc
+		}
+	}
+
+	out := make([]model.MQTTLocationStruct, 0, len(latest))
+	for _, loc := range latest {
+		out = append(out, loc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleId < out[j].VehicleId })
+
+	return out, nil
+}
+
+func (r *Locations) Track(ctx context.Context, vehicleId string, from, to int64) ([]model.MQTTLocationStruct, error) {
+	var out []model.MQTTLocationStruct
+	for _, loc := range r.All() {
+		if loc.VehicleId == vehicleId && loc.Timestamp >= from && loc.Timestamp < to {
+			out = append(out, loc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Timestamp < out[j].Timestamp })
+
+	return out, nil
+}
+
+func (r *Locations) Last(ctx context.Context, f repository.Filter, vehicleId string) (*model.VehicleLocation, error) {
+	rows, _ := r.LastByVehicles(ctx, f, []string{vehicleId})
+	if len(rows) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &rows[0], nil
+}
+
+func (r *Locations) LastByVehicles(ctx context.Context, f repository.Filter, vehicleIds []string) ([]model.VehicleLocation, error) {
+	wanted := make(map[string]bool, len(vehicleIds))
+	for _, id := range vehicleIds {
+		wanted[id] = true
+	}
+
+	last := make(map[string]model.VehicleLocation)
+	for _, loc := range r.t.all() {
+		if !wanted[loc.VehicleId] || !f.Allows(loc.VehicleId, loc.OperatorId) {
+			continue
+		}
+		if cur, ok := last[loc.VehicleId]; !ok || keysetLess(cur.Timestamp, cur.Id, loc.Timestamp, loc.Id) {
+			last[loc.VehicleId] = loc
+		}
+	}
+
+	out := make([]model.VehicleLocation, 0, len(last))
+	for _, loc := range last {
+		out = append(out, loc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleId < out[j].VehicleId })
+
+	return out, nil
+}
+
+func (r *Locations) history(f repository.Filter, q repository.HistoryQuery) []model.VehicleLocation {
+	return r.t.filter(func(loc *model.VehicleLocation) bool {
+		return loc.VehicleId == q.VehicleId && f.Allows(loc.VehicleId, loc.OperatorId) && q.Range.Contains(loc.Timestamp)
+	})
+}
+
+func (r *Locations) History(ctx context.Context, f repository.Filter, q repository.HistoryQuery) ([]model.VehicleLocation, error) {
+	rows := r.history(f, q)
+	sort.Slice(rows, func(i, j int) bool {
+		less := keysetLess(rows[i].Timestamp, rows[i].Id, rows[j].Timestamp, rows[j].Id)
+		return less != q.Desc
+	})
+
+	out := rows[:0]
+	for _, loc := range rows {
+		if q.After != nil && !pastKeyset(loc.Timestamp, loc.Id, q.After, q.Desc) {
+			continue
+		}
+		out = append(out, loc)
+	}
+
+	return limit(out, q.Limit), nil
+}
+
+func (r *Locations) CountHistory(ctx context.Context, f repository.Filter, q repository.HistoryQuery) (int64, error) {
+	return int64(len(r.history(f, q))), nil
+}
+
+// keysetLess orders rows by (time, id) like a Postgres row comparison.
+func keysetLess(ts1, id1, ts2, id2 int64) bool {
+	return ts1 < ts2 || (ts1 == ts2 && id1 < id2)
+}
+
+// pastKeyset reports whether a row comes after the cursor k in the listing's order.
+func pastKeyset(ts, id int64, k *repository.Keyset, desc bool) bool {
+	if desc {
+		return keysetLess(ts, id, k.Timestamp, k.Id)
+	}
+	return keysetLess(k.Timestamp, k.Id, ts, id)
+}
+
+func limit[T any](rows []T, n int) []T {
+	if n > 0 && len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
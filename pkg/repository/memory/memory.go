@@ -0,0 +1,101 @@
+// Package memory implements the repository interfaces on slices, for tests of the
+// controllers that need no Postgres. Each repository has Add to seed rows and All to
+// inspect what was written; ids are assigned like a serial column.
+package memory
+
+import (
+	"sync"
+
+	"tj/pkg/repository"
+)
+
+type Store struct {
+	Locations      *Locations
+	Vehicles       *Vehicles
+	Operators      *Operators
+	Stations       *Stations
+	GeofenceEvents *GeofenceEvents
+	Visits         *Visits
+	Trips          *Trips
+	Rules          *Rules
+	SpeedZones     *SpeedZones
+	Notifications  *Notifications
+}
+
+func New() *Store {
+	stations := &Stations{}
+
+	return &Store{
+		Locations:      &Locations{},
+		Vehicles:       &Vehicles{},
+		Operators:      &Operators{},
+		Stations:       stations,
+		GeofenceEvents: &GeofenceEvents{Stations: stations},
+		Visits:         &Visits{Stations: stations},
+		Trips:          &Trips{},
+		Rules:          &Rules{},
+		SpeedZones:     &SpeedZones{},
+		Notifications:  &Notifications{},
+	}
+}
+
+func (s *Store) Repositories() *repository.Repositories {
+	return &repository.Repositories{
+		Locations:      s.Locations,
+		Vehicles:       s.Vehicles,
+		Operators:      s.Operators,
+		Stations:       s.Stations,
+		GeofenceEvents: s.GeofenceEvents,
+		Visits:         s.Visits,
+		Trips:          s.Trips,
+		Rules:          s.Rules,
+		SpeedZones:     s.SpeedZones,
+		Notifications:  s.Notifications,
+	}
+}
+
+// table is a guarded slice of rows, the part every repository shares.
+type table[T any] struct {
+	mu     sync.Mutex
+	rows   []T
+	lastId int64
+}
+
+func (t *table[T]) add(rows ...T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rows = append(t.rows, rows...)
+}
+
+// insert appends a row after setId gave it the next id.
+func (t *table[T]) insert(row *T, setId func(*T, int64)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastId++
+	setId(row, t.lastId)
+	t.rows = append(t.rows, *row)
+}
+
+func (t *table[T]) all() []T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]T(nil), t.rows...)
+}
+
+func (t *table[T]) filter(keep func(*T) bool) []T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// empty rather than nil, like gorm's Find
+	out := []T{}
+	for i := range t.rows {
+		if keep(&t.rows[i]) {
+			out = append(out, t.rows[i])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+type Notifications struct {
+	subscribers table[model.NotificationSubscriber]
+	deliveries  table[model.NotificationDelivery]
+}
+
+func (r *Notifications) Add(rows ...model.NotificationSubscriber) { r.subscribers.add(rows...) }
+func (r *Notifications) Subscribers() []model.NotificationSubscriber {
+	return r.subscribers.all()
+}
+func (r *Notifications) Deliveries() []model.NotificationDelivery { return r.deliveries.all() }
+
+func (r *Notifications) EnabledSubscribers(ctx context.Context) ([]model.NotificationSubscriber, error) {
+	return r.subscribers.filter(func(s *model.NotificationSubscriber) bool { return s.Enabled }), nil
+}
+
+func (r *Notifications) RecordDelivery(ctx context.Context, d *model.NotificationDelivery) error {
+	r.deliveries.insert(d, func(d *model.NotificationDelivery, id int64) { d.Id = id })
+	return nil
+}
+
+func (r *Notifications) ResetFailures(ctx context.Context, subscriberId int64) error {
+	r.update(subscriberId, func(s *model.NotificationSubscriber) { s.ConsecutiveFailures = 0 })
+	return nil
+}
+
+func (r *Notifications) CountFailure(ctx context.Context, subscriberId int64) error {
+	r.update(subscriberId, func(s *model.NotificationSubscriber) { s.ConsecutiveFailures++ })
+	return nil
+}
+
+func (r *Notifications) Disable(ctx context.Context, subscriberId int64, reason string, minFailures int) (bool, error) {
+	disabled := false
+	r.update(subscriberId, func(s *model.NotificationSubscriber) {
+		if !s.Enabled || s.ConsecutiveFailures < minFailures {
+			return
+		}
+		now := time.Now()
+		s.Enabled = false
+		s.DisabledAt = &now
+		s.DisabledReason = &reason
+		disabled = true
+	})
+
+	return disabled, nil
+}
+
+func (r *Notifications) update(subscriberId int64, fn func(*model.NotificationSubscriber)) {
+	r.subscribers.mu.Lock()
+	defer r.subscribers.mu.Unlock()
+
+	for i := range r.subscribers.rows {
+		if r.subscribers.rows[i].Id == subscriberId {
+			fn(&r.subscribers.rows[i])
+		}
+	}
+}
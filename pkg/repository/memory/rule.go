@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"context"
+
+	model "tj/pkg/model"
+)
+
+type Rules struct {
+	t table[model.AlertRule]
+}
+
+func (r *Rules) Add(rows ...model.AlertRule) { r.t.add(rows...) }
+
+func (r *Rules) Enabled(ctx context.Context) ([]model.AlertRule, error) {
+	return r.t.filter(func(a *model.AlertRule) bool { return a.Enabled }), nil
+}
+
+type SpeedZones struct {
+	t table[model.SpeedZone]
+}
+
+func (r *SpeedZones) Add(rows ...model.SpeedZone) { r.t.add(rows...) }
+
+func (r *SpeedZones) List(ctx context.Context) ([]model.SpeedZone, error) {
+	return r.t.all(), nil
+}
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+)
+
+type Stations struct {
+	t table[model.BusStation]
+}
+
+func (r *Stations) Add(rows ...model.BusStation) { r.t.add(rows...) }
+
+func (r *Stations) Visible(ctx context.Context, operatorId *string) ([]model.BusStation, error) {
+	return r.t.filter(func(s *model.BusStation) bool {
+		return s.OperatorId == nil || (operatorId != nil && *s.OperatorId == *operatorId)
+	}), nil
+}
+
+// names maps station ids to names for the joins of the listings, nil without stations
+// to join.
+func (r *Stations) names() map[int64]string {
+	if r == nil {
+		return nil
+	}
+	names := make(map[int64]string)
+	for _, s := range r.t.all() {
+		names[s.Id] = s.Name
+	}
+
+	return names
+}
+
+// GeofenceEvents needs the stations for the names List joins in, New shares the store's.
+type GeofenceEvents struct {
+	t        table[model.GeofenceEvent]
+	Stations *Stations
+}
+
+func (r *GeofenceEvents) Add(rows ...model.GeofenceEvent) { r.t.add(rows...) }
+func (r *GeofenceEvents) All() []model.GeofenceEvent      { return r.t.all() }
+
+func (r *GeofenceEvents) Create(ctx context.Context, ev *model.GeofenceEvent) error {
+	r.t.insert(ev, func(e *model.GeofenceEvent, id int64) { e.Id = id })
+	return nil
+}
+
+func (r *GeofenceEvents) List(ctx context.Context, f repository.Filter, q repository.EventQuery) ([]model.GeofenceEvent, error) {
+	names := r.Stations.names()
+	out := r.t.filter(func(ev *model.GeofenceEvent) bool {
+		return joined(names, ev.StationId) && f.Allows(ev.VehicleId, ev.OperatorId) &&
+			matchesStationQuery(q.StationQuery, ev.VehicleId, ev.StationId, ev.Timestamp, ev.Id) &&
+			(q.EventType == "" || ev.EventType == q.EventType)
+	})
+	for i := range out {
+		out[i].StationName = names[out[i].StationId]
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return keysetLess(out[i].Timestamp, out[i].Id, out[j].Timestamp, out[j].Id)
+	})
+
+	return limit(out, q.Limit), nil
+}
+
+// joined reports whether a row of the station survives the join, always when there are
+// no stations to join.
+func joined(names map[int64]string, stationId int64) bool {
+	if names == nil {
+		return true
+	}
+	_, ok := names[stationId]
+	return ok
+}
+
+func matchesStationQuery(q repository.StationQuery, vehicleId string, stationId, ts, id int64) bool {
+	return (q.VehicleId == "" || vehicleId == q.VehicleId) &&
+		(q.StationId == nil || stationId == *q.StationId) &&
+		q.Range.Contains(ts) &&
+		(q.After == nil || pastKeyset(ts, id, q.After, false))
+}
+
+// Visits needs the stations for the names Open and List join in, New shares the store's.
+type Visits struct {
+	t        table[model.StationVisit]
+	Stations *Stations
+}
+
+func (r *Visits) Add(rows ...model.StationVisit) { r.t.add(rows...) }
+func (r *Visits) All() []model.StationVisit      { return r.t.all() }
+
+func (r *Visits) Open(ctx context.Context) ([]model.StationVisit, error) {
+	open := r.t.filter(func(v *model.StationVisit) bool { return v.DepartureTime == nil })
+	if r.Stations == nil {
+		return open, nil
+	}
+
+	names := r.Stations.names()
+	// the join drops visits of deleted stations
+	out := open[:0]
+	for _, v := range open {
+		name, ok := names[v.StationId]
+		if !ok {
+			continue
+		}
+		v.StationName = name
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+func (r *Visits) Create(ctx context.Context, v *model.StationVisit) error {
+	r.t.insert(v, func(v *model.StationVisit, id int64) { v.Id = id })
+	return nil
+}
+
+// Close needs the departure and dwell; Postgres would store a missing one as NULL and
+// leave the visit looking open.
+func (r *Visits) Close(ctx context.Context, v *model.StationVisit) error {
+	if v.DepartureTime == nil || v.DwellS == nil {
+		return errors.New("closing a station visit needs departure_time and dwell_s")
+	}
+
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+
+	for i := range r.t.rows {
+		row := &r.t.rows[i]
+		if row.VehicleId != v.VehicleId || row.StationId != v.StationId || row.DepartureTime != nil {
+			continue
+		}
+		departure, dwell := *v.DepartureTime, *v.DwellS
+		row.DepartureTime = &departure
+		row.DwellS = &dwell
+		row.MinDistanceM = v.MinDistanceM
+		row.PointCount = v.PointCount
+	}
+
+	return nil
+}
+
+func (r *Visits) List(ctx context.Context, f repository.Filter, q repository.VisitQuery) ([]model.StationVisit, error) {
+	names := r.Stations.names()
+	out := r.t.filter(func(v *model.StationVisit) bool {
+		return joined(names, v.StationId) && f.Allows(v.VehicleId, v.OperatorId) &&
+			matchesStationQuery(q.StationQuery, v.VehicleId, v.StationId, v.ArrivalTime, v.Id) &&
+			(q.Open == nil || (v.DepartureTime == nil) == *q.Open)
+	})
+	for i := range out {
+		out[i].StationName = names[out[i].StationId]
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return keysetLess(out[i].ArrivalTime, out[i].Id, out[j].ArrivalTime, out[j].Id)
+	})
+
+	return limit(out, q.Limit), nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	model "tj/pkg/model"
+)
+
+func TestClosingAVisitNeedsDepartureAndDwell(t *testing.T) {
+	ctx := context.Background()
+	r := &Visits{}
+	if err := r.Create(ctx, &model.StationVisit{VehicleId: "bus-1", StationId: 1, ArrivalTime: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Close(ctx, &model.StationVisit{VehicleId: "bus-1", StationId: 1}); err == nil {
+		t.Error("closed without departure_time and dwell_s")
+	}
+	if v := r.All(); v[0].DepartureTime != nil {
+		t.Errorf("visit %+v changed by the failed Close", v[0])
+	}
+
+	departure, dwell := int64(1060), int64(60)
+	if err := r.Close(ctx, &model.StationVisit{VehicleId: "bus-1", StationId: 1, DepartureTime: &departure, DwellS: &dwell}); err != nil {
+		t.Fatal(err)
+	}
+	if v := r.All(); v[0].DepartureTime == nil || *v[0].DepartureTime != 1060 || *v[0].DwellS != 60 {
+		t.Errorf("visit %+v, want it closed at 1060", v[0])
+	}
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+)
+
+type Trips struct {
+	t table[model.VehicleTrip]
+}
+
+func (r *Trips) Add(rows ...model.VehicleTrip) { r.t.add(rows...) }
+func (r *Trips) All() []model.VehicleTrip      { return r.t.all() }
+
+func (r *Trips) Create(ctx context.Context, t *model.VehicleTrip) error {
+	r.t.insert(t, func(t *model.VehicleTrip, id int64) { t.Id = id })
+	return nil
+}
+
+func (r *Trips) List(ctx context.Context, f repository.Filter, q repository.TripQuery) ([]model.VehicleTrip, error) {
+	out := r.t.filter(func(t *model.VehicleTrip) bool {
+		return t.VehicleId == q.VehicleId && f.Allows(t.VehicleId, t.OperatorId) && q.Range.Contains(t.StartTime)
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].StartTime > out[j].StartTime })
+
+	return limit(out, q.Limit), nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+
+	model "tj/pkg/model"
+)
+
+type Vehicles struct {
+	t table[model.Vehicle]
+}
+
+func (r *Vehicles) Add(rows ...model.Vehicle) { r.t.add(rows...) }
+func (r *Vehicles) All() []model.Vehicle      { return r.t.all() }
+
+func (r *Vehicles) List(ctx context.Context) ([]model.Vehicle, error) {
+	return r.t.all(), nil
+}
+
+func (r *Vehicles) Claim(ctx context.Context, vehicleId, operatorId string) (bool, error) {
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+
+	for i := range r.t.rows {
+		v := &r.t.rows[i]
+		if v.VehicleId != vehicleId {
+			continue
+		}
+		if v.OperatorId != nil {
+			return false, nil
+		}
+		v.OperatorId = &operatorId
+		return true, nil
+	}
+	r.t.rows = append(r.t.rows, model.Vehicle{VehicleId: vehicleId, OperatorId: &operatorId})
+
+	return true, nil
+}
+
+type Operators struct {
+	t table[model.Operator]
+}
+
+func (r *Operators) Add(rows ...model.Operator) { r.t.add(rows...) }
+
+func (r *Operators) List(ctx context.Context) ([]model.Operator, error) {
+	return r.t.all(), nil
+}
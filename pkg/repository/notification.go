@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+type NotificationRepository interface {
+	EnabledSubscribers(ctx context.Context) ([]model.NotificationSubscriber, error)
+	RecordDelivery(ctx context.Context, d *model.NotificationDelivery) error
+	ResetFailures(ctx context.Context, subscriberId int64) error
+	CountFailure(ctx context.Context, subscriberId int64) error
+	// Disable turns the subscriber off once its failure streak reaches minFailures. It
+	// reports whether this call disabled it.
+	Disable(ctx context.Context, subscriberId int64, reason string, minFailures int) (bool, error)
+}
+
+type notifications struct {
+	db *gorm.DB
+}
+
+func (r *notifications) EnabledSubscribers(ctx context.Context) ([]model.NotificationSubscriber, error) {
+	var rows []model.NotificationSubscriber
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *notifications) RecordDelivery(ctx context.Context, d *model.NotificationDelivery) error {
+	return r.db.WithContext(ctx).Create(d).Error
+}
+
+func (r *notifications) ResetFailures(ctx context.Context, subscriberId int64) error {
+	return r.db.WithContext(ctx).Exec(`UPDATE notification_subscribers SET consecutive_failures = 0
+		WHERE id = ? AND consecutive_failures > 0`, subscriberId).Error
+}
+
+func (r *notifications) CountFailure(ctx context.Context, subscriberId int64) error {
+	return r.db.WithContext(ctx).Exec(`UPDATE notification_subscribers SET consecutive_failures = consecutive_failures + 1
+		WHERE id = ?`, subscriberId).Error
+}
+
+func (r *notifications) Disable(ctx context.Context, subscriberId int64, reason string, minFailures int) (bool, error) {
+	res := r.db.WithContext(ctx).Exec(`UPDATE notification_subscribers
+		SET enabled = FALSE, disabled_at = NOW(), disabled_reason = ?, updated_at = NOW()
+		WHERE id = ? AND enabled AND consecutive_failures >= ?`, reason, subscriberId, minFailures)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	model "tj/pkg/model"
+)
+
+func newMock(t *testing.T) (*Repositories, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return NewPostgres(gdb), mock
+}
+
+func TestClaimTakesOnlyUnownedVehicles(t *testing.T) {
+	claim := regexp.QuoteMeta(`INSERT INTO "vehicles" ("vehicle_id","operator_id","created_at","updated_at") VALUES ($1,$2,$3,$4) ` +
+		`ON CONFLICT ("vehicle_id") DO UPDATE SET "operator_id"=$5 WHERE vehicles.operator_id IS NULL`)
+
+	cases := []struct {
+		name     string
+		affected int64
+		want     bool
+	}{
+		{"new or unowned", 1, true},
+		{"owned by another operator", 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repos, mock := newMock(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(claim).
+				WithArgs("bus-1", "op-a", sqlmock.AnyArg(), sqlmock.AnyArg(), "op-a").
+				WillReturnResult(sqlmock.NewResult(0, tc.affected))
+			mock.ExpectCommit()
+
+			got, err := repos.Vehicles.Claim(context.Background(), "bus-1", "op-a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Claim = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCloseUpdatesTheOpenVisit(t *testing.T) {
+	repos, mock := newMock(t)
+	departure, dwell := int64(1060), int64(60)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "station_visits" SET "departure_time"=$1,"dwell_s"=$2,"min_distance_m"=$3,"point_count"=$4,"updated_at"=$5 `+
+		`WHERE vehicle_id = $6 AND station_id = $7 AND departure_time IS NULL`)).
+		WithArgs(departure, dwell, 12.5, 7, sqlmock.AnyArg(), "bus-1", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repos.Visits.Close(context.Background(), &model.StationVisit{
+		VehicleId:     "bus-1",
+		StationId:     3,
+		DepartureTime: &departure,
+		DwellS:        &dwell,
+		MinDistanceM:  12.5,
+		PointCount:    7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackReadsTheHalfOpenWindowOldestFirst(t *testing.T) {
+	repos, mock := newMock(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vehicle_locations" `+
+		`WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp < $3 ORDER BY timestamp ASC`)).
+		WithArgs("bus-1", int64(1000), int64(1600)).
+		WillReturnRows(sqlmock.NewRows([]string{"vehicle_id", "latitude", "longitude", "timestamp"}).
+			AddRow("bus-1", -6.2, 106.8, 1000).
+			AddRow("bus-1", -6.21, 106.81, 1030))
+
+	points, err := repos.Locations.Track(context.Background(), "bus-1", 1000, 1600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Timestamp != 1000 || points[1].Timestamp != 1030 {
+		t.Errorf("Track = %+v, want the two points in order", points)
+	}
+}
+
+func TestListsApplyTheCallerFilter(t *testing.T) {
+	repos, mock := newMock(t)
+	op := "op-a"
+	f := Filter{OperatorId: &op, Scoped: true, VehicleIds: []string{"bus-1"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT geofence_events.*, bus_stations.name AS station_name FROM "geofence_events" `+
+		`JOIN bus_stations ON bus_stations.id = geofence_events.station_id `+
+		`WHERE geofence_events.operator_id = $1 AND geofence_events.vehicle_id IN ($2) `+
+		`AND geofence_events.event_type = $3 ORDER BY geofence_events.timestamp ASC,geofence_events.id ASC LIMIT $4`)).
+		WithArgs("op-a", "bus-1", model.GeofenceEntry, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repos.GeofenceEvents.List(context.Background(), f, EventQuery{
+		StationQuery: StationQuery{Limit: 11},
+		EventType:    model.GeofenceEntry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by reads of a single row that doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Filter limits reads to what an API caller may see: the rows of one operator and, when
+// Scoped, of the listed vehicles. The zero Filter sees everything.
+type Filter struct {
+	OperatorId *string
+	Scoped     bool
+	VehicleIds []string
+}
+
+// apply adds the filter to a query on table.
+func (f Filter) apply(q *gorm.DB, table string) *gorm.DB {
+	if f.OperatorId != nil {
+		q = q.Where(table+".operator_id = ?", *f.OperatorId)
+	}
+	if f.Scoped {
+		q = q.Where(table+".vehicle_id IN ?", f.VehicleIds)
+	}
+
+	return q
+}
+
+// Keyset is the (time, id) of the last row of a page; the next page starts after it.
+type Keyset struct {
+	Timestamp int64
+	Id        int64
+}
+
+// TimeRange bounds a listing on unix seconds, nil is unbounded. Both ends are inclusive.
+type TimeRange struct {
+	Start *int64
+	End   *int64
+}
+
+func (tr TimeRange) apply(q *gorm.DB, col string) *gorm.DB {
+	if tr.Start != nil {
+		q = q.Where(col+" >= ?", *tr.Start)
+	}
+	if tr.End != nil {
+		q = q.Where(col+" <= ?", *tr.End)
+	}
+
+	return q
+}
+
+// Contains reports whether ts is inside the range, for implementations outside Postgres.
+func (tr TimeRange) Contains(ts int64) bool {
+	return (tr.Start == nil || ts >= *tr.Start) && (tr.End == nil || ts <= *tr.End)
+}
+
+// Allows reports whether the filter lets a row of the vehicle and operator through, for
+// implementations outside Postgres.
+func (f Filter) Allows(vehicleId string, operatorId *string) bool {
+	if f.OperatorId != nil && (operatorId == nil || *operatorId != *f.OperatorId) {
+		return false
+	}
+	if !f.Scoped {
+		return true
+	}
+	for _, id := range f.VehicleIds {
+		if id == vehicleId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+// Package repository is the storage layer behind the subscriber, worker and notifier, and
+// behind the API's vehicle and geofence reads. Controllers depend on the interfaces;
+// NewPostgres backs them with gorm, package memory with plain slices for tests that run
+// without Postgres.
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// Repositories is what a service's main.go builds once and hands to its controllers.
+type Repositories struct {
+	Locations      LocationRepository
+	Vehicles       VehicleRepository
+	Operators      OperatorRepository
+	Stations       StationRepository
+	GeofenceEvents GeofenceEventRepository
+	Visits         VisitRepository
+	Trips          TripRepository
+	Rules          RuleRepository
+	SpeedZones     SpeedZoneRepository
+	Notifications  NotificationRepository
+}
+
+func NewPostgres(dbConn *gorm.DB) *Repositories {
+	return &Repositories{
+		Locations:      &locations{db: dbConn},
+		Vehicles:       &vehicles{db: dbConn},
+		Operators:      &operators{db: dbConn},
+		Stations:       &stations{db: dbConn},
+		GeofenceEvents: &geofenceEvents{db: dbConn},
+		Visits:         &visits{db: dbConn},
+		Trips:          &trips{db: dbConn},
+		Rules:          &alertRules{db: dbConn},
+		SpeedZones:     &speedZones{db: dbConn},
+		Notifications:  &notifications{db: dbConn},
+	}
+}
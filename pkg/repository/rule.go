@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+type RuleRepository interface {
+	Enabled(ctx context.Context) ([]model.AlertRule, error)
+}
+
+type SpeedZoneRepository interface {
+	List(ctx context.Context) ([]model.SpeedZone, error)
+}
+
+type alertRules struct {
+	db *gorm.DB
+}
+
+func (r *alertRules) Enabled(ctx context.Context) ([]model.AlertRule, error) {
+	var rows []model.AlertRule
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Find(&rows).Error
+
+	return rows, err
+}
+
+type speedZones struct {
+	db *gorm.DB
+}
+
+func (r *speedZones) List(ctx context.Context) ([]model.SpeedZone, error) {
+	var rows []model.SpeedZone
+	err := r.db.WithContext(ctx).Find(&rows).Error
+
+	return rows, err
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+type StationRepository interface {
+	// Visible returns the shared stations plus, when operatorId is set, that operator's own.
+	Visible(ctx context.Context, operatorId *string) ([]model.BusStation, error)
+}
+
+type GeofenceEventRepository interface {
+	Create(ctx context.Context, ev *model.GeofenceEvent) error
+	// List returns events with their station names, ordered by (timestamp, id).
+	List(ctx context.Context, f Filter, q EventQuery) ([]model.GeofenceEvent, error)
+}
+
+// StationQuery holds the filters shared by the event and visit listings. Range bounds the
+// event time or the visit arrival.
+type StationQuery struct {
+	// "" is every vehicle
+	VehicleId string
+	StationId *int64
+	Range     TimeRange
+	After     *Keyset
+	Limit     int
+}
+
+type EventQuery struct {
+	StationQuery
+	// entry or exit, "" is both
+	EventType string
+}
+
+type VisitQuery struct {
+	StationQuery
+	// only open (true) or closed (false) visits, nil is both
+	Open *bool
+}
+
+type VisitRepository interface {
+	// Open returns the visits without a departure, with their station names.
+	Open(ctx context.Context) ([]model.StationVisit, error)
+	Create(ctx context.Context, v *model.StationVisit) error
+	// Close sets the departure, dwell and final stats on the vehicle's open visit of the
+	// station.
+	Close(ctx context.Context, v *model.StationVisit) error
+	// List returns visits with their station names, ordered by (arrival_time, id).
+	List(ctx context.Context, f Filter, q VisitQuery) ([]model.StationVisit, error)
+}
+
+// apply adds the filters, keyset and ordering on table.timeCol.
+func (sq StationQuery) apply(q *gorm.DB, table, timeCol string) *gorm.DB {
+	col := table + "." + timeCol
+	if sq.VehicleId != "" {
+		q = q.Where(table+".vehicle_id = ?", sq.VehicleId)
+	}
+	if sq.StationId != nil {
+		q = q.Where(table+".station_id = ?", *sq.StationId)
+	}
+	q = sq.Range.apply(q, col)
+	if sq.After != nil {
+		q = q.Where("("+col+", "+table+".id) > (?, ?)", sq.After.Timestamp, sq.After.Id)
+	}
+
+	return q.Order(col + " ASC").Order(table + ".id ASC").Limit(sq.Limit)
+}
+
+type stations struct {
+	db *gorm.DB
+}
+
+func (r *stations) Visible(ctx context.Context, operatorId *string) ([]model.BusStation, error) {
+	var rows []model.BusStation
+	q := r.db.WithContext(ctx).Where("operator_id IS NULL")
+	if operatorId != nil {
+		q = q.Or("operator_id = ?", *operatorId)
+	}
+	err := q.Find(&rows).Error
+
+	return rows, err
+}
+
+type geofenceEvents struct {
+	db *gorm.DB
+}
+
+func (r *geofenceEvents) Create(ctx context.Context, ev *model.GeofenceEvent) error {
+	return r.db.WithContext(ctx).Create(ev).Error
+}
+
+func (r *geofenceEvents) List(ctx context.Context, f Filter, q EventQuery) ([]model.GeofenceEvent, error) {
+	db := r.db.WithContext(ctx).Model(&model.GeofenceEvent{}).
+		Select("geofence_events.*, bus_stations.name AS station_name").
+		Joins("JOIN bus_stations ON bus_stations.id = geofence_events.station_id")
+	db = q.apply(f.apply(db, "geofence_events"), "geofence_events", "timestamp")
+	if q.EventType != "" {
+		db = db.Where("geofence_events.event_type = ?", q.EventType)
+	}
+
+	var rows []model.GeofenceEvent
+	err := db.Find(&rows).Error
+
+	return rows, err
+}
+
+type visits struct {
+	db *gorm.DB
+}
+
+func (r *visits) Open(ctx context.Context) ([]model.StationVisit, error) {
+	var rows []model.StationVisit
+	err := r.db.WithContext(ctx).Select("station_visits.*, bus_stations.name AS station_name").
+		Joins("JOIN bus_stations ON bus_stations.id = station_visits.station_id").
+		Where("station_visits.departure_time IS NULL").
+		Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *visits) Create(ctx context.Context, v *model.StationVisit) error {
+	return r.db.WithContext(ctx).Create(v).Error
+}
+
+func (r *visits) Close(ctx context.Context, v *model.StationVisit) error {
+	return r.db.WithContext(ctx).Model(&model.StationVisit{}).
+		Where("vehicle_id = ? AND station_id = ? AND departure_time IS NULL", v.VehicleId, v.StationId).
+		Updates(map[string]interface{}{
+			"departure_time": v.DepartureTime,
+			"dwell_s":        v.DwellS,
+			"min_distance_m": v.MinDistanceM,
+			"point_count":    v.PointCount,
+			"updated_at":     r.db.NowFunc(),
+		}).Error
+}
+
+func (r *visits) List(ctx context.Context, f Filter, q VisitQuery) ([]model.StationVisit, error) {
+	db := r.db.WithContext(ctx).Model(&model.StationVisit{}).
+		Select("station_visits.*, bus_stations.name AS station_name").
+		Joins("JOIN bus_stations ON bus_stations.id = station_visits.station_id")
+	db = q.apply(f.apply(db, "station_visits"), "station_visits", "arrival_time")
+	if q.Open != nil {
+		if *q.Open {
+			db = db.Where("station_visits.departure_time IS NULL")
+		} else {
+			db = db.Where("station_visits.departure_time IS NOT NULL")
+		}
+	}
+
+	var rows []model.StationVisit
+	err := db.Find(&rows).Error
+
+	return rows, err
+}
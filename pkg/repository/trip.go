@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+type TripRepository interface {
+	Create(ctx context.Context, t *model.VehicleTrip) error
+	// List returns a vehicle's trips that started in the range, newest first.
+	List(ctx context.Context, f Filter, q TripQuery) ([]model.VehicleTrip, error)
+}
+
+type TripQuery struct {
+	VehicleId string
+	// bounds the start time
+	Range TimeRange
+	Limit int
+}
+
+type trips struct {
+	db *gorm.DB
+}
+
+func (r *trips) Create(ctx context.Context, t *model.VehicleTrip) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+func (r *trips) List(ctx context.Context, f Filter, q TripQuery) ([]model.VehicleTrip, error) {
+	db := f.apply(r.db.WithContext(ctx), "vehicle_trips").Where("vehicle_id = ?", q.VehicleId)
+
+	var rows []model.VehicleTrip
+	err := q.Range.apply(db, "start_time").Order("start_time DESC").Limit(q.Limit).Find(&rows).Error
+
+	return rows, err
+}
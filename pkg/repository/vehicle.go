@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	model "tj/pkg/model"
+)
+
+type VehicleRepository interface {
+	List(ctx context.Context) ([]model.Vehicle, error)
+	// Claim registers the vehicle to the operator unless another operator owns it already.
+	// It reports false when the vehicle was taken.
+	Claim(ctx context.Context, vehicleId, operatorId string) (bool, error)
+}
+
+type OperatorRepository interface {
+	List(ctx context.Context) ([]model.Operator, error)
+}
+
+type vehicles struct {
+	db *gorm.DB
+}
+
+func (r *vehicles) List(ctx context.Context) ([]model.Vehicle, error) {
+	var rows []model.Vehicle
+	err := r.db.WithContext(ctx).Find(&rows).Error
+
+	return rows, err
+}
+
+func (r *vehicles) Claim(ctx context.Context, vehicleId, operatorId string) (bool, error) {
+	// claim unowned vehicles only, another replica may have registered it meanwhile
+	v := model.Vehicle{VehicleId: vehicleId, OperatorId: &operatorId}
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "vehicle_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"operator_id": operatorId}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "vehicles.operator_id IS NULL"}}},
+	}).Select("vehicle_id", "operator_id").Create(&v)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+type operators struct {
+	db *gorm.DB
+}
+
+func (r *operators) List(ctx context.Context) ([]model.Operator, error) {
+	var rows []model.Operator
+	err := r.db.WithContext(ctx).Find(&rows).Error
+
+	return rows, err
+}
@@ -0,0 +1,330 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// alpine images ship without zoneinfo
+	_ "time/tzdata"
+
+	geopkg "tj/pkg/geofence"
+	model "tj/pkg/model"
+)
+
+const (
+	CondGeofence     = "geofence"
+	CondTimeOfDay    = "time_of_day"
+	CondSpeed        = "speed"
+	CondVehicleGroup = "vehicle_group"
+	CondVehicle      = "vehicle"
+	CondIgnition     = "ignition"
+)
+
+const defaultGeofenceRadius = 50.0
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// Input is everything a rule can look at for one location point.
+type Input struct {
+	VehicleId string
+	Group     string
+	Latitude  float64
+	Longitude float64
+	Timestamp int64
+	SpeedKmh  *float64
+	Ignition  *bool
+	Stations  []model.BusStation
+}
+
+// Firing is produced when all conditions of a rule have held for its duration.
+type Firing struct {
+	Rule      model.AlertRule
+	VehicleId string
+	Since     int64
+	Input     Input
+}
+
+type ruleState struct {
+	// start of the current episode, 0 while the conditions don't hold
+	since     int64
+	fired     bool
+	lastFired int64
+}
+
+// Engine evaluates alert rules against a location stream, tracking per rule and vehicle how
+// long the conditions have held. Not safe for concurrent use.
+type Engine struct {
+	rules []model.AlertRule
+	state map[int64]map[string]*ruleState
+}
+
+func NewEngine() *Engine {
+	return &Engine{state: make(map[int64]map[string]*ruleState)}
+}
+
+// SetRules replaces the rule set, keeping duration state for rules that still exist.
+func (e *Engine) SetRules(rules []model.AlertRule) {
+	keep := make(map[int64]bool, len(rules))
+	for _, r := range rules {
+		keep[r.Id] = true
+	}
+	for id := range e.state {
+		if !keep[id] {
+			delete(e.state, id)
+		}
+	}
+
+	e.rules = rules
+}
+
+func (e *Engine) Evaluate(in Input) []Firing {
+	var firings []Firing
+
+	for _, r := range e.rules {
+		if !r.Enabled {
+			continue
+		}
+
+		vehicles, ok := e.state[r.Id]
+		if !ok {
+			vehicles = make(map[string]*ruleState)
+			e.state[r.Id] = vehicles
+		}
+
+		st, ok := vehicles[in.VehicleId]
+		if !Matches(r.Conditions, in) {
+			// conditions broke: re-arm the rule for the next episode, keeping the cooldown
+			if ok {
+				st.since, st.fired = 0, false
+			}
+			continue
+		}
+
+		if !ok {
+			st = &ruleState{}
+			vehicles[in.VehicleId] = st
+		}
+		if st.since == 0 {
+			st.since = in.Timestamp
+		}
+		if st.fired || in.Timestamp-st.since < r.DurationS {
+			continue
+		}
+		if st.lastFired != 0 && in.Timestamp-st.lastFired < r.CooldownS {
+			continue
+		}
+
+		st.fired = true
+		st.lastFired = in.Timestamp
+		firings = append(firings, Firing{Rule: r, VehicleId: in.VehicleId, Since: st.since, Input: in})
+	}
+
+	return firings
+}
+
+// Matches reports whether every condition holds for the input.
+func Matches(conds model.RuleConditions, in Input) bool {
+	for _, c := range conds {
+		if !matchCondition(c, in) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchCondition(c model.RuleCondition, in Input) bool {
+	switch c.Type {
+	case CondGeofence:
+		radius := c.RadiusM
+		if radius <= 0 {
+			radius = defaultGeofenceRadius
+		}
+		inside := false
+		for _, st := range in.Stations {
+			if !containsInt(c.StationIds, st.Id) {
+				continue
+			}
+			if geopkg.HaversineMeters(in.Latitude, in.Longitude, st.Latitude, st.Longitude) <= radius {
+				inside = true
+				break
+			}
+		}
+		if c.Inside != nil && !*c.Inside {
+			return !inside
+		}
+		return inside
+
+	case CondTimeOfDay:
+		loc, err := loadLocation(c.Timezone)
+		if err != nil {
+			return false
+		}
+		from, _ := parseClock(c.From)
+		to, _ := parseClock(c.To)
+		t := time.Unix(in.Timestamp, 0).In(loc)
+		now := t.Hour()*60 + t.Minute()
+		if from <= to {
+			return now >= from && now < to
+		}
+		return now >= from || now < to
+
+	case CondSpeed:
+		if in.SpeedKmh == nil || c.Value == nil {
+			return false
+		}
+		v, limit := *in.SpeedKmh, *c.Value
+		switch c.Op {
+		case ">":
+			return v > limit
+		case ">=":
+			return v >= limit
+		case "<":
+			return v < limit
+		case "<=":
+			return v <= limit
+		}
+		return false
+
+	case CondVehicleGroup:
+		return containsString(c.Groups, in.Group)
+
+	case CondVehicle:
+		return containsString(c.VehicleIds, in.VehicleId)
+
+	case CondIgnition:
+		return in.Ignition != nil && c.Ignition != nil && *in.Ignition == *c.Ignition
+	}
+
+	return false
+}
+
+// Validate checks a rule before it is stored, so the engine never sees a rule it can't evaluate.
+func Validate(r *model.AlertRule) error {
+	if !keyPattern.MatchString(r.Key) {
+		return fmt.Errorf("key must match %s", keyPattern.String())
+	}
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Severity {
+	case "info", "warning", "critical":
+	default:
+		return fmt.Errorf("severity must be info, warning or critical")
+	}
+	if r.DurationS < 0 || r.CooldownS < 0 {
+		return fmt.Errorf("duration_s and cooldown_s must not be negative")
+	}
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("at least one condition is required")
+	}
+
+	for i, c := range r.Conditions {
+		if err := validateCondition(c); err != nil {
+			return fmt.Errorf("conditions[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCondition(c model.RuleCondition) error {
+	switch c.Type {
+	case CondGeofence:
+		if len(c.StationIds) == 0 {
+			return fmt.Errorf("geofence needs station_ids")
+		}
+	case CondTimeOfDay:
+		if _, err := parseClock(c.From); err != nil {
+			return fmt.Errorf("invalid from: %w", err)
+		}
+		if _, err := parseClock(c.To); err != nil {
+			return fmt.Errorf("invalid to: %w", err)
+		}
+		if _, err := loadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", c.Timezone)
+		}
+	case CondSpeed:
+		switch c.Op {
+		case ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("speed op must be >, >=, < or <=")
+		}
+		if c.Value == nil {
+			return fmt.Errorf("speed needs value")
+		}
+	case CondVehicleGroup:
+		if len(c.Groups) == 0 {
+			return fmt.Errorf("vehicle_group needs groups")
+		}
+	case CondVehicle:
+		if len(c.VehicleIds) == 0 {
+			return fmt.Errorf("vehicle needs vehicle_ids")
+		}
+	case CondIgnition:
+		if c.Ignition == nil {
+			return fmt.Errorf("ignition needs ignition")
+		}
+	default:
+		return fmt.Errorf("unknown condition type %q", c.Type)
+	}
+
+	return nil
+}
+
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+
+	return loc, nil
+}
+
+// parseClock turns "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+
+	return h*60 + m, nil
+}
+
+func containsInt(vals []int64, v int64) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(vals []string, v string) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,224 @@
+package rules
+
+import (
+	"testing"
+
+	model "tj/pkg/model"
+)
+
+// step is one point of bus-1, ts seconds after t0: fast or not, and whether the rule
+// should fire on it.
+type step struct {
+	ts   int64
+	fast bool
+	fire bool
+}
+
+func speedingRule(durationS, cooldownS int64) model.AlertRule {
+	limit := 80.0
+	return model.AlertRule{
+		Id: 1, Key: "speeding", Enabled: true, DurationS: durationS, CooldownS: cooldownS,
+		Conditions: model.RuleConditions{{Type: CondSpeed, Op: ">", Value: &limit}},
+	}
+}
+
+// t0 is where the steps' clock starts, the engine never sees a zero timestamp.
+const t0 = 1700000000
+
+func point(vehicleId string, ts int64, fast bool) Input {
+	speed := 50.0
+	if fast {
+		speed = 100
+	}
+	return Input{VehicleId: vehicleId, Timestamp: t0 + ts, SpeedKmh: &speed}
+}
+
+func TestEngineFiresOncePerEpisode(t *testing.T) {
+	cases := []struct {
+		name                 string
+		durationS, cooldownS int64
+		steps                []step
+	}{
+		{
+			name: "fires on the first match without duration",
+			steps: []step{
+				{ts: 0, fast: false},
+				{ts: 10, fast: true, fire: true},
+			},
+		},
+		{
+			name: "holds for duration_s before firing", durationS: 60,
+			steps: []step{
+				{ts: 0, fast: true},
+				{ts: 30, fast: true},
+				{ts: 59, fast: true},
+				{ts: 60, fast: true, fire: true},
+			},
+		},
+		{
+			name: "keeps quiet while the episode lasts", durationS: 30,
+			steps: []step{
+				{ts: 0, fast: true},
+				{ts: 30, fast: true, fire: true},
+				{ts: 60, fast: true},
+				{ts: 600, fast: true},
+			},
+		},
+		{
+			name: "a break restarts the duration", durationS: 60,
+			steps: []step{
+				{ts: 0, fast: true},
+				{ts: 50, fast: false},
+				{ts: 60, fast: true},
+				{ts: 100, fast: true},
+				{ts: 120, fast: true, fire: true},
+			},
+		},
+		{
+			name: "a break re-arms the rule for the next episode",
+			steps: []step{
+				{ts: 0, fast: true, fire: true},
+				{ts: 10, fast: false},
+				{ts: 20, fast: true, fire: true},
+			},
+		},
+		{
+			name: "cooldown_s holds back the next episode", cooldownS: 300,
+			steps: []step{
+				{ts: 0, fast: true, fire: true},
+				{ts: 10, fast: false},
+				{ts: 20, fast: true},
+				{ts: 299, fast: true},
+				// the episode still holds when the cooldown ends
+				{ts: 300, fast: true, fire: true},
+				{ts: 310, fast: false},
+				{ts: 700, fast: true, fire: true},
+			},
+		},
+		{
+			name: "cooldown_s counts from the last firing, not the episode start", durationS: 60, cooldownS: 100,
+			steps: []step{
+				{ts: 0, fast: true},
+				{ts: 60, fast: true, fire: true},
+				{ts: 70, fast: false},
+				{ts: 80, fast: true},
+				// held 60s, but only 80s since firing
+				{ts: 140, fast: true},
+				{ts: 160, fast: true, fire: true},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEngine()
+			e.SetRules([]model.AlertRule{speedingRule(tc.durationS, tc.cooldownS)})
+
+			for _, s := range tc.steps {
+				firings := e.Evaluate(point("bus-1", s.ts, s.fast))
+				if fired := len(firings) > 0; fired != s.fire {
+					t.Fatalf("at %d: fired %v, want %v", s.ts, fired, s.fire)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineFiringCarriesTheEpisodeStart(t *testing.T) {
+	e := NewEngine()
+	e.SetRules([]model.AlertRule{speedingRule(60, 0)})
+
+	e.Evaluate(point("bus-1", 100, true))
+	firings := e.Evaluate(point("bus-1", 170, true))
+	if len(firings) != 1 {
+		t.Fatalf("%d firings, want 1", len(firings))
+	}
+	if f := firings[0]; f.Since != t0+100 || f.VehicleId != "bus-1" || f.Rule.Key != "speeding" || f.Input.Timestamp != t0+170 {
+		t.Errorf("firing %+v, want bus-1 speeding since 100", f)
+	}
+}
+
+func TestEngineTracksVehiclesApart(t *testing.T) {
+	e := NewEngine()
+	e.SetRules([]model.AlertRule{speedingRule(60, 0)})
+
+	e.Evaluate(point("bus-1", 0, true))
+	e.Evaluate(point("bus-2", 50, true))
+	if f := e.Evaluate(point("bus-2", 60, true)); len(f) != 0 {
+		t.Errorf("bus-2 fired after 10s on bus-1's episode: %+v", f)
+	}
+	if f := e.Evaluate(point("bus-1", 60, true)); len(f) != 1 {
+		t.Errorf("bus-1 didn't fire after 60s")
+	}
+}
+
+func TestEngineSkipsDisabledRules(t *testing.T) {
+	e := NewEngine()
+	r := speedingRule(0, 0)
+	r.Enabled = false
+	e.SetRules([]model.AlertRule{r})
+
+	if f := e.Evaluate(point("bus-1", 0, true)); len(f) != 0 {
+		t.Errorf("disabled rule fired: %+v", f)
+	}
+}
+
+func TestSetRulesKeepsStateOfRemainingRules(t *testing.T) {
+	e := NewEngine()
+	kept, dropped := speedingRule(60, 0), speedingRule(60, 0)
+	dropped.Id, dropped.Key = 2, "speeding_again"
+	e.SetRules([]model.AlertRule{kept, dropped})
+	e.Evaluate(point("bus-1", 0, true))
+
+	// a rule that comes back starts over, the one that stayed keeps its episode
+	e.SetRules([]model.AlertRule{kept})
+	e.SetRules([]model.AlertRule{kept, dropped})
+	firings := e.Evaluate(point("bus-1", 60, true))
+	if len(firings) != 1 || firings[0].Rule.Id != kept.Id {
+		t.Errorf("firings %+v, want only rule %d", firings, kept.Id)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	on, off, outside := true, false, false
+	station := model.BusStation{Id: 7, Latitude: -6.2, Longitude: 106.8}
+	// 2024-01-01 23:30 in Jakarta
+	lateEvening := int64(1704126600)
+
+	cases := []struct {
+		name string
+		cond model.RuleCondition
+		in   Input
+		want bool
+	}{
+		{"inside a station", model.RuleCondition{Type: CondGeofence, StationIds: []int64{7}},
+			Input{Latitude: -6.2, Longitude: 106.8, Stations: []model.BusStation{station}}, true},
+		{"beyond the default radius", model.RuleCondition{Type: CondGeofence, StationIds: []int64{7}},
+			Input{Latitude: -6.201, Longitude: 106.8, Stations: []model.BusStation{station}}, false},
+		{"inside a wider radius", model.RuleCondition{Type: CondGeofence, StationIds: []int64{7}, RadiusM: 200},
+			Input{Latitude: -6.201, Longitude: 106.8, Stations: []model.BusStation{station}}, true},
+		{"outside wanted", model.RuleCondition{Type: CondGeofence, StationIds: []int64{7}, Inside: &outside},
+			Input{Latitude: -6.3, Longitude: 106.8, Stations: []model.BusStation{station}}, true},
+		{"window across midnight", model.RuleCondition{Type: CondTimeOfDay, From: "22:00", To: "05:00", Timezone: "Asia/Jakarta"},
+			Input{Timestamp: lateEvening}, true},
+		{"outside the window", model.RuleCondition{Type: CondTimeOfDay, From: "06:00", To: "22:00", Timezone: "Asia/Jakarta"},
+			Input{Timestamp: lateEvening}, false},
+		{"speed unknown", model.RuleCondition{Type: CondSpeed, Op: "<", Value: new(float64)},
+			Input{}, false},
+		{"ignition off", model.RuleCondition{Type: CondIgnition, Ignition: &off},
+			Input{Ignition: &off}, true},
+		{"ignition unknown", model.RuleCondition{Type: CondIgnition, Ignition: &on},
+			Input{}, false},
+		{"group", model.RuleCondition{Type: CondVehicleGroup, Groups: []string{"night"}},
+			Input{Group: "night"}, true},
+		{"unknown type", model.RuleCondition{Type: "weather"}, Input{}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Matches(model.RuleConditions{tc.cond}, tc.in); got != tc.want {
+				t.Errorf("Matches = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
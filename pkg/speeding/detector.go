@@ -0,0 +1,138 @@
+package speeding
+
+import (
+	"math"
+
+	geopkg "tj/pkg/geofence"
+)
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Timestamp int64
+	// device-reported speed in km/h, preferred over the derived speed when present
+	Speed *float64
+}
+
+// Limit is the speed limit in force at a point.
+type Limit struct {
+	Kmh  float64
+	Zone string // "global" or the speed zone name
+}
+
+// Episode is one continuous period above the limit that lasted at least MinDurationSec.
+type Episode struct {
+	VehicleId   string
+	Start       Point
+	End         Point
+	PeakKmh     float64
+	Limit       Limit
+	DurationSec int64
+}
+
+type vehicleState struct {
+	last    *Point
+	prev    *Point
+	current *Episode
+	// the episode rests on one derived segment so far, whose end may be a GPS outlier
+	unconfirmed bool
+}
+
+// Detector debounces speeding: an episode is only reported once speed has stayed above the
+// limit for MinDurationSec, so single GPS jumps don't count. Not safe for concurrent use.
+type Detector struct {
+	minDurationSec int64
+	vehicles       map[string]*vehicleState
+}
+
+func NewDetector(minDurationSec int64) *Detector {
+	return &Detector{minDurationSec: minDurationSec, vehicles: make(map[string]*vehicleState)}
+}
+
+// Speed returns the device speed if reported, else the speed derived from the point before
+// p. It gives the same answer before and after p was added.
+func (d *Detector) Speed(vehicleId string, p Point) (float64, bool) {
+	if p.Speed != nil {
+		return *p.Speed, true
+	}
+
+	st, ok := d.vehicles[vehicleId]
+	if !ok {
+		return 0, false
+	}
+	ref := st.last
+	if ref != nil && p.Timestamp == ref.Timestamp {
+		// p itself was added already
+		ref = st.prev
+	}
+	if ref == nil || p.Timestamp <= ref.Timestamp {
+		return 0, false
+	}
+
+	return derivedKmh(*ref, p), true
+}
+
+func derivedKmh(from, to Point) float64 {
+	dist := geopkg.HaversineMeters(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	return dist / float64(to.Timestamp-from.Timestamp) * 3.6
+}
+
+// Add feeds one point with the limit in force there and returns a finished episode, if any.
+func (d *Detector) Add(vehicleId string, p Point, limit Limit) *Episode {
+	speed, ok := d.Speed(vehicleId, p)
+
+	st, exists := d.vehicles[vehicleId]
+	if !exists {
+		st = &vehicleState{}
+		d.vehicles[vehicleId] = st
+	}
+	if st.last != nil && p.Timestamp <= st.last.Timestamp {
+		return nil
+	}
+	prev := st.last
+	st.prev, st.last = prev, &p
+
+	if !ok {
+		return nil
+	}
+
+	if speed > limit.Kmh {
+		if st.current == nil {
+			start := p
+			if prev != nil && p.Speed == nil {
+				// derived speed covers the whole segment, so the episode began at the previous point
+				start = *prev
+			}
+			st.current = &Episode{VehicleId: vehicleId, Start: start, Limit: limit}
+			st.unconfirmed = p.Speed == nil
+		} else if st.unconfirmed {
+			st.unconfirmed = false
+			// a lone outlier makes two fast segments, away and back: slow reporting would
+			// stretch them past the minimum duration. Without it, the ride wasn't fast.
+			if p.Speed == nil && derivedKmh(st.current.Start, p) <= limit.Kmh {
+				st.current = nil
+				return nil
+			}
+		}
+		st.current.End = p
+		st.current.PeakKmh = math.Max(st.current.PeakKmh, speed)
+		// report the strictest limit seen during the episode
+		if limit.Kmh < st.current.Limit.Kmh {
+			st.current.Limit = limit
+		}
+		return nil
+	}
+
+	ep := st.current
+	st.current, st.unconfirmed = nil, false
+	if ep == nil {
+		return nil
+	}
+
+	ep.DurationSec = ep.End.Timestamp - ep.Start.Timestamp
+	if ep.DurationSec < d.minDurationSec {
+		return nil
+	}
+
+	return ep
+}
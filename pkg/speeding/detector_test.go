@@ -0,0 +1,197 @@
+package speeding
+
+import "testing"
+
+var (
+	global = Limit{Kmh: 80, Zone: "global"}
+	school = Limit{Kmh: 40, Zone: "school"}
+)
+
+func kmh(v float64) *float64 { return &v }
+
+// at is a point with a device-reported speed; 0.001° of latitude is about 111 m.
+func at(ts int64, lat float64, speed *float64) Point {
+	return Point{Latitude: lat, Longitude: 106.8, Timestamp: ts, Speed: speed}
+}
+
+type input struct {
+	p     Point
+	limit Limit
+}
+
+// feed adds the points and fails on an episode before the last one, which it returns.
+func feed(t *testing.T, d *Detector, in ...input) *Episode {
+	t.Helper()
+
+	var ep *Episode
+	for i, x := range in {
+		ep = d.Add("bus-1", x.p, x.limit)
+		if ep != nil && i < len(in)-1 {
+			t.Fatalf("episode %+v reported at %d, before it ended", ep, x.p.Timestamp)
+		}
+	}
+
+	return ep
+}
+
+func TestEpisodeIsReportedWhenSpeedDrops(t *testing.T) {
+	d := NewDetector(60)
+
+	ep := feed(t, d,
+		input{at(0, -6.2, kmh(50)), global},
+		input{at(30, -6.2, kmh(90)), global},
+		input{at(60, -6.2, kmh(110)), global},
+		input{at(90, -6.2, kmh(95)), global},
+		input{at(120, -6.2, kmh(70)), global},
+	)
+	if ep == nil {
+		t.Fatal("no episode after 60 s above the limit")
+	}
+	if ep.Start.Timestamp != 30 || ep.End.Timestamp != 90 || ep.DurationSec != 60 || ep.PeakKmh != 110 || ep.Limit != global {
+		t.Errorf("episode %+v, want 30-90 peaking at 110 over the global limit", ep)
+	}
+}
+
+func TestShortEpisodesAreDebounced(t *testing.T) {
+	d := NewDetector(60)
+
+	ep := feed(t, d,
+		input{at(0, -6.2, kmh(90)), global},
+		input{at(30, -6.2, kmh(120)), global},
+		input{at(45, -6.2, kmh(60)), global},
+	)
+	if ep != nil {
+		t.Errorf("episode %+v of 30 s reported, the minimum is 60 s", ep)
+	}
+
+	// the next episode starts over
+	ep = feed(t, d,
+		input{at(60, -6.2, kmh(90)), global},
+		input{at(100, -6.2, kmh(90)), global},
+		input{at(130, -6.2, kmh(90)), global},
+		input{at(160, -6.2, kmh(60)), global},
+	)
+	if ep == nil || ep.Start.Timestamp != 60 {
+		t.Errorf("episode %+v, want one from 60", ep)
+	}
+}
+
+func TestZoneLimitAppliesInsideTheZone(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit Limit
+		want  *Limit
+	}{
+		{"60 km/h under the global limit", global, nil},
+		{"60 km/h in a 40 km/h zone", school, &school},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDetector(10)
+			ep := feed(t, d,
+				input{at(0, -6.2, kmh(60)), tc.limit},
+				input{at(30, -6.2, kmh(60)), tc.limit},
+				input{at(60, -6.2, kmh(30)), tc.limit},
+			)
+			switch {
+			case tc.want == nil && ep != nil:
+				t.Errorf("episode %+v, want none", ep)
+			case tc.want != nil && (ep == nil || ep.Limit != *tc.want):
+				t.Errorf("episode %+v, want one over %+v", ep, *tc.want)
+			}
+		})
+	}
+}
+
+func TestEpisodeReportsTheStrictestLimit(t *testing.T) {
+	d := NewDetector(10)
+
+	ep := feed(t, d,
+		input{at(0, -6.2, kmh(90)), global},
+		input{at(30, -6.2, kmh(60)), school},
+		input{at(60, -6.2, kmh(90)), global},
+		input{at(90, -6.2, kmh(30)), global},
+	)
+	if ep == nil || ep.Limit != school || ep.DurationSec != 60 {
+		t.Errorf("episode %+v, want 60 s reported against the school zone", ep)
+	}
+}
+
+func TestDerivedSpeedStartsTheEpisodeAtThePreviousPoint(t *testing.T) {
+	d := NewDetector(10)
+
+	// 0.01° every 30 s is about 133 km/h
+	ep := feed(t, d,
+		input{at(0, -6.20, nil), global},
+		input{at(30, -6.21, nil), global},
+		input{at(60, -6.22, nil), global},
+		input{at(90, -6.221, nil), global},
+	)
+	if ep == nil || ep.Start.Timestamp != 0 || ep.End.Timestamp != 60 || ep.PeakKmh < 130 {
+		t.Errorf("episode %+v, want 0-60 at about 133 km/h", ep)
+	}
+}
+
+func TestLoneOutlierIsNotAnEpisode(t *testing.T) {
+	d := NewDetector(10)
+
+	// slow reporting: one fix 2 km off makes two 30 s segments at about 270 km/h
+	ep := feed(t, d,
+		input{at(0, -6.200, nil), global},
+		input{at(30, -6.220, nil), global},
+		input{at(60, -6.201, nil), global},
+		input{at(90, -6.202, nil), global},
+		input{at(120, -6.203, nil), global},
+	)
+	if ep != nil {
+		t.Errorf("outlier reported as episode %+v", ep)
+	}
+
+	// a real ride above the limit is still one
+	ep = feed(t, d,
+		input{at(150, -6.213, nil), global},
+		input{at(180, -6.223, nil), global},
+		input{at(210, -6.224, nil), global},
+	)
+	if ep == nil || ep.Start.Timestamp != 120 || ep.End.Timestamp != 180 {
+		t.Errorf("episode %+v, want 120-180", ep)
+	}
+}
+
+func TestSpeedIsTheSameBeforeAndAfterAdd(t *testing.T) {
+	d := NewDetector(10)
+	d.Add("bus-1", at(0, -6.20, nil), global)
+
+	p := at(30, -6.21, nil)
+	before, ok := d.Speed("bus-1", p)
+	if !ok {
+		t.Fatal("no derived speed")
+	}
+	d.Add("bus-1", p, global)
+	if after, _ := d.Speed("bus-1", p); after != before {
+		t.Errorf("speed %.1f after Add, %.1f before", after, before)
+	}
+
+	if _, ok := d.Speed("bus-2", p); ok {
+		t.Error("speed derived for a vehicle without points")
+	}
+	if v, ok := d.Speed("bus-2", at(0, 0, kmh(42))); !ok || v != 42 {
+		t.Errorf("device speed %.1f, want 42", v)
+	}
+}
+
+func TestLatePointsAreIgnored(t *testing.T) {
+	d := NewDetector(10)
+
+	ep := feed(t, d,
+		input{at(0, -6.2, kmh(90)), global},
+		input{at(60, -6.2, kmh(90)), global},
+		// older than the last point, must not end the episode
+		input{at(30, -6.2, kmh(30)), global},
+		input{at(90, -6.2, kmh(30)), global},
+	)
+	if ep == nil || ep.End.Timestamp != 60 {
+		t.Errorf("episode %+v, want 0-60", ep)
+	}
+}
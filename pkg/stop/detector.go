@@ -0,0 +1,125 @@
+package stop
+
+import (
+	geopkg "tj/pkg/geofence"
+)
+
+type Config struct {
+	// points within this many meters of the stop anchor count as not moving
+	RadiusM float64
+	// the vehicle must stay within RadiusM this long before a stop is reported
+	MinDurationSec int64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		RadiusM:        30,
+		MinDurationSec: 120,
+	}
+}
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Timestamp int64
+	Ignition  *bool
+}
+
+type Kind int
+
+const (
+	Stopped Kind = iota + 1
+	Moving
+)
+
+// Transition is reported when a vehicle is confirmed stopped and again when it moves off.
+type Transition struct {
+	Kind Kind
+	// where and when the stationary period began
+	Anchor Point
+	// the point that triggered the transition
+	At Point
+	// seconds stationary so far (Stopped) or in total (Moving)
+	DurationSec int64
+	// seconds of the stop during which the device reported ignition on
+	EngineOnSec int64
+	// latest known ignition state, nil if the device doesn't report it
+	Ignition *bool
+}
+
+type vehicleState struct {
+	anchor      *Point
+	last        *Point
+	stopped     bool
+	engineOnSec int64
+}
+
+// Detector finds stationary periods from consecutive points: the vehicle is stopped once all
+// points stay within RadiusM of the first one for MinDurationSec. Not safe for concurrent use.
+type Detector struct {
+	cfg      Config
+	vehicles map[string]*vehicleState
+}
+
+func NewDetector(cfg Config) *Detector {
+	return &Detector{cfg: cfg, vehicles: make(map[string]*vehicleState)}
+}
+
+func (d *Detector) Add(vehicleId string, p Point) *Transition {
+	st, ok := d.vehicles[vehicleId]
+	if !ok {
+		st = &vehicleState{}
+		d.vehicles[vehicleId] = st
+	}
+	if st.last != nil && p.Timestamp <= st.last.Timestamp {
+		return nil
+	}
+
+	prev := st.last
+	st.last = &p
+
+	if st.anchor == nil {
+		st.anchor = &p
+		return nil
+	}
+
+	if prev != nil && prev.Ignition != nil && *prev.Ignition {
+		st.engineOnSec += p.Timestamp - prev.Timestamp
+	}
+
+	dist := geopkg.HaversineMeters(st.anchor.Latitude, st.anchor.Longitude, p.Latitude, p.Longitude)
+	if dist <= d.cfg.RadiusM {
+		elapsed := p.Timestamp - st.anchor.Timestamp
+		if !st.stopped && elapsed >= d.cfg.MinDurationSec {
+			st.stopped = true
+			return &Transition{
+				Kind:        Stopped,
+				Anchor:      *st.anchor,
+				At:          p,
+				DurationSec: elapsed,
+				EngineOnSec: st.engineOnSec,
+				Ignition:    p.Ignition,
+			}
+		}
+		return nil
+	}
+
+	var t *Transition
+	if st.stopped {
+		t = &Transition{
+			Kind:        Moving,
+			Anchor:      *st.anchor,
+			At:          p,
+			DurationSec: p.Timestamp - st.anchor.Timestamp,
+			EngineOnSec: st.engineOnSec,
+			Ignition:    p.Ignition,
+		}
+	}
+
+	// moved away: this point is the anchor of the next candidate stop
+	st.anchor = &p
+	st.stopped = false
+	st.engineOnSec = 0
+
+	return t
+}
@@ -0,0 +1,103 @@
+package stop
+
+import "testing"
+
+// 0.0001° of latitude is about 11 m.
+func at(ts int64, dLat float64, ignition *bool) Point {
+	return Point{Latitude: -6.2 + dLat, Longitude: 106.8, Timestamp: ts, Ignition: ignition}
+}
+
+var on, off = true, false
+
+// feed adds the points and returns every transition, in order.
+func feed(d *Detector, points ...Point) []*Transition {
+	var out []*Transition
+	for _, p := range points {
+		if t := d.Add("bus-1", p); t != nil {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func TestStopIsReportedAfterMinDuration(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	got := feed(d, at(0, 0, nil), at(60, 0, nil), at(119, 0, nil), at(120, 0, nil), at(180, 0, nil))
+	if len(got) != 1 {
+		t.Fatalf("%d transitions, want a single stop", len(got))
+	}
+	if s := got[0]; s.Kind != Stopped || s.Anchor.Timestamp != 0 || s.At.Timestamp != 120 || s.DurationSec != 120 {
+		t.Errorf("transition %+v, want stopped since 0, reported at 120", s)
+	}
+}
+
+func TestDriftWithinTheAnchorRadiusIsStillStopped(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	// wandering 22 m around the anchor, GPS noise at a standstill
+	got := feed(d, at(0, 0, nil), at(40, 0.0002, nil), at(80, -0.0002, nil), at(120, 0.0001, nil))
+	if len(got) != 1 || got[0].Kind != Stopped || got[0].Anchor.Timestamp != 0 {
+		t.Errorf("transitions %+v, want a stop anchored at 0", got)
+	}
+}
+
+func TestLeavingTheRadiusMovesTheAnchor(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	// creeping 44 m every 40 s never stays within 30 m of one anchor for long
+	got := feed(d, at(0, 0, nil), at(40, 0.0004, nil), at(80, 0.0008, nil), at(120, 0.0012, nil), at(160, 0.0016, nil))
+	if len(got) != 0 {
+		t.Fatalf("transitions %+v while creeping, want none", got)
+	}
+
+	// then it stands still at the last point, which is the new anchor
+	got = feed(d, at(200, 0.0016, nil), at(280, 0.0016, nil))
+	if len(got) != 1 || got[0].Anchor.Timestamp != 160 || got[0].DurationSec != 120 {
+		t.Errorf("transitions %+v, want a stop anchored at 160", got)
+	}
+}
+
+func TestMovingOffReportsTheWholeStop(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	got := feed(d, at(0, 0, nil), at(120, 0, nil), at(300, 0, nil), at(330, 0.001, nil))
+	if len(got) != 2 || got[0].Kind != Stopped || got[1].Kind != Moving {
+		t.Fatalf("transitions %+v, want stopped then moving", got)
+	}
+	if m := got[1]; m.Anchor.Timestamp != 0 || m.At.Timestamp != 330 || m.DurationSec != 330 {
+		t.Errorf("moving %+v, want the stop from 0 ended at 330", m)
+	}
+
+	// moving without having stopped reports nothing
+	if got := feed(d, at(360, 0.002, nil), at(390, 0.003, nil)); len(got) != 0 {
+		t.Errorf("transitions %+v while driving, want none", got)
+	}
+}
+
+func TestEngineOnTimeIsCountedDuringTheStop(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	// engine on for the first 60 s, off for the next 60 s
+	got := feed(d, at(0, 0, &on), at(60, 0, &off), at(120, 0, &off), at(180, 0, &on), at(240, 0.001, &on))
+	if len(got) != 2 {
+		t.Fatalf("transitions %+v, want stopped then moving", got)
+	}
+	if s := got[0]; s.EngineOnSec != 60 || s.Ignition == nil || *s.Ignition {
+		t.Errorf("stopped %+v, want 60 s engine on and ignition off now", s)
+	}
+	if m := got[1]; m.EngineOnSec != 120 || m.Ignition == nil || !*m.Ignition {
+		t.Errorf("moving %+v, want 120 s engine on", m)
+	}
+}
+
+func TestLatePointsAreIgnored(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+
+	// the late point far away must not move the anchor
+	got := feed(d, at(0, 0, nil), at(60, 0, nil), at(30, 0.01, nil), at(120, 0, nil))
+	if len(got) != 1 || got[0].Kind != Stopped {
+		t.Errorf("transitions %+v, want a stop", got)
+	}
+}
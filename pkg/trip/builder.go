@@ -0,0 +1,184 @@
+package trip
+
+import (
+	"math"
+
+	geopkg "tj/pkg/geofence"
+)
+
+// Reasons a trip was closed.
+const (
+	EndStopped     = "stopped"      // dwelled below the stop speed for MinDwell
+	EndIgnitionOff = "ignition_off" // device reported ignition off
+	EndSignalGap   = "signal_gap"   // no points for longer than MaxGap
+	EndShutdown    = "shutdown"     // still open when the builder was flushed
+)
+
+type Config struct {
+	// below this speed (km/h) the vehicle counts as stationary
+	StopSpeedKmh float64
+	// stationary for at least this long ends the trip
+	MinDwellSec int64
+	// a reporting gap longer than this ends the trip at the last point seen
+	MaxGapSec int64
+	// trips shorter than this (meters) are GPS drift around a stop and are discarded
+	MinDistanceM float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		StopSpeedKmh: 3,
+		MinDwellSec:  5 * 60,
+		MaxGapSec:    15 * 60,
+		MinDistanceM: 200,
+	}
+}
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Timestamp int64
+	Ignition  *bool
+}
+
+type Trip struct {
+	VehicleId   string
+	Start       Point
+	End         Point
+	DistanceM   float64
+	MaxSpeedKmh float64
+	Points      int
+	EndReason   string
+}
+
+func (t *Trip) DurationSec() int64 {
+	return t.End.Timestamp - t.Start.Timestamp
+}
+
+type vehicleState struct {
+	last    *Point
+	current *Trip
+
+	// first point of the current stationary period, nil while moving
+	stoppedAt *Point
+	// distance/points accumulated since stoppedAt, rolled back if the stop ends the trip
+	stoppedDist   float64
+	stoppedPoints int
+}
+
+// Builder segments each vehicle's point stream into trips. Points must arrive in timestamp
+// order per vehicle; older or duplicate points are ignored. Not safe for concurrent use.
+type Builder struct {
+	cfg      Config
+	vehicles map[string]*vehicleState
+}
+
+func NewBuilder(cfg Config) *Builder {
+	return &Builder{cfg: cfg, vehicles: make(map[string]*vehicleState)}
+}
+
+// Add feeds one point and returns the trip it completed, if any.
+func (b *Builder) Add(vehicleId string, p Point) *Trip {
+	st, ok := b.vehicles[vehicleId]
+	if !ok {
+		st = &vehicleState{}
+		b.vehicles[vehicleId] = st
+	}
+
+	prev := st.last
+	if prev != nil && p.Timestamp <= prev.Timestamp {
+		return nil
+	}
+	st.last = &p
+
+	if prev == nil {
+		return nil
+	}
+
+	// speed across a long gap means nothing, so the point after a gap only starts fresh
+	if p.Timestamp-prev.Timestamp > b.cfg.MaxGapSec {
+		if st.current == nil {
+			return nil
+		}
+		return b.finish(st, *prev, EndSignalGap)
+	}
+
+	dist := geopkg.HaversineMeters(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+	speed := dist / float64(p.Timestamp-prev.Timestamp) * 3.6
+	ignitionOff := p.Ignition != nil && !*p.Ignition
+	moving := speed >= b.cfg.StopSpeedKmh && !ignitionOff
+
+	if st.current == nil {
+		if moving {
+			st.current = &Trip{VehicleId: vehicleId, Start: *prev, Points: 1}
+			b.extend(st, p, dist, speed)
+		}
+		return nil
+	}
+
+	if ignitionOff {
+		b.extend(st, p, dist, 0)
+		return b.finish(st, p, EndIgnitionOff)
+	}
+
+	if moving {
+		st.stoppedAt = nil
+		st.stoppedDist, st.stoppedPoints = 0, 0
+		b.extend(st, p, dist, speed)
+		return nil
+	}
+
+	if st.stoppedAt == nil {
+		st.stoppedAt = prev
+	}
+	st.stoppedDist += dist
+	st.stoppedPoints++
+	b.extend(st, p, dist, speed)
+
+	if p.Timestamp-st.stoppedAt.Timestamp >= b.cfg.MinDwellSec {
+		// the trip ended where the vehicle stopped, not where the dwell threshold was crossed
+		st.current.DistanceM -= st.stoppedDist
+		st.current.Points -= st.stoppedPoints
+		return b.finish(st, *st.stoppedAt, EndStopped)
+	}
+
+	return nil
+}
+
+// Flush closes and returns every open trip at its last point, with EndShutdown: the
+// vehicle was still on its way, it isn't a loss of signal.
+func (b *Builder) Flush() []*Trip {
+	var trips []*Trip
+	for _, st := range b.vehicles {
+		if st.current == nil || st.last == nil {
+			continue
+		}
+		if t := b.finish(st, *st.last, EndShutdown); t != nil {
+			trips = append(trips, t)
+		}
+	}
+
+	return trips
+}
+
+func (b *Builder) extend(st *vehicleState, p Point, dist, speed float64) {
+	st.current.DistanceM += dist
+	st.current.MaxSpeedKmh = math.Max(st.current.MaxSpeedKmh, speed)
+	st.current.Points++
+	st.current.End = p
+}
+
+func (b *Builder) finish(st *vehicleState, end Point, reason string) *Trip {
+	t := st.current
+	st.current = nil
+	st.stoppedAt = nil
+	st.stoppedDist, st.stoppedPoints = 0, 0
+
+	t.End = end
+	t.EndReason = reason
+	if t.DistanceM < b.cfg.MinDistanceM {
+		return nil
+	}
+
+	return t
+}
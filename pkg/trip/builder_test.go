@@ -0,0 +1,167 @@
+package trip
+
+import (
+	"math"
+	"testing"
+)
+
+// step is 0.001° of latitude, about 111 m: one step every 30 s is about 13 km/h.
+const step = 0.001
+
+func at(ts int64, steps int) Point {
+	return Point{Latitude: -6.2 - float64(steps)*step, Longitude: 106.8, Timestamp: ts}
+}
+
+func off(p Point) Point {
+	ignition := false
+	p.Ignition = &ignition
+	return p
+}
+
+// drive adds the points and fails on a trip before the last one, which it returns.
+func drive(t *testing.T, b *Builder, points ...Point) *Trip {
+	t.Helper()
+
+	var trip *Trip
+	for i, p := range points {
+		trip = b.Add("bus-1", p)
+		if trip != nil && i < len(points)-1 {
+			t.Fatalf("trip %+v ended at %d, before the last point", trip, p.Timestamp)
+		}
+	}
+
+	return trip
+}
+
+// fourSteps moves 444 m between 0 and 120.
+func fourSteps() []Point {
+	return []Point{at(0, 0), at(30, 1), at(60, 2), at(90, 3), at(120, 4)}
+}
+
+func near(got, want float64) bool { return math.Abs(got-want) < 5 }
+
+func TestIgnitionOffEndsTheTrip(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	trip := drive(t, b, append(fourSteps(), off(at(150, 4)))...)
+	if trip == nil {
+		t.Fatal("no trip at ignition off")
+	}
+	if trip.EndReason != EndIgnitionOff || trip.Start.Timestamp != 0 || trip.End.Timestamp != 150 {
+		t.Errorf("trip %+v, want 0-150 ended by ignition off", trip)
+	}
+	if !near(trip.DistanceM, 445) || trip.Points != 6 || trip.MaxSpeedKmh < 13 {
+		t.Errorf("trip %+v, want 445 m over 6 points at about 13 km/h", trip)
+	}
+}
+
+func TestStopEndsTheTripWhereItStopped(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	points := fourSteps()
+	// standing at the fourth step until the 5 minute dwell is reached at 420
+	for ts := int64(150); ts <= 420; ts += 30 {
+		points = append(points, at(ts, 4))
+	}
+
+	trip := drive(t, b, points...)
+	if trip == nil {
+		t.Fatal("no trip after dwelling 5 minutes")
+	}
+	if trip.EndReason != EndStopped || trip.End.Timestamp != 120 {
+		t.Errorf("trip %+v, want it stopped at 120", trip)
+	}
+	// the standing points are not part of the trip
+	if !near(trip.DistanceM, 445) || trip.Points != 5 {
+		t.Errorf("trip %+v, want 445 m over 5 points", trip)
+	}
+}
+
+func TestShortStopKeepsTheTripGoing(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	points := fourSteps()
+	for ts := int64(150); ts <= 300; ts += 30 {
+		points = append(points, at(ts, 4))
+	}
+	points = append(points, at(330, 5), at(360, 6), off(at(390, 6)))
+
+	trip := drive(t, b, points...)
+	if trip == nil || trip.Start.Timestamp != 0 || trip.End.Timestamp != 390 || trip.EndReason != EndIgnitionOff {
+		t.Fatalf("trip %+v, want one 0-390 trip through the short stop", trip)
+	}
+	if !near(trip.DistanceM, 667) {
+		t.Errorf("distance %.0f m, want 667", trip.DistanceM)
+	}
+}
+
+func TestSignalGapEndsTheTripAtTheLastPoint(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	// more than 15 minutes without a point
+	trip := drive(t, b, append(fourSteps(), at(1200, 40))...)
+	if trip == nil {
+		t.Fatal("no trip after the gap")
+	}
+	if trip.EndReason != EndSignalGap || trip.End.Timestamp != 120 || !near(trip.DistanceM, 445) {
+		t.Errorf("trip %+v, want it ended at 120 with 445 m", trip)
+	}
+
+	// the point after the gap starts the next trip
+	trip = drive(t, b, at(1230, 41), at(1260, 42), at(1290, 43), off(at(1320, 43)))
+	if trip == nil || trip.Start.Timestamp != 1200 {
+		t.Errorf("trip %+v, want the next one from 1200", trip)
+	}
+}
+
+func TestTripsUnderMinDistanceAreDropped(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	// 111 m is drift around a stop, not a trip
+	if trip := drive(t, b, at(0, 0), at(30, 1), off(at(60, 1))); trip != nil {
+		t.Errorf("trip %+v of %.0f m kept, the minimum is 200 m", trip, trip.DistanceM)
+	}
+
+	cfg := DefaultConfig()
+	cfg.MinDistanceM = 100
+	b = NewBuilder(cfg)
+	if trip := drive(t, b, at(0, 0), at(30, 1), off(at(60, 1))); trip == nil {
+		t.Error("trip of 111 m dropped with a 100 m minimum")
+	}
+}
+
+func TestStationaryVehicleStartsNoTrip(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	for ts := int64(0); ts <= 900; ts += 30 {
+		if trip := b.Add("bus-1", at(ts, 0)); trip != nil {
+			t.Fatalf("trip %+v from a parked vehicle", trip)
+		}
+	}
+	if trips := b.Flush(); len(trips) != 0 {
+		t.Errorf("flushed %+v, want nothing open", trips)
+	}
+}
+
+func TestLatePointsAreIgnored(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+
+	// the late ignition off must not end the trip
+	trip := drive(t, b, at(0, 0), at(30, 1), at(60, 2), off(at(45, 2)), at(90, 3), off(at(120, 3)))
+	if trip == nil || trip.End.Timestamp != 120 || trip.Points != 5 {
+		t.Errorf("trip %+v, want 0-120 over 5 points", trip)
+	}
+}
+
+func TestFlushEndsOpenTripsAtTheirLastPoint(t *testing.T) {
+	b := NewBuilder(DefaultConfig())
+	drive(t, b, fourSteps()...)
+
+	trips := b.Flush()
+	if len(trips) != 1 || trips[0].EndReason != EndShutdown || trips[0].End.Timestamp != 120 {
+		t.Fatalf("flushed %+v, want the open trip ended at 120", trips)
+	}
+	if trips := b.Flush(); len(trips) != 0 {
+		t.Errorf("flushed %+v again", trips)
+	}
+}
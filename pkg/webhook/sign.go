@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Headers set on every webhook request.
+const (
+	HeaderEvent     = "X-Fleet-Event"
+	HeaderDelivery  = "X-Fleet-Delivery"
+	HeaderTimestamp = "X-Fleet-Timestamp"
+	HeaderSignature = "X-Fleet-Signature"
+)
+
+// Sign returns the X-Fleet-Signature value: "sha256=" + hex HMAC-SHA256 over
+// "<timestamp>.<body>", so a captured request can't be replayed with a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign and rejects timestamps older than tolerance.
+// Receivers can use it as-is.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return errors.New("timestamp outside tolerance")
+		}
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrInternalTarget is returned for webhook targets inside the deployment's own network:
+// loopback, private, link-local (cloud metadata lives at 169.254.169.254) and the like.
+var ErrInternalTarget = errors.New("webhook target is an internal address")
+
+// shared address space (RFC 6598), carrier NAT and some cloud metadata endpoints
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// hostnames that resolve to internal addresses by definition
+var internalHosts = []string{"localhost", "metadata.google.internal"}
+
+// CheckAddr rejects addresses a webhook must not be sent to.
+func CheckAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrInternalTarget, ip)
+	}
+
+	return nil
+}
+
+// CheckURL rejects target URLs whose host is an internal address literal or name. Other
+// hostnames are only checked once resolved, by the dialer from Dialer.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return CheckAddr(ip)
+	}
+	for _, h := range internalHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return fmt.Errorf("%w: %s", ErrInternalTarget, host)
+		}
+	}
+
+	return nil
+}
+
+// Dialer refuses connections to internal addresses. The check runs on the resolved
+// address right before connecting, so a name re-pointed after CheckURL (DNS rebinding)
+// doesn't get through either.
+func Dialer(d *net.Dialer) *net.Dialer {
+	d.Control = func(network, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		return CheckAddr(ap.Addr())
+	}
+
+	return d
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCheckURL(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		internal bool
+	}{
+		{"https://example.com/hook", false},
+		{"https://93.184.216.34/hook", false},
+		{"http://127.0.0.1:8080/", true},
+		{"http://localhost/", true},
+		{"http://api.localhost./", true},
+		{"http://10.1.2.3/", true},
+		{"http://192.168.0.10/", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://metadata.google.internal/computeMetadata/v1", true},
+		{"http://100.100.100.200/", true},
+		{"http://[::1]/", true},
+		{"http://[::ffff:127.0.0.1]/", true},
+		{"http://[fd00:ec2::254]/", true},
+		{"http://0.0.0.0/", true},
+	} {
+		err := CheckURL(tc.url)
+		if got := errors.Is(err, ErrInternalTarget); got != tc.internal {
+			t.Errorf("CheckURL(%s) = %v, internal want %v", tc.url, err, tc.internal)
+		}
+	}
+}
+
+func TestDialerRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// the dialer only sees the resolved address, the same as for a rebound name
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: Dialer(&net.Dialer{Timeout: time.Second}).DialContext,
+	}}
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, ErrInternalTarget) {
+		t.Errorf("GET %s = %v, want it refused", srv.URL, err)
+	}
+
+	if err := CheckAddr(netip.MustParseAddr("1.1.1.1")); err != nil {
+		t.Errorf("public address refused: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Command apikey creates an API key directly in Postgres, to bootstrap the first admin key
+// (later keys can be managed through /api-keys). The key is printed once and not stored:
+//
+//	go run ./services/api/cmd/apikey -name ops -role admin
+//	go run ./services/api/cmd/apikey -name partner-x -role partner -routes 1,2
+//	go run ./services/api/cmd/apikey -name mayasari-ops -role admin -operator mayasari
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"tj/config"
+	db "tj/pkg/database"
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+)
+
+func main() {
+	name := flag.String("name", "", "key name")
+	role := flag.String("role", model.RoleAdmin, "admin, dispatcher, partner or read-only")
+	groups := flag.String("groups", "", "comma separated fleets (vehicles.group_name) the key is limited to")
+	routes := flag.String("routes", "", "comma separated route ids the key is limited to")
+	operator := flag.String("operator", "", "operator the key belongs to; empty for a platform key")
+	flag.Parse()
+
+	if *name == "" || !auth.ValidRole(*role) {
+		flag.Usage()
+		log.Fatal("a -name and a valid -role are required")
+	}
+
+	if *role == model.RolePartner && *groups == "" && *routes == "" {
+		log.Fatal("partner keys must be limited with -groups or -routes")
+	}
+
+	config.Load()
+	dbConn, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Postgres init error: %v", err)
+	}
+
+	key, prefix := auth.GenerateKey()
+	createdBy := "cli"
+	k := model.APIKey{
+		Name:       *name,
+		KeyPrefix:  prefix,
+		KeyHash:    auth.HashKey(key),
+		Role:       *role,
+		GroupNames: splitList(*groups),
+		RouteIds:   splitList(*routes),
+		CreatedBy:  &createdBy,
+	}
+	if *operator != "" {
+		k.OperatorId = operator
+	}
+	if err := dbConn.Create(&k).Error; err != nil {
+		log.Fatalf("create api key error: %v", err)
+	}
+
+	log.Printf("created api key %d (%s, %s)", k.Id, k.Name, k.Role)
+	fmt.Println(key)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"tj/config"
+	db "tj/pkg/database"
+	"tj/pkg/gtfs"
+)
+
+func main() {
+	path := flag.String("path", "", "GTFS static feed, either a .zip or an extracted directory")
+
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatal("gtfs import requires -path")
+	}
+
+	config.Load()
+
+	dbConn, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Postgres init error: %v", err)
+	}
+
+	feed, closer, err := gtfs.OpenFeed(*path)
+	if err != nil {
+		log.Fatalf("open gtfs feed error: %v", err)
+	}
+	defer closer.Close()
+
+	stats, err := gtfs.Import(dbConn, feed)
+	if err != nil {
+		log.Fatalf("gtfs import error: %v", err)
+	}
+
+	log.Printf("GTFS import done: stops=%d routes=%d trips=%d shapes=%d stop_times=%d",
+		stats.Stops, stats.Routes, stats.Trips, stats.Shapes, stats.StopTimes)
+}
@@ -1,29 +1,168 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
+	"net/http"
 
-	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 
 	"tj/config"
 	db "tj/pkg/database"
-	handler "tj/services/api/internal/controller"
+	"tj/pkg/lifecycle"
+	rmq "tj/pkg/rabbitmq"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/graph"
+	"tj/services/api/internal/grpcapi"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/ratelimit"
+	"tj/services/api/internal/router"
+	"tj/services/api/internal/stream"
 )
 
 func main() {
 	config.Load()
+	lc := lifecycle.New(config.Cfg.ShutdownTimeout)
 
-	if err := db.Connect(); err != nil {
+	dbConn, err := db.Connect()
+	if err != nil {
 		log.Fatalf("Postgres init error: %v", err)
 	}
+	repos := repository.NewPostgres(dbConn)
 
-	r := gin.Default()
-	vh := handler.NewVehicleHandler(db.DB)
+	cache.Connect()
 
-	r.GET("/vehicles/:vehicle_id/location", vh.GetLastLocation)
-	r.GET("/vehicles/:vehicle_id/history", vh.GetHistory)
+	rmqClient, err := rmq.Connect()
+	if err != nil {
+		log.Fatalf("RabbitMQ init error: %v", err)
+	}
+
+	// every replica gets its own queue so each one sees the full event stream
+	queue, err := rmq.SetupTransientQueue(rmqClient, "fleet.events", []string{"location.raw", "geofence.*"})
+	if err != nil {
+		log.Fatalf("RabbitMQ setup error: %v", err)
+	}
+
+	streamCfg := rmq.RabbitConfig{QueueName: queue, ConsumerName: "api-stream"}
+	msgs, err := rmq.ConsumeRMQWithConfig(rmqClient, streamCfg, true)
+	if err != nil {
+		log.Fatalf("RabbitMQ consume error: %v", err)
+	}
+
+	hub := stream.NewHub(dbConn)
+	lc.Go("stream hub", func(ctx context.Context) error {
+		hub.Run(msgs)
+		if ctx.Err() == nil {
+			return errors.New("delivery channel closed")
+		}
+		return nil
+	})
+	// the hub closes its clients once the consumer is gone, ending the open streams
+	lc.OnShutdown(lifecycle.StopIntake, "stream consumer", lifecycle.ErrFunc(func() error {
+		return rmqClient.Cancel(streamCfg)
+	}))
+
+	authn := auth.NewAuthenticator(dbConn, cache.Rdb, auth.Config{
+		JWTSecret: []byte(config.Cfg.JWTSecret),
+		JWTIssuer: config.Cfg.JWTIssuer,
+		JWTTTL:    config.Cfg.JWTTTL,
+	})
+	// keys revoked or changed on another replica leave this one's cache too
+	lc.Go("api key watcher", authn.WatchKeys)
+
+	keyLimit := ratelimit.Policy{PerMin: config.Cfg.RateLimitKeyPerMin, Burst: config.Cfg.RateLimitKeyBurst}
+
+	r, err := router.New(router.Deps{
+		DB:       dbConn,
+		Repos:    repos,
+		Rdb:      cache.Rdb,
+		Hub:      hub,
+		Auth:     authn,
+		IPLimit:  ratelimit.Policy{PerMin: config.Cfg.RateLimitIPPerMin, Burst: config.Cfg.RateLimitIPBurst},
+		KeyLimit: keyLimit,
+		GraphQL:  graph.Limits{MaxDepth: config.Cfg.GraphQLMaxDepth, MaxComplexity: config.Cfg.GraphQLMaxComplexity},
+
+		TrustedProxies: config.Cfg.TrustedProxies,
+	})
+	if err != nil {
+		log.Fatalf("Router init error: %v", err)
+	}
+
+	srv := &http.Server{Addr: ":8093", Handler: r}
+	lc.Go("http server", func(context.Context) error {
+		return serve(srv)
+	})
+	// Shutdown also waits for the requests in progress
+	lc.OnShutdown(lifecycle.StopIntake, "http server", srv.Shutdown)
+
+	if addr := config.Cfg.MQTTAuthAddr; addr != "" {
+		handler, err := router.NewMQTTAuth(dbConn, config.Cfg.MQTTUsername, config.Cfg.MQTTPassword)
+		if err != nil {
+			log.Fatalf("MQTT auth backend init error: %v", err)
+		}
+		mr := &http.Server{Addr: addr, Handler: handler}
+		lc.Go("MQTT auth backend", func(context.Context) error {
+			return serve(mr)
+		})
+		lc.OnShutdown(lifecycle.StopIntake, "MQTT auth backend", mr.Shutdown)
+	}
+
+	if addr := config.Cfg.GRPCAddr; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("gRPC listen error: %v", err)
+		}
+		gs := grpcapi.NewServer(grpcapi.Deps{
+			Locations: location.NewQuery(repos.Locations),
+			Hub:       hub,
+			Auth:      authn,
+			Limiter:   ratelimit.NewLimiter(cache.Rdb),
+			KeyLimit:  keyLimit,
+		})
+		lc.Go("gRPC server", func(context.Context) error {
+			return gs.Serve(lis)
+		})
+		lc.OnShutdown(lifecycle.StopIntake, "gRPC server", stopGRPC(gs))
+	}
+
+	lc.OnShutdown(lifecycle.Close, "rabbitmq", lifecycle.Func(rmqClient.Close))
+	lc.OnShutdown(lifecycle.Close, "redis", lifecycle.ErrFunc(cache.Rdb.Close))
+	lc.OnShutdown(lifecycle.Close, "postgres", lifecycle.ErrFunc(func() error { return db.Close(dbConn) }))
+
+	if err := lc.Wait(); err != nil {
+		log.Fatalf("api shutdown error: %v", err)
+	}
+	log.Println("API stopped")
+}
+
+func serve(srv *http.Server) error {
+	log.Printf("HTTP listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// stopGRPC lets the calls in progress finish, cutting them off at the shutdown deadline.
+func stopGRPC(gs *grpc.Server) lifecycle.Hook {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(done)
+		}()
 
-	if err := r.Run(":8093"); err != nil {
-		log.Fatalf("Gin run error: %v", err)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			gs.Stop()
+			return ctx.Err()
+		}
 	}
 }
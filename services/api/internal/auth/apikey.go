@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// API keys look like fk_<prefix>_<secret>. The prefix is stored in clear to find the row,
+// only the SHA-256 of the whole key is kept.
+const apiKeyScheme = "fk_"
+
+var errMalformedKey = errors.New("malformed api key")
+
+// GenerateKey returns a new key and the prefix it is stored under.
+func GenerateKey() (key, prefix string) {
+	p := make([]byte, 6)
+	s := make([]byte, 24)
+	rand.Read(p)
+	rand.Read(s)
+
+	prefix = hex.EncodeToString(p)
+	return apiKeyScheme + prefix + "_" + hex.EncodeToString(s), prefix
+}
+
+// HashKey is what gets stored in api_keys.key_hash. Keys are random, so a plain hash is
+// enough; there is nothing to brute force.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyScheme)
+}
+
+func keyPrefix(key string) (string, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, apiKeyScheme), "_")
+	if !ok || prefix == "" {
+		return "", errMalformedKey
+	}
+
+	return prefix, nil
+}
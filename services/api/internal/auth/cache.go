@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// principalCacheTTL is how long a resolved caller is reused. It keeps the key lookup and
+// scope queries off every request, throttled ones included. Changed and revoked keys are
+// evicted right away (ForgetKey), so this only bounds how stale the vehicle scope and
+// identity provider tokens get.
+const principalCacheTTL = 30 * time.Second
+
+// entries beyond this are swept, and the cache dropped if they are all still live
+const principalCacheMax = 10000
+
+type cachedPrincipal struct {
+	p       *Principal
+	expires time.Time
+}
+
+// principalCache maps the hash of a credential to its principal. Only successful
+// authentications are cached, invalid credentials are the IP limit's business.
+type principalCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedPrincipal
+}
+
+func newPrincipalCache() *principalCache {
+	return &principalCache{entries: make(map[string]cachedPrincipal)}
+}
+
+func (c *principalCache) get(key string, now time.Time) (*Principal, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+
+	return e.p, true
+}
+
+// put caches p until the TTL or the credential's own expiry, whichever comes first.
+func (c *principalCache) put(key string, p *Principal, now time.Time) {
+	expires := now.Add(principalCacheTTL)
+	if p.expiresAt != nil && p.expiresAt.Before(expires) {
+		expires = *p.expiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= principalCacheMax {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= principalCacheMax {
+			c.entries = make(map[string]cachedPrincipal)
+		}
+	}
+	c.entries[key] = cachedPrincipal{p: p, expires: expires}
+}
+
+// dropKey evicts every cached principal of an API key, the key itself and the tokens
+// issued for it.
+func (c *principalCache) dropKey(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if e.p.KeyId != nil && *e.p.KeyId == id {
+			delete(c.entries, k)
+		}
+	}
+}
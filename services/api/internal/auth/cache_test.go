@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestChangedKeyIsForgottenOnEveryReplica(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	here, other := NewAuthenticator(nil, rdb, Config{}), NewAuthenticator(nil, rdb, Config{})
+	go other.WatchKeys(ctx)
+
+	now := time.Now()
+	id, otherId := int64(1), int64(2)
+	for _, a := range []*Authenticator{here, other} {
+		a.cache.put("key", &Principal{KeyId: &id, Method: MethodAPIKey}, now)
+		a.cache.put("token", &Principal{KeyId: &id, Method: MethodJWT}, now)
+		a.cache.put("other key", &Principal{KeyId: &otherId, Method: MethodAPIKey}, now)
+	}
+
+	// the watcher has to be subscribed before the change is announced
+	deadline := time.Now().Add(time.Second)
+	for len(mr.PubSubChannels("")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	here.ForgetKey(ctx, id)
+
+	for name, a := range map[string]*Authenticator{"this replica": here, "other replica": other} {
+		for time.Now().Before(deadline) {
+			if _, ok := a.cache.get("token", now); !ok {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		for _, k := range []string{"key", "token"} {
+			if _, ok := a.cache.get(k, now); ok {
+				t.Errorf("%s: %s of the changed key still cached", name, k)
+			}
+		}
+		if _, ok := a.cache.get("other key", now); !ok {
+			t.Errorf("%s: unrelated key evicted", name)
+		}
+	}
+}
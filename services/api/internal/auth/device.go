@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// device usernames are public like key prefixes; passwords are random, so they are stored
+// with HashKey as well
+const deviceUserPrefix = "dev_"
+
+// GenerateDeviceCredentials returns a new MQTT username and password for a tracker.
+func GenerateDeviceCredentials() (username, password string) {
+	u := make([]byte, 8)
+	p := make([]byte, 24)
+	rand.Read(u)
+	rand.Read(p)
+
+	return deviceUserPrefix + hex.EncodeToString(u), hex.EncodeToString(p)
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+// Claims is the JWT payload: the role and scope sit next to the registered claims.
+type Claims struct {
+	Role       string   `json:"role"`
+	OperatorId string   `json:"operator_id,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	RouteIds   []string `json:"route_ids,omitempty"`
+	// the key's rate limit override, tokens share its bucket
+	RateLimitPerMin *int `json:"rate_limit_per_min,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IssueToken signs a short-lived token carrying the principal's role and scope. It never
+// outlives the key it was issued for.
+func (a *Authenticator) IssueToken(p *Principal) (string, time.Time, error) {
+	if len(a.cfg.JWTSecret) == 0 {
+		return "", time.Time{}, errors.New("JWT_SECRET is not set")
+	}
+
+	now := time.Now()
+	exp := now.Add(a.cfg.JWTTTL)
+	if p.expiresAt != nil && p.expiresAt.Before(exp) {
+		exp = *p.expiresAt
+	}
+	claims := Claims{
+		Role:            p.Role,
+		Groups:          p.Scope.Groups,
+		RouteIds:        p.Scope.RouteIds,
+		RateLimitPerMin: p.RateLimitPerMin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   p.Subject,
+			Issuer:    a.cfg.JWTIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+
+	if p.OperatorId != nil {
+		claims.OperatorId = *p.OperatorId
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.cfg.JWTSecret)
+	return signed, exp, err
+}
+
+// parseToken verifies a JWT. Tokens exchanged for an API key take the key's current role,
+// scope and expiry, so revoking, narrowing or demoting the key applies to them too.
+func (a *Authenticator) parseToken(ctx context.Context, token string) (*Principal, error) {
+	if len(a.cfg.JWTSecret) == 0 {
+		return nil, errors.New("bearer tokens are not accepted")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.cfg.JWTIssuer))
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return a.cfg.JWTSecret, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidRole(claims.Role) {
+		return nil, errors.New("token has no valid role")
+	}
+
+	exp := claims.ExpiresAt.Time
+	if id, ok := keyIdFromSubject(claims.Subject); ok {
+		k, err := a.keyById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		p := keyPrincipal(k, MethodJWT)
+		if p.expiresAt == nil || exp.Before(*p.expiresAt) {
+			p.expiresAt = &exp
+		}
+		return p, nil
+	}
+
+	p := &Principal{
+		Subject:         claims.Subject,
+		Role:            claims.Role,
+		Scope:           Scope{Groups: claims.Groups, RouteIds: claims.RouteIds},
+		Method:          MethodJWT,
+		RateLimitPerMin: claims.RateLimitPerMin,
+		expiresAt:       &exp,
+	}
+	if claims.OperatorId != "" {
+		p.OperatorId = &claims.OperatorId
+	}
+
+	return p, nil
+}
+
+// keyById loads the key a token was issued for, errInvalidKey once it is gone, revoked
+// or expired.
+func (a *Authenticator) keyById(ctx context.Context, id int64) (*model.APIKey, error) {
+	var k model.APIKey
+	err := a.db.WithContext(ctx).Where("id = ?", id).First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !usable(&k, time.Now()) {
+		return nil, errInvalidKey
+	}
+
+	return &k, nil
+}
+
+func keySubject(id int64) string {
+	return "key:" + strconv.FormatInt(id, 10)
+}
+
+func keyIdFromSubject(sub string) (int64, bool) {
+	if len(sub) < 5 || sub[:4] != "key:" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(sub[4:], 10, 64)
+	return id, err == nil
+}
@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+)
+
+// last_used_at is only bumped when it is older than this, to keep writes off the hot path
+const lastUsedResolution = time.Minute
+
+var (
+	// ErrInvalidCredentials is returned by Authenticate for unknown, revoked or expired credentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	errInvalidKey = errors.New("invalid api key")
+)
+
+type Config struct {
+	JWTSecret []byte
+	JWTIssuer string
+	JWTTTL    time.Duration
+}
+
+// Authenticator resolves API keys and JWT bearer tokens into a Principal.
+type Authenticator struct {
+	db *gorm.DB
+	// tells the other replicas about changed keys, may be nil with a single replica
+	rdb   *redis.Client
+	cfg   Config
+	cache *principalCache
+}
+
+func NewAuthenticator(dbConn *gorm.DB, rdb *redis.Client, cfg Config) *Authenticator {
+	return &Authenticator{db: dbConn, rdb: rdb, cfg: cfg, cache: newPrincipalCache()}
+}
+
+// Option tweaks a Middleware.
+type Option func(*options)
+
+type options struct {
+	queryToken bool
+}
+
+// AllowQueryToken also accepts ?access_token=, for browser WebSocket and EventSource
+// clients that can't set headers. Query strings end up in logs, so only streams use it.
+func AllowQueryToken() Option {
+	return func(o *options) { o.queryToken = true }
+}
+
+// Middleware rejects requests without valid credentials and stores the caller for
+// FromContext. Credentials are "Authorization: Bearer <api key or JWT>" or "X-API-Key".
+func (a *Authenticator) Middleware(opts ...Option) gin.HandlerFunc {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(c *gin.Context) {
+		token := bearerToken(c.Request)
+		if token == "" && o.queryToken {
+			token = c.Query("access_token")
+		}
+		if token == "" {
+			unauthorized(c, "missing credentials")
+			return
+		}
+
+		p, err := a.Authenticate(c.Request.Context(), token)
+		if errors.Is(err, ErrInvalidCredentials) {
+			unauthorized(c, "invalid credentials")
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+// Authenticate resolves an API key or JWT into a Principal with its vehicle scope loaded.
+// Transports other than gin (gRPC) call it directly. Principals are cached for
+// principalCacheTTL, so the per-key limit runs before any query for known callers.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	now := time.Now()
+	cacheKey := HashKey(token)
+	if p, ok := a.cache.get(cacheKey, now); ok {
+		return p, nil
+	}
+
+	var p *Principal
+	var err error
+	if isAPIKey(token) {
+		p, err = a.lookupKey(ctx, token)
+	} else {
+		p, err = a.parseToken(ctx, token)
+	}
+	if err != nil {
+		if !errors.Is(err, errInvalidKey) {
+			log.Printf("auth: %v", err)
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if !p.Platform() || !p.Scope.Unrestricted() {
+		if p.vehicles, err = a.scopeVehicles(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	a.cache.put(cacheKey, p, now)
+
+	return p, nil
+}
+
+// RequireRole lets only the given roles through; admins always pass.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := FromContext(c)
+		if p != nil && (p.Role == model.RoleAdmin || containsString(roles, p.Role)) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	}
+}
+
+// RequirePlatform keeps operator-bound callers out of fleet-wide configuration.
+func RequirePlatform() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := FromContext(c)
+		if p != nil && p.Platform() {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden for operator keys"})
+	}
+}
+
+// RequireUnscoped keeps callers limited to some fleets or routes out of features that
+// can't be narrowed to them.
+func RequireUnscoped() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := FromContext(c)
+		if p != nil && p.Scope.Unrestricted() {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden for keys scoped to fleets or routes"})
+	}
+}
+
+// RequireVehicle rejects :vehicle_id routes for vehicles outside the caller's scope.
+func RequireVehicle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := FromContext(c)
+		if p == nil || !p.AllowsVehicle(c.Param("vehicle_id")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "vehicle not in scope"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (a *Authenticator) lookupKey(ctx context.Context, key string) (*Principal, error) {
+	prefix, err := keyPrefix(key)
+	if err != nil {
+		return nil, errInvalidKey
+	}
+
+	var k model.APIKey
+	err = a.db.WithContext(ctx).Where("key_prefix = ?", prefix).First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashKey(key)), []byte(k.KeyHash)) != 1 {
+		return nil, errInvalidKey
+	}
+	now := time.Now()
+	if !usable(&k, now) {
+		return nil, errInvalidKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > lastUsedResolution {
+		go a.touch(k.Id, now)
+	}
+
+	return keyPrincipal(&k, MethodAPIKey), nil
+}
+
+func usable(k *model.APIKey, now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// keyPrincipal is the caller of a key, presented directly or through a token issued for it.
+func keyPrincipal(k *model.APIKey, method string) *Principal {
+	return &Principal{
+		Subject:         keySubject(k.Id),
+		Role:            k.Role,
+		KeyId:           &k.Id,
+		OperatorId:      k.OperatorId,
+		Scope:           Scope{Groups: k.GroupNames, RouteIds: k.RouteIds},
+		Method:          method,
+		RateLimitPerMin: k.RateLimitPerMin,
+		expiresAt:       k.ExpiresAt,
+	}
+}
+
+func (a *Authenticator) touch(id int64, now time.Time) {
+	if err := a.db.Model(&model.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", now).Error; err != nil {
+		log.Printf("auth: update last_used_at for key %d error: %v", id, err)
+	}
+}
+
+func (a *Authenticator) scopeVehicles(ctx context.Context, p *Principal) (map[string]struct{}, error) {
+	s := p.Scope
+	q := a.db.WithContext(ctx).Model(&model.Vehicle{}).Scopes(p.Tenant("vehicles"))
+	switch {
+	case len(s.Groups) > 0 && len(s.RouteIds) > 0:
+		q = q.Where("group_name IN ? OR route_id IN ?", s.Groups, s.RouteIds)
+	case len(s.Groups) > 0:
+		q = q.Where("group_name IN ?", s.Groups)
+	case len(s.RouteIds) > 0:
+		q = q.Where("route_id IN ?", s.RouteIds)
+	}
+
+	var ids []string
+	if err := q.Pluck("vehicle_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+
+	return set, nil
+}
+
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func unauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", `Bearer realm="fleet"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+}
+
+func containsString(vals []string, v string) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+)
+
+const principalKey = "auth.principal"
+
+type ctxKey struct{}
+
+// How a principal authenticated.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Scope limits a caller to some fleets (vehicles.group_name) and/or routes. A vehicle is
+// visible when it is in any listed fleet or on any listed route; an empty scope sees all.
+type Scope struct {
+	Groups   []string `json:"groups,omitempty"`
+	RouteIds []string `json:"route_ids,omitempty"`
+}
+
+func (s Scope) Unrestricted() bool {
+	return len(s.Groups) == 0 && len(s.RouteIds) == 0
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// "key:<id>" for API keys, the token subject for JWTs
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	KeyId   *int64 `json:"key_id,omitempty"`
+	// tenant of the caller; nil for platform callers, who see every operator
+	OperatorId *string `json:"operator_id,omitempty"`
+	Scope      Scope   `json:"scope"`
+	// overrides the default per-key rate limit, 0 = unlimited
+	RateLimitPerMin *int `json:"rate_limit_per_min,omitempty"`
+	// MethodAPIKey or MethodJWT
+	Method string `json:"method"`
+
+	// vehicles of the operator inside the scope, resolved at authentication and cached with
+	// the principal; nil when unrestricted
+	vehicles map[string]struct{}
+	// when the key or token expires, nil if never
+	expiresAt *time.Time
+}
+
+// AllowsVehicle reports whether the caller may see the vehicle.
+func (p *Principal) AllowsVehicle(vehicleId string) bool {
+	if p.vehicles == nil {
+		return true
+	}
+	_, ok := p.vehicles[vehicleId]
+	return ok
+}
+
+// VehicleIds lists the vehicles in scope. ok is false for unrestricted callers, who
+// need no filtering.
+func (p *Principal) VehicleIds() (ids []string, ok bool) {
+	if p.vehicles == nil {
+		return nil, false
+	}
+
+	ids = make([]string, 0, len(p.vehicles))
+	for id := range p.vehicles {
+		ids = append(ids, id)
+	}
+
+	return ids, true
+}
+
+// Platform reports whether the caller is not bound to an operator.
+func (p *Principal) Platform() bool {
+	return p.OperatorId == nil
+}
+
+// Tenant is a GORM scope limiting a query on table to the caller's operator. Platform
+// callers are not limited.
+func (p *Principal) Tenant(table string) func(*gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		if p.OperatorId == nil {
+			return q
+		}
+		return q.Where(table+".operator_id = ?", *p.OperatorId)
+	}
+}
+
+// Filter limits repository reads to the caller's operator and vehicles.
+func (p *Principal) Filter() repository.Filter {
+	f := repository.Filter{OperatorId: p.OperatorId}
+	f.VehicleIds, f.Scoped = p.VehicleIds()
+
+	return f
+}
+
+// FromContext returns the caller set by Authenticator.Middleware.
+func FromContext(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+
+	return nil
+}
+
+// NewContext returns a copy of ctx carrying p, for transports without a gin.Context.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored by NewContext, nil when there is none.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
+
+// ValidRole reports whether role is one of the model.Role* constants.
+func ValidRole(role string) bool {
+	switch role {
+	case model.RoleAdmin, model.RoleDispatcher, model.RolePartner, model.RoleReadOnly:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+// keysChangedChannel carries the ids of API keys updated or revoked on any API replica.
+const keysChangedChannel = "api_keys:changed"
+
+// ForgetKey evicts a changed or revoked key from the principal cache of this replica, and
+// through Redis from the others, so the change applies to the next request. If the
+// message is lost the cache TTL still bounds the delay.
+func (a *Authenticator) ForgetKey(ctx context.Context, id int64) {
+	a.cache.dropKey(id)
+
+	if a.rdb == nil {
+		return
+	}
+	if err := a.rdb.Publish(ctx, keysChangedChannel, id).Err(); err != nil {
+		log.Printf("auth: publish change of key %d error: %v", id, err)
+	}
+}
+
+// WatchKeys evicts keys changed on other replicas until ctx is done.
+func (a *Authenticator) WatchKeys(ctx context.Context) error {
+	sub := a.rdb.Subscribe(ctx, keysChangedChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			id, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil {
+				log.Printf("auth: bad key id %q on %s", msg.Payload, keysChangedChannel)
+				continue
+			}
+			a.cache.dropKey(id)
+		}
+	}
+}
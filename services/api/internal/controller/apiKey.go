@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/openapi"
+)
+
+type APIKeyHandler struct {
+	DB   *gorm.DB
+	Auth *auth.Authenticator
+}
+
+func NewAPIKeyHandler(dbConn *gorm.DB, a *auth.Authenticator) *APIKeyHandler {
+	return &APIKeyHandler{DB: dbConn, Auth: a}
+}
+
+// apiKeyRequest is the body of POST/PUT /api-keys. Keys can't be changed after creation;
+// rotate by creating a new one and revoking the old.
+type apiKeyRequest openapi.APIKeyRequest
+
+type apiKeyResponse struct {
+	*model.APIKey
+	// only returned on create
+	Key string `json:"key,omitempty"`
+}
+
+func (req *apiKeyRequest) validate() error {
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("name is required")
+	}
+	if !auth.ValidRole(req.Role) {
+		return errors.New("role must be admin, dispatcher, partner or read-only")
+	}
+	if req.Role == model.RolePartner && len(req.GroupNames) == 0 && len(req.RouteIds) == 0 {
+		return errors.New("partner keys must be scoped to group_names or route_ids")
+	}
+	if req.RateLimitPerMin != nil && *req.RateLimitPerMin < 0 {
+		return errors.New("rate_limit_per_min must be >= 0")
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+
+	return nil
+}
+
+func (req *apiKeyRequest) apply(k *model.APIKey) {
+	k.Name = req.Name
+	k.Role = req.Role
+	k.GroupNames = req.GroupNames
+	k.RouteIds = req.RouteIds
+	k.OperatorId = req.OperatorId
+	k.RateLimitPerMin = req.RateLimitPerMin
+	k.ExpiresAt = req.ExpiresAt
+}
+
+// resolveOperator pins keys made by operator admins to their own operator; platform admins
+// may pick any existing operator, or none for another platform key.
+func (h *APIKeyHandler) resolveOperator(c *gin.Context, req *apiKeyRequest) bool {
+	p := auth.FromContext(c)
+	if !p.Platform() {
+		if req.OperatorId != nil && *req.OperatorId != *p.OperatorId {
+			c.JSON(http.StatusForbidden, gin.H{"error": "operator keys can only create keys for their own operator"})
+			return false
+		}
+		req.OperatorId = p.OperatorId
+		return true
+	}
+
+	if req.OperatorId != nil {
+		var n int64
+		if err := h.DB.Model(&model.Operator{}).Where("operator_id = ?", *req.OperatorId).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return false
+		}
+		if n == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown operator_id"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// resolveRateLimit leaves the rate limit override to platform admins, operator admins
+// could otherwise make their own keys unlimited. They keep the key's current value.
+func resolveRateLimit(c *gin.Context, req *apiKeyRequest, current *int) bool {
+	if auth.FromContext(c).Platform() {
+		return true
+	}
+
+	if req.RateLimitPerMin == nil {
+		req.RateLimitPerMin = current
+		return true
+	}
+	if current == nil || *req.RateLimitPerMin != *current {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only platform admins can set rate_limit_per_min"})
+		return false
+	}
+
+	return true
+}
+
+// ListKeys: GET /api-keys, revoked keys included
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	var keys []model.APIKey
+	if err := h.DB.Scopes(auth.FromContext(c).Tenant("api_keys")).Order("id ASC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  keys,
+		"count": len(keys),
+	})
+}
+
+// GetKey: GET /api-keys/:id
+func (h *APIKeyHandler) GetKey(c *gin.Context) {
+	k, ok := h.findKey(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, k)
+}
+
+// CreateKey: POST /api-keys. The key is only ever shown in this response.
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.resolveOperator(c, &req) || !resolveRateLimit(c, &req, nil) {
+		return
+	}
+
+	key, prefix := auth.GenerateKey()
+	k := model.APIKey{KeyPrefix: prefix, KeyHash: auth.HashKey(key)}
+	req.apply(&k)
+	if p := auth.FromContext(c); p != nil {
+		k.CreatedBy = &p.Subject
+	}
+
+	if err := h.DB.Create(&k).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, apiKeyResponse{APIKey: &k, Key: key})
+}
+
+// UpdateKey changes name, role, scope, rate limit and expiry: PUT /api-keys/:id. The
+// operator stays as created.
+func (h *APIKeyHandler) UpdateKey(c *gin.Context) {
+	k, ok := h.findKey(c)
+	if !ok {
+		return
+	}
+
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// a key's operator is fixed: left out, it must not turn a tenant key into a platform one
+	if req.OperatorId != nil && (k.OperatorId == nil || *req.OperatorId != *k.OperatorId) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operator_id can't be changed, create a new key instead"})
+		return
+	}
+	req.OperatorId = k.OperatorId
+	if !resolveRateLimit(c, &req, k.RateLimitPerMin) {
+		return
+	}
+
+	req.apply(k)
+	if err := h.DB.Save(k).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	h.Auth.ForgetKey(c.Request.Context(), k.Id)
+
+	c.JSON(http.StatusOK, k)
+}
+
+// RevokeKey: DELETE /api-keys/:id. The row stays for auditing; the key and the tokens
+// issued for it stop working at once.
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	k, ok := h.findKey(c)
+	if !ok {
+		return
+	}
+
+	if k.RevokedAt == nil {
+		if err := h.DB.Model(k).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+	}
+	h.Auth.ForgetKey(c.Request.Context(), k.Id)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) findKey(c *gin.Context) (*model.APIKey, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	var k model.APIKey
+	if err := h.DB.Scopes(auth.FromContext(c).Tenant("api_keys")).First(&k, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return nil, false
+	}
+
+	return &k, true
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tj/services/api/internal/auth"
+)
+
+type AuthHandler struct {
+	Auth *auth.Authenticator
+}
+
+func NewAuthHandler(a *auth.Authenticator) *AuthHandler {
+	return &AuthHandler{Auth: a}
+}
+
+// Me returns the authenticated caller: GET /auth/me
+func (h *AuthHandler) Me(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.FromContext(c))
+}
+
+// IssueToken exchanges an API key for a short-lived JWT with the same role and scope,
+// e.g. for browser clients: POST /auth/token
+func (h *AuthHandler) IssueToken(c *gin.Context) {
+	p := auth.FromContext(c)
+	// a token must not be able to renew itself past a revoked key
+	if p.Method != auth.MethodAPIKey {
+		c.JSON(http.StatusForbidden, gin.H{"error": "tokens can only be issued for api keys"})
+		return
+	}
+
+	token, exp, err := h.Auth.IssueToken(p)
+	if err != nil {
+		log.Printf("issue token error: %v", err)
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "token issuing is disabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(time.Until(exp).Seconds()),
+	})
+}
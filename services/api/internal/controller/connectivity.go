@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tj/config"
+	cache "tj/pkg/redis"
+	"tj/services/api/internal/auth"
+)
+
+type connectivityResponse struct {
+	VehicleId     string `json:"vehicle_id"`
+	Status        string `json:"status"`
+	Since         int64  `json:"since"`
+	LastSeen      int64  `json:"last_seen"`
+	SecondsSilent int64  `json:"seconds_since_last_seen"`
+}
+
+// connectivityOf prefers the status recorded by the worker's heartbeat monitor and
+// falls back to the threshold for vehicles it hasn't evaluated yet.
+func connectivityOf(vehicleId string, lastSeen int64, st *cache.ConnectivityStatus, now time.Time) connectivityResponse {
+	resp := connectivityResponse{
+		VehicleId:     vehicleId,
+		LastSeen:      lastSeen,
+		SecondsSilent: now.Unix() - lastSeen,
+	}
+
+	if st != nil {
+		resp.Status, resp.Since = st.Status, st.Since
+		return resp
+	}
+
+	resp.Status, resp.Since = cache.StatusOnline, lastSeen
+	if now.Sub(time.Unix(lastSeen, 0)) > config.Cfg.OfflineThreshold {
+		resp.Status = cache.StatusOffline
+	}
+
+	return resp
+}
+
+// GetFleetConnectivity lists online/offline status for every vehicle that ever reported.
+// ?status=online|offline filters the list.
+func (h *FleetHandler) GetFleetConnectivity(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	lastSeen, err := cache.GetLastSeen(ctx, h.Rdb)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cache error"})
+		return
+	}
+	statuses, err := cache.GetStatuses(ctx, h.Rdb)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cache error"})
+		return
+	}
+
+	filter := c.Query("status")
+	p := auth.FromContext(c)
+	now := time.Now()
+	resp := make([]connectivityResponse, 0, len(lastSeen))
+	for vehicleId, seen := range lastSeen {
+		if !p.AllowsVehicle(vehicleId) {
+			continue
+		}
+		var st *cache.ConnectivityStatus
+		if s, ok := statuses[vehicleId]; ok {
+			st = &s
+		}
+
+		item := connectivityOf(vehicleId, seen, st, now)
+		if filter != "" && item.Status != filter {
+			continue
+		}
+		resp = append(resp, item)
+	}
+
+	sort.Slice(resp, func(i, j int) bool { return resp[i].VehicleId < resp[j].VehicleId })
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  resp,
+		"count": len(resp),
+	})
+}
+
+func (h *FleetHandler) GetVehicleConnectivity(c *gin.Context) {
+	vehicleId := c.Param("vehicle_id")
+	ctx := c.Request.Context()
+
+	seen, ok, err := cache.GetVehicleLastSeen(ctx, h.Rdb, vehicleId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cache error"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "vehicle has never reported"})
+		return
+	}
+
+	st, err := cache.GetStatus(ctx, h.Rdb, vehicleId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cache error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, connectivityOf(vehicleId, seen, st, time.Now()))
+}
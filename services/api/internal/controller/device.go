@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	mqttpkg "tj/pkg/mqtt"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/openapi"
+)
+
+type DeviceHandler struct {
+	DB *gorm.DB
+}
+
+func NewDeviceHandler(dbConn *gorm.DB) *DeviceHandler {
+	return &DeviceHandler{DB: dbConn}
+}
+
+type deviceRequest openapi.DeviceRequest
+
+type deviceResponse struct {
+	*model.Device
+	// only returned on create
+	Password string `json:"password,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+}
+
+// ListDevices: GET /devices?vehicle_id=, revoked devices included
+func (h *DeviceHandler) ListDevices(c *gin.Context) {
+	q := h.DB.Order("id ASC")
+	if ids, ok := auth.FromContext(c).VehicleIds(); ok {
+		q = q.Where("vehicle_id IN ?", ids)
+	}
+	if v := c.Query("vehicle_id"); v != "" {
+		q = q.Where("vehicle_id = ?", v)
+	}
+
+	var devices []model.Device
+	if err := q.Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  devices,
+		"count": len(devices),
+	})
+}
+
+// CreateDevice: POST /devices. Unknown vehicles are registered to the caller's operator.
+// The password is only ever shown in this response.
+func (h *DeviceHandler) CreateDevice(c *gin.Context) {
+	var req deviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	// the vehicle id is a topic segment
+	if req.VehicleId == "" || strings.ContainsAny(req.VehicleId, "/+#") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle_id is required and may not contain / + #"})
+		return
+	}
+
+	p := auth.FromContext(c)
+	var v model.Vehicle
+	err := h.DB.First(&v, "vehicle_id = ?", req.VehicleId).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// a scoped caller couldn't see the new vehicle
+		if !p.Scope.Unrestricted() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "vehicle not in scope"})
+			return
+		}
+		v = model.Vehicle{VehicleId: req.VehicleId, OperatorId: p.OperatorId}
+		if err := h.DB.Select("vehicle_id", "operator_id").Create(&v).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	case !p.AllowsVehicle(v.VehicleId):
+		c.JSON(http.StatusForbidden, gin.H{"error": "vehicle not in scope"})
+		return
+	}
+
+	username, password := auth.GenerateDeviceCredentials()
+	d := model.Device{
+		VehicleId:    v.VehicleId,
+		Username:     username,
+		PasswordHash: auth.HashKey(password),
+		Name:         req.Name,
+		CreatedBy:    &p.Subject,
+	}
+	if err := h.DB.Create(&d).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	operatorId := ""
+	if v.OperatorId != nil {
+		operatorId = *v.OperatorId
+	}
+	c.JSON(http.StatusCreated, deviceResponse{
+		Device:   &d,
+		Password: password,
+		Topic:    mqttpkg.LocationTopic(operatorId, v.VehicleId),
+	})
+}
+
+// RevokeDevice: DELETE /devices/:id. The broker rejects the credentials once its auth
+// cache expires.
+func (h *DeviceHandler) RevokeDevice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var d model.Device
+	if err := h.DB.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	if !auth.FromContext(c).AllowsVehicle(d.VehicleId) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+
+	if d.RevokedAt == nil {
+		if err := h.DB.Model(&d).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
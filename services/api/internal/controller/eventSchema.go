@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"tj/pkg/events"
+)
+
+type EventSchemaHandler struct{}
+
+func NewEventSchemaHandler() *EventSchemaHandler {
+	return &EventSchemaHandler{}
+}
+
+// ListSchemas lists every event type with its current schema version: GET /events/schemas
+func (h *EventSchemaHandler) ListSchemas(c *gin.Context) {
+	types := events.Types()
+	data := make([]gin.H, 0, len(types))
+	for _, t := range types {
+		data = append(data, gin.H{"type": t, "version": events.Version(t)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"count": len(data),
+	})
+}
+
+// GetSchema returns the JSON Schema of one event type: GET /events/schemas/:type
+func (h *EventSchemaHandler) GetSchema(c *gin.Context) {
+	s, err := events.Schema(c.Param("type"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/export"
+)
+
+const (
+	maxExportRange    = 31 * 24 * time.Hour
+	maxExportVehicles = 50
+)
+
+type ExportHandler struct {
+	DB *gorm.DB
+}
+
+func NewExportHandler(dbConn *gorm.DB) *ExportHandler {
+	return &ExportHandler{DB: dbConn}
+}
+
+// ExportVehicle streams a single vehicle's track: GET /vehicles/:vehicle_id/export
+func (h *ExportHandler) ExportVehicle(c *gin.Context) {
+	h.export(c, []string{c.Param("vehicle_id")})
+}
+
+// ExportVehicles streams several vehicles at once: GET /vehicles/export?vehicle_id=a,b
+func (h *ExportHandler) ExportVehicles(c *gin.Context) {
+	ids := queryList(c, "vehicle_id")
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle_id is required"})
+		return
+	}
+	if len(ids) > maxExportVehicles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d vehicles per export", maxExportVehicles)})
+		return
+	}
+	p := auth.FromContext(c)
+	for _, id := range ids {
+		if !p.AllowsVehicle(id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("vehicle %s not in scope", id)})
+			return
+		}
+	}
+
+	h.export(c, ids)
+}
+
+func (h *ExportHandler) export(c *gin.Context, vehicleIds []string) {
+	format, err := export.Lookup(c.DefaultQuery("format", "csv"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, err := parseTimeBound(c.Query("start"))
+	if err != nil || start == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start is required (unix or RFC3339)"})
+		return
+	}
+	end, err := parseTimeBound(c.Query("end"))
+	if err != nil || end == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end is required (unix or RFC3339)"})
+		return
+	}
+	if *end < *start || time.Duration(*end-*start)*time.Second > maxExportRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "time range must be positive and at most 31 days"})
+		return
+	}
+
+	rows, err := h.DB.WithContext(c.Request.Context()).
+		Model(&model.VehicleLocation{}).
+		Scopes(auth.FromContext(c).Tenant("vehicle_locations")).
+		Where("vehicle_id IN ? AND timestamp >= ? AND timestamp <= ?", vehicleIds, *start, *end).
+		Order("vehicle_id ASC, timestamp ASC, id ASC").
+		Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("%s_%d_%d.%s", safeFilename(vehicleIds[0]), *start, *end, format.Extension)
+	if len(vehicleIds) > 1 {
+		filename = fmt.Sprintf("fleet_%d_%d.%s", *start, *end, format.Extension)
+	}
+	c.Header("Content-Type", format.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// headers are already sent from here on, so failures can only be logged and the body cut short
+	w := format.New(c.Writer)
+	if err := w.Begin(); err != nil {
+		log.Printf("export %s begin error: %v", format.Name, err)
+		return
+	}
+
+	n := 0
+	for rows.Next() {
+		var loc model.VehicleLocation
+		if err := h.DB.ScanRows(rows, &loc); err != nil {
+			log.Printf("export %s scan error: %v", format.Name, err)
+			return
+		}
+		if err := w.Write(&loc); err != nil {
+			log.Printf("export %s write error: %v", format.Name, err)
+			return
+		}
+
+		n++
+		if n%1000 == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("export %s rows error: %v", format.Name, err)
+		return
+	}
+
+	if err := w.End(); err != nil {
+		log.Printf("export %s end error: %v", format.Name, err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+func safeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	model "tj/pkg/model"
+	cache "tj/pkg/redis"
+	"tj/services/api/internal/auth"
+)
+
+var errBoundingBoxIncomplete = errors.New("min_lat, min_lon, max_lat and max_lon must be given together")
+
+type FleetHandler struct {
+	Rdb *redis.Client
+}
+
+func NewFleetHandler(rdb *redis.Client) *FleetHandler {
+	return &FleetHandler{Rdb: rdb}
+}
+
+type boundingBox struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+func (b *boundingBox) contains(lat, lon float64) bool {
+	return lat >= b.minLat && lat <= b.maxLat && lon >= b.minLon && lon <= b.maxLon
+}
+
+// GetFleetLocations returns every vehicle's current position from the latest-location cache.
+// Optional filters: min_lat/min_lon/max_lat/max_lon (all four) and max_age in seconds.
+func (h *FleetHandler) GetFleetLocations(c *gin.Context) {
+	bbox, err := parseBoundingBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var minTimestamp int64
+	if maxAgeStr := c.Query("max_age"); maxAgeStr != "" {
+		maxAge, err := strconv.ParseInt(maxAgeStr, 10, 64)
+		if err != nil || maxAge <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_age"})
+			return
+		}
+
+		minTimestamp = time.Now().Unix() - maxAge
+	}
+
+	locs, err := cache.GetLatestLocations(c.Request.Context(), h.Rdb)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cache error"})
+		return
+	}
+
+	p := auth.FromContext(c)
+	resp := make([]model.MQTTLocationStruct, 0, len(locs))
+	for _, l := range locs {
+		if !p.AllowsVehicle(l.VehicleId) {
+			continue
+		}
+		if l.Timestamp < minTimestamp {
+			continue
+		}
+		if bbox != nil && !bbox.contains(l.Latitude, l.Longitude) {
+			continue
+		}
+		resp = append(resp, l)
+	}
+
+	sort.Slice(resp, func(i, j int) bool { return resp[i].VehicleId < resp[j].VehicleId })
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         resp,
+		"count":        len(resp),
+		"generated_at": time.Now().Unix(),
+	})
+}
+
+func parseBoundingBox(c *gin.Context) (*boundingBox, error) {
+	keys := []string{"min_lat", "min_lon", "max_lat", "max_lon"}
+	vals := make([]float64, 0, len(keys))
+
+	for _, k := range keys {
+		s := c.Query(k)
+		if s == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", k)
+		}
+		vals = append(vals, v)
+	}
+
+	switch len(vals) {
+	case 0:
+		return nil, nil
+	case len(keys):
+		bbox := &boundingBox{minLat: vals[0], minLon: vals[1], maxLat: vals[2], maxLon: vals[3]}
+		if bbox.minLat > bbox.maxLat || bbox.minLon > bbox.maxLon {
+			return nil, errors.New("invalid bounding box")
+		}
+		return bbox, nil
+	default:
+		return nil, errBoundingBoxIncomplete
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/location"
+)
+
+const (
+	defaultGeofenceLimit = 100
+	maxGeofenceLimit     = 1000
+)
+
+type GeofenceHandler struct {
+	Events repository.GeofenceEventRepository
+	Visits repository.VisitRepository
+}
+
+func NewGeofenceHandler(repos *repository.Repositories) *GeofenceHandler {
+	return &GeofenceHandler{Events: repos.GeofenceEvents, Visits: repos.Visits}
+}
+
+type geofencePage struct {
+	Data       interface{} `json:"data"`
+	Count      int         `json:"count"`
+	HasMore    bool        `json:"has_more"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// ListEvents: GET /geofence/events?vehicle_id=&station_id=&type=entry|exit&start=&end=
+func (h *GeofenceHandler) ListEvents(c *gin.Context) {
+	h.listEvents(c, c.Query("vehicle_id"), c.Query("station_id"))
+}
+
+// ListVehicleEvents: GET /vehicles/:vehicle_id/geofence-events
+func (h *GeofenceHandler) ListVehicleEvents(c *gin.Context) {
+	h.listEvents(c, c.Param("vehicle_id"), c.Query("station_id"))
+}
+
+// ListVisits: GET /geofence/visits?vehicle_id=&station_id=&start=&end=&open=true
+func (h *GeofenceHandler) ListVisits(c *gin.Context) {
+	h.listVisits(c, c.Query("vehicle_id"), c.Query("station_id"))
+}
+
+// ListVehicleVisits: GET /vehicles/:vehicle_id/visits
+func (h *GeofenceHandler) ListVehicleVisits(c *gin.Context) {
+	h.listVisits(c, c.Param("vehicle_id"), c.Query("station_id"))
+}
+
+// ListStationVisits: GET /stations/:station_id/visits
+func (h *GeofenceHandler) ListStationVisits(c *gin.Context) {
+	h.listVisits(c, c.Query("vehicle_id"), c.Param("station_id"))
+}
+
+func (h *GeofenceHandler) listEvents(c *gin.Context, vehicleId, stationId string) {
+	sq, limit, err := parseGeofenceQuery(c, vehicleId, stationId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	eq := repository.EventQuery{StationQuery: sq}
+	if t := c.Query("type"); t != "" {
+		if t != model.GeofenceEntry && t != model.GeofenceExit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be entry or exit"})
+			return
+		}
+		eq.EventType = t
+	}
+
+	events, err := h.Events.List(c.Request.Context(), auth.FromContext(c).Filter(), eq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	page := geofencePage{}
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[len(events)-1]
+		next := location.Cursor{Timestamp: last.Timestamp, Id: last.Id}.Encode()
+		page.HasMore, page.NextCursor = true, &next
+	}
+	page.Data, page.Count = events, len(events)
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *GeofenceHandler) listVisits(c *gin.Context, vehicleId, stationId string) {
+	sq, limit, err := parseGeofenceQuery(c, vehicleId, stationId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vq := repository.VisitQuery{StationQuery: sq}
+	switch c.Query("open") {
+	case "":
+	case "true", "false":
+		open := c.Query("open") == "true"
+		vq.Open = &open
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "open must be true or false"})
+		return
+	}
+
+	visits, err := h.Visits.List(c.Request.Context(), auth.FromContext(c).Filter(), vq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	page := geofencePage{}
+	if len(visits) > limit {
+		visits = visits[:limit]
+		last := visits[len(visits)-1]
+		next := location.Cursor{Timestamp: last.ArrivalTime, Id: last.Id}.Encode()
+		page.HasMore, page.NextCursor = true, &next
+	}
+	page.Data, page.Count = visits, len(visits)
+
+	c.JSON(http.StatusOK, page)
+}
+
+// parseGeofenceQuery reads the filters shared by the event and visit listings. The query
+// asks for one row past limit, which tells whether another page exists.
+func parseGeofenceQuery(c *gin.Context, vehicleId, stationId string) (sq repository.StationQuery, limit int, err error) {
+	sq.VehicleId, limit = vehicleId, defaultGeofenceLimit
+
+	if stationId != "" {
+		id, err := strconv.ParseInt(stationId, 10, 64)
+		if err != nil {
+			return sq, 0, fmt.Errorf("invalid station_id")
+		}
+		sq.StationId = &id
+	}
+
+	if sq.Range.Start, err = parseTimeBound(c.Query("start")); err != nil {
+		return sq, 0, fmt.Errorf("invalid start")
+	}
+	if sq.Range.End, err = parseTimeBound(c.Query("end")); err != nil {
+		return sq, 0, fmt.Errorf("invalid end")
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxGeofenceLimit {
+			return sq, 0, fmt.Errorf("limit must be between 1 and %d", maxGeofenceLimit)
+		}
+	}
+	sq.Limit = limit + 1
+
+	if cur := c.Query("cursor"); cur != "" {
+		after, err := location.DecodeCursor(cur)
+		if err != nil {
+			return sq, 0, fmt.Errorf("invalid cursor")
+		}
+		sq.After = &repository.Keyset{Timestamp: after.Timestamp, Id: after.Id}
+	}
+
+	return sq, limit, nil
+}
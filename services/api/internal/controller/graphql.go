@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/graph"
+)
+
+// graphql-transport-ws, the protocol of the graphql-ws client library
+const (
+	gqlSubprotocol  = "graphql-transport-ws"
+	gqlInitTimeout  = 10 * time.Second
+	gqlReadLimit    = 64 * 1024
+	gqlMaxQueryBody = 64 * 1024
+)
+
+type GraphQLHandler struct {
+	Schema   *graph.Schema
+	upgrader websocket.Upgrader
+}
+
+func NewGraphQLHandler(schema *graph.Schema) *GraphQLHandler {
+	return &GraphQLHandler{
+		Schema: schema,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			Subprotocols:    []string{gqlSubprotocol},
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Query: POST /graphql. Errors are reported in the response body with 200, as GraphQL
+// clients expect; only unreadable requests get 400.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graph.Request
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, gqlMaxQueryBody)
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil || req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be a JSON object with a query"})
+		return
+	}
+
+	ctx := auth.NewContext(c.Request.Context(), auth.FromContext(c))
+	c.JSON(http.StatusOK, h.Schema.Exec(ctx, &req))
+}
+
+type gqlMessage struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Subscribe: GET /graphql, upgraded to a websocket speaking graphql-transport-ws. Each
+// subscribe message runs one operation until it ends or the client completes it.
+func (h *GraphQLHandler) Subscribe(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("graphql websocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ws := &gqlConn{conn: conn}
+	if conn.Subprotocol() != gqlSubprotocol {
+		ws.close(4406, "Subprotocol not acceptable")
+		return
+	}
+
+	conn.SetReadLimit(gqlReadLimit)
+	conn.SetReadDeadline(time.Now().Add(gqlInitTimeout))
+	var init gqlMessage
+	if err := conn.ReadJSON(&init); err != nil || init.Type != "connection_init" {
+		ws.close(4408, "Connection initialisation timeout")
+		return
+	}
+	if err := ws.send(gqlMessage{Type: "connection_ack"}); err != nil {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	ctx, cancel := context.WithCancel(auth.NewContext(c.Request.Context(), auth.FromContext(c)))
+	defer cancel()
+	go ws.keepAlive(ctx)
+
+	ops := make(map[string]context.CancelFunc)
+	var mu sync.Mutex
+	for {
+		var msg gqlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		switch msg.Type {
+		case "ping":
+			ws.send(gqlMessage{Type: "pong"})
+
+		case "pong":
+
+		case "subscribe":
+			var req graph.Request
+			if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.Id == "" {
+				ws.close(4400, "Invalid subscribe message")
+				return
+			}
+
+			mu.Lock()
+			if _, ok := ops[msg.Id]; ok {
+				mu.Unlock()
+				ws.close(4409, "Subscriber for "+msg.Id+" already exists")
+				return
+			}
+			opCtx, opCancel := context.WithCancel(ctx)
+			ops[msg.Id] = opCancel
+			mu.Unlock()
+
+			go func(id string) {
+				h.run(opCtx, ws, id, &req)
+				mu.Lock()
+				delete(ops, id)
+				mu.Unlock()
+				opCancel()
+			}(msg.Id)
+
+		case "complete":
+			mu.Lock()
+			if opCancel, ok := ops[msg.Id]; ok {
+				opCancel()
+				delete(ops, msg.Id)
+			}
+			mu.Unlock()
+
+		default:
+			ws.close(4400, "Unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// run streams one operation's results. Requests rejected before execution get a single
+// error message, as the protocol asks; everything else ends with complete unless the
+// client completed it first.
+func (h *GraphQLHandler) run(ctx context.Context, ws *gqlConn, id string, req *graph.Request) {
+	results, err := h.Schema.Subscribe(ctx, req)
+	if err != nil {
+		ws.sendErrors(id, []gin.H{{"message": err.Error()}})
+		return
+	}
+
+	first := true
+	for r := range results {
+		resp := r.(*graphql.Response)
+		if first && resp.Data == nil && len(resp.Errors) > 0 {
+			ws.sendErrors(id, resp.Errors)
+			return
+		}
+		first = false
+
+		payload, err := json.Marshal(resp)
+		if err != nil {
+			log.Printf("graphql marshal response error: %v", err)
+			continue
+		}
+		if err := ws.send(gqlMessage{Id: id, Type: "next", Payload: payload}); err != nil {
+			return
+		}
+	}
+
+	if ctx.Err() == nil {
+		ws.send(gqlMessage{Id: id, Type: "complete"})
+	}
+}
+
+// gqlConn serializes writes, gorilla allows a single writer and every operation runs in
+// its own goroutine.
+type gqlConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (w *gqlConn) send(msg gqlMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return w.conn.WriteJSON(msg)
+}
+
+func (w *gqlConn) sendErrors(id string, errs interface{}) {
+	payload, err := json.Marshal(errs)
+	if err != nil {
+		log.Printf("graphql marshal errors error: %v", err)
+		return
+	}
+	w.send(gqlMessage{Id: id, Type: "error", Payload: payload})
+}
+
+func (w *gqlConn) close(code int, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(code, reason)
+	w.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+}
+
+func (w *gqlConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			err := w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			w.mu.Unlock()
+			if err != nil {
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tj/pkg/gtfs"
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+)
+
+// vehicles that haven't reported within this window are left out of the feed
+const vehiclePositionsMaxAge = 15 * time.Minute
+
+type GTFSRealtimeHandler struct {
+	DB *gorm.DB
+}
+
+func NewGTFSRealtimeHandler(dbConn *gorm.DB) *GTFSRealtimeHandler {
+	return &GTFSRealtimeHandler{DB: dbConn}
+}
+
+// VehiclePositions serves a full-dataset GTFS-Realtime VehiclePositions feed built from the
+// latest row per vehicle in vehicle_locations. ?format=json returns the same feed as JSON for debugging.
+func (h *GTFSRealtimeHandler) VehiclePositions(c *gin.Context) {
+	now := time.Now()
+	since := now.Add(-vehiclePositionsMaxAge).Unix()
+
+	p := auth.FromContext(c)
+	var rows []model.VehicleLocation
+	err := h.DB.Model(&model.VehicleLocation{}).
+		Select("DISTINCT ON (vehicle_id) id, vehicle_id, latitude, longitude, timestamp, created_at").
+		Scopes(p.Tenant("vehicle_locations")).
+		Where("timestamp >= ?", since).
+		Order("vehicle_id, timestamp DESC").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	feed := gtfs.VehiclePositionsFeed{
+		Header: gtfs.FeedHeader{
+			Version:        gtfs.RealtimeVersion,
+			Incrementality: gtfs.FullDataset,
+			Timestamp:      uint64(now.Unix()),
+		},
+		Entities: make([]gtfs.VehiclePosition, 0, len(rows)),
+	}
+	for _, r := range rows {
+		if !p.AllowsVehicle(r.VehicleId) {
+			continue
+		}
+		feed.Entities = append(feed.Entities, gtfs.VehiclePosition{
+			EntityId:  fmt.Sprintf("vehicle-%s", r.VehicleId),
+			VehicleId: r.VehicleId,
+			Latitude:  float32(r.Latitude),
+			Longitude: float32(r.Longitude),
+			Timestamp: uint64(r.Timestamp),
+		})
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, feed)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/x-protobuf", feed.Marshal())
+}
@@ -1,21 +1,26 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	model "tj/pkg/model"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/location"
 )
 
 type VehicleHandler struct {
-	DB *gorm.DB
+	Trips     repository.TripRepository
+	Locations *location.Query
 }
 
-func NewVehicleHandler(dbConn *gorm.DB) *VehicleHandler {
-	return &VehicleHandler{DB: dbConn}
+func NewVehicleHandler(repos *repository.Repositories) *VehicleHandler {
+	return &VehicleHandler{Trips: repos.Trips, Locations: location.NewQuery(repos.Locations)}
 }
 
 func (h *VehicleHandler) GetLastLocation(c *gin.Context) {
@@ -25,13 +30,8 @@ func (h *VehicleHandler) GetLastLocation(c *gin.Context) {
 		return
 	}
 
-	var loc model.VehicleLocation
-	err := h.DB.
-		Where("vehicle_id = ?", vehicleId).
-		Order("timestamp DESC").
-		Limit(1).
-		Take(&loc).Error
-	if err == gorm.ErrRecordNotFound {
+	loc, err := h.Locations.Last(c.Request.Context(), auth.FromContext(c), vehicleId)
+	if errors.Is(err, location.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
 		return
 	} else if err != nil {
@@ -46,6 +46,8 @@ func (h *VehicleHandler) GetLastLocation(c *gin.Context) {
 			Latitude:  loc.Latitude,
 			Longitude: loc.Longitude,
 			Timestamp: loc.Timestamp,
+			Ignition:  loc.Ignition,
+			Speed:     loc.Speed,
 		},
 		CreatedAt: loc.CreatedAt,
 	}
@@ -53,64 +55,70 @@ func (h *VehicleHandler) GetLastLocation(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// GetHistory pages through a vehicle's points with a keyset cursor on (timestamp, id),
+// so deep pages cost the same as the first one.
 func (h *VehicleHandler) GetHistory(c *gin.Context) {
-	var (
-        start *int64
-        end   *int64
-    )
-
 	vehicleID := c.Param("vehicle_id")
-	startStr := c.Query("start")
-    if startStr != "" {
-        v, err := strconv.ParseInt(startStr, 10, 64)
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start"})
-            return
-        }
-
-        start = &v
-    }
-
-	endStr := c.Query("end")
-    if endStr != "" {
-        v, err := strconv.ParseInt(endStr, 10, 64)
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end"})
-            return
-        }
-
-        end = &v
-    }
-
-	limitStr := c.DefaultQuery("limit", "10")
-    limit, err := strconv.Atoi(limitStr)
-    if err != nil || limit <= 0 {
-        limit = 10
-    }
-
-	offsetStr := c.DefaultQuery("offset", "0")
-    offset, err := strconv.Atoi(offsetStr)
-    if err != nil || offset < 0 {
-        offset = 0
-    }
-
-	q := h.DB.Where("vehicle_id = ?", vehicleID)
-
-    if start != nil {
-        q = q.Where("timestamp >= ?", *start)
-    }
-    if end != nil {
-        q = q.Where("timestamp <= ?", *end)
-    }
-
-	var rows []model.VehicleLocation
-	if err := q.Order("timestamp ASC").Limit(limit).Offset(offset).Find(&rows).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
-        return
-    }
-
-	resp := make([]model.VehicleLocation, 0, len(rows))
-	for _, r := range rows {
+
+	if c.Query("offset") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset is not supported, follow next_cursor instead"})
+		return
+	}
+
+	start, err := parseTimeBound(c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start"})
+		return
+	}
+	end, err := parseTimeBound(c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end"})
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit)})
+			return
+		}
+	}
+
+	order := c.DefaultQuery("order", "asc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	var after *location.Cursor
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		after, err = location.DecodeCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	page, err := h.Locations.History(c.Request.Context(), auth.FromContext(c), location.HistoryParams{
+		VehicleId: vehicleID,
+		Start:     start,
+		End:       end,
+		Limit:     limit,
+		Desc:      order == "desc",
+		After:     after,
+		WithTotal: c.Query("include_total") == "true",
+	})
+	if errors.Is(err, location.ErrCursorMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor belongs to a different query, keep order, start and end unchanged"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	resp := make([]model.VehicleLocation, 0, len(page.Rows))
+	for _, r := range page.Rows {
 		resp = append(resp, model.VehicleLocation{
 			Id: r.Id,
 			MQTTLocationStruct: model.MQTTLocationStruct{
@@ -118,10 +126,24 @@ func (h *VehicleHandler) GetHistory(c *gin.Context) {
 				Latitude:  r.Latitude,
 				Longitude: r.Longitude,
 				Timestamp: r.Timestamp,
+				Ignition:  r.Ignition,
+				Speed:     r.Speed,
 			},
 			CreatedAt: r.CreatedAt,
 		})
 	}
 
-	c.JSON(http.StatusOK, resp)
+	var nextCursor *string
+	if page.Next != nil {
+		cur := page.Next.Encode()
+		nextCursor = &cur
+	}
+
+	c.JSON(http.StatusOK, historyPage{
+		Data:       resp,
+		Count:      len(resp),
+		Total:      page.Total,
+		HasMore:    page.HasMore,
+		NextCursor: nextCursor,
+	})
 }
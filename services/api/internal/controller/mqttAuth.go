@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	mqttpkg "tj/pkg/mqtt"
+	"tj/services/api/internal/auth"
+)
+
+// mosquitto-go-auth ACL access: 1 read, 2 write (publish), 3 readwrite, 4 subscribe
+const mqttAccWrite = 2
+
+// last_auth_at is only bumped when it is older than this, brokers re-check often
+const deviceAuthResolution = time.Minute
+
+// MQTTAuthHandler is the HTTP backend of the broker's auth plugin (mosquitto-go-auth
+// "http" backend, status or json response mode). Devices authenticate with the credentials from
+// POST /devices and may only publish on their vehicle's location topic; the service account
+// is a superuser.
+type MQTTAuthHandler struct {
+	DB              *gorm.DB
+	ServiceUser     string
+	ServicePassword string
+}
+
+func NewMQTTAuthHandler(dbConn *gorm.DB, serviceUser, servicePassword string) *MQTTAuthHandler {
+	return &MQTTAuthHandler{DB: dbConn, ServiceUser: serviceUser, ServicePassword: servicePassword}
+}
+
+// mqttAuthRequest covers the user, superuser and acl checks, sent as JSON or a form.
+type mqttAuthRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+	ClientId string `json:"clientid" form:"clientid"`
+	Topic    string `json:"topic" form:"topic"`
+	Acc      int    `json:"acc" form:"acc"`
+}
+
+// User: POST /mqtt/user
+func (h *MQTTAuthHandler) User(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBind(&req); err != nil || req.Username == "" {
+		mqttDeny(c, "invalid request")
+		return
+	}
+
+	if h.isService(req.Username) {
+		if subtle.ConstantTimeCompare([]byte(req.Password), []byte(h.ServicePassword)) != 1 {
+			mqttDeny(c, "invalid credentials")
+			return
+		}
+		mqttAllow(c)
+		return
+	}
+
+	d, ok := h.findDevice(c, req.Username)
+	if !ok {
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.HashKey(req.Password)), []byte(d.PasswordHash)) != 1 {
+		mqttDeny(c, "invalid credentials")
+		return
+	}
+
+	now := time.Now()
+	if d.LastAuthAt == nil || now.Sub(*d.LastAuthAt) > deviceAuthResolution {
+		if err := h.DB.Model(d).UpdateColumn("last_auth_at", now).Error; err != nil {
+			log.Printf("mqtt auth: update last_auth_at for device %d error: %v", d.Id, err)
+		}
+	}
+
+	mqttAllow(c)
+}
+
+// Superuser: POST /mqtt/superuser. Only the service account.
+func (h *MQTTAuthHandler) Superuser(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBind(&req); err != nil || !h.isService(req.Username) {
+		mqttDeny(c, "not a superuser")
+		return
+	}
+
+	mqttAllow(c)
+}
+
+// ACL: POST /mqtt/acl. A device may publish on its vehicle's location topic, under its
+// operator or the legacy topic, and nothing else.
+func (h *MQTTAuthHandler) ACL(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBind(&req); err != nil || req.Username == "" {
+		mqttDeny(c, "invalid request")
+		return
+	}
+	if h.isService(req.Username) {
+		mqttAllow(c)
+		return
+	}
+	if req.Acc != mqttAccWrite {
+		mqttDeny(c, "devices may only publish")
+		return
+	}
+
+	d, ok := h.findDevice(c, req.Username)
+	if !ok {
+		return
+	}
+	operatorId, vehicleId, ok := mqttpkg.ParseLocationTopic(req.Topic)
+	if !ok || vehicleId != d.VehicleId {
+		mqttDeny(c, "topic not allowed")
+		return
+	}
+	if operatorId != "" {
+		var v model.Vehicle
+		if err := h.DB.Select("vehicle_id", "operator_id").First(&v, "vehicle_id = ?", d.VehicleId).Error; err != nil {
+			mqttError(c, err)
+			return
+		}
+		if v.OperatorId == nil || *v.OperatorId != operatorId {
+			mqttDeny(c, "topic not allowed")
+			return
+		}
+	}
+
+	mqttAllow(c)
+}
+
+// isService is false for everyone while the service password is empty, so the superuser
+// can't log in without one.
+func (h *MQTTAuthHandler) isService(username string) bool {
+	return h.ServiceUser != "" && h.ServicePassword != "" && username == h.ServiceUser
+}
+
+// findDevice returns the active device with the username, or writes a denial.
+func (h *MQTTAuthHandler) findDevice(c *gin.Context, username string) (*model.Device, bool) {
+	var d model.Device
+	err := h.DB.Where("username = ? AND revoked_at IS NULL", username).First(&d).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mqttDeny(c, "unknown device")
+		return nil, false
+	}
+	if err != nil {
+		mqttError(c, err)
+		return nil, false
+	}
+
+	return &d, true
+}
+
+// responses work with the plugin's status and json response modes
+func mqttAllow(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"ok": true, "error": ""})
+}
+
+func mqttDeny(c *gin.Context, msg string) {
+	c.JSON(http.StatusForbidden, gin.H{"ok": false, "error": msg})
+}
+
+func mqttError(c *gin.Context, err error) {
+	log.Printf("mqtt auth: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "error": "db error"})
+}
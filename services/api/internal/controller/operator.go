@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/openapi"
+)
+
+// operator ids are also MQTT topic segments, so no wildcards or separators
+var operatorIdPattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+type OperatorHandler struct {
+	DB *gorm.DB
+}
+
+func NewOperatorHandler(dbConn *gorm.DB) *OperatorHandler {
+	return &OperatorHandler{DB: dbConn}
+}
+
+// ListOperators: GET /operators. Operator keys only see their own.
+func (h *OperatorHandler) ListOperators(c *gin.Context) {
+	var ops []model.Operator
+	if err := h.DB.Scopes(auth.FromContext(c).Tenant("operators")).Order("operator_id ASC").Find(&ops).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  ops,
+		"count": len(ops),
+	})
+}
+
+// CreateOperator: POST /operators
+func (h *OperatorHandler) CreateOperator(c *gin.Context) {
+	var req openapi.OperatorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	op := model.Operator{OperatorId: req.OperatorId, Name: req.Name}
+	if !operatorIdPattern.MatchString(op.OperatorId) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operator_id must match " + operatorIdPattern.String()})
+		return
+	}
+	if strings.TrimSpace(op.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	if err := h.DB.Select("operator_id", "name").Create(&op).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "operator already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, op)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+const (
+	defaultHistoryLimit = 10
+	maxHistoryLimit     = 1000
+)
+
+type historyPage struct {
+	Data       []model.VehicleLocation `json:"data"`
+	Count      int                     `json:"count"`
+	Total      *int64                  `json:"total,omitempty"`
+	HasMore    bool                    `json:"has_more"`
+	NextCursor *string                 `json:"next_cursor"`
+}
+
+// parseTimeBound accepts either a unix timestamp or an RFC3339 time. Empty means unbounded.
+func parseTimeBound(s string) (*int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &v, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	v := t.Unix()
+
+	return &v, nil
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/pkg/rules"
+	"tj/services/api/internal/openapi"
+)
+
+type RuleHandler struct {
+	DB *gorm.DB
+}
+
+func NewRuleHandler(dbConn *gorm.DB) *RuleHandler {
+	return &RuleHandler{DB: dbConn}
+}
+
+// ruleRequest is the body of POST/PUT /rules. Enabled defaults to true when omitted.
+type ruleRequest openapi.RuleRequest
+
+func (req *ruleRequest) apply(r *model.AlertRule) {
+	r.Key = req.Key
+	r.Name = req.Name
+	r.Description = req.Description
+	r.Severity = req.Severity
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	r.Enabled = req.Enabled == nil || *req.Enabled
+	r.Conditions = req.Conditions
+	r.DurationS = req.DurationS
+	r.CooldownS = req.CooldownS
+}
+
+// ListRules returns every rule, enabled or not: GET /rules
+func (h *RuleHandler) ListRules(c *gin.Context) {
+	var list []model.AlertRule
+	if err := h.DB.Order("id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  list,
+		"count": len(list),
+	})
+}
+
+// GetRule returns a single rule: GET /rules/:id
+func (h *RuleHandler) GetRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateRule stores a new rule: POST /rules
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	var rule model.AlertRule
+	req.apply(&rule)
+	if err := rules.Validate(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.DB.Create(&rule).Error; err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule replaces a rule: PUT /rules/:id
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	req.apply(rule)
+	if err := rules.Validate(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.DB.Save(rule).Error; err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes a rule: DELETE /rules/:id
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	res := h.DB.Delete(&model.AlertRule{}, id)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *RuleHandler) findRule(c *gin.Context) (*model.AlertRule, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	var rule model.AlertRule
+	if err := h.DB.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return nil, false
+	}
+
+	return &rule, true
+}
+
+func (h *RuleHandler) writeError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "rule key already exists"})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/stream"
+)
+
+const (
+	streamPingInterval = 15 * time.Second
+	wsWriteTimeout     = 10 * time.Second
+	wsPongTimeout      = 2 * streamPingInterval
+)
+
+type StreamHandler struct {
+	Hub      *stream.Hub
+	upgrader websocket.Upgrader
+}
+
+func NewStreamHandler(hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{
+		Hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Stream payload formats. The default is the compact stream event; cloudevents sends every
+// event as a structured CloudEvent instead.
+const (
+	streamFormatDefault     = ""
+	streamFormatCloudEvents = "cloudevents"
+)
+
+// subscribeMessage is sent by websocket clients to replace their subscription.
+type subscribeMessage struct {
+	Action string `json:"action"`
+	stream.Filter
+}
+
+// WebSocket streams location and geofence events. The initial subscription comes from the
+// query string (same as SSE); clients may send {"action":"subscribe", ...filter} at any time.
+func (h *StreamHandler) WebSocket(c *gin.Context) {
+	filter, err := parseStreamFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, err := parseStreamFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("websocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := h.Hub.Register(filter)
+	defer h.Hub.Unregister(client)
+	p := auth.FromContext(c)
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	// gorilla allows a single writer, so the reader hands errors to the write loop
+	readerDone := make(chan struct{})
+	readerErrs := make(chan string, 1)
+	go func() {
+		defer close(readerDone)
+		for {
+			var msg subscribeMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Action != "subscribe" {
+				continue
+			}
+
+			f := msg.Filter
+			if err := f.Validate(); err != nil {
+				select {
+				case readerErrs <- err.Error():
+				default:
+				}
+				continue
+			}
+			f.Restrict(p.AllowsVehicle)
+			client.SetFilter(&f)
+		}
+	}()
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case evt := <-client.Events():
+			if n := client.TakeDropped(); n > 0 {
+				if err := h.writeWS(conn, droppedNotice(n)); err != nil {
+					return
+				}
+			}
+			if err := h.writeWS(conn, streamPayload(evt, format)); err != nil {
+				return
+			}
+
+		case msg := <-readerErrs:
+			if err := h.writeWS(conn, gin.H{"type": "error", "error": msg}); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-client.Done():
+			code := websocket.ClosePolicyViolation
+			if client.Reason() == stream.ReasonShutdown {
+				code = websocket.CloseGoingAway
+			}
+			msg := websocket.FormatCloseMessage(code, client.Reason())
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+			return
+
+		case <-readerDone:
+			return
+		}
+	}
+}
+
+// SSE streams the same events as Server-Sent Events, filtered by query string only.
+func (h *StreamHandler) SSE(c *gin.Context) {
+	filter, err := parseStreamFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, err := parseStreamFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client := h.Hub.Register(filter)
+	defer h.Hub.Unregister(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case evt := <-client.Events():
+			if n := client.TakeDropped(); n > 0 {
+				c.SSEvent("stream.dropped", droppedNotice(n))
+			}
+			c.SSEvent(evt.Type, streamPayload(evt, format))
+			return true
+
+		case <-ticker.C:
+			// comment line keeps idle connections open through proxies
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+
+		case <-client.Done():
+			c.SSEvent("stream.closed", gin.H{"reason": client.Reason()})
+			return false
+
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (h *StreamHandler) writeWS(conn *websocket.Conn, v interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(v)
+}
+
+func droppedNotice(n int64) gin.H {
+	return gin.H{"type": "stream.dropped", "data": gin.H{"count": n}}
+}
+
+func parseStreamFormat(c *gin.Context) (string, error) {
+	switch f := c.Query("format"); f {
+	case streamFormatDefault, streamFormatCloudEvents:
+		return f, nil
+	default:
+		return "", fmt.Errorf("format must be empty or cloudevents")
+	}
+}
+
+func streamPayload(evt *stream.Event, format string) interface{} {
+	if format == streamFormatCloudEvents {
+		return evt.CloudEvent()
+	}
+	return evt
+}
+
+// parseStreamFilter reads vehicle_id, route_id, events (comma separated or repeated)
+// and bbox=min_lat,min_lon,max_lat,max_lon.
+func parseStreamFilter(c *gin.Context) (*stream.Filter, error) {
+	f := &stream.Filter{
+		VehicleIds: queryList(c, "vehicle_id"),
+		RouteIds:   queryList(c, "route_id"),
+		Events:     queryList(c, "events"),
+	}
+
+	if s := c.Query("bbox"); s != "" {
+		for _, part := range strings.Split(s, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bbox")
+			}
+			f.BBox = append(f.BBox, v)
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	f.Restrict(auth.FromContext(c).AllowsVehicle)
+
+	return f, nil
+}
+
+func queryList(c *gin.Context, key string) []string {
+	var out []string
+	for _, v := range c.QueryArray(key) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+
+	return out
+}
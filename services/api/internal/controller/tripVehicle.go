@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+)
+
+const (
+	defaultTripLimit = 50
+	maxTripLimit     = 500
+)
+
+// GetTrips lists a vehicle's completed trips, newest first. start/end (unix or RFC3339)
+// bound the trip start time.
+func (h *VehicleHandler) GetTrips(c *gin.Context) {
+	vehicleID := c.Param("vehicle_id")
+
+	start, err := parseTimeBound(c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start"})
+		return
+	}
+	end, err := parseTimeBound(c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end"})
+		return
+	}
+
+	limit := defaultTripLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxTripLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTripLimit)})
+			return
+		}
+	}
+
+	trips, err := h.Trips.List(c.Request.Context(), auth.FromContext(c).Filter(), repository.TripQuery{
+		VehicleId: vehicleID,
+		Range:     repository.TimeRange{Start: start, End: end},
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  trips,
+		"count": len(trips),
+	})
+}
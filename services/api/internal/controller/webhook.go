@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tj/config"
+	model "tj/pkg/model"
+	"tj/pkg/webhook"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/openapi"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookHandler manages webhook subscriptions. They are notification_subscribers rows with
+// channel "webhook"; the notifier service does the signing, retrying and delivery logging.
+type WebhookHandler struct {
+	DB *gorm.DB
+}
+
+func NewWebhookHandler(dbConn *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{DB: dbConn}
+}
+
+// webhookRequest is the body of POST/PUT /webhooks. A secret is generated when none is
+// given on create; on update an empty secret keeps the current one.
+type webhookRequest openapi.WebhookRequest
+
+type webhookResponse struct {
+	*model.NotificationSubscriber
+	URL string `json:"url"`
+	// only returned when the secret was generated or changed
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookResponse(sub *model.NotificationSubscriber, secret string) webhookResponse {
+	return webhookResponse{NotificationSubscriber: sub, URL: sub.Target, Secret: secret}
+}
+
+func (req *webhookRequest) validate() error {
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("name is required")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	if !config.Cfg.WebhookAllowInternal {
+		if err := webhook.CheckURL(req.URL); err != nil {
+			return errors.New("url must not point to a loopback, private or link-local address")
+		}
+	}
+	if len(req.EventTypes) == 0 {
+		return errors.New("event_types is required, e.g. [\"geofence.entry\"] or [\"#\"] for everything")
+	}
+	for _, p := range req.EventTypes {
+		for _, word := range strings.Split(p, ".") {
+			if word == "" {
+				return fmt.Errorf("invalid event type pattern %q", p)
+			}
+		}
+	}
+	if req.RateLimitPerMin < 0 {
+		return errors.New("rate_limit_per_min must not be negative")
+	}
+	switch req.PayloadFormat {
+	case "":
+		req.PayloadFormat = model.PayloadEnvelope
+	case model.PayloadEnvelope, model.PayloadCloudEvents, model.PayloadCloudEventsBinary:
+	default:
+		return errors.New("payload_format must be envelope, cloudevents or cloudevents-binary")
+	}
+
+	return nil
+}
+
+func (req *webhookRequest) apply(sub *model.NotificationSubscriber) {
+	sub.Name = req.Name
+	sub.Channel = model.ChannelWebhook
+	sub.Target = req.URL
+	sub.EventTypes = req.EventTypes
+	sub.VehicleIds = req.VehicleIds
+	sub.RouteIds = req.RouteIds
+	sub.RateLimitPerMin = req.RateLimitPerMin
+	sub.PayloadFormat = req.PayloadFormat
+
+	enabled := req.Enabled == nil || *req.Enabled
+	if enabled && !sub.Enabled {
+		// re-enabling starts a fresh failure streak
+		sub.ConsecutiveFailures = 0
+		sub.DisabledAt = nil
+		sub.DisabledReason = nil
+	}
+	sub.Enabled = enabled
+}
+
+// ListWebhooks: GET /webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	var subs []model.NotificationSubscriber
+	err := h.DB.Scopes(auth.FromContext(c).Tenant("notification_subscribers")).
+		Where("channel = ?", model.ChannelWebhook).
+		Order("id ASC").
+		Find(&subs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	data := make([]webhookResponse, len(subs))
+	for i := range subs {
+		data[i] = newWebhookResponse(&subs[i], "")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"count": len(data),
+	})
+}
+
+// GetWebhook: GET /webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	sub, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookResponse(sub, ""))
+}
+
+// CreateWebhook: POST /webhooks. The secret is only ever shown in this response.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+
+	var sub model.NotificationSubscriber
+	req.apply(&sub)
+	sub.Secret = &secret
+	// webhooks of operator keys only receive that operator's events
+	sub.OperatorId = auth.FromContext(c).OperatorId
+
+	if err := h.DB.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newWebhookResponse(&sub, secret))
+}
+
+// UpdateWebhook: PUT /webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	sub, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(sub)
+	if req.Secret != "" {
+		sub.Secret = &req.Secret
+	}
+
+	if err := h.DB.Save(sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookResponse(sub, req.Secret))
+}
+
+// DeleteWebhook: DELETE /webhooks/:id. Its delivery log goes with it.
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	res := h.DB.Scopes(auth.FromContext(c).Tenant("notification_subscribers")).
+		Where("channel = ?", model.ChannelWebhook).
+		Delete(&model.NotificationSubscriber{}, id)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDeliveries lists delivery attempts, newest first: GET /webhooks/:id/deliveries
+// Optional status filter (sent, retrying, failed, rate_limited) and before=<id> to page back.
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	sub, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxDeliveryLimit)})
+			return
+		}
+	}
+
+	q := h.DB.Where("subscriber_id = ?", sub.Id)
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case model.DeliverySent, model.DeliveryRetrying, model.DeliveryFailed, model.DeliveryRateLimited:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+			return
+		}
+		q = q.Where("status = ?", status)
+	}
+	if deliveryId := c.Query("delivery_id"); deliveryId != "" {
+		q = q.Where("delivery_id = ?", deliveryId)
+	}
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		before, err := strconv.ParseInt(beforeStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+			return
+		}
+		q = q.Where("id < ?", before)
+	}
+
+	var rows []model.NotificationDelivery
+	if err := q.Order("id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+
+	var next *int64
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next = &rows[limit-1].Id
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        rows,
+		"count":       len(rows),
+		"next_before": next,
+	})
+}
+
+func (h *WebhookHandler) findWebhook(c *gin.Context) (*model.NotificationSubscriber, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	var sub model.NotificationSubscriber
+	err = h.DB.Scopes(auth.FromContext(c).Tenant("notification_subscribers")).
+		Where("channel = ?", model.ChannelWebhook).
+		First(&sub, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return nil, false
+	}
+
+	return &sub, true
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,37 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin() error {
+	return c.w.Write([]string{"vehicle_id", "timestamp", "time", "latitude", "longitude"})
+}
+
+func (c *csvWriter) Write(loc *model.VehicleLocation) error {
+	return c.w.Write([]string{
+		loc.VehicleId,
+		strconv.FormatInt(loc.Timestamp, 10),
+		time.Unix(loc.Timestamp, 0).UTC().Format(time.RFC3339),
+		strconv.FormatFloat(loc.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(loc.Longitude, 'f', -1, 64),
+	})
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
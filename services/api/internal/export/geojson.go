@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+// geoJSONLineWriter emits a FeatureCollection with one LineString feature per vehicle.
+// A vehicle with a single point becomes a Point, since a LineString needs two positions.
+type geoJSONLineWriter struct {
+	w        *bufio.Writer
+	features int
+
+	vehicle string
+	first   *model.VehicleLocation
+	points  int
+	startTs int64
+	endTs   int64
+}
+
+func newGeoJSONLineWriter(w io.Writer) Writer {
+	return &geoJSONLineWriter{w: bufio.NewWriter(w)}
+}
+
+func (g *geoJSONLineWriter) Begin() error {
+	_, err := g.w.WriteString(`{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (g *geoJSONLineWriter) Write(loc *model.VehicleLocation) error {
+	if loc.VehicleId != g.vehicle {
+		if err := g.closeFeature(); err != nil {
+			return err
+		}
+		g.vehicle = loc.VehicleId
+		g.startTs = loc.Timestamp
+		g.points = 0
+		cp := *loc
+		g.first = &cp
+	}
+
+	g.points++
+	g.endTs = loc.Timestamp
+
+	switch g.points {
+	case 1:
+		// hold the first point until we know whether this is a line
+		return nil
+	case 2:
+		if err := g.openFeature("LineString"); err != nil {
+			return err
+		}
+		writePosition(g.w, g.first)
+		g.w.WriteByte(',')
+	default:
+		g.w.WriteByte(',')
+	}
+	writePosition(g.w, loc)
+
+	return nil
+}
+
+func (g *geoJSONLineWriter) End() error {
+	if err := g.closeFeature(); err != nil {
+		return err
+	}
+	g.w.WriteString(`]}`)
+
+	return g.w.Flush()
+}
+
+func (g *geoJSONLineWriter) openFeature(geomType string) error {
+	if g.features > 0 {
+		g.w.WriteByte(',')
+	}
+	g.features++
+
+	// properties go after the geometry: start/end are only known once the track is complete
+	_, err := g.w.WriteString(`{"type":"Feature","geometry":{"type":"` + geomType + `","coordinates":`)
+	if geomType == "LineString" {
+		g.w.WriteByte('[')
+	}
+
+	return err
+}
+
+func (g *geoJSONLineWriter) closeFeature() error {
+	switch {
+	case g.points == 0:
+		return nil
+	case g.points == 1:
+		if err := g.openFeature("Point"); err != nil {
+			return err
+		}
+		writePosition(g.w, g.first)
+		g.w.WriteString(`}`)
+	default:
+		g.w.WriteString(`]}`)
+	}
+
+	vehicle, _ := json.Marshal(g.vehicle)
+	g.w.WriteString(`,"properties":{"vehicle_id":`)
+	g.w.Write(vehicle)
+	g.w.WriteString(`,"points":` + strconv.Itoa(g.points))
+	g.w.WriteString(`,"start":"` + time.Unix(g.startTs, 0).UTC().Format(time.RFC3339) + `"`)
+	_, err := g.w.WriteString(`,"end":"` + time.Unix(g.endTs, 0).UTC().Format(time.RFC3339) + `"}}`)
+
+	g.points = 0
+
+	return err
+}
+
+// geoJSONPointWriter emits one Point feature per location with its timestamp.
+type geoJSONPointWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newGeoJSONPointWriter(w io.Writer) Writer {
+	return &geoJSONPointWriter{w: bufio.NewWriter(w)}
+}
+
+func (g *geoJSONPointWriter) Begin() error {
+	_, err := g.w.WriteString(`{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (g *geoJSONPointWriter) Write(loc *model.VehicleLocation) error {
+	if g.count > 0 {
+		g.w.WriteByte(',')
+	}
+	g.count++
+
+	vehicle, _ := json.Marshal(loc.VehicleId)
+	g.w.WriteString(`{"type":"Feature","properties":{"vehicle_id":`)
+	g.w.Write(vehicle)
+	g.w.WriteString(`,"timestamp":` + strconv.FormatInt(loc.Timestamp, 10))
+	g.w.WriteString(`,"time":"` + time.Unix(loc.Timestamp, 0).UTC().Format(time.RFC3339) + `"`)
+	g.w.WriteString(`},"geometry":{"type":"Point","coordinates":`)
+	writePosition(g.w, loc)
+	_, err := g.w.WriteString(`}}`)
+
+	return err
+}
+
+func (g *geoJSONPointWriter) End() error {
+	g.w.WriteString(`]}`)
+	return g.w.Flush()
+}
+
+// GeoJSON positions are [longitude, latitude]
+func writePosition(w *bufio.Writer, loc *model.VehicleLocation) {
+	w.WriteByte('[')
+	w.WriteString(strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+	w.WriteByte(',')
+	w.WriteString(strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+	w.WriteByte(']')
+}
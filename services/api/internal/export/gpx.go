@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+// gpxWriter emits one <trk> per vehicle with a single <trkseg>.
+type gpxWriter struct {
+	w       *bufio.Writer
+	vehicle string
+	open    bool
+}
+
+func newGPXWriter(w io.Writer) Writer {
+	return &gpxWriter{w: bufio.NewWriter(w)}
+}
+
+func (g *gpxWriter) Begin() error {
+	g.w.WriteString(xml.Header)
+	_, err := g.w.WriteString(`<gpx version="1.1" creator="fleet-tracker" xmlns="http://www.topografix.com/GPX/1/1">` + "\n")
+	return err
+}
+
+func (g *gpxWriter) Write(loc *model.VehicleLocation) error {
+	if !g.open || loc.VehicleId != g.vehicle {
+		g.closeTrack()
+		g.vehicle = loc.VehicleId
+		g.open = true
+
+		g.w.WriteString("<trk><name>")
+		xml.EscapeText(g.w, []byte(loc.VehicleId))
+		g.w.WriteString("</name><trkseg>\n")
+	}
+
+	g.w.WriteString(`<trkpt lat="` + strconv.FormatFloat(loc.Latitude, 'f', -1, 64) +
+		`" lon="` + strconv.FormatFloat(loc.Longitude, 'f', -1, 64) + `">`)
+	_, err := g.w.WriteString("<time>" + time.Unix(loc.Timestamp, 0).UTC().Format(time.RFC3339) + "</time></trkpt>\n")
+
+	return err
+}
+
+func (g *gpxWriter) End() error {
+	g.closeTrack()
+	g.w.WriteString("</gpx>\n")
+
+	return g.w.Flush()
+}
+
+func (g *gpxWriter) closeTrack() {
+	if g.open {
+		g.w.WriteString("</trkseg></trk>\n")
+		g.open = false
+	}
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	model "tj/pkg/model"
+)
+
+// kmlWriter emits one Placemark per vehicle, a LineString or, for a vehicle with a single
+// point, a Point: a LineString needs at least two coordinates.
+type kmlWriter struct {
+	w       *bufio.Writer
+	vehicle string
+	open    bool
+	// the placemark's first coordinate, held back until the geometry is known
+	first  string
+	points int
+}
+
+func newKMLWriter(w io.Writer) Writer {
+	return &kmlWriter{w: bufio.NewWriter(w)}
+}
+
+func (k *kmlWriter) Begin() error {
+	k.w.WriteString(xml.Header)
+	_, err := k.w.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>fleet-tracker export</name>` + "\n")
+	return err
+}
+
+func (k *kmlWriter) Write(loc *model.VehicleLocation) error {
+	if !k.open || loc.VehicleId != k.vehicle {
+		k.closePlacemark()
+		k.vehicle = loc.VehicleId
+		k.open = true
+
+		k.points = 0
+
+		k.w.WriteString("<Placemark><name>")
+		xml.EscapeText(k.w, []byte(loc.VehicleId))
+		k.w.WriteString("</name>")
+	}
+
+	// KML coordinates are lon,lat[,alt]
+	coord := strconv.FormatFloat(loc.Longitude, 'f', -1, 64) + "," +
+		strconv.FormatFloat(loc.Latitude, 'f', -1, 64)
+
+	k.points++
+	switch k.points {
+	case 1:
+		k.first = coord
+		return nil
+	case 2:
+		k.w.WriteString("<LineString><tessellate>1</tessellate><coordinates>\n" + k.first + "\n")
+	}
+	_, err := k.w.WriteString(coord + "\n")
+
+	return err
+}
+
+func (k *kmlWriter) End() error {
+	k.closePlacemark()
+	k.w.WriteString("</Document></kml>\n")
+
+	return k.w.Flush()
+}
+
+func (k *kmlWriter) closePlacemark() {
+	if !k.open {
+		return
+	}
+	if k.points == 1 {
+		k.w.WriteString("<Point><coordinates>" + k.first + "</coordinates></Point></Placemark>\n")
+	} else {
+		k.w.WriteString("</coordinates></LineString></Placemark>\n")
+	}
+	k.open = false
+}
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	model "tj/pkg/model"
+)
+
+func TestKMLUsesAPointForASinglePoint(t *testing.T) {
+	var buf bytes.Buffer
+	w := newKMLWriter(&buf)
+
+	w.Begin()
+	for _, loc := range []model.MQTTLocationStruct{
+		{VehicleId: "bus-1", Latitude: -6.2, Longitude: 106.8},
+		{VehicleId: "bus-1", Latitude: -6.3, Longitude: 106.9},
+		{VehicleId: "bus-2", Latitude: -6.1, Longitude: 106.7},
+	} {
+		if err := w.Write(&model.VehicleLocation{MQTTLocationStruct: loc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Placemarks []struct {
+			Name       string `xml:"name"`
+			LineString *struct {
+				Coordinates string `xml:"coordinates"`
+			} `xml:"LineString"`
+			Point *struct {
+				Coordinates string `xml:"coordinates"`
+			} `xml:"Point"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	if len(doc.Placemarks) != 2 {
+		t.Fatalf("%d placemarks, want 2:\n%s", len(doc.Placemarks), buf.String())
+	}
+
+	line, point := doc.Placemarks[0], doc.Placemarks[1]
+	if line.LineString == nil || strings.Join(strings.Fields(line.LineString.Coordinates), " ") != "106.8,-6.2 106.9,-6.3" {
+		t.Errorf("bus-1 placemark %+v, want a LineString of both points", line)
+	}
+	if point.LineString != nil || point.Point == nil || point.Point.Coordinates != "106.7,-6.1" {
+		t.Errorf("bus-2 placemark %+v, want a Point", point)
+	}
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	model "tj/pkg/model"
+)
+
+// Writer streams points in (vehicle_id, timestamp) order. Implementations write as they go
+// and only keep per-vehicle state, so exports of any size run in constant memory.
+type Writer interface {
+	Begin() error
+	Write(loc *model.VehicleLocation) error
+	End() error
+}
+
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) Writer
+}
+
+var formats = map[string]Format{
+	"csv":            {"csv", "text/csv; charset=utf-8", "csv", newCSVWriter},
+	"geojson":        {"geojson", "application/geo+json", "geojson", newGeoJSONLineWriter},
+	"geojson-points": {"geojson-points", "application/geo+json", "geojson", newGeoJSONPointWriter},
+	"gpx":            {"gpx", "application/gpx+xml", "gpx", newGPXWriter},
+	"kml":            {"kml", "application/vnd.google-earth.kml+xml", "kml", newKMLWriter},
+}
+
+func Lookup(name string) (Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unsupported format %q (csv, geojson, geojson-points, gpx, kml)", name)
+	}
+
+	return f, nil
+}
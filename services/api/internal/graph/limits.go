@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+type Limits struct {
+	// deepest field nesting, 0 = unlimited
+	MaxDepth int
+	// estimated field resolutions, 0 = unlimited
+	MaxComplexity int
+}
+
+// analyzer estimates the cost of an operation before it runs. Every field costs 1 and a
+// list field taking first costs its children's cost times first, so
+// vehicles(first: 100) { history(first: 50) { latitude } } costs 1 + 100 * (1 + 50).
+// Introspection fields are free, GraphiQL's schema query would exceed any sane depth.
+type analyzer struct {
+	schema *ast.Schema
+	limits Limits
+}
+
+func newAnalyzer(schemaString string, l Limits) (*analyzer, error) {
+	s, err := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphqls", Input: schemaString})
+	if err != nil {
+		return nil, err
+	}
+
+	return &analyzer{schema: s, limits: l}, nil
+}
+
+// check rejects operations over the limits. Invalid documents pass, the executor reports
+// them with better messages.
+func (a *analyzer) check(query, operationName string, variables map[string]any) error {
+	if a.limits.MaxDepth <= 0 && a.limits.MaxComplexity <= 0 {
+		return nil
+	}
+
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return nil
+	}
+	op := operation(doc, operationName)
+	if op == nil {
+		return nil
+	}
+
+	root := a.schema.Query
+	if op.Operation == ast.Subscription {
+		root = a.schema.Subscription
+	}
+
+	w := &walk{a: a, doc: doc, vars: variables, active: map[string]bool{}}
+	depth, cost := w.selections(op.SelectionSet, root)
+	if a.limits.MaxDepth > 0 && depth > a.limits.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, a.limits.MaxDepth)
+	}
+	if a.limits.MaxComplexity > 0 && cost > a.limits.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, a.limits.MaxComplexity)
+	}
+
+	return nil
+}
+
+func operation(doc *ast.QueryDocument, name string) *ast.OperationDefinition {
+	if name == "" {
+		if len(doc.Operations) == 1 {
+			return doc.Operations[0]
+		}
+		return nil
+	}
+
+	return doc.Operations.ForName(name)
+}
+
+type walk struct {
+	a    *analyzer
+	doc  *ast.QueryDocument
+	vars map[string]any
+	// fragments on the current path, cycles are left to validation
+	active map[string]bool
+}
+
+func (w *walk) selections(set ast.SelectionSet, parent *ast.Definition) (depth, cost int) {
+	if parent == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = w.field(sel, parent)
+
+		case *ast.InlineFragment:
+			typ := parent
+			if sel.TypeCondition != "" {
+				typ = w.a.schema.Types[sel.TypeCondition]
+			}
+			d, c = w.selections(sel.SelectionSet, typ)
+
+		case *ast.FragmentSpread:
+			frag := w.doc.Fragments.ForName(sel.Name)
+			if frag == nil || w.active[sel.Name] {
+				continue
+			}
+			w.active[sel.Name] = true
+			d, c = w.selections(frag.SelectionSet, w.a.schema.Types[frag.TypeCondition])
+			w.active[sel.Name] = false
+		}
+
+		depth = max(depth, d)
+		cost += c
+	}
+
+	return depth, cost
+}
+
+func (w *walk) field(f *ast.Field, parent *ast.Definition) (depth, cost int) {
+	if strings.HasPrefix(f.Name, "__") {
+		return 0, 0
+	}
+	def := parent.Fields.ForName(f.Name)
+	if def == nil {
+		return 0, 0
+	}
+
+	d, c := w.selections(f.SelectionSet, w.a.schema.Types[def.Type.Name()])
+	if def.Type.Elem != nil {
+		c *= w.first(f, def)
+	}
+
+	return d + 1, c + 1
+}
+
+// first is the page size a list field was asked for, its default, or 1 when it has none.
+func (w *walk) first(f *ast.Field, def *ast.FieldDefinition) int {
+	argDef := def.Arguments.ForName("first")
+	if argDef == nil {
+		return 1
+	}
+
+	var v *ast.Value
+	if arg := f.Arguments.ForName("first"); arg != nil {
+		v = arg.Value
+	} else {
+		v = argDef.DefaultValue
+	}
+	if v == nil {
+		return 1
+	}
+
+	n := 0
+	switch v.Kind {
+	case ast.IntValue:
+		n, _ = strconv.Atoi(v.Raw)
+	case ast.Variable:
+		if x, ok := w.vars[v.Raw].(float64); ok {
+			n = int(x)
+		} else if argDef.DefaultValue != nil {
+			n, _ = strconv.Atoi(argDef.DefaultValue.Raw)
+		}
+	}
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
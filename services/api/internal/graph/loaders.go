@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/location"
+)
+
+type loadersKey struct{}
+
+// loaders batch the lookups of one operation: a page of vehicles asking for their last
+// location costs one query, not one per vehicle. They cache for the whole operation, so
+// they are never shared between callers.
+type loaders struct {
+	vehicles     *dataloader.Loader[string, *model.Vehicle]
+	lastLocation *dataloader.Loader[string, *model.VehicleLocation]
+	stations     *dataloader.Loader[int64, *model.BusStation]
+
+	db *gorm.DB
+	p  *auth.Principal
+	// every station the caller sees, for nearbyStations
+	allOnce     sync.Once
+	allStations []model.BusStation
+	allErr      error
+}
+
+func newLoaders(db *gorm.DB, locations *location.Query, p *auth.Principal) *loaders {
+	return &loaders{
+		db: db,
+		p:  p,
+
+		vehicles: dataloader.NewBatchedLoader(func(ctx context.Context, ids []string) []*dataloader.Result[*model.Vehicle] {
+			var rows []model.Vehicle
+			err := db.WithContext(ctx).Scopes(p.Tenant("vehicles")).Where("vehicle_id IN ?", ids).Find(&rows).Error
+			byId := make(map[string]*model.Vehicle, len(rows))
+			for i := range rows {
+				byId[rows[i].VehicleId] = &rows[i]
+			}
+			return results(ids, byId, err)
+		}),
+
+		lastLocation: dataloader.NewBatchedLoader(func(ctx context.Context, ids []string) []*dataloader.Result[*model.VehicleLocation] {
+			last, err := locations.LastByVehicles(ctx, p, ids)
+			return results(ids, last, err)
+		}),
+
+		stations: dataloader.NewBatchedLoader(func(ctx context.Context, ids []int64) []*dataloader.Result[*model.BusStation] {
+			var rows []model.BusStation
+			err := db.WithContext(ctx).Scopes(stationTenant(p)).Where("id IN ?", ids).Find(&rows).Error
+			byId := make(map[int64]*model.BusStation, len(rows))
+			for i := range rows {
+				byId[rows[i].Id] = &rows[i]
+			}
+			return results(ids, byId, err)
+		}),
+	}
+}
+
+// visibleStations loads the caller's stations once per operation. There are few enough
+// that the distance is computed here rather than in SQL, like the worker does.
+func (l *loaders) visibleStations(ctx context.Context) ([]model.BusStation, error) {
+	l.allOnce.Do(func() {
+		l.allErr = l.db.WithContext(ctx).Scopes(stationTenant(l.p)).Find(&l.allStations).Error
+	})
+
+	return l.allStations, l.allErr
+}
+
+// results lines values up with keys; missing keys resolve to nil.
+func results[K comparable, V any](keys []K, byKey map[K]*V, err error) []*dataloader.Result[*V] {
+	out := make([]*dataloader.Result[*V], len(keys))
+	for i, k := range keys {
+		if err != nil {
+			out[i] = &dataloader.Result[*V]{Error: err}
+			continue
+		}
+		out[i] = &dataloader.Result[*V]{Data: byKey[k]}
+	}
+
+	return out
+}
+
+// stationTenant limits stations to the shared ones and the caller's operator's.
+func stationTenant(p *auth.Principal) func(*gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		if p.OperatorId == nil {
+			return q
+		}
+		return q.Where("bus_stations.operator_id IS NULL OR bus_stations.operator_id = ?", *p.OperatorId)
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"tj/pkg/events"
+	"tj/pkg/geofence"
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/stream"
+)
+
+// page sizes are capped like the REST listings
+const maxFirst = 1000
+
+var (
+	errDB       = errors.New("db error")
+	errNotScope = errors.New("vehicle not in scope")
+)
+
+// resolver is the root of Query and Subscription.
+type resolver struct {
+	deps Deps
+}
+
+func (r *resolver) Vehicles(ctx context.Context, args struct {
+	GroupName *string
+	RouteId   *string
+	First     int32
+}) ([]*vehicleResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	p := auth.PrincipalFrom(ctx)
+	q := r.deps.DB.WithContext(ctx).Scopes(p.Tenant("vehicles"))
+	if ids, ok := p.VehicleIds(); ok {
+		q = q.Where("vehicle_id IN ?", ids)
+	}
+	if args.GroupName != nil {
+		q = q.Where("group_name = ?", *args.GroupName)
+	}
+	if args.RouteId != nil {
+		q = q.Where("route_id = ?", *args.RouteId)
+	}
+
+	var rows []model.Vehicle
+	if err := q.Order("vehicle_id").Limit(n).Find(&rows).Error; err != nil {
+		return nil, dbError(err)
+	}
+
+	l := loadersFrom(ctx)
+	out := make([]*vehicleResolver, len(rows))
+	for i := range rows {
+		l.vehicles.Prime(ctx, rows[i].VehicleId, &rows[i])
+		out[i] = &vehicleResolver{r: r, v: &rows[i]}
+	}
+
+	return out, nil
+}
+
+func (r *resolver) Vehicle(ctx context.Context, args struct{ Id graphql.ID }) (*vehicleResolver, error) {
+	id := string(args.Id)
+	if !auth.PrincipalFrom(ctx).AllowsVehicle(id) {
+		return nil, errNotScope
+	}
+
+	return r.vehicle(ctx, id)
+}
+
+func (r *resolver) vehicle(ctx context.Context, id string) (*vehicleResolver, error) {
+	v, err := loadersFrom(ctx).vehicles.Load(ctx, id)()
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	return &vehicleResolver{r: r, v: v}, nil
+}
+
+func (r *resolver) NearbyStations(ctx context.Context, args struct {
+	Latitude     float64
+	Longitude    float64
+	RadiusMeters float64
+	First        int32
+}) ([]*nearbyStationResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	return nearbyStations(ctx, args.Latitude, args.Longitude, args.RadiusMeters, n)
+}
+
+func (r *resolver) GeofenceEvents(ctx context.Context, args struct {
+	VehicleId *graphql.ID
+	StationId *graphql.ID
+	Type      *string
+	Start     *graphql.Time
+	End       *graphql.Time
+	First     int32
+}) ([]*geofenceEventResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	p := auth.PrincipalFrom(ctx)
+	q := r.deps.DB.WithContext(ctx).Scopes(p.Tenant("geofence_events"))
+	if args.VehicleId != nil {
+		if !p.AllowsVehicle(string(*args.VehicleId)) {
+			return nil, errNotScope
+		}
+		q = q.Where("vehicle_id = ?", string(*args.VehicleId))
+	}
+	if ids, ok := p.VehicleIds(); ok {
+		q = q.Where("vehicle_id IN ?", ids)
+	}
+	if args.StationId != nil {
+		id, err := strconv.ParseInt(string(*args.StationId), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid stationId")
+		}
+		q = q.Where("station_id = ?", id)
+	}
+	if args.Type != nil {
+		q = q.Where("event_type = ?", eventType(*args.Type))
+	}
+	if args.Start != nil {
+		q = q.Where("timestamp >= ?", args.Start.Unix())
+	}
+	if args.End != nil {
+		q = q.Where("timestamp <= ?", args.End.Unix())
+	}
+
+	var rows []model.GeofenceEvent
+	if err := q.Order("timestamp ASC, id ASC").Limit(n).Find(&rows).Error; err != nil {
+		return nil, dbError(err)
+	}
+
+	return r.geofenceEvents(rows), nil
+}
+
+func (r *resolver) geofenceEvents(rows []model.GeofenceEvent) []*geofenceEventResolver {
+	out := make([]*geofenceEventResolver, len(rows))
+	for i := range rows {
+		out[i] = &geofenceEventResolver{r: r, e: &rows[i]}
+	}
+
+	return out
+}
+
+// Locations relays location.raw events from the stream hub, the feed behind /stream/ws.
+func (r *resolver) Locations(ctx context.Context, args struct {
+	VehicleIds *[]graphql.ID
+	RouteIds   *[]graphql.ID
+	Bbox       *struct {
+		MinLat float64
+		MinLon float64
+		MaxLat float64
+		MaxLon float64
+	}
+}) (<-chan *locationResolver, error) {
+	f := &stream.Filter{
+		VehicleIds: ids(args.VehicleIds),
+		RouteIds:   ids(args.RouteIds),
+		Events:     []string{events.TypeLocationRaw},
+	}
+	if b := args.Bbox; b != nil {
+		f.BBox = []float64{b.MinLat, b.MinLon, b.MaxLat, b.MaxLon}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	f.Restrict(auth.PrincipalFrom(ctx).AllowsVehicle)
+
+	client := r.deps.Hub.Register(f)
+	out := make(chan *locationResolver)
+	go func() {
+		defer close(out)
+		defer r.deps.Hub.Unregister(client)
+
+		for {
+			select {
+			case evt := <-client.Events():
+				var raw events.LocationRaw
+				if err := json.Unmarshal(evt.Data, &raw); err != nil {
+					log.Printf("graphql: decode %s event error: %v", evt.Type, err)
+					continue
+				}
+				select {
+				case out <- &locationResolver{r: r, loc: liveLocation(&raw)}:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-client.Done():
+				return
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func nearbyStations(ctx context.Context, lat, lon, radius float64, n int) ([]*nearbyStationResolver, error) {
+	stations, err := loadersFrom(ctx).visibleStations(ctx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	var out []*nearbyStationResolver
+	for i := range stations {
+		d := geofence.HaversineMeters(lat, lon, stations[i].Latitude, stations[i].Longitude)
+		if d <= radius {
+			out = append(out, &nearbyStationResolver{s: &stations[i], distance: d})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].distance < out[j].distance })
+	if len(out) > n {
+		out = out[:n]
+	}
+
+	return out, nil
+}
+
+func pageSize(first int32) (int, error) {
+	if first < 1 || first > maxFirst {
+		return 0, fmt.Errorf("first must be between 1 and %d", maxFirst)
+	}
+
+	return int(first), nil
+}
+
+func ids(vals *[]graphql.ID) []string {
+	if vals == nil {
+		return nil
+	}
+
+	out := make([]string, len(*vals))
+	for i, v := range *vals {
+		out[i] = string(v)
+	}
+
+	return out
+}
+
+func dbError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	log.Printf("graphql: query error: %v", err)
+	return errDB
+}
@@ -0,0 +1,91 @@
+// Package graph is the GraphQL API over vehicles, locations, stations and geofence events.
+// Reads go through per-operation dataloaders and the location query layer shared with REST
+// and gRPC; operations are checked against depth and complexity limits before they run.
+package graph
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/graph-gophers/graphql-go"
+	qerrors "github.com/graph-gophers/graphql-go/errors"
+	"gorm.io/gorm"
+
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/stream"
+)
+
+//go:embed schema.graphqls
+var schemaString string
+
+// resolvers run concurrently up to this many, enough for a page of vehicles to land in
+// one dataloader batch
+const maxParallelism = 100
+
+type Deps struct {
+	DB        *gorm.DB
+	Locations *location.Query
+	Hub       *stream.Hub
+}
+
+// Request is a GraphQL-over-HTTP request body, also the payload of websocket subscribe
+// messages.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Schema struct {
+	schema   *graphql.Schema
+	analyzer *analyzer
+	deps     Deps
+}
+
+func NewSchema(d Deps, l Limits) (*Schema, error) {
+	a, err := newAnalyzer(schemaString, l)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := graphql.ParseSchema(schemaString, &resolver{deps: d},
+		graphql.UseStringDescriptions(),
+		graphql.MaxParallelism(maxParallelism),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{schema: s, analyzer: a, deps: d}, nil
+}
+
+// Exec runs a query for the caller stored in ctx by auth.NewContext.
+func (s *Schema) Exec(ctx context.Context, req *Request) *graphql.Response {
+	if err := s.analyzer.check(req.Query, req.OperationName, req.Variables); err != nil {
+		return errorResponse(err)
+	}
+
+	return s.schema.Exec(s.withLoaders(ctx), req.Query, req.OperationName, req.Variables)
+}
+
+// Subscribe runs a subscription, or a query answered once, until ctx is done. The channel
+// carries *graphql.Response values and is closed at the end.
+func (s *Schema) Subscribe(ctx context.Context, req *Request) (<-chan any, error) {
+	if err := s.analyzer.check(req.Query, req.OperationName, req.Variables); err != nil {
+		c := make(chan any, 1)
+		c <- errorResponse(err)
+		close(c)
+		return c, nil
+	}
+
+	return s.schema.Subscribe(s.withLoaders(ctx), req.Query, req.OperationName, req.Variables)
+}
+
+func (s *Schema) withLoaders(ctx context.Context) context.Context {
+	return withLoaders(ctx, newLoaders(s.deps.DB, s.deps.Locations, auth.PrincipalFrom(ctx)))
+}
+
+func errorResponse(err error) *graphql.Response {
+	return &graphql.Response{Errors: []*qerrors.QueryError{{Message: err.Error()}}}
+}
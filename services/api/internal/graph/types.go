@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/location"
+)
+
+type vehicleResolver struct {
+	r *resolver
+	v *model.Vehicle
+}
+
+func (v *vehicleResolver) ID() graphql.ID        { return graphql.ID(v.v.VehicleId) }
+func (v *vehicleResolver) Label() *string        { return v.v.Label }
+func (v *vehicleResolver) LicensePlate() *string { return v.v.LicensePlate }
+func (v *vehicleResolver) RouteId() *string      { return v.v.RouteId }
+func (v *vehicleResolver) GroupName() *string    { return v.v.GroupName }
+
+func (v *vehicleResolver) LastLocation(ctx context.Context) (*locationResolver, error) {
+	loc, err := loadersFrom(ctx).lastLocation.Load(ctx, v.v.VehicleId)()
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if loc == nil {
+		return nil, nil
+	}
+
+	return &locationResolver{r: v.r, loc: loc}, nil
+}
+
+func (v *vehicleResolver) History(ctx context.Context, args struct {
+	Start      *graphql.Time
+	End        *graphql.Time
+	First      int32
+	Descending bool
+}) ([]*locationResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	hp := location.HistoryParams{
+		VehicleId: v.v.VehicleId,
+		Start:     unix(args.Start),
+		End:       unix(args.End),
+		Limit:     n,
+		Desc:      args.Descending,
+	}
+	page, err := v.r.deps.Locations.History(ctx, auth.PrincipalFrom(ctx), hp)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	out := make([]*locationResolver, len(page.Rows))
+	for i := range page.Rows {
+		out[i] = &locationResolver{r: v.r, loc: &page.Rows[i]}
+	}
+
+	return out, nil
+}
+
+func (v *vehicleResolver) GeofenceEvents(ctx context.Context, args struct {
+	Type  *string
+	First int32
+}) ([]*geofenceEventResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	q := v.r.deps.DB.WithContext(ctx).
+		Scopes(auth.PrincipalFrom(ctx).Tenant("geofence_events")).
+		Where("vehicle_id = ?", v.v.VehicleId)
+	if args.Type != nil {
+		q = q.Where("event_type = ?", eventType(*args.Type))
+	}
+
+	var rows []model.GeofenceEvent
+	if err := q.Order("timestamp DESC, id DESC").Limit(n).Find(&rows).Error; err != nil {
+		return nil, dbError(err)
+	}
+
+	return v.r.geofenceEvents(rows), nil
+}
+
+func (v *vehicleResolver) NearbyStations(ctx context.Context, args struct {
+	RadiusMeters float64
+	First        int32
+}) ([]*nearbyStationResolver, error) {
+	n, err := pageSize(args.First)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := loadersFrom(ctx).lastLocation.Load(ctx, v.v.VehicleId)()
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if loc == nil {
+		return []*nearbyStationResolver{}, nil
+	}
+
+	return nearbyStations(ctx, loc.Latitude, loc.Longitude, args.RadiusMeters, n)
+}
+
+type locationResolver struct {
+	r   *resolver
+	loc *model.VehicleLocation
+}
+
+func (l *locationResolver) ID() *graphql.ID {
+	if l.loc.Id == 0 {
+		return nil
+	}
+	id := graphql.ID(strconv.FormatInt(l.loc.Id, 10))
+	return &id
+}
+
+func (l *locationResolver) VehicleId() graphql.ID { return graphql.ID(l.loc.VehicleId) }
+func (l *locationResolver) Latitude() float64     { return l.loc.Latitude }
+func (l *locationResolver) Longitude() float64    { return l.loc.Longitude }
+func (l *locationResolver) Timestamp() graphql.Time {
+	return graphql.Time{Time: time.Unix(l.loc.Timestamp, 0).UTC()}
+}
+func (l *locationResolver) Ignition() *bool { return l.loc.Ignition }
+func (l *locationResolver) Speed() *float64 { return l.loc.Speed }
+
+func (l *locationResolver) CreatedAt() *graphql.Time {
+	if l.loc.CreatedAt.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: l.loc.CreatedAt}
+}
+
+func (l *locationResolver) Vehicle(ctx context.Context) (*vehicleResolver, error) {
+	return l.r.vehicle(ctx, l.loc.VehicleId)
+}
+
+type stationResolver struct {
+	s *model.BusStation
+}
+
+func (s *stationResolver) ID() graphql.ID      { return graphql.ID(strconv.FormatInt(s.s.Id, 10)) }
+func (s *stationResolver) Name() string        { return s.s.Name }
+func (s *stationResolver) Code() *string       { return s.s.Code }
+func (s *stationResolver) GtfsStopId() *string { return s.s.GtfsStopId }
+func (s *stationResolver) Latitude() float64   { return s.s.Latitude }
+func (s *stationResolver) Longitude() float64  { return s.s.Longitude }
+
+type nearbyStationResolver struct {
+	s        *model.BusStation
+	distance float64
+}
+
+func (n *nearbyStationResolver) Station() *stationResolver { return &stationResolver{s: n.s} }
+func (n *nearbyStationResolver) DistanceMeters() float64   { return n.distance }
+
+type geofenceEventResolver struct {
+	r *resolver
+	e *model.GeofenceEvent
+}
+
+func (g *geofenceEventResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(g.e.Id, 10))
+}
+func (g *geofenceEventResolver) VehicleId() graphql.ID { return graphql.ID(g.e.VehicleId) }
+func (g *geofenceEventResolver) Type() string          { return strings.ToUpper(g.e.EventType) }
+func (g *geofenceEventResolver) Timestamp() graphql.Time {
+	return graphql.Time{Time: time.Unix(g.e.Timestamp, 0).UTC()}
+}
+func (g *geofenceEventResolver) Latitude() float64       { return g.e.Latitude }
+func (g *geofenceEventResolver) Longitude() float64      { return g.e.Longitude }
+func (g *geofenceEventResolver) DistanceMeters() float64 { return g.e.DistanceM }
+
+func (g *geofenceEventResolver) Vehicle(ctx context.Context) (*vehicleResolver, error) {
+	return g.r.vehicle(ctx, g.e.VehicleId)
+}
+
+func (g *geofenceEventResolver) Station(ctx context.Context) (*stationResolver, error) {
+	s, err := loadersFrom(ctx).stations.Load(ctx, g.e.StationId)()
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if s == nil {
+		return nil, nil
+	}
+
+	return &stationResolver{s: s}, nil
+}
+
+// eventType maps the GeofenceEventType enum to model.GeofenceEntry / GeofenceExit.
+func eventType(enum string) string {
+	return strings.ToLower(enum)
+}
+
+func liveLocation(raw *events.LocationRaw) *model.VehicleLocation {
+	return &model.VehicleLocation{
+		MQTTLocationStruct: model.MQTTLocationStruct{
+			VehicleId: raw.VehicleId,
+			Latitude:  raw.Latitude,
+			Longitude: raw.Longitude,
+			Timestamp: raw.Timestamp,
+			Ignition:  raw.Ignition,
+			Speed:     raw.Speed,
+		},
+	}
+}
+
+func unix(t *graphql.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	v := t.Unix()
+	return &v
+}
@@ -0,0 +1,183 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/grpcapi/fleetv1"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/stream"
+)
+
+// GetHistory reads this many rows per query while streaming
+const historyBatch = 1000
+
+type fleetServer struct {
+	fleetv1.UnimplementedFleetServer
+
+	locations *location.Query
+	hub       *stream.Hub
+}
+
+func (s *fleetServer) GetLastLocation(ctx context.Context, req *fleetv1.GetLastLocationRequest) (*fleetv1.Location, error) {
+	p, err := vehicleInScope(ctx, req.GetVehicleId())
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := s.locations.Last(ctx, p, req.GetVehicleId())
+	if errors.Is(err, location.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "location not found")
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return storedLocation(loc), nil
+}
+
+// GetHistory walks the range with the REST cursor pages, so a long history streams in
+// constant memory.
+func (s *fleetServer) GetHistory(req *fleetv1.GetHistoryRequest, out fleetv1.Fleet_GetHistoryServer) error {
+	ctx := out.Context()
+	p, err := vehicleInScope(ctx, req.GetVehicleId())
+	if err != nil {
+		return err
+	}
+
+	hp := location.HistoryParams{
+		VehicleId: req.GetVehicleId(),
+		Start:     req.Start,
+		End:       req.End,
+		Desc:      req.GetDescending(),
+	}
+	remaining := int(req.GetLimit())
+	for {
+		hp.Limit = historyBatch
+		if remaining > 0 && remaining < historyBatch {
+			hp.Limit = remaining
+		}
+
+		page, err := s.locations.History(ctx, p, hp)
+		if err != nil {
+			return dbError(err)
+		}
+		for i := range page.Rows {
+			if err := out.Send(storedLocation(&page.Rows[i])); err != nil {
+				return err
+			}
+		}
+
+		if remaining > 0 {
+			if remaining -= len(page.Rows); remaining <= 0 {
+				return nil
+			}
+		}
+		if !page.HasMore {
+			return nil
+		}
+		hp.After = page.Next
+	}
+}
+
+// SubscribeLocations relays location.raw events from the stream hub, the same feed the
+// WebSocket and SSE endpoints serve.
+func (s *fleetServer) SubscribeLocations(req *fleetv1.SubscribeLocationsRequest, out fleetv1.Fleet_SubscribeLocationsServer) error {
+	ctx := out.Context()
+	p := auth.PrincipalFrom(ctx)
+
+	f := &stream.Filter{
+		VehicleIds: req.GetVehicleIds(),
+		RouteIds:   req.GetRouteIds(),
+		Events:     []string{events.TypeLocationRaw},
+	}
+	if b := req.GetBbox(); b != nil {
+		f.BBox = []float64{b.GetMinLat(), b.GetMinLon(), b.GetMaxLat(), b.GetMaxLon()}
+	}
+	if err := f.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	f.Restrict(p.AllowsVehicle)
+
+	client := s.hub.Register(f)
+	defer s.hub.Unregister(client)
+
+	for {
+		select {
+		case evt := <-client.Events():
+			var raw events.LocationRaw
+			if err := json.Unmarshal(evt.Data, &raw); err != nil {
+				log.Printf("grpc: decode %s event error: %v", evt.Type, err)
+				continue
+			}
+			if err := out.Send(liveLocation(&raw)); err != nil {
+				return err
+			}
+
+		case <-client.Done():
+			if client.Reason() == stream.ReasonShutdown {
+				return status.Error(codes.Unavailable, client.Reason())
+			}
+			return status.Error(codes.ResourceExhausted, client.Reason())
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// vehicleInScope is auth.RequireVehicle for gRPC.
+func vehicleInScope(ctx context.Context, vehicleId string) (*auth.Principal, error) {
+	if vehicleId == "" {
+		return nil, status.Error(codes.InvalidArgument, "vehicle_id is required")
+	}
+
+	p := auth.PrincipalFrom(ctx)
+	if p == nil || !p.AllowsVehicle(vehicleId) {
+		return nil, status.Error(codes.PermissionDenied, "vehicle not in scope")
+	}
+
+	return p, nil
+}
+
+func dbError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	log.Printf("grpc: query locations error: %v", err)
+	return status.Error(codes.Internal, "db error")
+}
+
+// storedLocation leaves out the operator, as the REST responses do.
+func storedLocation(loc *model.VehicleLocation) *fleetv1.Location {
+	return &fleetv1.Location{
+		Id:        loc.Id,
+		VehicleId: loc.VehicleId,
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Timestamp: loc.Timestamp,
+		Ignition:  loc.Ignition,
+		Speed:     loc.Speed,
+		CreatedAt: timestamppb.New(loc.CreatedAt),
+	}
+}
+
+func liveLocation(raw *events.LocationRaw) *fleetv1.Location {
+	return &fleetv1.Location{
+		VehicleId: raw.VehicleId,
+		Latitude:  raw.Latitude,
+		Longitude: raw.Longitude,
+		Timestamp: raw.Timestamp,
+		Ignition:  raw.Ignition,
+		Speed:     raw.Speed,
+	}
+}
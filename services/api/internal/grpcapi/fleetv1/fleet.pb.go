@@ -0,0 +1,490 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: fleet/v1/fleet.proto
+
+package fleetv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetLastLocationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VehicleId     string                 `protobuf:"bytes,1,opt,name=vehicle_id,json=vehicleId,proto3" json:"vehicle_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLastLocationRequest) Reset() {
+	*x = GetLastLocationRequest{}
+	mi := &file_fleet_v1_fleet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLastLocationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLastLocationRequest) ProtoMessage() {}
+
+func (x *GetLastLocationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fleet_v1_fleet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLastLocationRequest.ProtoReflect.Descriptor instead.
+func (*GetLastLocationRequest) Descriptor() ([]byte, []int) {
+	return file_fleet_v1_fleet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetLastLocationRequest) GetVehicleId() string {
+	if x != nil {
+		return x.VehicleId
+	}
+	return ""
+}
+
+type GetHistoryRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	VehicleId string                 `protobuf:"bytes,1,opt,name=vehicle_id,json=vehicleId,proto3" json:"vehicle_id,omitempty"`
+	// unix seconds, inclusive; unset is unbounded
+	Start *int64 `protobuf:"varint,2,opt,name=start,proto3,oneof" json:"start,omitempty"`
+	End   *int64 `protobuf:"varint,3,opt,name=end,proto3,oneof" json:"end,omitempty"`
+	// maximum number of points, 0 streams the whole range
+	Limit uint32 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	// newest first
+	Descending    bool `protobuf:"varint,5,opt,name=descending,proto3" json:"descending,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHistoryRequest) Reset() {
+	*x = GetHistoryRequest{}
+	mi := &file_fleet_v1_fleet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryRequest) ProtoMessage() {}
+
+func (x *GetHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fleet_v1_fleet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_fleet_v1_fleet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetHistoryRequest) GetVehicleId() string {
+	if x != nil {
+		return x.VehicleId
+	}
+	return ""
+}
+
+func (x *GetHistoryRequest) GetStart() int64 {
+	if x != nil && x.Start != nil {
+		return *x.Start
+	}
+	return 0
+}
+
+func (x *GetHistoryRequest) GetEnd() int64 {
+	if x != nil && x.End != nil {
+		return *x.End
+	}
+	return 0
+}
+
+func (x *GetHistoryRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetHistoryRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+type SubscribeLocationsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// empty lists match everything; non-empty filters are ANDed together
+	VehicleIds    []string     `protobuf:"bytes,1,rep,name=vehicle_ids,json=vehicleIds,proto3" json:"vehicle_ids,omitempty"`
+	RouteIds      []string     `protobuf:"bytes,2,rep,name=route_ids,json=routeIds,proto3" json:"route_ids,omitempty"`
+	Bbox          *BoundingBox `protobuf:"bytes,3,opt,name=bbox,proto3" json:"bbox,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeLocationsRequest) Reset() {
+	*x = SubscribeLocationsRequest{}
+	mi := &file_fleet_v1_fleet_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeLocationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeLocationsRequest) ProtoMessage() {}
+
+func (x *SubscribeLocationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fleet_v1_fleet_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeLocationsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeLocationsRequest) Descriptor() ([]byte, []int) {
+	return file_fleet_v1_fleet_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubscribeLocationsRequest) GetVehicleIds() []string {
+	if x != nil {
+		return x.VehicleIds
+	}
+	return nil
+}
+
+func (x *SubscribeLocationsRequest) GetRouteIds() []string {
+	if x != nil {
+		return x.RouteIds
+	}
+	return nil
+}
+
+func (x *SubscribeLocationsRequest) GetBbox() *BoundingBox {
+	if x != nil {
+		return x.Bbox
+	}
+	return nil
+}
+
+type BoundingBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinLat        float64                `protobuf:"fixed64,1,opt,name=min_lat,json=minLat,proto3" json:"min_lat,omitempty"`
+	MinLon        float64                `protobuf:"fixed64,2,opt,name=min_lon,json=minLon,proto3" json:"min_lon,omitempty"`
+	MaxLat        float64                `protobuf:"fixed64,3,opt,name=max_lat,json=maxLat,proto3" json:"max_lat,omitempty"`
+	MaxLon        float64                `protobuf:"fixed64,4,opt,name=max_lon,json=maxLon,proto3" json:"max_lon,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BoundingBox) Reset() {
+	*x = BoundingBox{}
+	mi := &file_fleet_v1_fleet_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BoundingBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundingBox) ProtoMessage() {}
+
+func (x *BoundingBox) ProtoReflect() protoreflect.Message {
+	mi := &file_fleet_v1_fleet_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundingBox.ProtoReflect.Descriptor instead.
+func (*BoundingBox) Descriptor() ([]byte, []int) {
+	return file_fleet_v1_fleet_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BoundingBox) GetMinLat() float64 {
+	if x != nil {
+		return x.MinLat
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetMinLon() float64 {
+	if x != nil {
+		return x.MinLon
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetMaxLat() float64 {
+	if x != nil {
+		return x.MaxLat
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetMaxLon() float64 {
+	if x != nil {
+		return x.MaxLon
+	}
+	return 0
+}
+
+type Location struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// stored row id, 0 for live events that are not stored yet
+	Id        int64   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	VehicleId string  `protobuf:"bytes,2,opt,name=vehicle_id,json=vehicleId,proto3" json:"vehicle_id,omitempty"`
+	Latitude  float64 `protobuf:"fixed64,3,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,4,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	// unix seconds as reported by the device
+	Timestamp int64 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Ignition  *bool `protobuf:"varint,6,opt,name=ignition,proto3,oneof" json:"ignition,omitempty"`
+	// km/h as reported by the device
+	Speed *float64 `protobuf:"fixed64,7,opt,name=speed,proto3,oneof" json:"speed,omitempty"`
+	// unset for live events
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_fleet_v1_fleet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_fleet_v1_fleet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_fleet_v1_fleet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Location) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Location) GetVehicleId() string {
+	if x != nil {
+		return x.VehicleId
+	}
+	return ""
+}
+
+func (x *Location) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Location) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Location) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Location) GetIgnition() bool {
+	if x != nil && x.Ignition != nil {
+		return *x.Ignition
+	}
+	return false
+}
+
+func (x *Location) GetSpeed() float64 {
+	if x != nil && x.Speed != nil {
+		return *x.Speed
+	}
+	return 0
+}
+
+func (x *Location) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+var File_fleet_v1_fleet_proto protoreflect.FileDescriptor
+
+const file_fleet_v1_fleet_proto_rawDesc = "" +
+	"\n" +
+	"\x14fleet/v1/fleet.proto\x12\bfleet.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"7\n" +
+	"\x16GetLastLocationRequest\x12\x1d\n" +
+	"\n" +
+	"vehicle_id\x18\x01 \x01(\tR\tvehicleId\"\xac\x01\n" +
+	"\x11GetHistoryRequest\x12\x1d\n" +
+	"\n" +
+	"vehicle_id\x18\x01 \x01(\tR\tvehicleId\x12\x19\n" +
+	"\x05start\x18\x02 \x01(\x03H\x00R\x05start\x88\x01\x01\x12\x15\n" +
+	"\x03end\x18\x03 \x01(\x03H\x01R\x03end\x88\x01\x01\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\rR\x05limit\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x05 \x01(\bR\n" +
+	"descendingB\b\n" +
+	"\x06_startB\x06\n" +
+	"\x04_end\"\x84\x01\n" +
+	"\x19SubscribeLocationsRequest\x12\x1f\n" +
+	"\vvehicle_ids\x18\x01 \x03(\tR\n" +
+	"vehicleIds\x12\x1b\n" +
+	"\troute_ids\x18\x02 \x03(\tR\brouteIds\x12)\n" +
+	"\x04bbox\x18\x03 \x01(\v2\x15.fleet.v1.BoundingBoxR\x04bbox\"q\n" +
+	"\vBoundingBox\x12\x17\n" +
+	"\amin_lat\x18\x01 \x01(\x01R\x06minLat\x12\x17\n" +
+	"\amin_lon\x18\x02 \x01(\x01R\x06minLon\x12\x17\n" +
+	"\amax_lat\x18\x03 \x01(\x01R\x06maxLat\x12\x17\n" +
+	"\amax_lon\x18\x04 \x01(\x01R\x06maxLon\"\x9f\x02\n" +
+	"\bLocation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1d\n" +
+	"\n" +
+	"vehicle_id\x18\x02 \x01(\tR\tvehicleId\x12\x1a\n" +
+	"\blatitude\x18\x03 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x04 \x01(\x01R\tlongitude\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x1f\n" +
+	"\bignition\x18\x06 \x01(\bH\x00R\bignition\x88\x01\x01\x12\x19\n" +
+	"\x05speed\x18\a \x01(\x01H\x01R\x05speed\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAtB\v\n" +
+	"\t_ignitionB\b\n" +
+	"\x06_speed2\xe2\x01\n" +
+	"\x05Fleet\x12G\n" +
+	"\x0fGetLastLocation\x12 .fleet.v1.GetLastLocationRequest\x1a\x12.fleet.v1.Location\x12?\n" +
+	"\n" +
+	"GetHistory\x12\x1b.fleet.v1.GetHistoryRequest\x1a\x12.fleet.v1.Location0\x01\x12O\n" +
+	"\x12SubscribeLocations\x12#.fleet.v1.SubscribeLocationsRequest\x1a\x12.fleet.v1.Location0\x01B2Z0tj/services/api/internal/grpcapi/fleetv1;fleetv1b\x06proto3"
+
+var (
+	file_fleet_v1_fleet_proto_rawDescOnce sync.Once
+	file_fleet_v1_fleet_proto_rawDescData []byte
+)
+
+func file_fleet_v1_fleet_proto_rawDescGZIP() []byte {
+	file_fleet_v1_fleet_proto_rawDescOnce.Do(func() {
+		file_fleet_v1_fleet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fleet_v1_fleet_proto_rawDesc), len(file_fleet_v1_fleet_proto_rawDesc)))
+	})
+	return file_fleet_v1_fleet_proto_rawDescData
+}
+
+var file_fleet_v1_fleet_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_fleet_v1_fleet_proto_goTypes = []any{
+	(*GetLastLocationRequest)(nil),    // 0: fleet.v1.GetLastLocationRequest
+	(*GetHistoryRequest)(nil),         // 1: fleet.v1.GetHistoryRequest
+	(*SubscribeLocationsRequest)(nil), // 2: fleet.v1.SubscribeLocationsRequest
+	(*BoundingBox)(nil),               // 3: fleet.v1.BoundingBox
+	(*Location)(nil),                  // 4: fleet.v1.Location
+	(*timestamppb.Timestamp)(nil),     // 5: google.protobuf.Timestamp
+}
+var file_fleet_v1_fleet_proto_depIdxs = []int32{
+	3, // 0: fleet.v1.SubscribeLocationsRequest.bbox:type_name -> fleet.v1.BoundingBox
+	5, // 1: fleet.v1.Location.created_at:type_name -> google.protobuf.Timestamp
+	0, // 2: fleet.v1.Fleet.GetLastLocation:input_type -> fleet.v1.GetLastLocationRequest
+	1, // 3: fleet.v1.Fleet.GetHistory:input_type -> fleet.v1.GetHistoryRequest
+	2, // 4: fleet.v1.Fleet.SubscribeLocations:input_type -> fleet.v1.SubscribeLocationsRequest
+	4, // 5: fleet.v1.Fleet.GetLastLocation:output_type -> fleet.v1.Location
+	4, // 6: fleet.v1.Fleet.GetHistory:output_type -> fleet.v1.Location
+	4, // 7: fleet.v1.Fleet.SubscribeLocations:output_type -> fleet.v1.Location
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_fleet_v1_fleet_proto_init() }
+func file_fleet_v1_fleet_proto_init() {
+	if File_fleet_v1_fleet_proto != nil {
+		return
+	}
+	file_fleet_v1_fleet_proto_msgTypes[1].OneofWrappers = []any{}
+	file_fleet_v1_fleet_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fleet_v1_fleet_proto_rawDesc), len(file_fleet_v1_fleet_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fleet_v1_fleet_proto_goTypes,
+		DependencyIndexes: file_fleet_v1_fleet_proto_depIdxs,
+		MessageInfos:      file_fleet_v1_fleet_proto_msgTypes,
+	}.Build()
+	File_fleet_v1_fleet_proto = out.File
+	file_fleet_v1_fleet_proto_goTypes = nil
+	file_fleet_v1_fleet_proto_depIdxs = nil
+}
@@ -0,0 +1,218 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: fleet/v1/fleet.proto
+
+package fleetv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Fleet_GetLastLocation_FullMethodName    = "/fleet.v1.Fleet/GetLastLocation"
+	Fleet_GetHistory_FullMethodName         = "/fleet.v1.Fleet/GetHistory"
+	Fleet_SubscribeLocations_FullMethodName = "/fleet.v1.Fleet/SubscribeLocations"
+)
+
+// FleetClient is the client API for Fleet service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Fleet serves vehicle locations over gRPC. It reads through the same query layer as
+// the REST API, so tenant and scope rules are identical. Calls authenticate with
+// "authorization: Bearer <api key or JWT>" or "x-api-key" metadata.
+type FleetClient interface {
+	// GetLastLocation returns the newest stored location of a vehicle.
+	GetLastLocation(ctx context.Context, in *GetLastLocationRequest, opts ...grpc.CallOption) (*Location, error)
+	// GetHistory streams a vehicle's stored locations in timestamp order.
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Location], error)
+	// SubscribeLocations streams live location.raw events until the client cancels.
+	SubscribeLocations(ctx context.Context, in *SubscribeLocationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Location], error)
+}
+
+type fleetClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFleetClient(cc grpc.ClientConnInterface) FleetClient {
+	return &fleetClient{cc}
+}
+
+func (c *fleetClient) GetLastLocation(ctx context.Context, in *GetLastLocationRequest, opts ...grpc.CallOption) (*Location, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Location)
+	err := c.cc.Invoke(ctx, Fleet_GetLastLocation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fleetClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Location], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Fleet_ServiceDesc.Streams[0], Fleet_GetHistory_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetHistoryRequest, Location]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fleet_GetHistoryClient = grpc.ServerStreamingClient[Location]
+
+func (c *fleetClient) SubscribeLocations(ctx context.Context, in *SubscribeLocationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Location], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Fleet_ServiceDesc.Streams[1], Fleet_SubscribeLocations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeLocationsRequest, Location]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fleet_SubscribeLocationsClient = grpc.ServerStreamingClient[Location]
+
+// FleetServer is the server API for Fleet service.
+// All implementations must embed UnimplementedFleetServer
+// for forward compatibility.
+//
+// Fleet serves vehicle locations over gRPC. It reads through the same query layer as
+// the REST API, so tenant and scope rules are identical. Calls authenticate with
+// "authorization: Bearer <api key or JWT>" or "x-api-key" metadata.
+type FleetServer interface {
+	// GetLastLocation returns the newest stored location of a vehicle.
+	GetLastLocation(context.Context, *GetLastLocationRequest) (*Location, error)
+	// GetHistory streams a vehicle's stored locations in timestamp order.
+	GetHistory(*GetHistoryRequest, grpc.ServerStreamingServer[Location]) error
+	// SubscribeLocations streams live location.raw events until the client cancels.
+	SubscribeLocations(*SubscribeLocationsRequest, grpc.ServerStreamingServer[Location]) error
+	mustEmbedUnimplementedFleetServer()
+}
+
+// UnimplementedFleetServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFleetServer struct{}
+
+func (UnimplementedFleetServer) GetLastLocation(context.Context, *GetLastLocationRequest) (*Location, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLastLocation not implemented")
+}
+func (UnimplementedFleetServer) GetHistory(*GetHistoryRequest, grpc.ServerStreamingServer[Location]) error {
+	return status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedFleetServer) SubscribeLocations(*SubscribeLocationsRequest, grpc.ServerStreamingServer[Location]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeLocations not implemented")
+}
+func (UnimplementedFleetServer) mustEmbedUnimplementedFleetServer() {}
+func (UnimplementedFleetServer) testEmbeddedByValue()               {}
+
+// UnsafeFleetServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FleetServer will
+// result in compilation errors.
+type UnsafeFleetServer interface {
+	mustEmbedUnimplementedFleetServer()
+}
+
+func RegisterFleetServer(s grpc.ServiceRegistrar, srv FleetServer) {
+	// If the following call pancis, it indicates UnimplementedFleetServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Fleet_ServiceDesc, srv)
+}
+
+func _Fleet_GetLastLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLastLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FleetServer).GetLastLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Fleet_GetLastLocation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FleetServer).GetLastLocation(ctx, req.(*GetLastLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fleet_GetHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FleetServer).GetHistory(m, &grpc.GenericServerStream[GetHistoryRequest, Location]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fleet_GetHistoryServer = grpc.ServerStreamingServer[Location]
+
+func _Fleet_SubscribeLocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeLocationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FleetServer).SubscribeLocations(m, &grpc.GenericServerStream[SubscribeLocationsRequest, Location]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fleet_SubscribeLocationsServer = grpc.ServerStreamingServer[Location]
+
+// Fleet_ServiceDesc is the grpc.ServiceDesc for Fleet service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Fleet_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fleet.v1.Fleet",
+	HandlerType: (*FleetServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLastLocation",
+			Handler:    _Fleet_GetLastLocation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetHistory",
+			Handler:       _Fleet_GetHistory_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeLocations",
+			Handler:       _Fleet_SubscribeLocations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fleet/v1/fleet.proto",
+}
@@ -0,0 +1,132 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/ratelimit"
+)
+
+// interceptor authenticates every call except health checks and reflection, the same
+// way auth.Authenticator.Middleware does for REST, then applies the per-caller limit.
+type interceptor struct {
+	auth    *auth.Authenticator
+	limiter *ratelimit.Limiter
+	limit   ratelimit.Policy
+}
+
+func (i *interceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	if public(info.FullMethod) {
+		return next(ctx, req)
+	}
+
+	ctx, err := i.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return next(ctx, req)
+}
+
+func (i *interceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	if public(info.FullMethod) {
+		return next(srv, ss)
+	}
+
+	ctx, err := i.admit(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return next(srv, &principalStream{ServerStream: ss, ctx: ctx})
+}
+
+func (i *interceptor) admit(ctx context.Context) (context.Context, error) {
+	token := credentials(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	p, err := i.auth.Authenticate(ctx, token)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "db error")
+	}
+
+	if err := i.take(ctx, p); err != nil {
+		return nil, err
+	}
+
+	return auth.NewContext(ctx, p), nil
+}
+
+// take draws from the caller's REST bucket, so switching transports buys no extra quota.
+func (i *interceptor) take(ctx context.Context, p *auth.Principal) error {
+	policy := ratelimit.CallerPolicy(p, i.limit)
+	if i.limiter == nil || policy.Unlimited() {
+		return nil
+	}
+
+	key := ratelimit.CallerKey(p)
+	tctx, cancel := context.WithTimeout(ctx, ratelimit.TakeTimeout)
+	res, err := i.limiter.Take(tctx, key, policy)
+	cancel()
+	if err != nil {
+		// fail open, like the REST middleware
+		log.Printf("ratelimit %s error: %v", key, err)
+		return nil
+	}
+	if !res.Allowed {
+		retry := int(math.Ceil(res.RetryAfter.Seconds()))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retry)))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return nil
+}
+
+// credentials reads "authorization: Bearer <token>" or "x-api-key" metadata.
+func credentials(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get("authorization"); len(vals) > 0 {
+		scheme, token, ok := strings.Cut(vals[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if vals := md.Get("x-api-key"); len(vals) > 0 {
+		return strings.TrimSpace(vals[0])
+	}
+
+	return ""
+}
+
+// health probes and reflection need no credentials, like /docs on the REST side
+func public(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") ||
+		strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+// principalStream carries the authenticated context into stream handlers.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
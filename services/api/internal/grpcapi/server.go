@@ -0,0 +1,48 @@
+// Package grpcapi serves vehicle locations over gRPC next to the REST API. It shares the
+// location query layer, authentication and per-caller rate limits with the gin handlers.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=module=tj/services/api/internal/grpcapi --go-grpc_out=. --go-grpc_opt=module=tj/services/api/internal/grpcapi fleet/v1/fleet.proto
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/grpcapi/fleetv1"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/ratelimit"
+	"tj/services/api/internal/stream"
+)
+
+type Deps struct {
+	Locations *location.Query
+	Hub       *stream.Hub
+	Auth      *auth.Authenticator
+	Limiter   *ratelimit.Limiter
+	// per API key or token subject, shares the REST bucket
+	KeyLimit ratelimit.Policy
+}
+
+// NewServer builds the gRPC server with the Fleet service, the standard health service
+// and reflection, so grpcurl and grpc_health_probe work without the proto files.
+func NewServer(d Deps) *grpc.Server {
+	i := &interceptor{auth: d.Auth, limiter: d.Limiter, limit: d.KeyLimit}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(i.unary),
+		grpc.ChainStreamInterceptor(i.stream),
+	)
+
+	fleetv1.RegisterFleetServer(s, &fleetServer{locations: d.Locations, hub: d.Hub})
+
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus(fleetv1.Fleet_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+
+	reflection.Register(s)
+
+	return s
+}
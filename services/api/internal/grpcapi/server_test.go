@@ -0,0 +1,240 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository/memory"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/grpcapi/fleetv1"
+	"tj/services/api/internal/location"
+)
+
+// fleetTest serves NewServer over an in-memory listener, with the locations in a memory
+// store. The database only answers the scope lookups of scoped callers.
+type fleetTest struct {
+	client fleetv1.FleetClient
+	health healthpb.HealthClient
+	auth   *auth.Authenticator
+	mock   sqlmock.Sqlmock
+	store  *memory.Store
+}
+
+func newFleetTest(t *testing.T) *fleetTest {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(
+		func(string, string) error { return nil })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	authn := auth.NewAuthenticator(gdb, nil, auth.Config{JWTSecret: []byte("grpc-test"), JWTTTL: time.Hour})
+	s := NewServer(Deps{Locations: location.NewQuery(store.Locations), Auth: authn})
+
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &fleetTest{
+		client: fleetv1.NewFleetClient(conn),
+		health: healthpb.NewHealthClient(conn),
+		auth:   authn,
+		mock:   mock,
+		store:  store,
+	}
+}
+
+// as returns a context calling with a token for p. Scoped callers have their vehicles
+// looked up on the first call, vehicles are that answer.
+func (f *fleetTest) as(t *testing.T, p *auth.Principal, vehicles ...string) context.Context {
+	t.Helper()
+	token, _, err := f.auth.IssueToken(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.OperatorId != nil || !p.Scope.Unrestricted() {
+		rows := sqlmock.NewRows([]string{"vehicle_id"})
+		for _, v := range vehicles {
+			rows.AddRow(v)
+		}
+		f.mock.ExpectQuery("").WillReturnRows(rows)
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func platformAdmin() *auth.Principal {
+	return &auth.Principal{Subject: "grpc-test", Role: model.RoleAdmin}
+}
+
+func TestCallsWithoutValidCredentialsAreRefused(t *testing.T) {
+	f := newFleetTest(t)
+	req := &fleetv1.GetLastLocationRequest{VehicleId: "bus-1"}
+
+	_, err := f.client.GetLastLocation(context.Background(), req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no credentials: %v, want Unauthenticated", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	if _, err := f.client.GetLastLocation(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("bad token: %v, want Unauthenticated", err)
+	}
+
+	// streams are checked by the stream interceptor
+	stream, err := f.client.GetHistory(context.Background(), &fleetv1.GetHistoryRequest{VehicleId: "bus-1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("history without credentials: %v, want Unauthenticated", err)
+	}
+
+	// probes need none
+	res, err := f.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: fleetv1.Fleet_ServiceDesc.ServiceName})
+	if err != nil || res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health check: %v %v, want SERVING", res, err)
+	}
+}
+
+func TestVehiclesOutsideTheScopeAreDenied(t *testing.T) {
+	f := newFleetTest(t)
+	f.store.Locations.Add(
+		model.MQTTLocationStruct{VehicleId: "bus-1", Latitude: -6.2, Longitude: 106.8, Timestamp: 1000},
+		model.MQTTLocationStruct{VehicleId: "bus-2", Latitude: -6.3, Longitude: 106.9, Timestamp: 1000},
+	)
+	ctx := f.as(t, &auth.Principal{Subject: "route-1A", Role: model.RoleReadOnly, Scope: auth.Scope{RouteIds: []string{"1A"}}}, "bus-1")
+
+	loc, err := f.client.GetLastLocation(ctx, &fleetv1.GetLastLocationRequest{VehicleId: "bus-1"})
+	if err != nil || loc.GetVehicleId() != "bus-1" || loc.GetTimestamp() != 1000 {
+		t.Fatalf("bus-1: %v %v, want its location", loc, err)
+	}
+
+	if _, err := f.client.GetLastLocation(ctx, &fleetv1.GetLastLocationRequest{VehicleId: "bus-2"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("bus-2: %v, want PermissionDenied", err)
+	}
+	stream, err := f.client.GetHistory(ctx, &fleetv1.GetHistoryRequest{VehicleId: "bus-2"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("history of bus-2: %v, want PermissionDenied", err)
+	}
+
+	if _, err := f.client.GetLastLocation(ctx, &fleetv1.GetLastLocationRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("no vehicle_id: %v, want InvalidArgument", err)
+	}
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLastLocationOfASilentVehicleIsNotFound(t *testing.T) {
+	f := newFleetTest(t)
+
+	_, err := f.client.GetLastLocation(f.as(t, platformAdmin()), &fleetv1.GetLastLocationRequest{VehicleId: "bus-9"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("%v, want NotFound", err)
+	}
+}
+
+// recvAll reads the stream to its end.
+func recvAll(t *testing.T, stream fleetv1.Fleet_GetHistoryClient) []*fleetv1.Location {
+	t.Helper()
+
+	var out []*fleetv1.Location
+	for {
+		loc, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, loc)
+	}
+}
+
+func TestHistoryStreamsAcrossPages(t *testing.T) {
+	f := newFleetTest(t)
+
+	// more than two batches
+	const n = 2*historyBatch + 500
+	for i := int64(0); i < n; i++ {
+		f.store.Locations.Add(model.MQTTLocationStruct{VehicleId: "bus-1", Timestamp: 1000 + i})
+	}
+	f.store.Locations.Add(model.MQTTLocationStruct{VehicleId: "bus-2", Timestamp: 1000})
+	ctx := f.as(t, platformAdmin())
+
+	cases := []struct {
+		name  string
+		req   *fleetv1.GetHistoryRequest
+		count int
+		first int64
+	}{
+		{"everything", &fleetv1.GetHistoryRequest{VehicleId: "bus-1"}, n, 1000},
+		{"limit across batches", &fleetv1.GetHistoryRequest{VehicleId: "bus-1", Limit: historyBatch + 1}, historyBatch + 1, 1000},
+		{"limit within a batch", &fleetv1.GetHistoryRequest{VehicleId: "bus-1", Limit: 10}, 10, 1000},
+		{"descending", &fleetv1.GetHistoryRequest{VehicleId: "bus-1", Descending: true, Limit: 3}, 3, 1000 + n - 1},
+		{"time range", &fleetv1.GetHistoryRequest{VehicleId: "bus-1", Start: ptr(int64(1100)), End: ptr(int64(1199))}, 100, 1100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stream, err := f.client.GetHistory(ctx, tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := recvAll(t, stream)
+			if len(got) != tc.count {
+				t.Fatalf("%d points, want %d", len(got), tc.count)
+			}
+			if got[0].GetTimestamp() != tc.first {
+				t.Errorf("first point at %d, want %d", got[0].GetTimestamp(), tc.first)
+			}
+			// pages join without a gap or a repeat
+			for i := 1; i < len(got); i++ {
+				step := got[i].GetTimestamp() - got[i-1].GetTimestamp()
+				if (tc.req.Descending && step != -1) || (!tc.req.Descending && step != 1) {
+					t.Fatalf("points %d and %d are at %d and %d", i-1, i, got[i-1].GetTimestamp(), got[i].GetTimestamp())
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
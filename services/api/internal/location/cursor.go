@@ -0,0 +1,53 @@
+package location
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cursor is the (timestamp, id) of the last row of a page. It is opaque to clients.
+type Cursor struct {
+	Timestamp int64
+	Id        int64
+	// Query identifies the query the cursor was issued for, when it is bound to one
+	Query string
+}
+
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.Timestamp, c.Id)
+	if c.Query != "" {
+		raw += ":" + c.Query
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) < 2 {
+		return nil, errors.New("malformed cursor")
+	}
+	tsStr, idStr := parts[0], parts[1]
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cursor{Timestamp: ts, Id: id}
+	if len(parts) == 3 {
+		c.Query = parts[2]
+	}
+
+	return c, nil
+}
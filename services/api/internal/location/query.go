@@ -0,0 +1,127 @@
+// Package location is the query layer over stored vehicle locations. The REST handlers
+// and the gRPC service both go through it, so they apply the same tenant rules and paging.
+package location
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+)
+
+var ErrNotFound = repository.ErrNotFound
+
+// ErrCursorMismatch is returned for a history cursor issued for another vehicle, order
+// or time range: continuing it would skip or repeat rows.
+var ErrCursorMismatch = errors.New("cursor was issued for a different query")
+
+type Query struct {
+	repo repository.LocationRepository
+}
+
+func NewQuery(repo repository.LocationRepository) *Query {
+	return &Query{repo: repo}
+}
+
+// Last returns the newest stored location of a vehicle, ErrNotFound when it never reported.
+func (q *Query) Last(ctx context.Context, p *auth.Principal, vehicleId string) (*model.VehicleLocation, error) {
+	return q.repo.Last(ctx, p.Filter(), vehicleId)
+}
+
+// LastByVehicles returns the newest stored location of each vehicle in one query, keyed by
+// vehicle id. Vehicles that never reported are missing from the map.
+func (q *Query) LastByVehicles(ctx context.Context, p *auth.Principal, vehicleIds []string) (map[string]*model.VehicleLocation, error) {
+	rows, err := q.repo.LastByVehicles(ctx, p.Filter(), vehicleIds)
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[string]*model.VehicleLocation, len(rows))
+	for i := range rows {
+		last[rows[i].VehicleId] = &rows[i]
+	}
+
+	return last, nil
+}
+
+type HistoryParams struct {
+	VehicleId string
+	// unix seconds, nil is unbounded
+	Start *int64
+	End   *int64
+	Limit int
+	Desc  bool
+	// continue after this row
+	After *Cursor
+	// also count every row in range, which costs a scan
+	WithTotal bool
+}
+
+// key identifies what a history cursor is valid for.
+func (hp *HistoryParams) key() string {
+	order := "asc"
+	if hp.Desc {
+		order = "desc"
+	}
+	bound := func(v *int64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatInt(*v, 10)
+	}
+
+	return order + "," + bound(hp.Start) + "," + bound(hp.End) + "," + hp.VehicleId
+}
+
+type HistoryPage struct {
+	Rows    []model.VehicleLocation
+	Total   *int64
+	HasMore bool
+	// where the next page starts, nil on the last page
+	Next *Cursor
+}
+
+// History pages through a vehicle's points with a keyset cursor on (timestamp, id),
+// so deep pages cost the same as the first one.
+func (q *Query) History(ctx context.Context, p *auth.Principal, hp HistoryParams) (*HistoryPage, error) {
+	if hp.After != nil && hp.After.Query != hp.key() {
+		return nil, ErrCursorMismatch
+	}
+
+	rq := repository.HistoryQuery{
+		VehicleId: hp.VehicleId,
+		Range:     repository.TimeRange{Start: hp.Start, End: hp.End},
+		Desc:      hp.Desc,
+		// one extra row tells us whether there is a next page without a COUNT
+		Limit: hp.Limit + 1,
+	}
+	if hp.After != nil {
+		rq.After = &repository.Keyset{Timestamp: hp.After.Timestamp, Id: hp.After.Id}
+	}
+
+	page := &HistoryPage{}
+	if hp.WithTotal {
+		n, err := q.repo.CountHistory(ctx, p.Filter(), rq)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &n
+	}
+
+	rows, err := q.repo.History(ctx, p.Filter(), rq)
+	if err != nil {
+		return nil, err
+	}
+	page.Rows = rows
+
+	if len(page.Rows) > hp.Limit {
+		page.Rows = page.Rows[:hp.Limit]
+		last := page.Rows[len(page.Rows)-1]
+		page.HasMore, page.Next = true, &Cursor{Timestamp: last.Timestamp, Id: last.Id, Query: hp.key()}
+	}
+
+	return page, nil
+}
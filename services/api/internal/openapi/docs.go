@@ -0,0 +1,51 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// swagger-ui-dist from a CDN, the API doesn't ship frontend assets
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Fleet API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves the document as JSON and a Swagger UI on top of it.
+type DocsHandler struct {
+	spec []byte
+}
+
+func NewDocsHandler(spec *openapi3.T) (*DocsHandler, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocsHandler{spec: b}, nil
+}
+
+// Spec: GET /openapi.json
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", h.spec)
+}
+
+// UI: GET /docs
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
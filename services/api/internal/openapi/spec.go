@@ -0,0 +1,28 @@
+// Package openapi holds the OpenAPI document of services/api, the types generated from it,
+// the handlers serving it and the middleware validating requests against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:generate oapi-codegen -config oapi-codegen.yaml openapi.yaml
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// Load parses and validates the embedded document. Every call returns a fresh copy.
+func Load() (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(specYAML)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
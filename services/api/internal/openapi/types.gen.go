@@ -0,0 +1,1042 @@
+// Package openapi provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.1 DO NOT EDIT.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	model "tj/pkg/model"
+
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	AccessTokenScopes  = "accessToken.Scopes"
+	ApiKeyHeaderScopes = "apiKeyHeader.Scopes"
+	BearerAuthScopes   = "bearerAuth.Scopes"
+)
+
+// Defines values for ConnectivityStatus.
+const (
+	ConnectivityStatusOffline ConnectivityStatus = "offline"
+	ConnectivityStatusOnline  ConnectivityStatus = "online"
+)
+
+// Defines values for DeliveryStatus.
+const (
+	Failed      DeliveryStatus = "failed"
+	RateLimited DeliveryStatus = "rate_limited"
+	Retrying    DeliveryStatus = "retrying"
+	Sent        DeliveryStatus = "sent"
+)
+
+// Defines values for GeofenceEventEventType.
+const (
+	GeofenceEventEventTypeEntry GeofenceEventEventType = "entry"
+	GeofenceEventEventTypeExit  GeofenceEventEventType = "exit"
+)
+
+// Defines values for PayloadFormat.
+const (
+	PayloadFormatCloudevents       PayloadFormat = "cloudevents"
+	PayloadFormatCloudeventsBinary PayloadFormat = "cloudevents-binary"
+	PayloadFormatEnvelope          PayloadFormat = "envelope"
+)
+
+// Defines values for PrincipalMethod.
+const (
+	ApiKey PrincipalMethod = "api_key"
+	Jwt    PrincipalMethod = "jwt"
+)
+
+// Defines values for Role.
+const (
+	Admin      Role = "admin"
+	Dispatcher Role = "dispatcher"
+	Partner    Role = "partner"
+	ReadOnly   Role = "read-only"
+)
+
+// Defines values for Severity.
+const (
+	Critical Severity = "critical"
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+)
+
+// Defines values for TokenResponseTokenType.
+const (
+	Bearer TokenResponseTokenType = "Bearer"
+)
+
+// Defines values for WebhookChannel.
+const (
+	WebhookChannelWebhook WebhookChannel = "webhook"
+)
+
+// Defines values for ExportFormat.
+const (
+	ExportFormatCsv           ExportFormat = "csv"
+	ExportFormatGeojson       ExportFormat = "geojson"
+	ExportFormatGeojsonPoints ExportFormat = "geojson-points"
+	ExportFormatGpx           ExportFormat = "gpx"
+	ExportFormatKml           ExportFormat = "kml"
+)
+
+// Defines values for GeofenceEventType.
+const (
+	GeofenceEventTypeEntry GeofenceEventType = "entry"
+	GeofenceEventTypeExit  GeofenceEventType = "exit"
+)
+
+// Defines values for StreamFormat.
+const (
+	StreamFormatCloudevents StreamFormat = "cloudevents"
+)
+
+// Defines values for VisitOpen.
+const (
+	VisitOpenFalse VisitOpen = "false"
+	VisitOpenTrue  VisitOpen = "true"
+)
+
+// Defines values for ListGeofenceEventsParamsType.
+const (
+	ListGeofenceEventsParamsTypeEntry ListGeofenceEventsParamsType = "entry"
+	ListGeofenceEventsParamsTypeExit  ListGeofenceEventsParamsType = "exit"
+)
+
+// Defines values for ListVisitsParamsOpen.
+const (
+	ListVisitsParamsOpenFalse ListVisitsParamsOpen = "false"
+	ListVisitsParamsOpenTrue  ListVisitsParamsOpen = "true"
+)
+
+// Defines values for GetVehiclePositionsParamsFormat.
+const (
+	Json GetVehiclePositionsParamsFormat = "json"
+)
+
+// Defines values for ListStationVisitsParamsOpen.
+const (
+	ListStationVisitsParamsOpenFalse ListStationVisitsParamsOpen = "false"
+	ListStationVisitsParamsOpenTrue  ListStationVisitsParamsOpen = "true"
+)
+
+// Defines values for StreamSSEParamsFormat.
+const (
+	StreamSSEParamsFormatCloudevents StreamSSEParamsFormat = "cloudevents"
+)
+
+// Defines values for StreamWebSocketParamsFormat.
+const (
+	StreamWebSocketParamsFormatCloudevents StreamWebSocketParamsFormat = "cloudevents"
+)
+
+// Defines values for ExportVehiclesParamsFormat.
+const (
+	ExportVehiclesParamsFormatCsv           ExportVehiclesParamsFormat = "csv"
+	ExportVehiclesParamsFormatGeojson       ExportVehiclesParamsFormat = "geojson"
+	ExportVehiclesParamsFormatGeojsonPoints ExportVehiclesParamsFormat = "geojson-points"
+	ExportVehiclesParamsFormatGpx           ExportVehiclesParamsFormat = "gpx"
+	ExportVehiclesParamsFormatKml           ExportVehiclesParamsFormat = "kml"
+)
+
+// Defines values for GetFleetConnectivityParamsStatus.
+const (
+	GetFleetConnectivityParamsStatusOffline GetFleetConnectivityParamsStatus = "offline"
+	GetFleetConnectivityParamsStatusOnline  GetFleetConnectivityParamsStatus = "online"
+)
+
+// Defines values for ExportVehicleParamsFormat.
+const (
+	Csv           ExportVehicleParamsFormat = "csv"
+	Geojson       ExportVehicleParamsFormat = "geojson"
+	GeojsonPoints ExportVehicleParamsFormat = "geojson-points"
+	Gpx           ExportVehicleParamsFormat = "gpx"
+	Kml           ExportVehicleParamsFormat = "kml"
+)
+
+// Defines values for ListVehicleGeofenceEventsParamsType.
+const (
+	ListVehicleGeofenceEventsParamsTypeEntry ListVehicleGeofenceEventsParamsType = "entry"
+	ListVehicleGeofenceEventsParamsTypeExit  ListVehicleGeofenceEventsParamsType = "exit"
+)
+
+// Defines values for GetHistoryParamsOrder.
+const (
+	Asc  GetHistoryParamsOrder = "asc"
+	Desc GetHistoryParamsOrder = "desc"
+)
+
+// Defines values for ListVehicleVisitsParamsOpen.
+const (
+	ListVehicleVisitsParamsOpenFalse ListVehicleVisitsParamsOpen = "false"
+	ListVehicleVisitsParamsOpenTrue  ListVehicleVisitsParamsOpen = "true"
+)
+
+// APIKey defines model for APIKey.
+type APIKey struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  *string    `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	GroupNames *[]string  `json:"group_names"`
+	Id         int64      `json:"id"`
+
+	// Key The full key, only returned on create
+	Key             *string    `json:"key,omitempty"`
+	KeyPrefix       string     `json:"key_prefix"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	Name            string     `json:"name"`
+	OperatorId      *string    `json:"operator_id"`
+	RateLimitPerMin *int       `json:"rate_limit_per_min"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	Role            Role       `json:"role"`
+	RouteIds        *[]string  `json:"route_ids"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// APIKeyList defines model for APIKeyList.
+type APIKeyList struct {
+	Count int      `json:"count"`
+	Data  []APIKey `json:"data"`
+}
+
+// APIKeyRequest defines model for APIKeyRequest.
+type APIKeyRequest struct {
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	GroupNames []string   `json:"group_names,omitempty"`
+	Name       string     `json:"name"`
+
+	// OperatorId Platform admins only; operator admins always create keys of their own operator. Fixed once the key exists: PUT keeps it when left out and rejects another value
+	OperatorId *string `json:"operator_id,omitempty"`
+
+	// RateLimitPerMin Omitted or null for the default limit, 0 = unlimited. Platform admins only; operator admins get 403 for a value other than the key's current one
+	RateLimitPerMin *int     `json:"rate_limit_per_min,omitempty"`
+	Role            string   `json:"role"`
+	RouteIds        []string `json:"route_ids,omitempty"`
+}
+
+// Connectivity defines model for Connectivity.
+type Connectivity struct {
+	LastSeen             int64              `json:"last_seen"`
+	SecondsSinceLastSeen int64              `json:"seconds_since_last_seen"`
+	Since                int64              `json:"since"`
+	Status               ConnectivityStatus `json:"status"`
+	VehicleId            string             `json:"vehicle_id"`
+}
+
+// ConnectivityStatus defines model for Connectivity.Status.
+type ConnectivityStatus string
+
+// ConnectivityList defines model for ConnectivityList.
+type ConnectivityList struct {
+	Count int            `json:"count"`
+	Data  []Connectivity `json:"data"`
+}
+
+// Delivery defines model for Delivery.
+type Delivery struct {
+	Attempt      int            `json:"attempt"`
+	Channel      string         `json:"channel"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeliveryId   string         `json:"delivery_id"`
+	DurationMs   int64          `json:"duration_ms"`
+	Error        *string        `json:"error,omitempty"`
+	Id           int64          `json:"id"`
+	RoutingKey   string         `json:"routing_key"`
+	Status       DeliveryStatus `json:"status"`
+	StatusCode   *int           `json:"status_code,omitempty"`
+	SubscriberId int64          `json:"subscriber_id"`
+	VehicleId    *string        `json:"vehicle_id,omitempty"`
+}
+
+// DeliveryList defines model for DeliveryList.
+type DeliveryList struct {
+	Count      int        `json:"count"`
+	Data       []Delivery `json:"data"`
+	NextBefore *int64     `json:"next_before"`
+}
+
+// DeliveryStatus defines model for DeliveryStatus.
+type DeliveryStatus string
+
+// Device defines model for Device.
+type Device struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  *string    `json:"created_by"`
+	Id         int64      `json:"id"`
+	LastAuthAt *time.Time `json:"last_auth_at"`
+	Name       *string    `json:"name"`
+
+	// Password Only returned on create
+	Password  *string    `json:"password,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at"`
+
+	// Topic The topic the device publishes to, only returned on create
+	Topic     *string   `json:"topic,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Username  string    `json:"username"`
+	VehicleId string    `json:"vehicle_id"`
+}
+
+// DeviceList defines model for DeviceList.
+type DeviceList struct {
+	Count int      `json:"count"`
+	Data  []Device `json:"data"`
+}
+
+// DeviceRequest defines model for DeviceRequest.
+type DeviceRequest struct {
+	Name *string `json:"name,omitempty"`
+
+	// VehicleId Created under the caller's operator when it doesn't exist yet
+	VehicleId string `json:"vehicle_id"`
+}
+
+// Error defines model for Error.
+type Error struct {
+	Error string `json:"error"`
+
+	// RetryAfter Seconds until the rate limit allows another request (429 only)
+	RetryAfter *int `json:"retry_after,omitempty"`
+}
+
+// EventSchemaList defines model for EventSchemaList.
+type EventSchemaList struct {
+	Count int `json:"count"`
+	Data  []struct {
+		Type    string `json:"type"`
+		Version int    `json:"version"`
+	} `json:"data"`
+}
+
+// FleetSnapshot defines model for FleetSnapshot.
+type FleetSnapshot struct {
+	Count       int              `json:"count"`
+	Data        []LatestLocation `json:"data"`
+	GeneratedAt int64            `json:"generated_at"`
+}
+
+// GeofenceEvent defines model for GeofenceEvent.
+type GeofenceEvent struct {
+	CreatedAt   time.Time              `json:"created_at"`
+	DistanceM   float64                `json:"distance_m"`
+	EventType   GeofenceEventEventType `json:"event_type"`
+	Id          int64                  `json:"id"`
+	Latitude    float64                `json:"latitude"`
+	Longitude   float64                `json:"longitude"`
+	OperatorId  *string                `json:"operator_id,omitempty"`
+	StationId   int64                  `json:"station_id"`
+	StationName *string                `json:"station_name,omitempty"`
+	Timestamp   int64                  `json:"timestamp"`
+	VehicleId   string                 `json:"vehicle_id"`
+}
+
+// GeofenceEventEventType defines model for GeofenceEvent.EventType.
+type GeofenceEventEventType string
+
+// GeofenceEventPage defines model for GeofenceEventPage.
+type GeofenceEventPage struct {
+	Count      int             `json:"count"`
+	Data       []GeofenceEvent `json:"data"`
+	HasMore    bool            `json:"has_more"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// GraphQLRequest defines model for GraphQLRequest.
+type GraphQLRequest struct {
+	OperationName *string                 `json:"operationName"`
+	Query         string                  `json:"query"`
+	Variables     *map[string]interface{} `json:"variables"`
+}
+
+// GraphQLResponse defines model for GraphQLResponse.
+type GraphQLResponse struct {
+	Data   *map[string]interface{}        `json:"data"`
+	Errors *[]GraphQLResponse_Errors_Item `json:"errors,omitempty"`
+}
+
+// GraphQLResponse_Errors_Item defines model for GraphQLResponse.errors.Item.
+type GraphQLResponse_Errors_Item struct {
+	Message              string                 `json:"message"`
+	AdditionalProperties map[string]interface{} `json:"-"`
+}
+
+// HistoryPage defines model for HistoryPage.
+type HistoryPage struct {
+	Count      int        `json:"count"`
+	Data       []Location `json:"data"`
+	HasMore    bool       `json:"has_more"`
+	NextCursor *string    `json:"next_cursor"`
+
+	// Total Only with include_total=true
+	Total *int64 `json:"total,omitempty"`
+}
+
+// LatestLocation defines model for LatestLocation.
+type LatestLocation struct {
+	Ignition   *bool   `json:"ignition,omitempty"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	OperatorId *string `json:"operator_id,omitempty"`
+
+	// Speed km/h, as reported by the device
+	Speed     *float64 `json:"speed,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+	VehicleId string   `json:"vehicle_id"`
+}
+
+// Location defines model for Location.
+type Location struct {
+	CreatedAt  time.Time `json:"created_at"`
+	Id         int64     `json:"id"`
+	Ignition   *bool     `json:"ignition,omitempty"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	OperatorId *string   `json:"operator_id,omitempty"`
+
+	// Speed km/h, as reported by the device
+	Speed     *float64 `json:"speed,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+	VehicleId string   `json:"vehicle_id"`
+}
+
+// Operator defines model for Operator.
+type Operator struct {
+	CreatedAt  time.Time `json:"created_at"`
+	Name       string    `json:"name"`
+	OperatorId string    `json:"operator_id"`
+}
+
+// OperatorList defines model for OperatorList.
+type OperatorList struct {
+	Count int        `json:"count"`
+	Data  []Operator `json:"data"`
+}
+
+// OperatorRequest defines model for OperatorRequest.
+type OperatorRequest struct {
+	Name       string `json:"name"`
+	OperatorId string `json:"operator_id"`
+}
+
+// PayloadFormat defines model for PayloadFormat.
+type PayloadFormat string
+
+// Principal defines model for Principal.
+type Principal struct {
+	KeyId           *int64          `json:"key_id,omitempty"`
+	Method          PrincipalMethod `json:"method"`
+	OperatorId      *string         `json:"operator_id,omitempty"`
+	RateLimitPerMin *int            `json:"rate_limit_per_min,omitempty"`
+	Role            Role            `json:"role"`
+	Scope           struct {
+		Groups   *[]string `json:"groups,omitempty"`
+		RouteIds *[]string `json:"route_ids,omitempty"`
+	} `json:"scope"`
+	Subject string `json:"subject"`
+}
+
+// PrincipalMethod defines model for Principal.Method.
+type PrincipalMethod string
+
+// Role defines model for Role.
+type Role string
+
+// Rule defines model for Rule.
+type Rule struct {
+	Conditions  *[]RuleCondition `json:"conditions"`
+	CooldownS   int64            `json:"cooldown_s"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Description string           `json:"description"`
+	DurationS   int64            `json:"duration_s"`
+	Enabled     bool             `json:"enabled"`
+	Id          int64            `json:"id"`
+	Key         string           `json:"key"`
+	Name        string           `json:"name"`
+	Severity    Severity         `json:"severity"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// RuleCondition One clause of a rule; which fields apply depends on type
+type RuleCondition = model.RuleCondition
+
+// RuleList defines model for RuleList.
+type RuleList struct {
+	Count int    `json:"count"`
+	Data  []Rule `json:"data"`
+}
+
+// RuleRequest defines model for RuleRequest.
+type RuleRequest struct {
+	Conditions  model.RuleConditions `json:"conditions"`
+	CooldownS   int64                `json:"cooldown_s,omitempty"`
+	Description string               `json:"description,omitempty"`
+
+	// DurationS How long all conditions must hold before the alert fires
+	DurationS int64  `json:"duration_s,omitempty"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	Severity  string `json:"severity,omitempty"`
+}
+
+// Severity defines model for Severity.
+type Severity string
+
+// StationVisit defines model for StationVisit.
+type StationVisit struct {
+	ArrivalTime int64     `json:"arrival_time"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// DepartureTime null while the vehicle is still at the station
+	DepartureTime *int64    `json:"departure_time"`
+	DwellS        *int64    `json:"dwell_s"`
+	Id            int64     `json:"id"`
+	MinDistanceM  float64   `json:"min_distance_m"`
+	OperatorId    *string   `json:"operator_id,omitempty"`
+	PointCount    int       `json:"point_count"`
+	StationId     int64     `json:"station_id"`
+	StationName   *string   `json:"station_name,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	VehicleId     string    `json:"vehicle_id"`
+}
+
+// StationVisitPage defines model for StationVisitPage.
+type StationVisitPage struct {
+	Count      int            `json:"count"`
+	Data       []StationVisit `json:"data"`
+	HasMore    bool           `json:"has_more"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// TokenResponse defines model for TokenResponse.
+type TokenResponse struct {
+	AccessToken string                 `json:"access_token"`
+	ExpiresIn   int64                  `json:"expires_in"`
+	TokenType   TokenResponseTokenType `json:"token_type"`
+}
+
+// TokenResponseTokenType defines model for TokenResponse.TokenType.
+type TokenResponseTokenType string
+
+// Trip defines model for Trip.
+type Trip struct {
+	CreatedAt    time.Time `json:"created_at"`
+	DistanceM    float64   `json:"distance_m"`
+	DurationS    int64     `json:"duration_s"`
+	EndLatitude  float64   `json:"end_latitude"`
+	EndLongitude float64   `json:"end_longitude"`
+
+	// EndReason stopped, ignition_off, signal_gap, or shutdown when the worker stopped mid-trip
+	EndReason      string  `json:"end_reason"`
+	EndStationId   *int64  `json:"end_station_id"`
+	EndTime        int64   `json:"end_time"`
+	Id             int64   `json:"id"`
+	MaxSpeedKmh    float64 `json:"max_speed_kmh"`
+	OperatorId     *string `json:"operator_id,omitempty"`
+	PointCount     int     `json:"point_count"`
+	StartLatitude  float64 `json:"start_latitude"`
+	StartLongitude float64 `json:"start_longitude"`
+	StartStationId *int64  `json:"start_station_id"`
+	StartTime      int64   `json:"start_time"`
+	VehicleId      string  `json:"vehicle_id"`
+}
+
+// TripList defines model for TripList.
+type TripList struct {
+	Count int    `json:"count"`
+	Data  []Trip `json:"data"`
+}
+
+// VehiclePositionsFeed defines model for VehiclePositionsFeed.
+type VehiclePositionsFeed struct {
+	Entity []struct {
+		Bearing   *float32 `json:"bearing,omitempty"`
+		Id        string   `json:"id"`
+		Label     *string  `json:"label,omitempty"`
+		Latitude  float32  `json:"latitude"`
+		Longitude float32  `json:"longitude"`
+		RouteId   *string  `json:"route_id,omitempty"`
+
+		// Speed Meters per second
+		Speed     *float32 `json:"speed,omitempty"`
+		StopId    *string  `json:"stop_id,omitempty"`
+		Timestamp int64    `json:"timestamp"`
+		TripId    *string  `json:"trip_id,omitempty"`
+		VehicleId string   `json:"vehicle_id"`
+	} `json:"entity"`
+	Header struct {
+		GtfsRealtimeVersion string `json:"gtfs_realtime_version"`
+		Incrementality      int    `json:"incrementality"`
+		Timestamp           int64  `json:"timestamp"`
+	} `json:"header"`
+}
+
+// Webhook defines model for Webhook.
+type Webhook struct {
+	Channel             WebhookChannel `json:"channel"`
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+	CreatedAt           time.Time      `json:"created_at"`
+	DisabledAt          *time.Time     `json:"disabled_at,omitempty"`
+	DisabledReason      *string        `json:"disabled_reason,omitempty"`
+	Enabled             bool           `json:"enabled"`
+	EventTypes          *[]string      `json:"event_types"`
+	Id                  int64          `json:"id"`
+	Name                string         `json:"name"`
+	OperatorId          *string        `json:"operator_id"`
+	PayloadFormat       PayloadFormat  `json:"payload_format"`
+	RateLimitPerMin     int            `json:"rate_limit_per_min"`
+	RouteIds            *[]string      `json:"route_ids"`
+
+	// Secret Only returned when it was generated or changed
+	Secret     *string   `json:"secret,omitempty"`
+	Target     string    `json:"target"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Url        string    `json:"url"`
+	VehicleIds *[]string `json:"vehicle_ids"`
+}
+
+// WebhookChannel defines model for Webhook.Channel.
+type WebhookChannel string
+
+// WebhookList defines model for WebhookList.
+type WebhookList struct {
+	Count int       `json:"count"`
+	Data  []Webhook `json:"data"`
+}
+
+// WebhookRequest defines model for WebhookRequest.
+type WebhookRequest struct {
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// EventTypes Routing key patterns, e.g. geofence.entry, alert.* or # for everything
+	EventTypes    []string `json:"event_types"`
+	Name          string   `json:"name"`
+	PayloadFormat string   `json:"payload_format,omitempty"`
+
+	// RateLimitPerMin 0 = unlimited
+	RateLimitPerMin int      `json:"rate_limit_per_min,omitempty"`
+	RouteIds        []string `json:"route_ids,omitempty"`
+
+	// Secret Generated when empty on create; an empty secret keeps the current one on update
+	Secret     string   `json:"secret,omitempty"`
+	URL        string   `json:"url"`
+	VehicleIds []string `json:"vehicle_ids,omitempty"`
+}
+
+// Cursor defines model for Cursor.
+type Cursor = string
+
+// End defines model for End.
+type End = string
+
+// ExportEnd defines model for ExportEnd.
+type ExportEnd = string
+
+// ExportFormat defines model for ExportFormat.
+type ExportFormat string
+
+// ExportStart defines model for ExportStart.
+type ExportStart = string
+
+// GeofenceEventType defines model for GeofenceEventType.
+type GeofenceEventType string
+
+// GeofenceLimit defines model for GeofenceLimit.
+type GeofenceLimit = int
+
+// Id defines model for Id.
+type Id = int64
+
+// Start defines model for Start.
+type Start = string
+
+// StationIdQuery defines model for StationIdQuery.
+type StationIdQuery = int64
+
+// StreamBBox defines model for StreamBBox.
+type StreamBBox = string
+
+// StreamEvents defines model for StreamEvents.
+type StreamEvents = []string
+
+// StreamFormat defines model for StreamFormat.
+type StreamFormat string
+
+// StreamRouteId defines model for StreamRouteId.
+type StreamRouteId = []string
+
+// StreamVehicleId defines model for StreamVehicleId.
+type StreamVehicleId = []string
+
+// VehicleId defines model for VehicleId.
+type VehicleId = string
+
+// VehicleIdQuery defines model for VehicleIdQuery.
+type VehicleIdQuery = string
+
+// VisitOpen defines model for VisitOpen.
+type VisitOpen string
+
+// BadRequest defines model for BadRequest.
+type BadRequest = Error
+
+// Conflict defines model for Conflict.
+type Conflict = Error
+
+// Export defines model for Export.
+type Export = openapi_types.File
+
+// Forbidden defines model for Forbidden.
+type Forbidden = Error
+
+// NotFound defines model for NotFound.
+type NotFound = Error
+
+// TooManyRequests defines model for TooManyRequests.
+type TooManyRequests = Error
+
+// Unauthorized defines model for Unauthorized.
+type Unauthorized = Error
+
+// ListDevicesParams defines parameters for ListDevices.
+type ListDevicesParams struct {
+	VehicleId *VehicleIdQuery `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+}
+
+// ListGeofenceEventsParams defines parameters for ListGeofenceEvents.
+type ListGeofenceEventsParams struct {
+	VehicleId *VehicleIdQuery               `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+	StationId *StationIdQuery               `form:"station_id,omitempty" json:"station_id,omitempty"`
+	Type      *ListGeofenceEventsParamsType `form:"type,omitempty" json:"type,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End           `form:"end,omitempty" json:"end,omitempty"`
+	Limit *GeofenceLimit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListGeofenceEventsParamsType defines parameters for ListGeofenceEvents.
+type ListGeofenceEventsParamsType string
+
+// ListVisitsParams defines parameters for ListVisits.
+type ListVisitsParams struct {
+	VehicleId *VehicleIdQuery `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+	StationId *StationIdQuery `form:"station_id,omitempty" json:"station_id,omitempty"`
+
+	// Open true for visits still in progress, false for finished ones
+	Open *ListVisitsParamsOpen `form:"open,omitempty" json:"open,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End           `form:"end,omitempty" json:"end,omitempty"`
+	Limit *GeofenceLimit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListVisitsParamsOpen defines parameters for ListVisits.
+type ListVisitsParamsOpen string
+
+// GetVehiclePositionsParams defines parameters for GetVehiclePositions.
+type GetVehiclePositionsParams struct {
+	// Format json returns the same feed as JSON, for debugging
+	Format *GetVehiclePositionsParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// GetVehiclePositionsParamsFormat defines parameters for GetVehiclePositions.
+type GetVehiclePositionsParamsFormat string
+
+// ListStationVisitsParams defines parameters for ListStationVisits.
+type ListStationVisitsParams struct {
+	VehicleId *VehicleIdQuery `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+
+	// Open true for visits still in progress, false for finished ones
+	Open *ListStationVisitsParamsOpen `form:"open,omitempty" json:"open,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End           `form:"end,omitempty" json:"end,omitempty"`
+	Limit *GeofenceLimit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListStationVisitsParamsOpen defines parameters for ListStationVisits.
+type ListStationVisitsParamsOpen string
+
+// StreamSSEParams defines parameters for StreamSSE.
+type StreamSSEParams struct {
+	// VehicleId Comma separated or repeated
+	VehicleId *StreamVehicleId `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+
+	// RouteId Comma separated or repeated
+	RouteId *StreamRouteId `form:"route_id,omitempty" json:"route_id,omitempty"`
+
+	// Events Event types (location, geofence.entry, geofence.exit), comma separated or repeated
+	Events *StreamEvents `form:"events,omitempty" json:"events,omitempty"`
+
+	// Bbox min_lat,min_lon,max_lat,max_lon
+	Bbox   *StreamBBox            `form:"bbox,omitempty" json:"bbox,omitempty"`
+	Format *StreamSSEParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// StreamSSEParamsFormat defines parameters for StreamSSE.
+type StreamSSEParamsFormat string
+
+// StreamWebSocketParams defines parameters for StreamWebSocket.
+type StreamWebSocketParams struct {
+	// VehicleId Comma separated or repeated
+	VehicleId *StreamVehicleId `form:"vehicle_id,omitempty" json:"vehicle_id,omitempty"`
+
+	// RouteId Comma separated or repeated
+	RouteId *StreamRouteId `form:"route_id,omitempty" json:"route_id,omitempty"`
+
+	// Events Event types (location, geofence.entry, geofence.exit), comma separated or repeated
+	Events *StreamEvents `form:"events,omitempty" json:"events,omitempty"`
+
+	// Bbox min_lat,min_lon,max_lat,max_lon
+	Bbox   *StreamBBox                  `form:"bbox,omitempty" json:"bbox,omitempty"`
+	Format *StreamWebSocketParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// StreamWebSocketParamsFormat defines parameters for StreamWebSocket.
+type StreamWebSocketParamsFormat string
+
+// ExportVehiclesParams defines parameters for ExportVehicles.
+type ExportVehiclesParams struct {
+	// VehicleId Up to 50 vehicles, comma separated or repeated
+	VehicleId []string                    `form:"vehicle_id" json:"vehicle_id"`
+	Format    *ExportVehiclesParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start ExportStart `form:"start" json:"start"`
+
+	// End Unix timestamp or RFC3339, at most 31 days after start
+	End ExportEnd `form:"end" json:"end"`
+}
+
+// ExportVehiclesParamsFormat defines parameters for ExportVehicles.
+type ExportVehiclesParamsFormat string
+
+// GetFleetLocationsParams defines parameters for GetFleetLocations.
+type GetFleetLocationsParams struct {
+	MinLat *float32 `form:"min_lat,omitempty" json:"min_lat,omitempty"`
+	MinLon *float32 `form:"min_lon,omitempty" json:"min_lon,omitempty"`
+	MaxLat *float32 `form:"max_lat,omitempty" json:"max_lat,omitempty"`
+	MaxLon *float32 `form:"max_lon,omitempty" json:"max_lon,omitempty"`
+
+	// MaxAge Only vehicles that reported within the last N seconds
+	MaxAge *int64 `form:"max_age,omitempty" json:"max_age,omitempty"`
+}
+
+// GetFleetConnectivityParams defines parameters for GetFleetConnectivity.
+type GetFleetConnectivityParams struct {
+	Status *GetFleetConnectivityParamsStatus `form:"status,omitempty" json:"status,omitempty"`
+}
+
+// GetFleetConnectivityParamsStatus defines parameters for GetFleetConnectivity.
+type GetFleetConnectivityParamsStatus string
+
+// ExportVehicleParams defines parameters for ExportVehicle.
+type ExportVehicleParams struct {
+	Format *ExportVehicleParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start ExportStart `form:"start" json:"start"`
+
+	// End Unix timestamp or RFC3339, at most 31 days after start
+	End ExportEnd `form:"end" json:"end"`
+}
+
+// ExportVehicleParamsFormat defines parameters for ExportVehicle.
+type ExportVehicleParamsFormat string
+
+// ListVehicleGeofenceEventsParams defines parameters for ListVehicleGeofenceEvents.
+type ListVehicleGeofenceEventsParams struct {
+	StationId *StationIdQuery                      `form:"station_id,omitempty" json:"station_id,omitempty"`
+	Type      *ListVehicleGeofenceEventsParamsType `form:"type,omitempty" json:"type,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End           `form:"end,omitempty" json:"end,omitempty"`
+	Limit *GeofenceLimit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListVehicleGeofenceEventsParamsType defines parameters for ListVehicleGeofenceEvents.
+type ListVehicleGeofenceEventsParamsType string
+
+// GetHistoryParams defines parameters for GetHistory.
+type GetHistoryParams struct {
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End                   `form:"end,omitempty" json:"end,omitempty"`
+	Limit *int                   `form:"limit,omitempty" json:"limit,omitempty"`
+	Order *GetHistoryParamsOrder `form:"order,omitempty" json:"order,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// IncludeTotal Also count all matching rows (slower)
+	IncludeTotal *bool `form:"include_total,omitempty" json:"include_total,omitempty"`
+}
+
+// GetHistoryParamsOrder defines parameters for GetHistory.
+type GetHistoryParamsOrder string
+
+// GetTripsParams defines parameters for GetTrips.
+type GetTripsParams struct {
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End `form:"end,omitempty" json:"end,omitempty"`
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListVehicleVisitsParams defines parameters for ListVehicleVisits.
+type ListVehicleVisitsParams struct {
+	StationId *StationIdQuery `form:"station_id,omitempty" json:"station_id,omitempty"`
+
+	// Open true for visits still in progress, false for finished ones
+	Open *ListVehicleVisitsParamsOpen `form:"open,omitempty" json:"open,omitempty"`
+
+	// Start Unix timestamp or RFC3339
+	Start *Start `form:"start,omitempty" json:"start,omitempty"`
+
+	// End Unix timestamp or RFC3339
+	End   *End           `form:"end,omitempty" json:"end,omitempty"`
+	Limit *GeofenceLimit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor next_cursor of the previous page
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListVehicleVisitsParamsOpen defines parameters for ListVehicleVisits.
+type ListVehicleVisitsParamsOpen string
+
+// GetWebhookDeliveriesParams defines parameters for GetWebhookDeliveries.
+type GetWebhookDeliveriesParams struct {
+	Status     *DeliveryStatus `form:"status,omitempty" json:"status,omitempty"`
+	DeliveryId *string         `form:"delivery_id,omitempty" json:"delivery_id,omitempty"`
+
+	// Before next_before of the previous page
+	Before *int64 `form:"before,omitempty" json:"before,omitempty"`
+	Limit  *int   `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// CreateAPIKeyJSONRequestBody defines body for CreateAPIKey for application/json ContentType.
+type CreateAPIKeyJSONRequestBody = APIKeyRequest
+
+// UpdateAPIKeyJSONRequestBody defines body for UpdateAPIKey for application/json ContentType.
+type UpdateAPIKeyJSONRequestBody = APIKeyRequest
+
+// CreateDeviceJSONRequestBody defines body for CreateDevice for application/json ContentType.
+type CreateDeviceJSONRequestBody = DeviceRequest
+
+// GraphqlQueryJSONRequestBody defines body for GraphqlQuery for application/json ContentType.
+type GraphqlQueryJSONRequestBody = GraphQLRequest
+
+// CreateOperatorJSONRequestBody defines body for CreateOperator for application/json ContentType.
+type CreateOperatorJSONRequestBody = OperatorRequest
+
+// CreateRuleJSONRequestBody defines body for CreateRule for application/json ContentType.
+type CreateRuleJSONRequestBody = RuleRequest
+
+// UpdateRuleJSONRequestBody defines body for UpdateRule for application/json ContentType.
+type UpdateRuleJSONRequestBody = RuleRequest
+
+// CreateWebhookJSONRequestBody defines body for CreateWebhook for application/json ContentType.
+type CreateWebhookJSONRequestBody = WebhookRequest
+
+// UpdateWebhookJSONRequestBody defines body for UpdateWebhook for application/json ContentType.
+type UpdateWebhookJSONRequestBody = WebhookRequest
+
+// Getter for additional properties for GraphQLResponse_Errors_Item. Returns the specified
+// element and whether it was found
+func (a GraphQLResponse_Errors_Item) Get(fieldName string) (value interface{}, found bool) {
+	if a.AdditionalProperties != nil {
+		value, found = a.AdditionalProperties[fieldName]
+	}
+	return
+}
+
+// Setter for additional properties for GraphQLResponse_Errors_Item
+func (a *GraphQLResponse_Errors_Item) Set(fieldName string, value interface{}) {
+	if a.AdditionalProperties == nil {
+		a.AdditionalProperties = make(map[string]interface{})
+	}
+	a.AdditionalProperties[fieldName] = value
+}
+
+// Override default JSON handling for GraphQLResponse_Errors_Item to handle AdditionalProperties
+func (a *GraphQLResponse_Errors_Item) UnmarshalJSON(b []byte) error {
+	object := make(map[string]json.RawMessage)
+	err := json.Unmarshal(b, &object)
+	if err != nil {
+		return err
+	}
+
+	if raw, found := object["message"]; found {
+		err = json.Unmarshal(raw, &a.Message)
+		if err != nil {
+			return fmt.Errorf("error reading 'message': %w", err)
+		}
+		delete(object, "message")
+	}
+
+	if len(object) != 0 {
+		a.AdditionalProperties = make(map[string]interface{})
+		for fieldName, fieldBuf := range object {
+			var fieldVal interface{}
+			err := json.Unmarshal(fieldBuf, &fieldVal)
+			if err != nil {
+				return fmt.Errorf("error unmarshaling field %s: %w", fieldName, err)
+			}
+			a.AdditionalProperties[fieldName] = fieldVal
+		}
+	}
+	return nil
+}
+
+// Override default JSON handling for GraphQLResponse_Errors_Item to handle AdditionalProperties
+func (a GraphQLResponse_Errors_Item) MarshalJSON() ([]byte, error) {
+	var err error
+	object := make(map[string]json.RawMessage)
+
+	object["message"], err = json.Marshal(a.Message)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling 'message': %w", err)
+	}
+
+	for fieldName, field := range a.AdditionalProperties {
+		object[fieldName], err = json.Marshal(field)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling '%s': %w", fieldName, err)
+		}
+	}
+	return json.Marshal(object)
+}
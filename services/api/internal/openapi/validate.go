@@ -0,0 +1,97 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// GinPath turns a document path such as /vehicles/{vehicle_id}/history into the gin
+// pattern /vehicles/:vehicle_id/history.
+func GinPath(specPath string) string {
+	return pathParam.ReplaceAllString(specPath, ":$1")
+}
+
+// Validator checks requests against the operation of the route gin matched. Credentials
+// are left to auth.Middleware, responses are checked by the contract tests.
+type Validator struct {
+	// "GET /vehicles/:vehicle_id/history" -> operation
+	routes map[string]*routers.Route
+	opts   *openapi3filter.Options
+}
+
+func NewValidator(spec *openapi3.T) *Validator {
+	routes := make(map[string]*routers.Route)
+	for path, item := range spec.Paths.Map() {
+		for method, op := range item.Operations() {
+			routes[method+" "+GinPath(path)] = &routers.Route{
+				Spec:      spec,
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: op,
+			}
+		}
+	}
+
+	opts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		// defaults are the handlers' business, the request is passed on as sent
+		SkipSettingDefaults: true,
+	}
+	opts.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+	return &Validator{routes: routes, opts: opts}
+}
+
+// FindRoute returns the operation documented for a gin route pattern.
+func (v *Validator) FindRoute(method, ginPath string) (*routers.Route, bool) {
+	route, ok := v.routes[method+" "+ginPath]
+	return route, ok
+}
+
+// Middleware rejects requests whose parameters or body don't match the document with 400.
+// Routes the document doesn't know, like /docs, pass unchecked.
+func (v *Validator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := v.FindRoute(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+
+		err := openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    v.opts,
+		})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// schemaErrorMessage keeps the schema and value dumps out of error responses.
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	if p := err.JSONPointer(); len(p) > 0 {
+		return strings.Join(p, ".") + ": " + err.Reason
+	}
+
+	return err.Reason
+}
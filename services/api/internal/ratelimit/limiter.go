@@ -0,0 +1,107 @@
+// Package ratelimit implements token buckets in Redis, so every API replica draws from the
+// same bucket, and the gin middleware applying them per client IP and per caller.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "fleet:ratelimit:"
+
+// Policy is a bucket refilled at PerMin tokens a minute and holding at most Burst.
+// PerMin <= 0 means unlimited.
+type Policy struct {
+	PerMin int
+	Burst  int
+}
+
+func (p Policy) Unlimited() bool {
+	return p.PerMin <= 0
+}
+
+func (p Policy) burst() int {
+	if p.Burst < 1 {
+		return 1
+	}
+	return p.Burst
+}
+
+// Result of taking a token.
+type Result struct {
+	Allowed bool
+	// bucket size
+	Limit     int
+	Remaining int
+	// until the bucket is full again
+	Reset time.Duration
+	// until the next token, zero when allowed
+	RetryAfter time.Duration
+}
+
+// takeToken refills the bucket for the time elapsed since the last call and takes one
+// token if there is one. The clock is Redis', replicas' clocks don't matter.
+// KEYS[1] bucket; ARGV rate (tokens/ms), burst.
+// Returns allowed, tokens left (x1000), ms until full, ms until the next token.
+var takeToken = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+local full = math.ceil((burst - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], full + 1000)
+
+return {allowed, math.floor(tokens * 1000), full, wait}
+`)
+
+type Limiter struct {
+	rdb *redis.Client
+}
+
+func NewLimiter(rdb *redis.Client) *Limiter {
+	return &Limiter{rdb: rdb}
+}
+
+// Take takes a token from the bucket named key.
+func (l *Limiter) Take(ctx context.Context, key string, p Policy) (Result, error) {
+	burst := p.burst()
+	rate := float64(p.PerMin) / float64(time.Minute/time.Millisecond)
+
+	vals, err := takeToken.Run(ctx, l.rdb, []string{keyPrefix + key},
+		strconv.FormatFloat(rate, 'g', -1, 64), burst).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      burst,
+		Remaining:  int(math.Floor(float64(vals[1]) / 1000)),
+		Reset:      time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,227 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+)
+
+func keyRow(id int64, prefix, hash, role string, expiresAt, revokedAt *time.Time) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "name", "key_prefix", "key_hash", "role", "operator_id", "expires_at",
+		"last_used_at", "revoked_at", "created_at", "updated_at"}).
+		AddRow(id, "dispatch", prefix, hash, role, nil, expiresAt, now, revokedAt, now, now)
+}
+
+// exchangeKey presents a fresh key once and trades it for a token.
+func (s *testServer) exchangeKey(t *testing.T, expiresAt *time.Time) (token string, expiresIn int64) {
+	t.Helper()
+	key, prefix := auth.GenerateKey()
+	s.mock.ExpectQuery("").WillReturnRows(keyRow(1, prefix, auth.HashKey(key), "dispatcher", expiresAt, nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/token", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token exchange: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	return body.AccessToken, body.ExpiresIn
+}
+
+func TestTokenFollowsItsKey(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	cases := []struct {
+		name string
+		// the key row as it is when the token comes back
+		row    *sqlmock.Rows
+		status int
+		role   string
+	}{
+		{"key unchanged", keyRow(1, "p", "h", "dispatcher", nil, nil), http.StatusOK, "dispatcher"},
+		{"key demoted", keyRow(1, "p", "h", "read-only", nil, nil), http.StatusOK, "read-only"},
+		{"key revoked", keyRow(1, "p", "h", "dispatcher", nil, &past), http.StatusUnauthorized, ""},
+		{"key expired", keyRow(1, "p", "h", "dispatcher", &past, nil), http.StatusUnauthorized, ""},
+		{"key deleted", sqlmock.NewRows([]string{"id"}), http.StatusUnauthorized, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestServer(t)
+			token, _ := s.exchangeKey(t, nil)
+
+			s.mock.ExpectQuery("").WillReturnRows(c.row)
+			req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			s.engine.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+			if c.role != "" {
+				var me auth.Principal
+				if err := json.Unmarshal(rec.Body.Bytes(), &me); err != nil {
+					t.Fatal(err)
+				}
+				if me.Role != c.role || me.Method != auth.MethodJWT || me.KeyId == nil || *me.KeyId != 1 {
+					t.Errorf("caller %+v, want key 1 as %s over a token", me, c.role)
+				}
+			}
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTokenExpiresWithItsKey(t *testing.T) {
+	s := newTestServer(t)
+	soon := time.Now().Add(10 * time.Minute)
+
+	_, expiresIn := s.exchangeKey(t, &soon)
+	if expiresIn > 600 {
+		t.Errorf("token expires in %ds, after its key", expiresIn)
+	}
+}
+
+func TestRevokedKeyIsRefusedAtOnce(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.token
+	key, prefix := auth.GenerateKey()
+	past := time.Now()
+
+	me := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		s.engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	s.mock.ExpectQuery("").WillReturnRows(keyRow(1, prefix, auth.HashKey(key), "dispatcher", nil, nil))
+	if code := me(); code != http.StatusOK {
+		t.Fatalf("status %d before the revocation", code)
+	}
+
+	s.mock.ExpectQuery("").WillReturnRows(keyRow(1, prefix, auth.HashKey(key), "dispatcher", nil, nil))
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	req := httptest.NewRequest(http.MethodDelete, "/api-keys/1", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// looked up again instead of served from the cache
+	s.mock.ExpectQuery("").WillReturnRows(keyRow(1, prefix, auth.HashKey(key), "dispatcher", nil, &past))
+	if code := me(); code != http.StatusUnauthorized {
+		t.Errorf("status %d after the revocation, want 401", code)
+	}
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyKeepsItsOperatorOnUpdate(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"operator left out", `{"name":"dispatch","role":"dispatcher"}`, http.StatusOK},
+		{"operator restated", `{"name":"dispatch","role":"dispatcher","operator_id":"op-1"}`, http.StatusOK},
+		{"operator changed", `{"name":"dispatch","role":"dispatcher","operator_id":"op-2"}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.mock.ExpectQuery("").WillReturnRows(apiKeyRows(now))
+			if c.status == http.StatusOK {
+				s.mock.ExpectBegin()
+				s.mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				s.mock.ExpectCommit()
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/api-keys/1", strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			rec := httptest.NewRecorder()
+			s.engine.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+			if c.status == http.StatusOK {
+				var k model.APIKey
+				if err := json.Unmarshal(rec.Body.Bytes(), &k); err != nil {
+					t.Fatal(err)
+				}
+				if k.OperatorId == nil || *k.OperatorId != "op-1" {
+					t.Errorf("operator_id %v after the update, want op-1", k.OperatorId)
+				}
+			}
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestScopedKeysCantUseWebhooks(t *testing.T) {
+	routes := []struct{ method, target, body string }{
+		{http.MethodGet, "/webhooks", ""},
+		{http.MethodPost, "/webhooks", `{"name":"mine","url":"https://example.com/hook","event_types":["#"]}`},
+		{http.MethodGet, "/webhooks/3", ""},
+		{http.MethodPut, "/webhooks/3", `{"name":"mine","url":"https://example.com/hook","event_types":["#"]}`},
+		{http.MethodDelete, "/webhooks/3", ""},
+		{http.MethodGet, "/webhooks/3/deliveries", ""},
+	}
+
+	for _, r := range routes {
+		t.Run(r.method+" "+r.target, func(t *testing.T) {
+			s := newTestServer(t)
+			s.as(t, &auth.Principal{Subject: "route-1A", Role: model.RoleDispatcher, Scope: auth.Scope{RouteIds: []string{"1A"}}}, "bus-1")
+
+			req := httptest.NewRequest(r.method, r.target, strings.NewReader(r.body))
+			if r.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			rec := httptest.NewRecorder()
+			s.engine.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status %d, want 403: %s", rec.Code, rec.Body)
+			}
+			// nothing read or written past the vehicle scope
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"tj/config"
+	model "tj/pkg/model"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/graph"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/openapi"
+	"tj/services/api/internal/ratelimit"
+	"tj/services/api/internal/stream"
+)
+
+// the routes serving the document itself aren't part of it
+var undocumented = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /docs":         true,
+}
+
+type testServer struct {
+	engine    *gin.Engine
+	validator *openapi.Validator
+	mock      sqlmock.Sqlmock
+	rdb       *redis.Client
+	auth      *auth.Authenticator
+	token     string
+}
+
+// newTestServer builds the real router on sqlmock and miniredis. SQL isn't matched, each
+// case queues the rows its handler reads in order.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return newTestServerWith(t, nil)
+}
+
+// newTestServerWith lets a test change the dependencies before the router is built.
+func newTestServerWith(t *testing.T, change func(*Deps)) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.Cfg = &config.Config{OfflineThreshold: 5 * time.Minute}
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(
+		func(string, string) error { return nil })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	authn := auth.NewAuthenticator(gdb, rdb, auth.Config{JWTSecret: []byte("contract-test"), JWTTTL: time.Hour})
+	// a platform admin without scope: the auth middleware never touches the database
+	token, _, err := authn.IssueToken(&auth.Principal{Subject: "contract-test", Role: model.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deps := Deps{
+		DB:       gdb,
+		Repos:    repository.NewPostgres(gdb),
+		Rdb:      rdb,
+		Hub:      stream.NewHub(gdb),
+		Auth:     authn,
+		IPLimit:  ratelimit.Policy{},
+		KeyLimit: ratelimit.Policy{},
+		GraphQL:  graph.Limits{MaxDepth: 8, MaxComplexity: 5000},
+	}
+	if change != nil {
+		change(&deps)
+	}
+	engine, err := New(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{
+		engine:    engine,
+		validator: openapi.NewValidator(spec),
+		mock:      mock,
+		rdb:       rdb,
+		auth:      authn,
+		token:     token,
+	}
+}
+
+// as makes the following requests with a token for p. Callers bound to an operator or a
+// scope have their vehicles looked up on the first request, vehicles are that answer.
+func (s *testServer) as(t *testing.T, p *auth.Principal, vehicles ...string) {
+	t.Helper()
+	token, _, err := s.auth.IssueToken(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.token = token
+
+	if p.OperatorId != nil || !p.Scope.Unrestricted() {
+		rows := sqlmock.NewRows([]string{"vehicle_id"})
+		for _, v := range vehicles {
+			rows.AddRow(v)
+		}
+		s.mock.ExpectQuery("").WillReturnRows(rows)
+	}
+}
+
+func TestSpecIsValid(t *testing.T) {
+	if _, err := openapi.Load(); err != nil {
+		t.Fatalf("openapi.yaml: %v", err)
+	}
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	s := newTestServer(t)
+
+	registered := make(map[string]bool)
+	for _, r := range s.engine.Routes() {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if undocumented[key] {
+			continue
+		}
+		if _, ok := s.validator.FindRoute(r.Method, r.Path); !ok {
+			t.Errorf("%s is served but not documented in openapi.yaml", key)
+		}
+	}
+
+	spec, _ := openapi.Load()
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			if key := method + " " + openapi.GinPath(path); !registered[key] {
+				t.Errorf("%s %s is documented but not served", method, path)
+			}
+		}
+	}
+}
+
+type contractCase struct {
+	name string
+	// gin pattern of the route, to look up the documented operation
+	route  string
+	method string
+	target string
+	body   string
+	status int
+	// queues the database rows the handler reads, in order
+	db func(m sqlmock.Sqlmock)
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	now := time.Now()
+
+	cases := []contractCase{
+		{
+			name: "graphql", route: "/graphql", method: "POST", target: "/graphql", status: 200,
+			body: `{"query":"{ vehicles(first: 2) { id label lastLocation { latitude timestamp } } }"}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"vehicle_id", "label", "route_id"}).
+					AddRow("bus-1", "Bus 1", "1").AddRow("bus-2", nil, nil))
+				// both last locations in one batch
+				m.ExpectQuery("").WillReturnRows(locationRows(now))
+			},
+		},
+		{
+			name: "graphql over the complexity limit", route: "/graphql", method: "POST", target: "/graphql", status: 200,
+			body: `{"query":"{ vehicles(first: 1000) { history(first: 1000) { latitude } } }"}`,
+		},
+		{name: "graphql without a query", route: "/graphql", method: "POST", target: "/graphql", body: `{}`, status: 400},
+		{name: "event schemas", route: "/events/schemas", target: "/events/schemas", status: 200},
+		{name: "event schema", route: "/events/schemas/:type", target: "/events/schemas/geofence.entry", status: 200},
+		{name: "unknown event schema", route: "/events/schemas/:type", target: "/events/schemas/nope", status: 404},
+		{name: "me", route: "/auth/me", target: "/auth/me", status: 200},
+		{name: "token for a token", route: "/auth/token", method: "POST", target: "/auth/token", status: 403},
+		{name: "fleet locations", route: "/vehicles/locations", target: "/vehicles/locations?max_age=600", status: 200},
+		{name: "incomplete bbox", route: "/vehicles/locations", target: "/vehicles/locations?min_lat=1", status: 400},
+		{name: "fleet status", route: "/vehicles/status", target: "/vehicles/status", status: 200},
+		{name: "vehicle status", route: "/vehicles/:vehicle_id/status", target: "/vehicles/bus-1/status", status: 200},
+		{name: "vehicle status never seen", route: "/vehicles/:vehicle_id/status", target: "/vehicles/bus-9/status", status: 404},
+		{
+			name: "last location", route: "/vehicles/:vehicle_id/location", target: "/vehicles/bus-1/location", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(locationRows(now)) },
+		},
+		{
+			name: "no location", route: "/vehicles/:vehicle_id/location", target: "/vehicles/bus-1/location", status: 404,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"})) },
+		},
+		{
+			name: "history", route: "/vehicles/:vehicle_id/history", target: "/vehicles/bus-1/history?limit=1", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(locationRows(now, now)) },
+		},
+		{name: "history limit above maximum", route: "/vehicles/:vehicle_id/history", target: "/vehicles/bus-1/history?limit=5000", status: 400},
+		{name: "history offset", route: "/vehicles/:vehicle_id/history", target: "/vehicles/bus-1/history?offset=20", status: 400},
+		{
+			name: "history next page", route: "/vehicles/:vehicle_id/history", status: 200,
+			target: "/vehicles/bus-1/history?order=desc&cursor=" + location.Cursor{Timestamp: 1700000000, Id: 7, Query: "desc,,,bus-1"}.Encode(),
+			db:     func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(locationRows(now, now)) },
+		},
+		{
+			name: "history cursor of another order", route: "/vehicles/:vehicle_id/history", status: 400,
+			target: "/vehicles/bus-1/history?cursor=" + location.Cursor{Timestamp: 1700000000, Id: 7, Query: "desc,,,bus-1"}.Encode(),
+		},
+		{
+			name: "trips", route: "/vehicles/:vehicle_id/trips", target: "/vehicles/bus-1/trips", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "vehicle_id", "start_time", "end_time",
+					"start_latitude", "start_longitude", "end_latitude", "end_longitude", "start_station_id",
+					"end_station_id", "distance_m", "duration_s", "max_speed_kmh", "point_count", "end_reason", "created_at"}).
+					AddRow(1, "bus-1", 100, 200, -6.2, 106.8, -6.3, 106.9, 3, nil, 1200.5, 100, 42.0, 12, "ignition_off", now))
+			},
+		},
+		{
+			name: "geofence events", route: "/geofence/events", target: "/geofence/events?type=entry&limit=1", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				cols := []string{"id", "vehicle_id", "station_id", "station_name", "event_type", "timestamp",
+					"latitude", "longitude", "distance_m", "operator_id", "created_at"}
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows(cols).
+					AddRow(2, "bus-1", 3, "Blok M", "entry", 100, -6.2, 106.8, 12.5, "op-1", now).
+					AddRow(1, "bus-1", 3, "Blok M", "entry", 90, -6.2, 106.8, 12.5, nil, now))
+			},
+		},
+		{name: "geofence event type", route: "/geofence/events", target: "/geofence/events?type=stay", status: 400},
+		{
+			name: "vehicle visits", route: "/vehicles/:vehicle_id/visits", target: "/vehicles/bus-1/visits?open=true", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "vehicle_id", "station_id", "station_name",
+					"arrival_time", "departure_time", "dwell_s", "min_distance_m", "point_count", "created_at", "updated_at"}).
+					AddRow(1, "bus-1", 3, "Blok M", 100, nil, nil, 8.1, 4, now, now))
+			},
+		},
+		{
+			name: "vehicle positions", route: "/gtfs-rt/vehicle-positions", target: "/gtfs-rt/vehicle-positions?format=json", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(locationRows(now)) },
+		},
+		{
+			name: "export", route: "/vehicles/:vehicle_id/export",
+			target: "/vehicles/bus-1/export?format=geojson&start=0&end=3600", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(locationRows(now)) },
+		},
+		{name: "export without range", route: "/vehicles/export", target: "/vehicles/export?vehicle_id=bus-1", status: 400},
+		{
+			name: "rules", route: "/rules", target: "/rules", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(ruleRows(now)) },
+		},
+		{
+			name: "create rule", route: "/rules", method: "POST", target: "/rules", status: 201,
+			body: `{"key":"night_driving","name":"Night driving","conditions":[{"type":"ignition","ignition":true}]}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				m.ExpectCommit()
+			},
+		},
+		{
+			name: "rule body of the wrong type", route: "/rules", method: "POST", target: "/rules", status: 400,
+			body: `{"key":"k","name":"n","conditions":{}}`,
+		},
+		{
+			name: "delete rule", route: "/rules/:id", method: "DELETE", target: "/rules/7", status: 204,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit()
+			},
+		},
+		{name: "rule id", route: "/rules/:id", target: "/rules/abc", status: 400},
+		{
+			name: "webhooks", route: "/webhooks", target: "/webhooks", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(webhookRows(now, false)) },
+		},
+		{
+			name: "create webhook", route: "/webhooks", method: "POST", target: "/webhooks", status: 201,
+			body: `{"name":"ops","url":"https://example.com/hook","event_types":["geofence.*"],"enabled":true}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				m.ExpectCommit()
+			},
+		},
+		{
+			name: "webhook to metadata address", route: "/webhooks", method: "POST", target: "/webhooks", status: 400,
+			body: `{"name":"ops","url":"http://169.254.169.254/latest/meta-data","event_types":["geofence.*"]}`,
+		},
+		{
+			name: "webhook deliveries", route: "/webhooks/:id/deliveries", target: "/webhooks/3/deliveries?status=failed&limit=1", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(webhookRows(now, true))
+				cols := []string{"id", "delivery_id", "subscriber_id", "channel", "routing_key", "vehicle_id",
+					"attempt", "status", "status_code", "error", "duration_ms", "created_at"}
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows(cols).
+					AddRow(9, "abc", 3, "webhook", "geofence.entry", "bus-1", 5, "failed", 500, "status 500", 120, now).
+					AddRow(8, "abd", 3, "webhook", "alert.fired", nil, 5, "failed", nil, "timeout", 15000, now))
+			},
+		},
+		{
+			name: "operators", route: "/operators", target: "/operators", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"operator_id", "name", "created_at"}).
+					AddRow("op-1", "Operator One", now))
+			},
+		},
+		{
+			name: "create operator", route: "/operators", method: "POST", target: "/operators", status: 201,
+			body: `{"operator_id":"op-2","name":"Operator Two"}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit()
+			},
+		},
+		{
+			name: "devices", route: "/devices", target: "/devices?vehicle_id=bus-1", status: 200,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "vehicle_id", "username", "name",
+					"last_auth_at", "revoked_at", "created_by", "created_at", "updated_at"}).
+					AddRow(1, "bus-1", "dev_0011223344556677", nil, now, nil, "key:1", now, now))
+			},
+		},
+		{
+			name: "create device", route: "/devices", method: "POST", target: "/devices", status: 201,
+			body: `{"vehicle_id":"bus-1","name":"front tracker"}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"vehicle_id", "operator_id"}).AddRow("bus-1", "op-1"))
+				m.ExpectBegin()
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				m.ExpectCommit()
+			},
+		},
+		{name: "device without vehicle", route: "/devices", method: "POST", target: "/devices", body: `{"name":"x"}`, status: 400},
+		{
+			name: "api keys", route: "/api-keys", target: "/api-keys", status: 200,
+			db: func(m sqlmock.Sqlmock) { m.ExpectQuery("").WillReturnRows(apiKeyRows(now)) },
+		},
+		{
+			name: "create api key", route: "/api-keys", method: "POST", target: "/api-keys", status: 201,
+			body: `{"name":"partner","role":"partner","route_ids":["1A"],"rate_limit_per_min":0}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+				m.ExpectCommit()
+			},
+		},
+		{name: "api key role", route: "/api-keys", method: "POST", target: "/api-keys", body: `{"name":"x","role":"root"}`, status: 400},
+		{
+			name: "revoke api key", route: "/api-keys/:id", method: "DELETE", target: "/api-keys/1", status: 204,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WillReturnRows(apiKeyRows(now))
+				m.ExpectBegin()
+				m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.seedCache(t, now)
+			if tc.db != nil {
+				tc.db(s.mock)
+			}
+			s.check(t, tc)
+		})
+	}
+}
+
+func TestMissingCredentialsMatchSpec(t *testing.T) {
+	s := newTestServer(t)
+	s.token = ""
+	s.check(t, contractCase{route: "/vehicles/locations", target: "/vehicles/locations", status: 401})
+}
+
+func (s *testServer) check(t *testing.T, tc contractCase) {
+	t.Helper()
+	if tc.method == "" {
+		tc.method = http.MethodGet
+	}
+
+	req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+	if tc.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+
+	if w.Code != tc.status {
+		t.Fatalf("status %d, want %d: %s", w.Code, tc.status, w.Body.String())
+	}
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("database: %v", err)
+	}
+
+	route, ok := s.validator.FindRoute(tc.method, tc.route)
+	if !ok {
+		t.Fatalf("%s %s is not documented", tc.method, tc.route)
+	}
+	// the response is checked against the request as the handler saw it
+	req = httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+	params := pathParams(tc.route, req.URL.Path)
+
+	err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+		},
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	})
+	if err != nil {
+		t.Errorf("response drifted from openapi.yaml: %v\nbody: %s", err, w.Body.String())
+	}
+}
+
+func (s *testServer) seedCache(t *testing.T, now time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	speed := 31.5
+	loc := model.MQTTLocationStruct{VehicleId: "bus-1", Latitude: -6.2, Longitude: 106.8, Timestamp: now.Unix(), Speed: &speed}
+	if err := cache.SetLatestLocation(ctx, s.rdb, loc); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.TouchLastSeen(ctx, s.rdb, "bus-1", now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pathParams pairs the :params of a gin pattern with the segments of a path.
+func pathParams(pattern, path string) map[string]string {
+	params := make(map[string]string)
+	segs := strings.Split(path, "/")
+	for i, p := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(p, ":") && i < len(segs) {
+			params[p[1:]] = segs[i]
+		}
+	}
+
+	return params
+}
+
+func locationRows(now time.Time, more ...time.Time) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "vehicle_id", "latitude", "longitude", "timestamp", "ignition", "speed", "created_at"})
+	rows.AddRow(1, "bus-1", -6.2, 106.8, now.Unix(), true, 31.5, now)
+	for i, t := range more {
+		rows.AddRow(int64(i+2), "bus-1", -6.21, 106.81, t.Unix(), nil, nil, t)
+	}
+
+	return rows
+}
+
+func ruleRows(now time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "key", "name", "description", "severity", "enabled", "conditions",
+		"duration_s", "cooldown_s", "created_at", "updated_at"}).
+		AddRow(1, "speeding_at_night", "Speeding at night", "", "critical", true,
+			`[{"type":"speed","op":">","value":60},{"type":"time_of_day","from":"22:00","to":"05:00","timezone":"Asia/Jakarta"}]`,
+			30, 600, now, now)
+}
+
+func webhookRows(now time.Time, disabled bool) *sqlmock.Rows {
+	var disabledAt, reason driver.Value
+	if disabled {
+		disabledAt, reason = now, "disabled after 5 consecutive failed deliveries"
+	}
+
+	return sqlmock.NewRows([]string{"id", "name", "channel", "target", "secret", "event_types", "vehicle_ids",
+		"route_ids", "rate_limit_per_min", "payload_format", "operator_id", "enabled", "consecutive_failures",
+		"disabled_at", "disabled_reason", "created_at", "updated_at"}).
+		AddRow(3, "ops", "webhook", "https://example.com/hook", "whsec_x", `["geofence.*"]`, nil, `[]`, 0,
+			"cloudevents", nil, !disabled, 0, disabledAt, reason, now, now)
+}
+
+func apiKeyRows(now time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "key_prefix", "key_hash", "role", "group_names", "route_ids",
+		"operator_id", "rate_limit_per_min", "expires_at", "last_used_at", "revoked_at", "created_by",
+		"created_at", "updated_at"}).
+		AddRow(1, "dispatch", "fk_0011", "hash", "dispatcher", `["north"]`, nil, "op-1", nil, nil, now, nil,
+			"key:0", now, now)
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+	"tj/services/api/internal/ratelimit"
+)
+
+// httptest requests come from 192.0.2.1
+func TestIPLimitIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxies []string
+		want    []int
+	}{
+		{"no trusted proxies", nil, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{"behind a trusted proxy", []string{"192.0.2.0/24"}, []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestServerWith(t, func(d *Deps) {
+				d.IPLimit = ratelimit.Policy{PerMin: 1, Burst: 2}
+				d.TrustedProxies = c.proxies
+			})
+
+			for i, want := range c.want {
+				req := httptest.NewRequest(http.MethodGet, "/events/schemas", nil)
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+				rec := httptest.NewRecorder()
+				s.engine.ServeHTTP(rec, req)
+
+				if rec.Code != want {
+					t.Errorf("request %d: status %d, want %d", i+1, rec.Code, want)
+				}
+			}
+		})
+	}
+}
+
+// a throttled key must not cost queries: the key is looked up once, then cached
+func TestThrottledKeyIsNotLookedUpAgain(t *testing.T) {
+	s := newTestServerWith(t, func(d *Deps) {
+		d.KeyLimit = ratelimit.Policy{PerMin: 1, Burst: 1}
+	})
+
+	key, prefix := auth.GenerateKey()
+	now := time.Now()
+	s.mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_prefix", "key_hash", "role",
+		"operator_id", "last_used_at", "created_at", "updated_at"}).
+		AddRow(1, "admin", prefix, auth.HashKey(key), "admin", nil, now, now, now))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		s.engine.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d: %s", i+1, rec.Code, want, rec.Body)
+		}
+	}
+	if err := s.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOnlyPlatformAdminsSetKeyRateLimits(t *testing.T) {
+	op := "op-1"
+	operatorAdmin := &auth.Principal{Subject: "tenant-admin", Role: model.RoleAdmin, OperatorId: &op}
+	now := time.Now()
+	keyWithLimit := func(m sqlmock.Sqlmock) {
+		m.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_prefix", "key_hash", "role",
+			"operator_id", "rate_limit_per_min", "created_at", "updated_at"}).
+			AddRow(1, "dispatch", "0011", "hash", "dispatcher", op, 100, now, now))
+	}
+	saved := func(m sqlmock.Sqlmock) {
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectCommit()
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		db     []func(sqlmock.Sqlmock)
+		status int
+		// rate_limit_per_min in the response
+		limit int
+	}{
+		{
+			name: "unlimited key", method: http.MethodPost, target: "/api-keys", status: http.StatusForbidden,
+			body: `{"name":"mine","role":"dispatcher","rate_limit_per_min":0}`,
+		},
+		{
+			name: "raised limit", method: http.MethodPut, target: "/api-keys/1", status: http.StatusForbidden,
+			body: `{"name":"dispatch","role":"dispatcher","rate_limit_per_min":1000}`,
+			db:   []func(sqlmock.Sqlmock){keyWithLimit},
+		},
+		{
+			name: "limit left out", method: http.MethodPut, target: "/api-keys/1", status: http.StatusOK, limit: 100,
+			body: `{"name":"renamed","role":"dispatcher"}`,
+			db:   []func(sqlmock.Sqlmock){keyWithLimit, saved},
+		},
+		{
+			name: "limit restated", method: http.MethodPut, target: "/api-keys/1", status: http.StatusOK, limit: 100,
+			body: `{"name":"renamed","role":"dispatcher","rate_limit_per_min":100}`,
+			db:   []func(sqlmock.Sqlmock){keyWithLimit, saved},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.as(t, operatorAdmin)
+			for _, db := range c.db {
+				db(s.mock)
+			}
+
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			rec := httptest.NewRecorder()
+			s.engine.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+			if c.limit != 0 {
+				var k model.APIKey
+				if err := json.Unmarshal(rec.Body.Bytes(), &k); err != nil {
+					t.Fatal(err)
+				}
+				if k.RateLimitPerMin == nil || *k.RateLimitPerMin != c.limit {
+					t.Errorf("rate_limit_per_min %v, want %d", k.RateLimitPerMin, c.limit)
+				}
+			}
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package router wires the handlers of services/api into gin engines, so the binary and
+// the contract tests serve exactly the same routes.
+package router
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository"
+	"tj/services/api/internal/auth"
+	handler "tj/services/api/internal/controller"
+	"tj/services/api/internal/graph"
+	"tj/services/api/internal/location"
+	"tj/services/api/internal/openapi"
+	"tj/services/api/internal/ratelimit"
+	"tj/services/api/internal/stream"
+)
+
+type Deps struct {
+	DB *gorm.DB
+	// the reads moved off DB so far, the vehicle and geofence routes
+	Repos *repository.Repositories
+	Rdb   *redis.Client
+	Hub   *stream.Hub
+	Auth  *auth.Authenticator
+	// per client IP, and per API key or token subject
+	IPLimit  ratelimit.Policy
+	KeyLimit ratelimit.Policy
+	GraphQL  graph.Limits
+	// proxies whose X-Forwarded-For is believed, nil trusts none
+	TrustedProxies []string
+}
+
+// New builds the public API. Every route must be documented in openapi.yaml, the
+// contract tests check both directions.
+func New(d Deps) (*gin.Engine, error) {
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := openapi.NewDocsHandler(spec)
+	if err != nil {
+		return nil, err
+	}
+	validate := openapi.NewValidator(spec).Middleware()
+	schema, err := graph.NewSchema(graph.Deps{DB: d.DB, Locations: location.NewQuery(d.Repos.Locations), Hub: d.Hub}, d.GraphQL)
+	if err != nil {
+		return nil, err
+	}
+
+	r := gin.Default()
+	// otherwise any client picks its own IP, and its own IP bucket, with X-Forwarded-For
+	if err := r.SetTrustedProxies(d.TrustedProxies); err != nil {
+		return nil, err
+	}
+	limiter := ratelimit.NewLimiter(d.Rdb)
+	r.Use(limiter.ByIP(d.IPLimit))
+	perKey := limiter.ByCaller(d.KeyLimit)
+
+	vh := handler.NewVehicleHandler(d.Repos)
+	gh := handler.NewGTFSRealtimeHandler(d.DB)
+	fh := handler.NewFleetHandler(d.Rdb)
+	sh := handler.NewStreamHandler(d.Hub)
+	eh := handler.NewExportHandler(d.DB)
+	rh := handler.NewRuleHandler(d.DB)
+	wh := handler.NewWebhookHandler(d.DB)
+	geh := handler.NewGeofenceHandler(d.Repos)
+	esh := handler.NewEventSchemaHandler()
+	kh := handler.NewAPIKeyHandler(d.DB, d.Auth)
+	ah := handler.NewAuthHandler(d.Auth)
+	oh := handler.NewOperatorHandler(d.DB)
+	dh := handler.NewDeviceHandler(d.DB)
+	qh := handler.NewGraphQLHandler(schema)
+
+	// the API documentation and event schemas are public, everything else needs a key or token
+	r.GET("/openapi.json", docs.Spec)
+	r.GET("/docs", docs.UI)
+	r.GET("/events/schemas", validate, esh.ListSchemas)
+	r.GET("/events/schemas/:type", validate, esh.GetSchema)
+
+	api := r.Group("/", d.Auth.Middleware(), perKey, validate)
+	// configuration is internal: partners only see vehicle data
+	staff := auth.RequireRole(model.RoleDispatcher, model.RoleReadOnly)
+	dispatch := auth.RequireRole(model.RoleDispatcher)
+	admin := auth.RequireRole()
+	// rules run against every operator's vehicles, so only platform keys manage them
+	platform := auth.RequirePlatform()
+	inScope := auth.RequireVehicle()
+	// a webhook receives its operator's whole event stream, fleet and route scopes can't
+	// narrow it, and its delivery log names any of the operator's vehicles
+	unscoped := auth.RequireUnscoped()
+
+	api.POST("/graphql", qh.Query)
+
+	api.GET("/auth/me", ah.Me)
+	api.POST("/auth/token", ah.IssueToken)
+
+	api.GET("/vehicles/locations", fh.GetFleetLocations)
+	api.GET("/vehicles/status", fh.GetFleetConnectivity)
+	api.GET("/vehicles/:vehicle_id/status", inScope, fh.GetVehicleConnectivity)
+	api.GET("/vehicles/:vehicle_id/location", inScope, vh.GetLastLocation)
+	api.GET("/vehicles/:vehicle_id/history", inScope, vh.GetHistory)
+	api.GET("/vehicles/:vehicle_id/trips", inScope, vh.GetTrips)
+	api.GET("/vehicles/:vehicle_id/geofence-events", inScope, geh.ListVehicleEvents)
+	api.GET("/vehicles/:vehicle_id/visits", inScope, geh.ListVehicleVisits)
+
+	api.GET("/geofence/events", geh.ListEvents)
+	api.GET("/geofence/visits", geh.ListVisits)
+	api.GET("/stations/:station_id/visits", geh.ListStationVisits)
+
+	api.GET("/vehicles/export", eh.ExportVehicles)
+	api.GET("/vehicles/:vehicle_id/export", inScope, eh.ExportVehicle)
+
+	api.GET("/gtfs-rt/vehicle-positions", gh.VehiclePositions)
+
+	api.GET("/rules", platform, staff, rh.ListRules)
+	api.POST("/rules", platform, dispatch, rh.CreateRule)
+	api.GET("/rules/:id", platform, staff, rh.GetRule)
+	api.PUT("/rules/:id", platform, dispatch, rh.UpdateRule)
+	api.DELETE("/rules/:id", platform, dispatch, rh.DeleteRule)
+
+	api.GET("/webhooks", unscoped, staff, wh.ListWebhooks)
+	api.POST("/webhooks", unscoped, dispatch, wh.CreateWebhook)
+	api.GET("/webhooks/:id", unscoped, staff, wh.GetWebhook)
+	api.PUT("/webhooks/:id", unscoped, dispatch, wh.UpdateWebhook)
+	api.DELETE("/webhooks/:id", unscoped, dispatch, wh.DeleteWebhook)
+	api.GET("/webhooks/:id/deliveries", unscoped, staff, wh.GetDeliveries)
+
+	api.GET("/operators", platform, staff, oh.ListOperators)
+	api.POST("/operators", platform, admin, oh.CreateOperator)
+
+	api.GET("/devices", admin, dh.ListDevices)
+	api.POST("/devices", admin, dh.CreateDevice)
+	api.DELETE("/devices/:id", admin, dh.RevokeDevice)
+
+	api.GET("/api-keys", admin, kh.ListKeys)
+	api.POST("/api-keys", admin, kh.CreateKey)
+	api.GET("/api-keys/:id", admin, kh.GetKey)
+	api.PUT("/api-keys/:id", admin, kh.UpdateKey)
+	api.DELETE("/api-keys/:id", admin, kh.RevokeKey)
+
+	// browsers can't set headers on WebSocket/EventSource requests
+	streams := r.Group("/stream", d.Auth.Middleware(auth.AllowQueryToken()), perKey, validate)
+	streams.GET("/ws", sh.WebSocket)
+	streams.GET("/sse", sh.SSE)
+	r.GET("/graphql", d.Auth.Middleware(auth.AllowQueryToken()), perKey, validate, qh.Subscribe)
+
+	return r, nil
+}
+
+// NewMQTTAuth builds the broker's auth backend. It runs on its own listener, only the
+// broker should be able to reach it.
+func NewMQTTAuth(dbConn *gorm.DB, serviceUser, servicePassword string) (*gin.Engine, error) {
+	// the service account is a superuser, it must not get in with an empty password
+	if serviceUser != "" && servicePassword == "" {
+		return nil, errors.New("MQTT_USERNAME is set without MQTT_PASSWORD")
+	}
+
+	mh := handler.NewMQTTAuthHandler(dbConn, serviceUser, servicePassword)
+
+	r := gin.Default()
+	r.POST("/mqtt/user", mh.User)
+	r.POST("/mqtt/superuser", mh.Superuser)
+	r.POST("/mqtt/acl", mh.ACL)
+
+	return r, nil
+}
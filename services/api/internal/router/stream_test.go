@@ -0,0 +1,161 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"tj/pkg/events"
+	"tj/services/api/internal/stream"
+)
+
+// gatedWriter holds the handler in its first write until the gate opens, standing in for
+// a client that stopped reading.
+type gatedWriter struct {
+	header http.Header
+	first  chan struct{}
+	gate   chan struct{}
+	once   sync.Once
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{header: make(http.Header), first: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (w *gatedWriter) Header() http.Header { return w.header }
+func (w *gatedWriter) WriteHeader(int)     {}
+func (w *gatedWriter) Flush()              {}
+
+func (w *gatedWriter) CloseNotify() <-chan bool { return make(chan bool) }
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.first) })
+	<-w.gate
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func rawLocation(t *testing.T, vehicleId string, ts int64) amqp.Delivery {
+	t.Helper()
+	env, err := events.New(events.SourceSubscriber, &events.LocationRaw{VehicleId: vehicleId, Latitude: -6.2, Longitude: 106.8, Timestamp: ts}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return amqp.Delivery{RoutingKey: events.TypeLocationRaw, Body: body}
+}
+
+func TestSlowStreamIsToldHowManyEventsItMissed(t *testing.T) {
+	var hub *stream.Hub
+	s := newTestServerWith(t, func(d *Deps) {
+		hub = stream.NewHub(d.DB)
+		d.Hub = hub
+	})
+	s.mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"vehicle_id", "route_id"}))
+
+	// unbuffered, so a send returns once the hub has broadcast the one before
+	msgs := make(chan amqp.Delivery)
+	go hub.Run(msgs)
+	t.Cleanup(func() { close(msgs) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/stream/sse?vehicle_id=bus-1", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	w := newGatedWriter()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.engine.ServeHTTP(w, req)
+	}()
+
+	// until the handler is subscribed and stuck writing the first event
+	for subscribed := false; !subscribed; {
+		select {
+		case <-w.first:
+			subscribed = true
+		case msgs <- rawLocation(t, "bus-1", 0):
+			time.Sleep(5 * time.Millisecond)
+		case <-time.After(time.Second):
+			t.Fatal("stream never wrote")
+		}
+	}
+
+	// three more than the client buffer holds; bus-9 is filtered out and only flushes the last
+	const sent = 259
+	for ts := int64(1); ts <= sent; ts++ {
+		msgs <- rawLocation(t, "bus-1", ts)
+	}
+	msgs <- rawLocation(t, "bus-9", 0)
+	close(w.gate)
+
+	last := fmt.Sprintf(`"timestamp":%d`, sent)
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(w.String(), last) {
+		if time.Now().After(deadline) {
+			t.Fatal("the newest event never arrived")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-served
+
+	// the oldest were dropped, and the notice comes before the first event after the gap
+	out := w.String()
+	notice := strings.Index(out, "event:stream.dropped")
+	if notice < 0 {
+		t.Fatalf("no stream.dropped notice in\n%s", out)
+	}
+	var dropped struct {
+		Data struct {
+			Count int64 `json:"count"`
+		} `json:"data"`
+	}
+	data := out[notice:]
+	data = data[strings.Index(data, "data:")+len("data:"):]
+	if err := json.Unmarshal([]byte(data[:strings.Index(data, "\n")]), &dropped); err != nil {
+		t.Fatal(err)
+	}
+	// the warm-up events still queued were dropped first
+	if dropped.Data.Count < 3 {
+		t.Errorf("notice counts %d dropped, want at least 3", dropped.Data.Count)
+	}
+
+	after := out[notice:]
+	for ts := int64(1); ts <= 3; ts++ {
+		if strings.Contains(out, fmt.Sprintf(`"timestamp":%d}`, ts)) {
+			t.Errorf("dropped event at %d was sent", ts)
+		}
+	}
+	for ts := int64(4); ts <= sent; ts++ {
+		i := strings.Index(after, fmt.Sprintf(`"timestamp":%d}`, ts))
+		if i < 0 {
+			t.Fatalf("event at %d missing after the notice", ts)
+		}
+		after = after[i:]
+	}
+}
@@ -0,0 +1,171 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	model "tj/pkg/model"
+	"tj/services/api/internal/auth"
+)
+
+// operatorAdmin is an admin of op-a, whose only vehicle is bus-1.
+func operatorAdmin() *auth.Principal {
+	op := "op-a"
+	return &auth.Principal{Subject: "op-a-admin", Role: model.RoleAdmin, OperatorId: &op}
+}
+
+func TestOperatorKeysCantReachOtherOperatorsVehicles(t *testing.T) {
+	routes := []string{
+		"/vehicles/bus-2/status",
+		"/vehicles/bus-2/location",
+		"/vehicles/bus-2/history",
+		"/vehicles/bus-2/trips",
+		"/vehicles/bus-2/geofence-events",
+		"/vehicles/bus-2/visits",
+		"/vehicles/bus-2/export?start=1000&end=2000",
+	}
+
+	for _, target := range routes {
+		t.Run(target, func(t *testing.T) {
+			s, _ := newMemoryServer(t)
+			s.as(t, operatorAdmin(), "bus-1")
+
+			if code := s.get(t, target, nil); code != http.StatusForbidden {
+				t.Errorf("status %d, want 403", code)
+			}
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOperatorKeysSeeOnlyTheirOperatorsRows(t *testing.T) {
+	s, store := newMemoryServer(t)
+	s.as(t, operatorAdmin(), "bus-1")
+
+	opA, opB := "op-a", "op-b"
+	store.Stations.Add(model.BusStation{Id: 3, Name: "Harmoni"})
+	// bus-1 drove for op-b before op-a claimed it, those rows stay op-b's
+	store.Locations.Add(
+		model.MQTTLocationStruct{VehicleId: "bus-1", Timestamp: 1000, OperatorId: &opA},
+		model.MQTTLocationStruct{VehicleId: "bus-1", Timestamp: 500, OperatorId: &opB},
+		model.MQTTLocationStruct{VehicleId: "bus-2", Timestamp: 2000, OperatorId: &opB},
+	)
+	store.Trips.Add(
+		model.VehicleTrip{Id: 1, VehicleId: "bus-1", StartTime: 1000, OperatorId: &opA},
+		model.VehicleTrip{Id: 2, VehicleId: "bus-1", StartTime: 400, OperatorId: &opB},
+	)
+	store.GeofenceEvents.Add(
+		model.GeofenceEvent{Id: 1, VehicleId: "bus-1", StationId: 3, EventType: model.GeofenceEntry, Timestamp: 1000, OperatorId: &opA},
+		model.GeofenceEvent{Id: 2, VehicleId: "bus-1", StationId: 3, EventType: model.GeofenceEntry, Timestamp: 500, OperatorId: &opB},
+		model.GeofenceEvent{Id: 3, VehicleId: "bus-2", StationId: 3, EventType: model.GeofenceEntry, Timestamp: 2000, OperatorId: &opB},
+	)
+	store.Visits.Add(
+		model.StationVisit{Id: 1, VehicleId: "bus-1", StationId: 3, ArrivalTime: 1000, OperatorId: &opA},
+		model.StationVisit{Id: 2, VehicleId: "bus-2", StationId: 3, ArrivalTime: 2000, OperatorId: &opB},
+	)
+
+	var history struct {
+		Data []model.VehicleLocation `json:"data"`
+	}
+	s.get(t, "/vehicles/bus-1/history", &history)
+	if len(history.Data) != 1 || history.Data[0].Timestamp != 1000 {
+		t.Errorf("history %+v, want op-a's point only", history.Data)
+	}
+
+	var trips struct {
+		Data []model.VehicleTrip `json:"data"`
+	}
+	s.get(t, "/vehicles/bus-1/trips", &trips)
+	if len(trips.Data) != 1 || trips.Data[0].Id != 1 {
+		t.Errorf("trips %+v, want op-a's trip only", trips.Data)
+	}
+
+	var events struct {
+		Data []model.GeofenceEvent `json:"data"`
+	}
+	s.get(t, "/geofence/events", &events)
+	if len(events.Data) != 1 || events.Data[0].Id != 1 {
+		t.Errorf("events %+v, want op-a's event only", events.Data)
+	}
+	// naming another operator's vehicle finds nothing rather than its rows
+	s.get(t, "/geofence/events?vehicle_id=bus-2", &events)
+	if len(events.Data) != 0 {
+		t.Errorf("events of bus-2 %+v, want none", events.Data)
+	}
+
+	var visits struct {
+		Data []model.StationVisit `json:"data"`
+	}
+	s.get(t, "/stations/3/visits", &visits)
+	if len(visits.Data) != 1 || visits.Data[0].Id != 1 {
+		t.Errorf("visits %+v, want op-a's visit only", visits.Data)
+	}
+}
+
+func TestOperatorKeysCantReachOtherOperatorsConfiguration(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name, method, target, body string
+		// the rows the handler reads, the operator or its vehicles must be in the query
+		db     func(m sqlmock.Sqlmock)
+		status int
+	}{
+		{
+			name: "another operator's webhook", method: http.MethodGet, target: "/webhooks/3", status: http.StatusNotFound,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WithArgs(model.ChannelWebhook, int64(3), "op-a", int64(1)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			name: "another operator's api key", method: http.MethodGet, target: "/api-keys/5", status: http.StatusNotFound,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WithArgs(int64(5), "op-a", int64(1)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			name: "devices", method: http.MethodGet, target: "/devices", status: http.StatusOK,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WithArgs("bus-1").WillReturnRows(sqlmock.NewRows([]string{"id", "vehicle_id", "username", "created_at"}).
+					AddRow(1, "bus-1", "bus-1", now))
+			},
+		},
+		{
+			name: "graphql vehicles", method: http.MethodPost, target: "/graphql", status: http.StatusOK,
+			body: `{"query":"{ vehicles(first: 10) { id } }"}`,
+			db: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("").WithArgs("bus-1", "op-a", int64(10)).WillReturnRows(sqlmock.NewRows([]string{"vehicle_id"}).AddRow("bus-1"))
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.as(t, operatorAdmin(), "bus-1")
+			tc.db(s.mock)
+
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			rec := httptest.NewRecorder()
+			s.engine.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tc.status, rec.Body)
+			}
+			if err := s.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
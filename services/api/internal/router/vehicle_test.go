@@ -0,0 +1,97 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	model "tj/pkg/model"
+	"tj/pkg/repository/memory"
+)
+
+// newMemoryServer serves the vehicle and geofence routes from an in-memory store.
+func newMemoryServer(t *testing.T) (*testServer, *memory.Store) {
+	t.Helper()
+	store := memory.New()
+	s := newTestServerWith(t, func(d *Deps) { d.Repos = store.Repositories() })
+
+	return s, store
+}
+
+// get requests target with the current token and decodes a 200 answer into out.
+func (s *testServer) get(t *testing.T, target string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusOK && out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rec.Code
+}
+
+func TestVehicleRoutesReadTheRepositories(t *testing.T) {
+	s, store := newMemoryServer(t)
+	store.Stations.Add(model.BusStation{Id: 3, Name: "Harmoni"})
+	store.Locations.Add(
+		model.MQTTLocationStruct{VehicleId: "bus-1", Latitude: -6.2, Longitude: 106.8, Timestamp: 1000},
+		model.MQTTLocationStruct{VehicleId: "bus-1", Latitude: -6.21, Longitude: 106.81, Timestamp: 1030},
+		model.MQTTLocationStruct{VehicleId: "bus-1", Latitude: -6.22, Longitude: 106.82, Timestamp: 1060},
+		model.MQTTLocationStruct{VehicleId: "bus-2", Latitude: -6.3, Longitude: 106.9, Timestamp: 2000},
+	)
+	store.Trips.Add(
+		model.VehicleTrip{Id: 1, VehicleId: "bus-1", StartTime: 1000, EndTime: 1060, EndReason: "ignition_off"},
+		model.VehicleTrip{Id: 2, VehicleId: "bus-2", StartTime: 2000, EndTime: 2100, EndReason: "ignition_off"},
+	)
+	store.GeofenceEvents.Add(
+		model.GeofenceEvent{Id: 1, VehicleId: "bus-1", StationId: 3, EventType: model.GeofenceEntry, Timestamp: 1030},
+		model.GeofenceEvent{Id: 2, VehicleId: "bus-1", StationId: 3, EventType: model.GeofenceExit, Timestamp: 1060},
+	)
+
+	var last model.VehicleLocation
+	if code := s.get(t, "/vehicles/bus-1/location", &last); code != http.StatusOK || last.Timestamp != 1060 {
+		t.Errorf("last location: status %d, %+v, want the point at 1060", code, last)
+	}
+	if code := s.get(t, "/vehicles/bus-9/location", nil); code != http.StatusNotFound {
+		t.Errorf("last location of a silent vehicle: status %d, want 404", code)
+	}
+
+	type historyPage struct {
+		Data       []model.VehicleLocation `json:"data"`
+		HasMore    bool                    `json:"has_more"`
+		NextCursor *string                 `json:"next_cursor"`
+	}
+	var first historyPage
+	s.get(t, "/vehicles/bus-1/history?order=desc&limit=2", &first)
+	if len(first.Data) != 2 || first.Data[0].Timestamp != 1060 || !first.HasMore || first.NextCursor == nil {
+		t.Fatalf("first page %+v, want 1060 and 1030 with a next cursor", first)
+	}
+	var second historyPage
+	s.get(t, "/vehicles/bus-1/history?order=desc&limit=2&cursor="+url.QueryEscape(*first.NextCursor), &second)
+	if len(second.Data) != 1 || second.Data[0].Timestamp != 1000 || second.HasMore {
+		t.Errorf("second page %+v, want the last point only", second)
+	}
+
+	var trips struct {
+		Data []model.VehicleTrip `json:"data"`
+	}
+	s.get(t, "/vehicles/bus-1/trips", &trips)
+	if len(trips.Data) != 1 || trips.Data[0].Id != 1 {
+		t.Errorf("trips %+v, want bus-1's only", trips.Data)
+	}
+
+	var events struct {
+		Data []model.GeofenceEvent `json:"data"`
+	}
+	s.get(t, "/geofence/events?vehicle_id=bus-1&type=exit", &events)
+	if len(events.Data) != 1 || events.Data[0].Id != 2 || events.Data[0].StationName != "Harmoni" {
+		t.Errorf("events %+v, want the exit at Harmoni", events.Data)
+	}
+}
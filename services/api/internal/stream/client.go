@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	clientBufferSize = 256
+	// a client that falls this many events behind without draining is disconnected
+	maxPendingDrops = 1024
+)
+
+// Client is one streaming connection. The hub never blocks on it: when the buffer is full
+// the oldest queued event is dropped, and clients that keep falling behind are closed.
+type Client struct {
+	send    chan *Event
+	filter  atomic.Pointer[Filter]
+	dropped atomic.Int64
+	done    chan struct{}
+
+	closeOnce sync.Once
+	reason    string
+}
+
+func newClient(f *Filter) *Client {
+	c := &Client{
+		send: make(chan *Event, clientBufferSize),
+		done: make(chan struct{}),
+	}
+	c.filter.Store(f)
+
+	return c
+}
+
+// Events is the client's outbound queue.
+func (c *Client) Events() <-chan *Event {
+	return c.send
+}
+
+// Done is closed once the hub has given up on the client.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Reason is why the hub closed the client, if it did.
+func (c *Client) Reason() string {
+	select {
+	case <-c.done:
+		return c.reason
+	default:
+		return ""
+	}
+}
+
+// SetFilter replaces the subscription of a live connection.
+func (c *Client) SetFilter(f *Filter) {
+	c.filter.Store(f)
+}
+
+// TakeDropped returns and resets the number of events dropped since the last call,
+// so writers can tell the client it missed something.
+func (c *Client) TakeDropped() int64 {
+	return c.dropped.Swap(0)
+}
+
+func (c *Client) offer(evt *Event) {
+	if !c.filter.Load().Matches(evt) {
+		return
+	}
+
+	select {
+	case c.send <- evt:
+		return
+	default:
+	}
+
+	// buffer full: drop the oldest event to make room for the newest
+	select {
+	case <-c.send:
+	default:
+	}
+	if c.dropped.Add(1) > maxPendingDrops {
+		c.close("slow consumer")
+		return
+	}
+
+	select {
+	case c.send <- evt:
+	default:
+	}
+}
+
+func (c *Client) close(reason string) {
+	c.closeOnce.Do(func() {
+		c.reason = reason
+		close(c.done)
+	})
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"errors"
+	"strings"
+)
+
+// Filter decides which events a connection receives. Empty sets match everything;
+// non-empty sets are ANDed together (e.g. route 1 inside the bounding box).
+type Filter struct {
+	VehicleIds []string  `json:"vehicle_ids,omitempty"`
+	RouteIds   []string  `json:"route_ids,omitempty"`
+	BBox       []float64 `json:"bbox,omitempty"`   // min_lat, min_lon, max_lat, max_lon
+	Events     []string  `json:"events,omitempty"` // routing key prefixes, e.g. "location", "geofence"
+	vehicles   map[string]struct{}
+	routes     map[string]struct{}
+	// set by the server from the caller's scope, not by clients
+	allow func(vehicleId string) bool
+}
+
+// Restrict limits the filter to vehicles the caller is allowed to see.
+func (f *Filter) Restrict(allow func(vehicleId string) bool) {
+	f.allow = allow
+}
+
+func (f *Filter) Validate() error {
+	if len(f.BBox) != 0 && len(f.BBox) != 4 {
+		return errors.New("bbox must be [min_lat, min_lon, max_lat, max_lon]")
+	}
+	if len(f.BBox) == 4 && (f.BBox[0] > f.BBox[2] || f.BBox[1] > f.BBox[3]) {
+		return errors.New("invalid bbox")
+	}
+
+	f.vehicles = toSet(f.VehicleIds)
+	f.routes = toSet(f.RouteIds)
+
+	return nil
+}
+
+func (f *Filter) Matches(evt *Event) bool {
+	if len(f.Events) > 0 {
+		ok := false
+		for _, prefix := range f.Events {
+			if strings.HasPrefix(evt.Type, prefix) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.allow != nil && !f.allow(evt.VehicleId) {
+		return false
+	}
+	if len(f.vehicles) > 0 {
+		if _, ok := f.vehicles[evt.VehicleId]; !ok {
+			return false
+		}
+	}
+	if len(f.routes) > 0 {
+		if _, ok := f.routes[evt.RouteId]; !ok {
+			return false
+		}
+	}
+	if len(f.BBox) == 4 {
+		if !evt.hasPosition {
+			return false
+		}
+		if evt.Latitude < f.BBox[0] || evt.Latitude > f.BBox[2] ||
+			evt.Longitude < f.BBox[1] || evt.Longitude > f.BBox[3] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toSet(vals []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(vals))
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = struct{}{}
+		}
+	}
+
+	return set
+}
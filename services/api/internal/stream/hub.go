@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	rmq "tj/pkg/rabbitmq"
+)
+
+const routeRefreshInterval = time.Minute
+
+// ReasonShutdown is the Reason of the clients closed when the hub stops.
+const ReasonShutdown = "server shutting down"
+
+type Event struct {
+	Id        string          `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	VehicleId string          `json:"vehicle_id,omitempty"`
+	RouteId   string          `json:"route_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+
+	Latitude    float64 `json:"-"`
+	Longitude   float64 `json:"-"`
+	hasPosition bool
+	env         *events.Envelope
+}
+
+// CloudEvent is the event in CloudEvents structured form, for clients asking for
+// format=cloudevents.
+func (e *Event) CloudEvent() *events.CloudEvent {
+	return events.ToCloudEvent(e.env)
+}
+
+// eventPayload covers both location.raw (flat lat/lon) and geofence.* (nested location).
+type eventPayload struct {
+	VehicleId string   `json:"vehicle_id"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Location  *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"location"`
+}
+
+// Hub fans events consumed from fleet.events out to every streaming connection.
+type Hub struct {
+	db *gorm.DB
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	stopped bool
+
+	// vehicle_id -> route_id, only touched by the Run goroutine
+	routes map[string]string
+}
+
+func NewHub(dbConn *gorm.DB) *Hub {
+	return &Hub{
+		db:      dbConn,
+		clients: make(map[*Client]struct{}),
+		routes:  make(map[string]string),
+	}
+}
+
+func (h *Hub) Register(f *Filter) *Client {
+	c := newClient(f)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// nothing would ever reach it
+	if h.stopped {
+		c.close(ReasonShutdown)
+		return c
+	}
+	h.clients[c] = struct{}{}
+
+	return c
+}
+
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+
+	c.close("unregistered")
+}
+
+// Run consumes deliveries until the channel closes, then closes every client so their
+// connections end. Route assignments are refreshed periodically so route subscriptions
+// follow vehicles being reassigned.
+func (h *Hub) Run(msgs <-chan amqp.Delivery) {
+	defer h.stop()
+
+	h.refreshRoutes()
+
+	ticker := time.NewTicker(routeRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				log.Println("stream hub: delivery channel closed")
+				return
+			}
+
+			evt, err := h.decode(d)
+			if err != nil {
+				log.Printf("stream hub: invalid %s payload: %v", d.RoutingKey, err)
+				continue
+			}
+			h.broadcast(evt)
+
+		case <-ticker.C:
+			h.refreshRoutes()
+		}
+	}
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopped = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		c.close(ReasonShutdown)
+	}
+}
+
+func (h *Hub) decode(d amqp.Delivery) (*Event, error) {
+	env, err := rmq.DecodeEvent(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var p eventPayload
+	if err := env.DecodeData(&p); err != nil {
+		return nil, err
+	}
+
+	evt := &Event{
+		Id:        env.Id,
+		Type:      d.RoutingKey,
+		VehicleId: p.VehicleId,
+		RouteId:   h.routes[p.VehicleId],
+		Data:      env.Data,
+		env:       env,
+	}
+	switch {
+	case p.Latitude != nil && p.Longitude != nil:
+		evt.Latitude, evt.Longitude, evt.hasPosition = *p.Latitude, *p.Longitude, true
+	case p.Location != nil:
+		evt.Latitude, evt.Longitude, evt.hasPosition = p.Location.Latitude, p.Location.Longitude, true
+	}
+
+	return evt, nil
+}
+
+func (h *Hub) broadcast(evt *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients {
+		c.offer(evt)
+	}
+}
+
+func (h *Hub) refreshRoutes() {
+	var vehicles []model.Vehicle
+	if err := h.db.Where("route_id IS NOT NULL").Find(&vehicles).Error; err != nil {
+		log.Printf("stream hub: load vehicle routes error: %v", err)
+		return
+	}
+
+	routes := make(map[string]string, len(vehicles))
+	for _, v := range vehicles {
+		routes[v.VehicleId] = *v.RouteId
+	}
+	h.routes = routes
+}
@@ -0,0 +1,175 @@
+package stream
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"tj/pkg/events"
+)
+
+// numbered is an event whose Id is n, to tell which ones got through.
+func numbered(n int) *Event {
+	return &Event{Id: strconv.Itoa(n), Type: events.TypeLocationRaw, VehicleId: "bus-1"}
+}
+
+func drain(c *Client) []*Event {
+	var out []*Event
+	for {
+		select {
+		case evt := <-c.Events():
+			out = append(out, evt)
+		default:
+			return out
+		}
+	}
+}
+
+func TestFullBufferDropsTheOldest(t *testing.T) {
+	c := newClient(&Filter{})
+
+	for n := 0; n < clientBufferSize+3; n++ {
+		c.offer(numbered(n))
+	}
+
+	if n := c.TakeDropped(); n != 3 {
+		t.Errorf("dropped %d, want 3", n)
+	}
+	if n := c.TakeDropped(); n != 0 {
+		t.Errorf("dropped %d after taking the count, want 0", n)
+	}
+
+	got := drain(c)
+	if len(got) != clientBufferSize || got[0].Id != "3" || got[len(got)-1].Id != strconv.Itoa(clientBufferSize+2) {
+		t.Errorf("%d queued from %s to %s, want the newest %d", len(got), got[0].Id, got[len(got)-1].Id, clientBufferSize)
+	}
+	if c.Reason() != "" {
+		t.Errorf("closed as %q after a few drops", c.Reason())
+	}
+}
+
+func TestClientThatNeverCatchesUpIsClosed(t *testing.T) {
+	c := newClient(&Filter{})
+
+	// taking the count means the writer is keeping up, so the client stays open
+	for n := 0; n < clientBufferSize+maxPendingDrops; n++ {
+		c.offer(numbered(n))
+	}
+	c.TakeDropped()
+	for n := 0; n < maxPendingDrops; n++ {
+		c.offer(numbered(n))
+	}
+	if c.Reason() != "" {
+		t.Fatalf("closed as %q, the drops were taken", c.Reason())
+	}
+
+	c.offer(numbered(0))
+	select {
+	case <-c.Done():
+		if c.Reason() != "slow consumer" {
+			t.Errorf("closed as %q, want slow consumer", c.Reason())
+		}
+	default:
+		t.Errorf("open after %d drops untaken", maxPendingDrops+1)
+	}
+}
+
+func locationDelivery(t *testing.T, vehicleId string, ts int64) amqp.Delivery {
+	t.Helper()
+	env, err := events.New(events.SourceSubscriber, &events.LocationRaw{VehicleId: vehicleId, Latitude: -6.2, Longitude: 106.8, Timestamp: ts}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return amqp.Delivery{RoutingKey: events.TypeLocationRaw, Body: body}
+}
+
+func newTestHub(t *testing.T) (*Hub, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(
+		func(string, string) error { return nil })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHub(gdb), mock
+}
+
+func subscribe(t *testing.T, h *Hub, f Filter) *Client {
+	t.Helper()
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return h.Register(&f)
+}
+
+func TestHubFansOutAndClosesClientsWhenItStops(t *testing.T) {
+	h, mock := newTestHub(t)
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"vehicle_id", "route_id"}).AddRow("bus-1", "1A"))
+
+	route := subscribe(t, h, Filter{RouteIds: []string{"1A"}})
+	vehicle := subscribe(t, h, Filter{VehicleIds: []string{"bus-2"}})
+	geofence := subscribe(t, h, Filter{Events: []string{"geofence"}})
+	gone := subscribe(t, h, Filter{})
+	h.Unregister(gone)
+
+	msgs := make(chan amqp.Delivery)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(msgs)
+	}()
+	msgs <- locationDelivery(t, "bus-1", 1000)
+	msgs <- locationDelivery(t, "bus-2", 1000)
+	close(msgs)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run still going after the deliveries closed")
+	}
+
+	for name, tc := range map[string]struct {
+		client  *Client
+		vehicle string
+	}{"route": {route, "bus-1"}, "vehicle": {vehicle, "bus-2"}, "geofence": {geofence, ""}} {
+		got := drain(tc.client)
+		if tc.vehicle == "" && len(got) != 0 {
+			t.Errorf("%s: got %d events, want none", name, len(got))
+		}
+		if tc.vehicle != "" && (len(got) != 1 || got[0].VehicleId != tc.vehicle) {
+			t.Errorf("%s: got %+v, want the %s location", name, got, tc.vehicle)
+		}
+		if tc.client.Reason() != ReasonShutdown {
+			t.Errorf("%s: closed as %q, want %q", name, tc.client.Reason(), ReasonShutdown)
+		}
+	}
+	if got := drain(gone); len(got) != 0 || gone.Reason() != "unregistered" {
+		t.Errorf("unregistered client got %d events and closed as %q", len(got), gone.Reason())
+	}
+
+	// nothing would reach a client registered after the hub stopped
+	late := subscribe(t, h, Filter{})
+	if late.Reason() != ReasonShutdown {
+		t.Errorf("late client closed as %q, want %q", late.Reason(), ReasonShutdown)
+	}
+}
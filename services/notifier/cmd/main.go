@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"tj/config"
+	db "tj/pkg/database"
+	"tj/pkg/lifecycle"
+	model "tj/pkg/model"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository"
+	"tj/services/notifier/internal/channel"
+	notify "tj/services/notifier/internal/controller"
+)
+
+func main() {
+	config.Load()
+	lc := lifecycle.New(config.Cfg.ShutdownTimeout)
+
+	dbConn, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Postgres init error: %v", err)
+	}
+
+	rmqClient, err := rmq.Connect()
+	if err != nil {
+		log.Fatalf("RabbitMQ init error: %v", err)
+	}
+
+	cfg := rmq.RabbitConfig{
+		ExchangeName: "fleet.events",
+		ExchangeType: "topic",
+		QueueName:    "notifications",
+		RoutingKey:   "geofence.*",
+		ConsumerName: "notifier",
+	}
+
+	if err := rmq.SetupRMQ(rmqClient, cfg); err != nil {
+		log.Fatalf("RabbitMQ setup error: %v", err)
+	}
+	if err := rmq.BindRMQ(rmqClient, cfg, []string{"vehicle.*", "alert.*"}); err != nil {
+		log.Fatalf("RabbitMQ setup error: %v", err)
+	}
+	// unacked messages are the backpressure while deliveries are slow
+	if err := rmqClient.Channel.Qos(64, 0, false); err != nil {
+		log.Fatalf("RabbitMQ qos error: %v", err)
+	}
+
+	channels := map[string]channel.Channel{
+		model.ChannelWebhook: channel.NewWebhook(10*time.Second, config.Cfg.WebhookAllowInternal),
+		model.ChannelEmail: channel.NewEmail(channel.EmailConfig{
+			Host:     config.Cfg.SMTPHost,
+			Port:     config.Cfg.SMTPPort,
+			Username: config.Cfg.SMTPUsername,
+			Password: config.Cfg.SMTPPassword,
+			From:     config.Cfg.SMTPFrom,
+		}),
+		model.ChannelSMS: channel.NewSMS(config.Cfg.SMSGatewayURL, config.Cfg.SMSGatewayToken, 10*time.Second),
+	}
+
+	dispatcher := notify.NewDispatcher(rmqClient, cfg, channels, notify.Config{
+		Workers:      config.Cfg.NotifierWorkers,
+		MaxAttempts:  config.Cfg.NotifierMaxAttempts,
+		DisableAfter: config.Cfg.NotifierDisableAfter,
+	}, repository.NewPostgres(dbConn))
+	if err := dispatcher.Start(lc.Context()); err != nil {
+		log.Fatalf("notifier start error: %v", err)
+	}
+
+	lc.OnShutdown(lifecycle.StopIntake, "event consumer", dispatcher.Stop)
+	lc.OnShutdown(lifecycle.Drain, "event handler", dispatcher.Wait)
+	lc.OnShutdown(lifecycle.Flush, "delivery queue", dispatcher.Flush)
+	lc.OnShutdown(lifecycle.Close, "rabbitmq", lifecycle.Func(rmqClient.Close))
+	lc.OnShutdown(lifecycle.Close, "postgres", lifecycle.ErrFunc(func() error { return db.Close(dbConn) }))
+
+	if err := lc.Wait(); err != nil {
+		log.Fatalf("notifier shutdown error: %v", err)
+	}
+	log.Println("Notifier stopped")
+}
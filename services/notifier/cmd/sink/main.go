@@ -0,0 +1,59 @@
+// Command sink is a local stand-in for webhook receivers and the SMS gateway. It logs every
+// request and checks webhook signatures, and can be told to fail to exercise retries:
+//
+//	go run ./services/notifier/cmd/sink -addr :9099 -secret s3cret -fail-rate 0.3
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"tj/pkg/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9099", "listen address")
+	secret := flag.String("secret", "", "webhook secret to verify X-Fleet-Signature with")
+	failRate := flag.Float64("fail-rate", 0, "fraction of requests answered with 503")
+	flag.Parse()
+
+	handle := func(kind string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				http.Error(w, "read error", http.StatusBadRequest)
+				return
+			}
+
+			if kind == "webhook" && *secret != "" {
+				err := webhook.Verify(*secret, r.Header.Get(webhook.HeaderTimestamp),
+					r.Header.Get(webhook.HeaderSignature), body, 5*time.Minute)
+				if err != nil {
+					log.Printf("%s %s rejected: %v", kind, r.Header.Get(webhook.HeaderDelivery), err)
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+
+			if rand.Float64() < *failRate {
+				log.Printf("%s %s -> 503 (simulated)", kind, r.Header.Get(webhook.HeaderDelivery))
+				http.Error(w, "simulated failure", http.StatusServiceUnavailable)
+				return
+			}
+
+			log.Printf("%s event=%s delivery=%s body=%s", kind,
+				r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderDelivery), body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	http.HandleFunc("/webhook", handle("webhook"))
+	http.HandleFunc("/sms", handle("sms"))
+
+	log.Printf("sink listening on %s (/webhook, /sms)", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
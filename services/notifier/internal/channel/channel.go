@@ -0,0 +1,60 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"tj/pkg/events"
+)
+
+// Message is one event rendered for delivery to one subscriber.
+type Message struct {
+	DeliveryId string
+	RoutingKey string
+	Target     string
+	Secret     string
+	// Event is the decoded envelope, re-encoded by the webhook channel per Format
+	Event  *events.Envelope
+	Format string
+	// Body is the envelope JSON
+	Body []byte
+	// Subject and Text are the human readable form used by email and SMS
+	Subject string
+	Text    string
+}
+
+// Result describes what the remote side answered, when there was an answer.
+type Result struct {
+	StatusCode int
+}
+
+type Channel interface {
+	Send(ctx context.Context, msg *Message) (Result, error)
+}
+
+// PermanentError marks a failure that retrying won't fix (bad address, 4xx response).
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+func permanent(format string, args ...interface{}) error {
+	return &PermanentError{Err: fmt.Errorf(format, args...)}
+}
+
+func IsPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+// httpStatusError classifies a non-2xx response: 408, 429 and 5xx are worth retrying.
+func httpStatusError(code int) error {
+	if code == 408 || code == 429 || code >= 500 {
+		return fmt.Errorf("http status %d", code)
+	}
+
+	return permanent("http status %d", code)
+}
@@ -0,0 +1,85 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type EmailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type Email struct {
+	cfg EmailConfig
+}
+
+func NewEmail(cfg EmailConfig) *Email {
+	return &Email{cfg: cfg}
+}
+
+// Send delivers a plain-text mail: the summary followed by the event JSON. Auth is only used when a username is configured,
+// so local catchers like mailpit work without credentials.
+func (e *Email) Send(ctx context.Context, msg *Message) (Result, error) {
+	to, err := mail.ParseAddress(msg.Target)
+	if err != nil {
+		return Result{}, permanent("invalid email address %q", msg.Target)
+	}
+
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to.Address)
+	// the subject is built from event data, keep it on one header line
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@fleet-notifier>\r\n", msg.DeliveryId)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, msg.Body, "", "  ") == nil {
+		b.WriteString("\r\n\r\n")
+		b.WriteString(strings.ReplaceAll(pretty.String(), "\n", "\r\n"))
+	}
+
+	// net/smtp has no context support, so run it aside and give up when ctx ends
+	addr := net.JoinHostPort(e.cfg.Host, e.cfg.Port)
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, e.cfg.From, []string{to.Address}, []byte(b.String()))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return Result{}, classifySMTP(err)
+		}
+		return Result{}, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// classifySMTP treats 5xx replies (mailbox unknown, rejected) as permanent.
+func classifySMTP(err error) error {
+	if s := err.Error(); len(s) >= 3 && s[0] == '5' && s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9' {
+		return &PermanentError{Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,68 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxSMSLength keeps messages within a few concatenated segments.
+const maxSMSLength = 480
+
+// SMS talks to a generic HTTP gateway: POST {"to": "...", "message": "..."} with an
+// optional bearer token. Most providers can be fronted by that shape.
+type SMS struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewSMS(url, token string, timeout time.Duration) *SMS {
+	return &SMS{url: url, token: token, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *SMS) Send(ctx context.Context, msg *Message) (Result, error) {
+	if s.url == "" {
+		return Result{}, &PermanentError{Err: errors.New("SMS_GATEWAY_URL is not configured")}
+	}
+
+	text := msg.Text
+	if r := []rune(text); len(r) > maxSMSLength {
+		text = string(r[:maxSMSLength-3]) + "..."
+	}
+	body, err := json.Marshal(map[string]string{
+		"to":        msg.Target,
+		"message":   text,
+		"reference": msg.DeliveryId,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, permanent("invalid sms gateway url: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	res := Result{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, httpStatusError(resp.StatusCode)
+	}
+
+	return res, nil
+}
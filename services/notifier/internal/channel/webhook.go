@@ -0,0 +1,110 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	"tj/pkg/webhook"
+)
+
+type Webhook struct {
+	client *http.Client
+}
+
+// NewWebhook returns the webhook channel. Unless allowInternal is set it refuses to connect
+// to loopback, private and link-local addresses, redirects included.
+func NewWebhook(timeout time.Duration, allowInternal bool) *Webhook {
+	client := &http.Client{Timeout: timeout}
+	if !allowInternal {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		// through a proxy the dialer would only ever see the proxy's address
+		t.Proxy = nil
+		t.DialContext = webhook.Dialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		client.Transport = t
+	}
+
+	return &Webhook{client: client}
+}
+
+// Send POSTs the event in the subscriber's payload format, signed with the subscriber
+// secret when one is set. The signature always covers the exact body sent.
+func (w *Webhook) Send(ctx context.Context, msg *Message) (Result, error) {
+	body, header, err := webhookPayload(msg)
+	if err != nil {
+		return Result{}, &PermanentError{Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Target, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, permanent("invalid webhook url: %v", err)
+	}
+
+	ts := time.Now().Unix()
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", "fleet-notifier")
+	req.Header.Set(webhook.HeaderEvent, msg.RoutingKey)
+	req.Header.Set(webhook.HeaderDelivery, msg.DeliveryId)
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if msg.Secret != "" {
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(msg.Secret, ts, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if errors.Is(err, webhook.ErrInternalTarget) {
+		return Result{}, &PermanentError{Err: err}
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	res := Result{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, httpStatusError(resp.StatusCode)
+	}
+
+	return res, nil
+}
+
+// webhookPayload renders the body and content headers. CloudEvents use the HTTP binding:
+// structured mode sends the whole event as application/cloudevents+json, binary mode sends
+// only the data with the attributes as ce-* headers.
+func webhookPayload(msg *Message) ([]byte, http.Header, error) {
+	header := http.Header{}
+
+	switch msg.Format {
+	case model.PayloadCloudEvents:
+		body, err := json.Marshal(events.ToCloudEvent(msg.Event))
+		header.Set("Content-Type", events.CloudEventsContentType)
+		return body, header, err
+
+	case model.PayloadCloudEventsBinary:
+		ce := events.ToCloudEvent(msg.Event)
+		for k, v := range ce.Attributes() {
+			header.Set("ce-"+k, v)
+		}
+		header.Set("Content-Type", ce.DataContentType)
+		return ce.Data, header, nil
+
+	case model.PayloadEnvelope, "":
+		header.Set("Content-Type", "application/json")
+		return msg.Body, header, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown payload format %q", msg.Format)
+	}
+}
@@ -0,0 +1,67 @@
+package channel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	"tj/pkg/webhook"
+)
+
+func TestWebhookSendsBinaryCloudEvents(t *testing.T) {
+	env, err := events.New(events.SourceWorker, &events.GeofenceEntry{VehicleId: "bus-1", EventId: 3}, "corr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	// the test server listens on loopback
+	wh := NewWebhook(time.Second, true)
+	msg := &Message{
+		DeliveryId: "d-1",
+		RoutingKey: env.Type,
+		Target:     srv.URL,
+		Secret:     "s3cret",
+		Event:      env,
+		Format:     model.PayloadCloudEventsBinary,
+	}
+	if _, err := wh.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Ce-Specversion":   events.CloudEventsSpecVersion,
+		"Ce-Id":            env.Id,
+		"Ce-Type":          "fleet.geofence.entry",
+		"Ce-Source":        "/fleet/worker",
+		"Ce-Time":          env.ProducedAt.Format(time.RFC3339Nano),
+		"Ce-Subject":       "bus-1",
+		"Ce-Dataschema":    events.SchemaURI(env.Type, env.Version),
+		"Ce-Correlationid": "corr-1",
+		"Content-Type":     "application/json",
+	}
+	for k, v := range want {
+		if h := got.Header.Get(k); h != v {
+			t.Errorf("%s: %q, want %q", k, h, v)
+		}
+	}
+	if string(body) != string(env.Data) {
+		t.Errorf("body %s, want the event data %s", body, env.Data)
+	}
+	ts := got.Header.Get(webhook.HeaderTimestamp)
+	if err := webhook.Verify("s3cret", ts, got.Header.Get(webhook.HeaderSignature), body, time.Minute); err != nil {
+		t.Errorf("signature over the data body: %v", err)
+	}
+}
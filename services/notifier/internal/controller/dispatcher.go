@@ -0,0 +1,478 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/time/rate"
+
+	model "tj/pkg/model"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository"
+	"tj/services/notifier/internal/channel"
+)
+
+const (
+	subscribersReloadInterval = 30 * time.Second
+	routesReloadInterval      = time.Minute
+	sendTimeout               = 15 * time.Second
+	retryBaseDelay            = 2 * time.Second
+	retryMaxDelay             = 5 * time.Minute
+	jobQueueSize              = 1024
+)
+
+type job struct {
+	sub       model.NotificationSubscriber
+	msg       channel.Message
+	vehicleId string
+	attempt   int
+	// the broker message the job came from, acked once all its jobs are done
+	from *message
+}
+
+// message counts the deliveries of one broker message still open. The routing loop holds
+// one reference while it creates them, so the count can't reach zero early.
+type message struct {
+	d    amqp.Delivery
+	open atomic.Int32
+}
+
+// done releases one reference and acks the message with the last one.
+func (m *message) done() {
+	if m.open.Add(-1) > 0 {
+		return
+	}
+	if err := m.d.Ack(false); err != nil {
+		log.Printf("notifier ack error: %v", err)
+	}
+}
+
+type Config struct {
+	Workers     int
+	MaxAttempts int
+	// consecutive failed deliveries before a subscriber is disabled, 0 = never
+	DisableAfter int
+}
+
+// Dispatcher routes fleet events to notification subscribers and delivers them with
+// retries. An event is acked only once each of its deliveries was sent, failed for good
+// or was rate limited, so delivery is at-least-once: an event whose deliveries were
+// still queued or being retried when the process stopped is redelivered by the broker
+// and sent to all its subscribers again, under new delivery ids. Unacked events count
+// against the channel's prefetch, so a subscriber that keeps failing slows consumption
+// down until its retries run out.
+type Dispatcher struct {
+	rmq      rmq.Consumer
+	cfg      rmq.RabbitConfig
+	channels map[string]channel.Channel
+	conf     Config
+	repo     repository.NotificationRepository
+	vehicles repository.VehicleRepository
+
+	jobs    chan *job
+	workers sync.WaitGroup
+	// closed when the consume loop ended
+	consumed chan struct{}
+	// set once Flush closed jobs, retries falling due after that are dropped
+	mu      sync.Mutex
+	flushed bool
+
+	// only touched by the consume loop
+	subscribers []model.NotificationSubscriber
+	loadedAt    time.Time
+	limiters    map[int64]*rate.Limiter
+	limits      map[int64]int
+	// vehicle_id -> route_id / operator_id for route and tenant filters
+	routes         map[string]string
+	operators      map[string]string
+	routesLoadedAt time.Time
+}
+
+func NewDispatcher(r rmq.Consumer, cfg rmq.RabbitConfig, channels map[string]channel.Channel, conf Config, repos *repository.Repositories) *Dispatcher {
+	if conf.Workers < 1 {
+		conf.Workers = 1
+	}
+	if conf.MaxAttempts < 1 {
+		conf.MaxAttempts = 1
+	}
+
+	return &Dispatcher{
+		rmq:       r,
+		cfg:       cfg,
+		channels:  channels,
+		conf:      conf,
+		repo:      repos.Notifications,
+		vehicles:  repos.Vehicles,
+		jobs:      make(chan *job, jobQueueSize),
+		consumed:  make(chan struct{}),
+		limiters:  make(map[int64]*rate.Limiter),
+		limits:    make(map[int64]int),
+		routes:    make(map[string]string),
+		operators: make(map[string]string),
+	}
+}
+
+// Start consumes until Stop. Events are handled and delivered with ctx's values but not its
+// cancellation, shutdown goes through Stop, Wait and Flush instead.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	msgs, err := d.rmq.Consume(d.cfg, false)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Notifier started, queue=%s consumer=%s workers=%d",
+		d.cfg.QueueName, d.cfg.ConsumerName, d.conf.Workers)
+
+	work := context.WithoutCancel(ctx)
+	for i := 0; i < d.conf.Workers; i++ {
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+
+			for j := range d.jobs {
+				d.deliver(work, j)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(d.consumed)
+
+		for m := range msgs {
+			d.handleMessage(work, m)
+		}
+	}()
+
+	return nil
+}
+
+// Stop cancels the consumer. Messages prefetched but not handled yet are unacked and go
+// back to the queue.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	return d.rmq.Cancel(d.cfg)
+}
+
+// Wait waits until the message in progress at Stop is routed to the job queue.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	select {
+	case <-d.consumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush delivers what is left in the job queue. Retries falling due from now on are
+// dropped; their last attempt stays logged as retrying and their event unacked, to be
+// redelivered after the restart.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	select {
+	case <-d.consumed:
+	default:
+		// the consume loop could still send on jobs
+		return errors.New("consume loop still running, job queue not flushed")
+	}
+
+	d.mu.Lock()
+	d.flushed = true
+	close(d.jobs)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d queued deliveries not sent: %w", len(d.jobs), ctx.Err())
+	}
+}
+
+func (d *Dispatcher) handleMessage(ctx context.Context, m amqp.Delivery) {
+	d.reloadSubscribers(ctx)
+	d.reloadRoutes(ctx)
+
+	env, err := rmq.DecodeEvent(m)
+	if err != nil {
+		log.Printf("notifier: dropping undecodable %s message: %v", m.RoutingKey, err)
+		m.Ack(false)
+		return
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("notifier: marshal %s envelope error: %v", m.RoutingKey, err)
+		m.Ack(false)
+		return
+	}
+
+	// released below, once every job was created
+	msg := &message{d: m}
+	msg.open.Store(1)
+	defer msg.done()
+
+	summary := parseSummary(env)
+	subject, text := render(m.RoutingKey, summary)
+	routeId := d.routes[summary.VehicleId]
+	operatorId := d.operators[summary.VehicleId]
+
+	for _, sub := range d.subscribers {
+		if !matches(&sub, m.RoutingKey, summary.VehicleId, routeId, operatorId) {
+			continue
+		}
+
+		j := &job{
+			sub: sub,
+			msg: channel.Message{
+				DeliveryId: newDeliveryId(),
+				RoutingKey: m.RoutingKey,
+				Target:     sub.Target,
+				Event:      env,
+				Format:     sub.PayloadFormat,
+				Body:       body,
+				Subject:    subject,
+				Text:       text,
+			},
+			vehicleId: summary.VehicleId,
+			attempt:   1,
+			from:      msg,
+		}
+		if sub.Secret != nil {
+			j.msg.Secret = *sub.Secret
+		}
+
+		if !d.allow(&sub) {
+			d.record(ctx, j, model.DeliveryRateLimited, channel.Result{}, errors.New("subscriber rate limit exceeded"), 0)
+			continue
+		}
+
+		// blocks when the workers fall behind, which holds back the broker through unacked messages
+		msg.open.Add(1)
+		d.jobs <- j
+	}
+}
+
+func matches(sub *model.NotificationSubscriber, routingKey, vehicleId, routeId, operatorId string) bool {
+	// tenant subscribers only ever see their own operator's vehicles
+	if sub.OperatorId != nil && *sub.OperatorId != operatorId {
+		return false
+	}
+
+	matched := false
+	for _, pattern := range sub.EventTypes {
+		if rmq.MatchRoutingKey(pattern, routingKey) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	if len(sub.VehicleIds) > 0 && !contains(sub.VehicleIds, vehicleId) {
+		return false
+	}
+	if len(sub.RouteIds) > 0 && !contains(sub.RouteIds, routeId) {
+		return false
+	}
+
+	return true
+}
+
+func contains(vals []string, v string) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allow applies the per-subscriber limit. Retries don't count against it.
+func (d *Dispatcher) allow(sub *model.NotificationSubscriber) bool {
+	if sub.RateLimitPerMin <= 0 {
+		return true
+	}
+
+	l, ok := d.limiters[sub.Id]
+	if !ok || d.limits[sub.Id] != sub.RateLimitPerMin {
+		l = rate.NewLimiter(rate.Limit(float64(sub.RateLimitPerMin)/60), sub.RateLimitPerMin)
+		d.limiters[sub.Id] = l
+		d.limits[sub.Id] = sub.RateLimitPerMin
+	}
+
+	return l.Allow()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, j *job) {
+	ch, ok := d.channels[j.sub.Channel]
+	if !ok {
+		d.record(ctx, j, model.DeliveryFailed, channel.Result{}, errors.New("unknown channel "+j.sub.Channel), 0)
+		j.from.done()
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	started := time.Now()
+	res, err := ch.Send(sendCtx, &j.msg)
+	elapsed := time.Since(started)
+	cancel()
+
+	switch {
+	case err == nil:
+		d.record(ctx, j, model.DeliverySent, res, nil, elapsed)
+		d.resetFailures(ctx, j.sub.Id)
+		j.from.done()
+
+	case channel.IsPermanent(err) || j.attempt >= d.conf.MaxAttempts:
+		d.record(ctx, j, model.DeliveryFailed, res, err, elapsed)
+		log.Printf("notify %s subscriber=%d delivery=%s failed after %d attempt(s): %v",
+			j.sub.Channel, j.sub.Id, j.msg.DeliveryId, j.attempt, err)
+		d.countFailure(ctx, j.sub.Id, err)
+		j.from.done()
+
+	default:
+		d.record(ctx, j, model.DeliveryRetrying, res, err, elapsed)
+		next := *j
+		next.attempt++
+		time.AfterFunc(backoff(j.attempt), func() { d.retry(&next) })
+	}
+}
+
+func (d *Dispatcher) retry(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.flushed {
+		log.Printf("notify %s subscriber=%d delivery=%s retry %d dropped: shutting down",
+			j.sub.Channel, j.sub.Id, j.msg.DeliveryId, j.attempt)
+		return
+	}
+	d.jobs <- j
+}
+
+// backoff doubles from retryBaseDelay per attempt, capped, with ±20% jitter so a
+// recovering endpoint isn't hit by every retry at once.
+func backoff(attempt int) time.Duration {
+	delay := float64(retryBaseDelay) * math.Pow(2, float64(attempt-1))
+	if delay > float64(retryMaxDelay) {
+		delay = float64(retryMaxDelay)
+	}
+	delay *= 0.8 + 0.4*mrand.Float64()
+
+	return time.Duration(delay)
+}
+
+func (d *Dispatcher) record(ctx context.Context, j *job, status string, res channel.Result, sendErr error, elapsed time.Duration) {
+	row := model.NotificationDelivery{
+		DeliveryId:   j.msg.DeliveryId,
+		SubscriberId: j.sub.Id,
+		Channel:      j.sub.Channel,
+		RoutingKey:   j.msg.RoutingKey,
+		Attempt:      j.attempt,
+		Status:       status,
+		DurationMs:   elapsed.Milliseconds(),
+	}
+	if j.vehicleId != "" {
+		row.VehicleId = &j.vehicleId
+	}
+	if res.StatusCode != 0 {
+		row.StatusCode = &res.StatusCode
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		row.Error = &msg
+	}
+
+	if err := d.repo.RecordDelivery(ctx, &row); err != nil {
+		log.Printf("save notification_deliveries error: %v", err)
+	}
+}
+
+func (d *Dispatcher) reloadSubscribers(ctx context.Context) {
+	if time.Since(d.loadedAt) < subscribersReloadInterval {
+		return
+	}
+
+	subs, err := d.repo.EnabledSubscribers(ctx)
+	if err != nil {
+		log.Printf("load notification_subscribers error: %v", err)
+		return
+	}
+	d.subscribers = subs
+	d.loadedAt = time.Now()
+}
+
+func (d *Dispatcher) reloadRoutes(ctx context.Context) {
+	if time.Since(d.routesLoadedAt) < routesReloadInterval {
+		return
+	}
+
+	vehicles, err := d.vehicles.List(ctx)
+	if err != nil {
+		log.Printf("load vehicle routes error: %v", err)
+		return
+	}
+
+	routes := make(map[string]string, len(vehicles))
+	operators := make(map[string]string, len(vehicles))
+	for _, v := range vehicles {
+		if v.RouteId != nil {
+			routes[v.VehicleId] = *v.RouteId
+		}
+		if v.OperatorId != nil {
+			operators[v.VehicleId] = *v.OperatorId
+		}
+	}
+	d.routes, d.operators = routes, operators
+	d.routesLoadedAt = time.Now()
+}
+
+func (d *Dispatcher) resetFailures(ctx context.Context, subscriberId int64) {
+	if err := d.repo.ResetFailures(ctx, subscriberId); err != nil {
+		log.Printf("reset consecutive_failures error: %v", err)
+	}
+}
+
+// countFailure bumps the subscriber's failure streak and disables it once the streak
+// reaches DisableAfter. The in-memory list catches up on the next reload.
+func (d *Dispatcher) countFailure(ctx context.Context, subscriberId int64, cause error) {
+	if err := d.repo.CountFailure(ctx, subscriberId); err != nil {
+		log.Printf("update consecutive_failures error: %v", err)
+		return
+	}
+	if d.conf.DisableAfter <= 0 {
+		return
+	}
+
+	reason := fmt.Sprintf("disabled after %d consecutive failed deliveries, last error: %v", d.conf.DisableAfter, cause)
+	disabled, err := d.repo.Disable(ctx, subscriberId, reason, d.conf.DisableAfter)
+	if err != nil {
+		log.Printf("disable subscriber error: %v", err)
+		return
+	}
+	if disabled {
+		log.Printf("notification subscriber %d disabled: %s", subscriberId, reason)
+	}
+}
+
+func newDeliveryId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
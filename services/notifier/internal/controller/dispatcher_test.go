@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository/memory"
+	"tj/services/notifier/internal/channel"
+)
+
+// ackingConsumer hands out the memory broker's deliveries with an acknowledger that
+// reports acks, which the broker itself ignores.
+type ackingConsumer struct {
+	*rmq.MemoryBroker
+	acks chan uint64
+}
+
+func (c *ackingConsumer) Consume(cfg rmq.RabbitConfig, autoAck bool) (<-chan amqp.Delivery, error) {
+	in, err := c.MemoryBroker.Consume(cfg, autoAck)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan amqp.Delivery)
+	go func() {
+		defer close(out)
+		for d := range in {
+			d.Acknowledger = c
+			out <- d
+		}
+	}()
+
+	return out, nil
+}
+
+func (c *ackingConsumer) Ack(tag uint64, multiple bool) error {
+	c.acks <- tag
+	return nil
+}
+func (c *ackingConsumer) Nack(tag uint64, multiple, requeue bool) error { return nil }
+func (c *ackingConsumer) Reject(tag uint64, requeue bool) error         { return nil }
+
+// heldChannel always succeeds, for the "held" target only once released.
+type heldChannel struct {
+	release chan struct{}
+}
+
+func (h *heldChannel) Send(ctx context.Context, msg *channel.Message) (channel.Result, error) {
+	if msg.Target == "held" {
+		<-h.release
+	}
+	return channel.Result{StatusCode: 200}, nil
+}
+
+func TestEventIsAckedOnceEveryDeliveryIsDone(t *testing.T) {
+	cfg := rmq.RabbitConfig{ExchangeName: "fleet.events", QueueName: "notifications", ConsumerName: "notifier"}
+	broker := rmq.NewMemoryBroker()
+	t.Cleanup(broker.Close)
+	broker.Bind(cfg.ExchangeName, cfg.QueueName, "geofence.*")
+	consumer := &ackingConsumer{MemoryBroker: broker, acks: make(chan uint64, 1)}
+
+	store := memory.New()
+	store.Notifications.Add(
+		model.NotificationSubscriber{Id: 1, Channel: "test", Target: "fast", EventTypes: []string{"#"}, Enabled: true},
+		model.NotificationSubscriber{Id: 2, Channel: "test", Target: "held", EventTypes: []string{"#"}, Enabled: true},
+	)
+	held := &heldChannel{release: make(chan struct{})}
+	d := NewDispatcher(consumer, cfg, map[string]channel.Channel{"test": held}, Config{Workers: 2, MaxAttempts: 1}, store.Repositories())
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := events.New(events.SourceWorker, &events.GeofenceEntry{VehicleId: "bus-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rmq.PublishEvent(broker, cfg.ExchangeName, events.TypeGeofenceEntry, env); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-consumer.acks:
+		t.Fatal("acked while a delivery was still in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(held.release)
+	select {
+	case <-consumer.acks:
+	case <-time.After(time.Second):
+		t.Fatal("not acked after both deliveries were sent")
+	}
+	if n := len(store.Notifications.Deliveries()); n != 2 {
+		t.Errorf("%d deliveries logged, want 2", n)
+	}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tj/pkg/events"
+)
+
+// eventSummary picks the fields the human readable channels mention; everything else
+// stays in the event JSON.
+type eventSummary struct {
+	VehicleId string `json:"vehicle_id"`
+	Timestamp int64  `json:"timestamp"`
+	Station   *struct {
+		Name string `json:"name"`
+	} `json:"station"`
+	Rule *struct {
+		Name     string `json:"name"`
+		Severity string `json:"severity"`
+	} `json:"rule"`
+}
+
+func parseSummary(env *events.Envelope) eventSummary {
+	var s eventSummary
+	// a payload we can't read is still delivered, just with a thinner summary
+	_ = env.DecodeData(&s)
+	return s
+}
+
+// render builds the subject and short text used by email and SMS.
+func render(routingKey string, s eventSummary) (subject, text string) {
+	what := routingKey
+	if s.Rule != nil && s.Rule.Name != "" {
+		what = s.Rule.Name
+	}
+
+	subject = "[fleet] " + what
+	if s.Rule != nil && s.Rule.Severity != "" {
+		subject = fmt.Sprintf("[fleet][%s] %s", s.Rule.Severity, what)
+	}
+	if s.VehicleId != "" {
+		subject += " - " + s.VehicleId
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Event: %s\n", routingKey)
+	if s.VehicleId != "" {
+		fmt.Fprintf(&b, "Vehicle: %s\n", s.VehicleId)
+	}
+	if s.Station != nil && s.Station.Name != "" {
+		fmt.Fprintf(&b, "Station: %s\n", s.Station.Name)
+	}
+	if s.Rule != nil && s.Rule.Name != "" {
+		fmt.Fprintf(&b, "Rule: %s (%s)\n", s.Rule.Name, s.Rule.Severity)
+	}
+	if s.Timestamp > 0 {
+		fmt.Fprintf(&b, "Time: %s\n", time.Unix(s.Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+
+	return subject, strings.TrimSuffix(b.String(), "\n")
+}
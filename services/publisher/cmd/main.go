@@ -1,39 +1,40 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"tj/config"
+	"tj/pkg/lifecycle"
 	"tj/pkg/mqtt"
 	mock "tj/services/publisher/internal/controller"
 )
 
 func main() {
 	config.Load()
+	lc := lifecycle.New(config.Cfg.ShutdownTimeout)
 
 	vehicleId := "B1234XYZ"
 	clientId := "vehicle-" + vehicleId
-	mqttClient, err := mqtt.NewMQTTClient(clientId)
+	// with broker auth on, the mock connects like a tracker with its device credentials
+	mqttClient, err := mqtt.NewMQTTClientAs(clientId, os.Getenv("MOCK_DEVICE_USERNAME"), os.Getenv("MOCK_DEVICE_PASSWORD"))
 	if err != nil {
 		log.Fatalf("MQTT connect error: %v", err)
 	}
-	defer mqttClient.Disconnect()
 
 	publisher := mock.NewPublisher(mqttClient.Client)
+	publisher.OperatorId = os.Getenv("MOCK_OPERATOR_ID")
 
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Start publishing until shutdown
+	lc.Go("mock publisher", func(ctx context.Context) error {
+		return publisher.PublishRandomMovement(ctx, vehicleId)
+	})
 
-	// Start publishing in goroutine
-	go func() {
-		publisher.PublishRandomMovement(vehicleId)
-	}()
+	lc.OnShutdown(lifecycle.Close, "mqtt", lifecycle.Func(mqttClient.Disconnect))
 
-	// Wait for interrupt signal
-	<-sigChan
-	log.Println("Shutting down publisher...")
+	if err := lc.Wait(); err != nil {
+		log.Fatalf("publisher shutdown error: %v", err)
+	}
+	log.Println("Publisher stopped")
 }
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	model "tj/pkg/model"
+	mqttpkg "tj/pkg/mqtt"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -16,6 +18,9 @@ type MockPublisher struct {
 	client mqtt.Client
 	lat    float64
 	lon    float64
+
+	// publish on /fleet/<OperatorId>/vehicle/... when set, the legacy topic otherwise
+	OperatorId string
 }
 
 func NewPublisher(client mqtt.Client) *MockPublisher {
@@ -30,8 +35,9 @@ func NewPublisher(client mqtt.Client) *MockPublisher {
 	}
 }
 
-// PublishRandomMovement publishes location data every 2 seconds with random movement
-func (p *MockPublisher) PublishRandomMovement(vehicleId string) {
+// PublishRandomMovement publishes location data every 2 seconds with random movement,
+// until ctx is done
+func (p *MockPublisher) PublishRandomMovement(ctx context.Context, vehicleId string) error {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -43,6 +49,8 @@ func (p *MockPublisher) PublishRandomMovement(vehicleId string) {
 		select {
 		case <-ticker.C:
 			p.publishLocation(vehicleId)
+		case <-ctx.Done():
+			return nil
 		}
 	}
 }
@@ -69,8 +77,7 @@ func (p *MockPublisher) publishLocation(vehicleId string) error {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	topic := fmt.Sprintf("/fleet/vehicle/%s/location", vehicleId)
-	token := p.client.Publish(topic, 0, false, data)
+	token := p.client.Publish(mqttpkg.LocationTopic(p.OperatorId, vehicleId), 0, false, data)
 
 	token.Wait()
 
@@ -90,5 +97,5 @@ func (p *MockPublisher) SetPosition(lat, lon float64) {
 	p.lat = lat
 	p.lon = lon
 
-	log.Println("Position set to: Lat=%.6f, Lon=%.6f\n", lat, lon)
+	log.Printf("Position set to: Lat=%.6f, Lon=%.6f\n", lat, lon)
 }
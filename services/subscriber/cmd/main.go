@@ -2,37 +2,40 @@ package main
 
 import (
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"time"
 
 	"tj/config"
 	db "tj/pkg/database"
+	"tj/pkg/lifecycle"
 	"tj/pkg/mqtt"
 	rmq "tj/pkg/rabbitmq"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository"
 	sub "tj/services/subscriber/internal/controller"
 )
 
 func main() {
 	config.Load()
+	lc := lifecycle.New(config.Cfg.ShutdownTimeout)
 
-	if err := db.Connect(); err != nil {
+	dbConn, err := db.Connect()
+	if err != nil {
 		log.Fatalf("Postgres init error: %v", err)
 	}
 
+	cache.Connect()
+
 	vehicleId := "B1234XYZ"
 	clientId := "subcribe-vehicle-" + vehicleId
 	mqttClient, err := mqtt.NewMQTTClient(clientId)
 	if err != nil {
 		log.Fatalf("MQTT connect error: %v", err)
 	}
-	defer mqttClient.Disconnect()
 
 	rmqClient, err := rmq.Connect()
 	if err != nil {
 		log.Fatalf("RabbitMQ init error: %v", err)
 	}
-	defer rmqClient.Close()
 
 	cfg := rmq.RabbitConfig{
 		ExchangeName: "fleet.events",
@@ -45,17 +48,26 @@ func main() {
 		log.Fatalf("RabbitMQ setup error: %v", err)
 	}
 
-	subscriber := sub.NewLocationSubscriber(rmqClient)
-	topic := "/fleet/vehicle/+/location"
-	if err := mqttClient.Subscribe(topic, subscriber.HandleMessage); err != nil {
-		log.Fatalf("MQTT subscribe error: %v", err)
+	subscriber := sub.NewLocationSubscriber(rmqClient, cache.Rdb, repository.NewPostgres(dbConn))
+	if err := subscriber.WarmCache(lc.Context(), 24*time.Hour); err != nil {
+		log.Printf("warm latest location cache error: %v", err)
 	}
 
-	log.Printf("Subscriber listening on topic %s", topic)
+	if err := subscriber.Listen(mqttClient); err != nil {
+		log.Fatalf("MQTT subscribe error: %v", err)
+	}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	lc.OnShutdown(lifecycle.StopIntake, "mqtt subscriptions", lifecycle.ErrFunc(func() error {
+		return subscriber.Unlisten(mqttClient)
+	}))
+	lc.OnShutdown(lifecycle.Drain, "mqtt handlers", subscriber.Drain)
+	lc.OnShutdown(lifecycle.Close, "mqtt", lifecycle.Func(mqttClient.Disconnect))
+	lc.OnShutdown(lifecycle.Close, "rabbitmq", lifecycle.Func(rmqClient.Close))
+	lc.OnShutdown(lifecycle.Close, "redis", lifecycle.ErrFunc(cache.Rdb.Close))
+	lc.OnShutdown(lifecycle.Close, "postgres", lifecycle.ErrFunc(func() error { return db.Close(dbConn) }))
 
-	<-sigChan
-	log.Println("Shutting down subscriber...")
+	if err := lc.Wait(); err != nil {
+		log.Fatalf("subscriber shutdown error: %v", err)
+	}
+	log.Println("Subscriber stopped")
 }
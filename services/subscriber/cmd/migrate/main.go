@@ -17,18 +17,19 @@ func main() {
 
 	config.Load()
 
-	if err := db.Connect(); err != nil {
+	dbConn, err := db.Connect()
+	if err != nil {
 		log.Fatalf("Postgres init error: %v", err)
 	}
 
 	switch *action {
 	case "up":
-		if err := db.RunMigrations("../../../migrations"); err != nil {
+		if err := db.RunMigrations(dbConn, "../../../migrations"); err != nil {
 			log.Fatal(err)
 		}
 
 	case "down", "rollback":
-		if err := db.RollbackMigration("../../../migrations", *steps); err != nil {
+		if err := db.RollbackMigration(dbConn, "../../../migrations", *steps); err != nil {
 			log.Fatal(err)
 		}
 
@@ -36,7 +37,7 @@ func main() {
 		if *version == 0 {
 			log.Fatal("force action requires -version")
 		}
-		if err := db.ForceMigrationVersion("../../../migrations", uint(*version)); err != nil {
+		if err := db.ForceMigrationVersion(dbConn, "../../../migrations", uint(*version)); err != nil {
 			log.Fatal(err)
 		}
 	}
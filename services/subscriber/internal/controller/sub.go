@@ -1,14 +1,21 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
-	db "tj/pkg/database"
+	"tj/pkg/events"
+	"tj/pkg/lifecycle"
+	mqttpkg "tj/pkg/mqtt"
 	rmq "tj/pkg/rabbitmq"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/redis/go-redis/v9"
 
 	model "tj/pkg/model"
 )
@@ -17,38 +24,121 @@ type LocationSubscriber struct {
 	mu      sync.Mutex
 	buffer  []model.MQTTLocationStruct
 	maxSize int
-	rmq     *rmq.RabbitClient
+	pub     rmq.Publisher
+	rdb     *redis.Client
+	repos   *repository.Repositories
+
+	// messages being handled, so shutdown can wait for their writes
+	inflight lifecycle.InFlight
+
+	// operator registry, guarded by mu
+	operators       map[string]bool
+	owners          map[string]*string
+	tenantsLoadedAt time.Time
 }
 
-func NewLocationSubscriber(rmq *rmq.RabbitClient) *LocationSubscriber {
+func NewLocationSubscriber(pub rmq.Publisher, rdb *redis.Client, repos *repository.Repositories) *LocationSubscriber {
 	return &LocationSubscriber{
 		buffer:  make([]model.MQTTLocationStruct, 0, 8),
 		maxSize: 8,
-		rmq:     rmq,
+		pub:     pub,
+		rdb:     rdb,
+		repos:   repos,
+	}
+}
+
+// WarmCache seeds the latest-location cache from Postgres so the fleet snapshot isn't empty
+// until every vehicle has reported again after a redis restart.
+func (h *LocationSubscriber) WarmCache(ctx context.Context, since time.Duration) error {
+	rows, err := h.repos.Locations.LatestSince(ctx, time.Now().Add(-since).Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		if err := cache.SetLatestLocation(ctx, h.rdb, r); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("latest location cache warmed with %d vehicles", len(rows))
+
+	return nil
+}
+
+var locationTopics = []string{mqttpkg.TenantLocationTopic, mqttpkg.LegacyLocationTopic}
+
+// Listen subscribes HandleMessage to the tenant and legacy location topics.
+func (h *LocationSubscriber) Listen(s mqttpkg.Subscriber) error {
+	for _, topic := range locationTopics {
+		if err := s.Subscribe(topic, h.HandleMessage); err != nil {
+			return err
+		}
+		log.Printf("Subscriber listening on topic %s", topic)
 	}
+
+	return nil
+}
+
+// Unlisten stops the subscriptions of Listen, the first step of shutting down.
+func (h *LocationSubscriber) Unlisten(s mqttpkg.Subscriber) error {
+	return s.Unsubscribe(locationTopics...)
+}
+
+// Drain waits for the messages being handled. Whatever the client still delivers
+// afterwards is dropped: it would be written to a database that is about to close.
+func (h *LocationSubscriber) Drain(ctx context.Context) error {
+	return h.inflight.Wait(ctx)
 }
 
 func (h *LocationSubscriber) HandleMessage(client mqtt.Client, msg mqtt.Message) {
+	if !h.inflight.Enter() {
+		log.Printf("dropping message on topic %s: shutting down", msg.Topic())
+		return
+	}
+	defer h.inflight.Leave()
+
+	// devices are authenticated but not trusted: a bad message is dropped, it must not
+	// stop ingest for the rest of the fleet. Returning also lets Drain see it finished.
 	var loc model.MQTTLocationStruct
 
 	if err := json.Unmarshal(msg.Payload(), &loc); err != nil {
-		log.Fatalf("invalid payload on topic %s: %v", msg.Topic(), err)
+		log.Printf("dropped invalid payload on topic %s: %v", msg.Topic(), err)
+		return
+	}
+
+	// the broker only lets a device publish on its own topic, so the topic is the identity
+	topicOperator, topicVehicle, ok := mqttpkg.ParseLocationTopic(msg.Topic())
+	if !ok {
+		log.Printf("ignoring message on unexpected topic %s", msg.Topic())
 		return
 	}
 	if loc.VehicleId == "" {
-		log.Fatalf("missing vehicle_id on topic %s", msg.Topic())
+		loc.VehicleId = topicVehicle
+	}
+	if loc.VehicleId != topicVehicle {
+		log.Printf("rejected point on topic %s: payload vehicle_id %q does not match the topic", msg.Topic(), loc.VehicleId)
+		return
+	}
+
+	operatorId, err := h.resolveOperator(topicOperator, loc.VehicleId)
+	if err != nil {
+		log.Printf("rejected point on topic %s: %v", msg.Topic(), err)
 		return
 	}
 
 	record := model.MQTTLocationStruct{
-		VehicleId: loc.VehicleId,
-		Latitude:  loc.Latitude,
-		Longitude: loc.Longitude,
-		Timestamp: loc.Timestamp,
+		VehicleId:  loc.VehicleId,
+		Latitude:   loc.Latitude,
+		Longitude:  loc.Longitude,
+		Timestamp:  loc.Timestamp,
+		Ignition:   loc.Ignition,
+		Speed:      loc.Speed,
+		OperatorId: operatorId,
 	}
 
-	if err := db.DB.Create(&record).Error; err != nil {
-		log.Fatalf("GORM insert error: %v", err)
+	if err := h.repos.Locations.Create(context.Background(), &record); err != nil {
+		log.Printf("dropped point of vehicle %s, GORM insert error: %v", record.VehicleId, err)
 		return
 	}
 	// h.mu.Lock()
@@ -74,11 +164,34 @@ func (h *LocationSubscriber) HandleMessage(client mqtt.Client, msg mqtt.Message)
 	log.Printf("stored (gorm) vehicle=%s lat=%.6f lon=%.6f ts=%d",
 		record.VehicleId, record.Latitude, record.Longitude, record.Timestamp)
 
-	paylaodInBytes, err := json.Marshal(record)
+	// the cache only backs the fleet snapshot and heartbeats, so a redis hiccup must not stop ingest
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if err := cache.SetLatestLocation(ctx, h.rdb, record); err != nil {
+		log.Printf("update latest location cache error: %v", err)
+	}
+	// heartbeat uses ingest time, not the device timestamp, so a tracker with a bad clock still counts as alive
+	if err := cache.TouchLastSeen(ctx, h.rdb, record.VehicleId, time.Now()); err != nil {
+		log.Printf("update last seen error: %v", err)
+	}
+	cancel()
+
+	raw := &events.LocationRaw{
+		VehicleId: record.VehicleId,
+		Latitude:  record.Latitude,
+		Longitude: record.Longitude,
+		Timestamp: record.Timestamp,
+		Ignition:  record.Ignition,
+		Speed:     record.Speed,
+	}
+	if operatorId != nil {
+		raw.OperatorId = *operatorId
+	}
+	env, err := events.New(events.SourceSubscriber, raw, "")
 	if err != nil {
-		log.Fatalf("err parse payload to bytes: %v", err)
+		log.Printf("build location.raw event of vehicle %s error: %v", record.VehicleId, err)
+		return
 	}
-	if err = rmq.PublishRMQ(h.rmq, "fleet.events", "location.raw", paylaodInBytes); err != nil {
-		log.Fatalf("publish location.raw error: %v", err)
+	if err = rmq.PublishEvent(h.pub, "fleet.events", events.TypeLocationRaw, env); err != nil {
+		log.Printf("publish location.raw of vehicle %s error: %v", record.VehicleId, err)
 	}
 }
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	mqttpkg "tj/pkg/mqtt"
+	rmq "tj/pkg/rabbitmq"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository/memory"
+)
+
+// ingest is the subscriber between an in-memory MQTT broker and an in-memory fleet.events
+// exchange, the first half of the flow the worker tests pick up from location.raw.
+type ingest struct {
+	devices *mqttpkg.MemoryBroker
+	sub     *LocationSubscriber
+	store   *memory.Store
+	rdb     *redis.Client
+	raw     <-chan amqp.Delivery
+}
+
+func newIngest(t *testing.T) *ingest {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	broker := rmq.NewMemoryBroker()
+	t.Cleanup(broker.Close)
+	broker.Bind("fleet.events", "test", "location.*")
+	raw, err := broker.Consume(rmq.RabbitConfig{QueueName: "test"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	store.Operators.Add(model.Operator{OperatorId: "op1"}, model.Operator{OperatorId: "op2"})
+
+	devices := mqttpkg.NewMemoryBroker()
+	sub := NewLocationSubscriber(broker, rdb, store.Repositories())
+	if err := sub.Listen(devices); err != nil {
+		t.Fatal(err)
+	}
+
+	return &ingest{devices: devices, sub: sub, store: store, rdb: rdb, raw: raw}
+}
+
+func (in *ingest) report(t *testing.T, topic string, payload any) {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.devices.Publish(topic, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// next returns the next location.raw event. The subscriber publishes before Publish
+// returns, so anything not queued yet never will be.
+func (in *ingest) next(t *testing.T) (*events.Envelope, *events.LocationRaw) {
+	t.Helper()
+
+	select {
+	case d := <-in.raw:
+		env, err := rmq.DecodeEvent(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw events.LocationRaw
+		if err := env.DecodeData(&raw); err != nil {
+			t.Fatal(err)
+		}
+		return env, &raw
+	case <-time.After(time.Second):
+		t.Fatal("no location.raw event")
+		return nil, nil
+	}
+}
+
+func (in *ingest) expectNone(t *testing.T) {
+	t.Helper()
+
+	select {
+	case d := <-in.raw:
+		t.Fatalf("unexpected %s event: %s", d.RoutingKey, d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTenantPointIsStoredAndPublished(t *testing.T) {
+	in := newIngest(t)
+
+	speed := 42.5
+	in.report(t, mqttpkg.LocationTopic("op1", "bus-1"), model.MQTTLocationStruct{
+		Latitude:  -6.2,
+		Longitude: 106.8,
+		Timestamp: 1700000000,
+		Speed:     &speed,
+	})
+
+	env, raw := in.next(t)
+	if env.Type != events.TypeLocationRaw || env.Source != events.SourceSubscriber {
+		t.Errorf("event %s from %s, want %s from %s", env.Type, env.Source, events.TypeLocationRaw, events.SourceSubscriber)
+	}
+	if raw.VehicleId != "bus-1" || raw.OperatorId != "op1" || raw.Timestamp != 1700000000 || raw.Speed == nil || *raw.Speed != speed {
+		t.Errorf("location.raw = %+v", raw)
+	}
+
+	stored := in.store.Locations.All()
+	if len(stored) != 1 || stored[0].VehicleId != "bus-1" || stored[0].OperatorId == nil || *stored[0].OperatorId != "op1" {
+		t.Fatalf("stored %+v, want one bus-1 point of op1", stored)
+	}
+
+	// first seen on a tenant topic: registered to that operator
+	vehicles := in.store.Vehicles.All()
+	if len(vehicles) != 1 || vehicles[0].OperatorId == nil || *vehicles[0].OperatorId != "op1" {
+		t.Errorf("vehicles %+v, want bus-1 claimed by op1", vehicles)
+	}
+
+	latest, err := cache.GetLatestLocations(context.Background(), in.rdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].VehicleId != "bus-1" {
+		t.Errorf("latest location cache %+v", latest)
+	}
+}
+
+func TestLegacyTopicTakesTheRegisteredOperator(t *testing.T) {
+	in := newIngest(t)
+	op := "op2"
+	in.store.Vehicles.Add(model.Vehicle{VehicleId: "bus-2", OperatorId: &op})
+
+	in.report(t, mqttpkg.LocationTopic("", "bus-2"), model.MQTTLocationStruct{Latitude: 1, Longitude: 2, Timestamp: 10})
+
+	if _, raw := in.next(t); raw.OperatorId != "op2" {
+		t.Errorf("operator %q, want op2 from the vehicles table", raw.OperatorId)
+	}
+}
+
+func TestRejectedPointsAreNeitherStoredNorPublished(t *testing.T) {
+	cases := []struct {
+		name    string
+		topic   string
+		payload model.MQTTLocationStruct
+	}{
+		{"unknown operator", mqttpkg.LocationTopic("op9", "bus-1"), model.MQTTLocationStruct{Timestamp: 1}},
+		{"vehicle of another operator", mqttpkg.LocationTopic("op1", "bus-2"), model.MQTTLocationStruct{Timestamp: 1}},
+		{"payload for another vehicle", mqttpkg.LocationTopic("op1", "bus-1"), model.MQTTLocationStruct{VehicleId: "bus-3", Timestamp: 1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in := newIngest(t)
+			op := "op2"
+			in.store.Vehicles.Add(model.Vehicle{VehicleId: "bus-2", OperatorId: &op})
+
+			in.report(t, c.topic, c.payload)
+
+			in.expectNone(t)
+			if stored := in.store.Locations.All(); len(stored) != 0 {
+				t.Errorf("stored %+v", stored)
+			}
+		})
+	}
+}
+
+func TestNothingIsHandledAfterTheDrain(t *testing.T) {
+	in := newIngest(t)
+
+	if err := in.sub.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// still subscribed, as when a message races the unsubscribe
+	in.report(t, mqttpkg.LocationTopic("op1", "bus-1"), model.MQTTLocationStruct{Timestamp: 1})
+
+	in.expectNone(t)
+	if stored := in.store.Locations.All(); len(stored) != 0 {
+		t.Errorf("stored %+v after the drain", stored)
+	}
+}
+
+func TestMalformedPayloadIsDroppedAndIngestGoesOn(t *testing.T) {
+	in := newIngest(t)
+
+	if err := in.devices.Publish(mqttpkg.LocationTopic("op1", "bus-1"), []byte(`{"latitude":`)); err != nil {
+		t.Fatal(err)
+	}
+	in.expectNone(t)
+
+	in.report(t, mqttpkg.LocationTopic("op1", "bus-2"), model.MQTTLocationStruct{Latitude: 1, Longitude: 2, Timestamp: 10})
+	if _, raw := in.next(t); raw.VehicleId != "bus-2" {
+		t.Errorf("location.raw of %s, want bus-2", raw.VehicleId)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := in.sub.Drain(ctx); err != nil {
+		t.Errorf("drain after a dropped message: %v", err)
+	}
+}
+
+// failingLocations is a location table whose inserts fail, as with Postgres down.
+type failingLocations struct {
+	*memory.Locations
+}
+
+func (failingLocations) Create(context.Context, *model.MQTTLocationStruct) error {
+	return errors.New("connection refused")
+}
+
+func TestPointIsDroppedWhenItCantBeStored(t *testing.T) {
+	in := newIngest(t)
+	repos := in.store.Repositories()
+	repos.Locations = failingLocations{in.store.Locations}
+	in.sub.repos = repos
+
+	in.report(t, mqttpkg.LocationTopic("op1", "bus-1"), model.MQTTLocationStruct{Latitude: 1, Longitude: 2, Timestamp: 10})
+	in.expectNone(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := in.sub.Drain(ctx); err != nil {
+		t.Errorf("drain after a failed insert: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const tenantRefreshInterval = time.Minute
+
+// resolveOperator decides which operator a point belongs to, given the operator segment of
+// its topic (empty for the legacy topic). A vehicle first seen on a tenant topic is
+// registered to that operator; one that already belongs to another operator is rejected,
+// so a tenant can't write into someone else's fleet.
+func (h *LocationSubscriber) resolveOperator(operatorId, vehicleId string) (*string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.tenantsLoadedAt) > tenantRefreshInterval {
+		h.loadTenants()
+	}
+
+	owner := h.owners[vehicleId]
+	if operatorId == "" {
+		return owner, nil
+	}
+
+	if !h.operators[operatorId] {
+		return nil, fmt.Errorf("unknown operator %q", operatorId)
+	}
+	if owner != nil {
+		if *owner != operatorId {
+			return nil, fmt.Errorf("vehicle %s belongs to operator %q", vehicleId, *owner)
+		}
+		return owner, nil
+	}
+
+	claimed, err := h.repos.Vehicles.Claim(context.Background(), vehicleId, operatorId)
+	if err != nil {
+		return nil, fmt.Errorf("register vehicle %s: %w", vehicleId, err)
+	}
+	if !claimed {
+		// lost the race: trust the database on the next refresh
+		h.tenantsLoadedAt = time.Time{}
+		return nil, fmt.Errorf("vehicle %s was claimed by another operator", vehicleId)
+	}
+
+	log.Printf("registered vehicle %s to operator %s", vehicleId, operatorId)
+	h.owners[vehicleId] = &operatorId
+
+	return &operatorId, nil
+}
+
+func (h *LocationSubscriber) loadTenants() {
+	ctx := context.Background()
+	ops, err := h.repos.Operators.List(ctx)
+	if err != nil {
+		log.Printf("load operators error: %v", err)
+		return
+	}
+	vehicles, err := h.repos.Vehicles.List(ctx)
+	if err != nil {
+		log.Printf("load vehicle operators error: %v", err)
+		return
+	}
+
+	h.operators = make(map[string]bool, len(ops))
+	for _, o := range ops {
+		h.operators[o.OperatorId] = true
+	}
+	h.owners = make(map[string]*string, len(vehicles))
+	for _, v := range vehicles {
+		h.owners[v.VehicleId] = v.OperatorId
+	}
+	h.tenantsLoadedAt = time.Now()
+}
@@ -0,0 +1,18 @@
+// Package subscribertest runs the real location subscriber in-process, for end-to-end
+// tests of the services behind it, which can't import the subscriber's internal packages.
+package subscribertest
+
+import (
+	"github.com/redis/go-redis/v9"
+
+	mqttpkg "tj/pkg/mqtt"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository"
+	"tj/services/subscriber/internal/controller"
+)
+
+// Listen subscribes a location subscriber to devices, usually an mqtt.MemoryBroker. It
+// stores points in repos and rdb and publishes location.raw to pub like in production.
+func Listen(devices mqttpkg.Subscriber, pub rmq.Publisher, rdb *redis.Client, repos *repository.Repositories) error {
+	return controller.NewLocationSubscriber(pub, rdb, repos).Listen(devices)
+}
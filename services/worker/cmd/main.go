@@ -1,29 +1,33 @@
 package main
 
 import (
+	"context"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 
 	"tj/config"
 	db "tj/pkg/database"
+	"tj/pkg/lifecycle"
 	rmq "tj/pkg/rabbitmq"
+	cache "tj/pkg/redis"
+	"tj/pkg/repository"
 	geo "tj/services/worker/internal/controller"
 )
 
 func main() {
 	config.Load()
+	lc := lifecycle.New(config.Cfg.ShutdownTimeout)
 
-	if err := db.Connect(); err != nil {
+	dbConn, err := db.Connect()
+	if err != nil {
 		log.Fatalf("Postgres init error: %v", err)
 	}
 
+	cache.Connect()
+
 	rmqClient, err := rmq.Connect()
 	if err != nil {
 		log.Fatalf("RabbitMQ init error: %v", err)
 	}
-	defer rmqClient.Close()
 
 	cfg := rmq.RabbitConfig{
 		ExchangeName: "fleet.events",
@@ -36,15 +40,28 @@ func main() {
 	if err := rmq.SetupRMQ(rmqClient, cfg); err != nil {
 		log.Fatalf("RabbitMQ setup error: %v", err)
 	}
+	// bounds what is still delivered after the consumer is canceled at shutdown
+	if err := rmqClient.Channel.Qos(64, 0, false); err != nil {
+		log.Fatalf("RabbitMQ qos error: %v", err)
+	}
 
-	worker := geo.NewWorker(rmqClient, cfg)
-	if err := worker.Start(); err != nil {
+	worker := geo.NewWorker(rmqClient, cfg, cache.Rdb, repository.NewPostgres(dbConn))
+	if err := worker.Start(lc.Context()); err != nil {
 		log.Fatalf("worker start error: %v", err)
 	}
+	lc.Go("heartbeat monitor", func(ctx context.Context) error {
+		return worker.HeartbeatMonitor(ctx, config.Cfg.OfflineCheckInterval, config.Cfg.OfflineThreshold, config.Cfg.LatestLocationMaxAge)
+	})
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	lc.OnShutdown(lifecycle.StopIntake, "location consumer", worker.Stop)
+	lc.OnShutdown(lifecycle.Drain, "location handler", worker.Wait)
+	lc.OnShutdown(lifecycle.Flush, "open trips", worker.FlushTrips)
+	lc.OnShutdown(lifecycle.Close, "rabbitmq", lifecycle.Func(rmqClient.Close))
+	lc.OnShutdown(lifecycle.Close, "redis", lifecycle.ErrFunc(cache.Rdb.Close))
+	lc.OnShutdown(lifecycle.Close, "postgres", lifecycle.ErrFunc(func() error { return db.Close(dbConn) }))
 
-	<-sigChan
-	log.Println("Shutting down geofence worker")
+	if err := lc.Wait(); err != nil {
+		log.Fatalf("geofence worker shutdown error: %v", err)
+	}
+	log.Println("Geofence worker stopped")
 }
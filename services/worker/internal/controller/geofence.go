@@ -1,26 +1,86 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
 	"log"
-	db "tj/pkg/database"
+	"time"
+
+	"tj/config"
+	"tj/pkg/events"
 	geopkg "tj/pkg/geofence"
 	model "tj/pkg/model"
+	"tj/pkg/rules"
+	"tj/pkg/speeding"
+	"tj/pkg/stop"
+	"tj/pkg/trip"
 
 	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
 )
 
+// Worker keeps each vehicle's trip, visit, stop, speeding and rule state in memory, so
+// every point of a vehicle has to reach the same worker: run a single replica per queue.
+// After a restart, open visits are restored from station_visits and the other detectors
+// are warmed up from the stored points, see warmUp.
 type Worker struct {
-	rmq *rmq.RabbitClient
-	cfg rmq.RabbitConfig
+	rmq   rmq.Broker
+	cfg   rmq.RabbitConfig
+	rdb   *redis.Client
+	repos *repository.Repositories
+	trips *trip.Builder
+	stops *stop.Detector
+
+	speeding      *speeding.Detector
+	zones         []model.SpeedZone
+	zonesLoadedAt time.Time
+
+	visits *geopkg.VisitTracker
+
+	rules          *rules.Engine
+	rulesLoadedAt  time.Time
+	groups         map[string]string
+	groupsLoadedAt time.Time
+
+	// operator of each vehicle with an open trip, for trips flushed at shutdown
+	tripOperators map[string]*string
+	// vehicles whose detector state was warmed up since the start
+	warm map[string]bool
+
+	// closed by Stop; deliveries still buffered after it are requeued
+	stopping chan struct{}
+	// closed when the delivery loop ended
+	done chan struct{}
 }
 
-func NewWorker(r *rmq.RabbitClient, cfg rmq.RabbitConfig) *Worker {
-	return &Worker{rmq: r, cfg: cfg}
+func NewWorker(r rmq.Broker, cfg rmq.RabbitConfig, rdb *redis.Client, repos *repository.Repositories) *Worker {
+	return &Worker{
+		rmq:   r,
+		cfg:   cfg,
+		rdb:   rdb,
+		repos: repos,
+		trips: trip.NewBuilder(trip.DefaultConfig()),
+		stops: stop.NewDetector(stop.DefaultConfig()),
+
+		visits:   geopkg.NewVisitTracker(geopkg.DefaultVisitConfig()),
+		speeding: speeding.NewDetector(int64(config.Cfg.SpeedingMinDuration.Seconds())),
+		rules:    rules.NewEngine(),
+
+		tripOperators: make(map[string]*string),
+		warm:          make(map[string]bool),
+		stopping:      make(chan struct{}),
+	}
 }
 
-func (w *Worker) Start() error {
-	msgs, err := rmq.ConsumeRMQWithConfig(w.rmq, w.cfg, true)
+// Start consumes location.raw until Stop. Messages are handled with ctx's values but not
+// its cancellation, so the one in progress when shutdown starts still gets written, and
+// acked once handled. The channel's prefetch bounds how many are in flight.
+func (w *Worker) Start(ctx context.Context) error {
+	w.restoreVisits(ctx)
+
+	msgs, err := w.rmq.Consume(w.cfg, false)
 	if err != nil {
 		return err
 	}
@@ -28,62 +88,99 @@ func (w *Worker) Start() error {
 	log.Printf("Geofence worker started, queue=%s routing=%s consumer=%s",
 		w.cfg.QueueName, w.cfg.RoutingKey, w.cfg.ConsumerName)
 
+	w.done = make(chan struct{})
+	work := context.WithoutCancel(ctx)
 	go func() {
+		defer close(w.done)
+
 		for d := range msgs {
-			w.handleLocationMessage(d.Body)
+			select {
+			case <-w.stopping:
+				if err := d.Nack(false, true); err != nil {
+					log.Printf("worker requeue error: %v", err)
+				}
+				continue
+			default:
+			}
+
+			w.handleLocationMessage(work, d)
 		}
 	}()
 
 	return nil
 }
 
-func (w *Worker) handleLocationMessage(body []byte) {
-	var loc model.VehicleLocation
-	if err := json.Unmarshal(body, &loc); err != nil {
-		log.Fatalf("invalid location payload: %v", err)
-		return
+// Stop cancels the consumer. The broker keeps delivering what it had already prefetched
+// until the buffer runs dry; those messages are requeued unhandled, as is anything still
+// unacked when the channel closes, so the next worker picks them up.
+func (w *Worker) Stop(ctx context.Context) error {
+	close(w.stopping)
+	return w.rmq.Cancel(w.cfg)
+}
+
+// Wait waits until the message in progress at Stop is handled.
+func (w *Worker) Wait(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	var stations []model.BusStation
-	if err := db.DB.Find(&stations).Error; err != nil {
-		log.Fatalf("load bus_stations error: %v", err)
+// handleLocationMessage handles one point and settles it: payloads that can't be decoded
+// are dropped, messages that failed on the database go back to the queue.
+func (w *Worker) handleLocationMessage(ctx context.Context, d amqp.Delivery) {
+	env, err := rmq.DecodeEvent(d)
+	if err != nil {
+		log.Printf("worker: dropping invalid location payload: %v", err)
+		d.Reject(false)
 		return
 	}
-
-	const radius = 50.0
-	for _, st := range stations {
-		dist := geopkg.HaversineMeters(loc.Latitude, loc.Longitude, st.Latitude, st.Longitude)
-		if dist <= radius {
-			w.publishGeofenceEntry(&loc, &st, dist)
-		}
+	var raw events.LocationRaw
+	if err := env.DecodeData(&raw); err != nil {
+		log.Printf("worker: dropping invalid location payload: %v", err)
+		d.Reject(false)
+		return
 	}
-}
-
-func (w *Worker) publishGeofenceEntry(loc *model.VehicleLocation, st *model.BusStation, dist float64) {
-	evt := map[string]interface{}{
-		"vehicle_id": loc.VehicleId,
-		"event":      "geofence_entry",
-		"location": map[string]interface{}{
-			"latitude":  loc.Latitude,
-			"longitude": loc.Longitude,
-		},
-		"timestamp": loc.Timestamp,
-		"station": map[string]interface{}{
-			"id":   st.Id,
-			"name": st.Name,
-		},
-		"distance_m": dist,
+	loc := model.VehicleLocation{MQTTLocationStruct: model.MQTTLocationStruct{
+		VehicleId: raw.VehicleId,
+		Latitude:  raw.Latitude,
+		Longitude: raw.Longitude,
+		Timestamp: raw.Timestamp,
+		Ignition:  raw.Ignition,
+		Speed:     raw.Speed,
+	}}
+	if raw.OperatorId != "" {
+		loc.OperatorId = &raw.OperatorId
 	}
-	b, err := json.Marshal(evt)
+	corr := env.Correlation()
+
+	// shared stations plus the ones of the vehicle's own operator
+	stations, err := w.repos.Stations.Visible(ctx, loc.OperatorId)
 	if err != nil {
-		log.Fatalf("marshal geofence_entry error: %v", err)
+		log.Printf("load bus_stations error: %v", err)
+		d.Nack(false, true)
 		return
 	}
-	if err := rmq.PublishRMQ(w.rmq, "fleet.events", "geofence.entry", b); err != nil {
-		log.Fatalf("publish geofence.entry error: %v", err)
+
+	if !w.warm[loc.VehicleId] {
+		w.warmUp(ctx, &loc, stations)
+		w.warm[loc.VehicleId] = true
+	}
+
+	// first, so nothing else has seen the point if it goes back to the queue
+	if err := w.handleVisits(ctx, &loc, stations, corr); err != nil {
+		log.Printf("worker: requeue point of vehicle %s: %v", loc.VehicleId, err)
+		d.Nack(false, true)
 		return
 	}
+	w.handleTrip(ctx, &loc, stations)
+	w.handleStop(&loc, stations, corr)
+	w.handleRules(ctx, &loc, stations, corr)
+	w.handleSpeeding(ctx, &loc, stations, corr)
 
-	log.Printf("geofence_entry vehicle=%s station=%s dist=%.1f m",
-		loc.VehicleId, st.Name, dist)
+	if err := d.Ack(false); err != nil {
+		log.Printf("worker ack error: %v", err)
+	}
 }
@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+
+	"tj/config"
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	mqttpkg "tj/pkg/mqtt"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository"
+	"tj/pkg/repository/memory"
+	"tj/pkg/trip"
+	"tj/services/subscriber/subscribertest"
+)
+
+// Harmoni and a station of op2 a few kilometres away; 0.001° of latitude is about 111 m.
+var (
+	harmoni = model.BusStation{Id: 1, Name: "Harmoni", Latitude: -6.1650, Longitude: 106.8200}
+	op2     = "op2"
+	kota    = model.BusStation{Id: 2, Name: "Kota", Latitude: -6.1376, Longitude: 106.8137, OperatorId: &op2}
+)
+
+// set once: workers of finished tests may still be reading it
+func TestMain(m *testing.M) {
+	config.Cfg = &config.Config{SpeedLimitKmh: 200, SpeedingMinDuration: time.Minute}
+	os.Exit(m.Run())
+}
+
+// geofenceFlow runs the worker on an in-memory fleet.events exchange: location.raw events
+// go in the way the subscriber publishes them, geofence events come out.
+type geofenceFlow struct {
+	broker *rmq.MemoryBroker
+	worker *Worker
+	store  *memory.Store
+	out    <-chan amqp.Delivery
+}
+
+func newGeofenceFlow(t *testing.T, seed func(*memory.Store)) *geofenceFlow {
+	t.Helper()
+
+	store := memory.New()
+	store.Stations.Add(harmoni, kota)
+	if seed != nil {
+		seed(store)
+	}
+
+	return startGeofenceFlow(t, store, store.Repositories())
+}
+
+// startGeofenceFlow runs the worker on repos, which may wrap the store's.
+func startGeofenceFlow(t *testing.T, store *memory.Store, repos *repository.Repositories) *geofenceFlow {
+	t.Helper()
+
+	cfg := rmq.RabbitConfig{
+		ExchangeName: "fleet.events",
+		ExchangeType: "topic",
+		QueueName:    "geofence_alerts",
+		RoutingKey:   "location.raw",
+		ConsumerName: "worker-geofence",
+	}
+	broker := rmq.NewMemoryBroker()
+	t.Cleanup(broker.Close)
+	broker.Bind(cfg.ExchangeName, cfg.QueueName, cfg.RoutingKey)
+	broker.Bind(cfg.ExchangeName, "test", "geofence.*")
+	out, err := broker.Consume(rmq.RabbitConfig{QueueName: "test"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker := NewWorker(broker, cfg, nil, repos)
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return &geofenceFlow{broker: broker, worker: worker, store: store, out: out}
+}
+
+// report publishes a location.raw event and returns its id, the correlation id of what
+// the worker derives from it.
+func (f *geofenceFlow) report(t *testing.T, vehicleId, operatorId string, lat, lon float64, ts int64) string {
+	t.Helper()
+
+	return f.publishRaw(t, &events.LocationRaw{
+		VehicleId:  vehicleId,
+		Latitude:   lat,
+		Longitude:  lon,
+		Timestamp:  ts,
+		OperatorId: operatorId,
+	})
+}
+
+// publishRaw publishes raw as the subscriber would and returns the event id.
+func (f *geofenceFlow) publishRaw(t *testing.T, raw *events.LocationRaw) string {
+	t.Helper()
+
+	env, err := events.New(events.SourceSubscriber, raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rmq.PublishEvent(f.broker, "fleet.events", events.TypeLocationRaw, env); err != nil {
+		t.Fatal(err)
+	}
+
+	return env.Id
+}
+
+func (f *geofenceFlow) next(t *testing.T, data any) *events.Envelope {
+	t.Helper()
+
+	select {
+	case d := <-f.out:
+		env, err := rmq.DecodeEvent(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.DecodeData(data); err != nil {
+			t.Fatal(err)
+		}
+		return env
+	case <-time.After(time.Second):
+		t.Fatal("no geofence event")
+		return nil
+	}
+}
+
+func TestEntryAndExitArePublishedAndStored(t *testing.T) {
+	f := newGeofenceFlow(t, nil)
+
+	f.report(t, "bus-1", "", harmoni.Latitude+0.005, harmoni.Longitude, 1000)
+	arrival := f.report(t, "bus-1", "", harmoni.Latitude+0.0001, harmoni.Longitude, 1030)
+	f.report(t, "bus-1", "", harmoni.Latitude, harmoni.Longitude, 1060)
+	departure := f.report(t, "bus-1", "", harmoni.Latitude+0.005, harmoni.Longitude, 1090)
+
+	var entry events.GeofenceEntry
+	env := f.next(t, &entry)
+	if env.Type != events.TypeGeofenceEntry || env.Source != events.SourceWorker || env.CorrelationId != arrival {
+		t.Errorf("entry envelope %+v, want a worker event correlated to %s", env, arrival)
+	}
+	if entry.VehicleId != "bus-1" || entry.Station != (events.StationRef{Id: 1, Name: "Harmoni"}) || entry.Timestamp != 1030 || entry.EventId == 0 {
+		t.Errorf("entry %+v", entry)
+	}
+
+	var exit events.GeofenceExit
+	env = f.next(t, &exit)
+	if env.Type != events.TypeGeofenceExit || env.CorrelationId != departure {
+		t.Errorf("exit envelope %+v, want one correlated to %s", env, departure)
+	}
+	if exit.ArrivalTime != 1030 || exit.DepartureTime != 1090 || exit.DwellS != 60 || exit.EventId == entry.EventId {
+		t.Errorf("exit %+v", exit)
+	}
+
+	stored := f.store.GeofenceEvents.All()
+	if len(stored) != 2 || stored[0].EventType != model.GeofenceEntry || stored[1].EventType != model.GeofenceExit {
+		t.Fatalf("geofence_events %+v, want an entry and an exit", stored)
+	}
+	if stored[0].Id != entry.EventId || stored[1].Id != exit.EventId {
+		t.Errorf("event ids %d, %d don't match the stored rows %d, %d", entry.EventId, exit.EventId, stored[0].Id, stored[1].Id)
+	}
+
+	visits := f.store.Visits.All()
+	if len(visits) != 1 || visits[0].DwellS == nil || *visits[0].DwellS != 60 || visits[0].PointCount != 2 {
+		t.Errorf("station_visits %+v, want one closed visit of 60 s over 2 points", visits)
+	}
+}
+
+func TestOperatorStationsOnlyApplyToTheirFleet(t *testing.T) {
+	f := newGeofenceFlow(t, nil)
+
+	f.report(t, "bus-1", "op1", kota.Latitude, kota.Longitude, 1000)
+	f.report(t, "bus-2", "op2", kota.Latitude, kota.Longitude, 1000)
+
+	// the worker handles points in order, so bus-1 passing by produced nothing
+	var entry events.GeofenceEntry
+	f.next(t, &entry)
+	if entry.VehicleId != "bus-2" || entry.Station.Id != kota.Id {
+		t.Errorf("entry %+v, want bus-2 at Kota", entry)
+	}
+	if stored := f.store.GeofenceEvents.All(); len(stored) != 1 || stored[0].OperatorId == nil || *stored[0].OperatorId != "op2" {
+		t.Errorf("geofence_events %+v, want one of op2", stored)
+	}
+}
+
+func TestOpenVisitsSurviveARestart(t *testing.T) {
+	f := newGeofenceFlow(t, func(s *memory.Store) {
+		s.Visits.Add(model.StationVisit{Id: 7, VehicleId: "bus-1", StationId: harmoni.Id, ArrivalTime: 900, PointCount: 3})
+	})
+
+	// still at the station: no second entry
+	f.report(t, "bus-1", "", harmoni.Latitude, harmoni.Longitude, 1000)
+	f.report(t, "bus-1", "", harmoni.Latitude+0.005, harmoni.Longitude, 1030)
+
+	var exit events.GeofenceExit
+	env := f.next(t, &exit)
+	if env.Type != events.TypeGeofenceExit {
+		t.Fatalf("got %s, want the exit of the restored visit", env.Type)
+	}
+	if exit.Station.Name != "Harmoni" || exit.ArrivalTime != 900 || exit.DwellS != 130 {
+		t.Errorf("exit %+v", exit)
+	}
+	if v := f.store.Visits.All(); len(v) != 1 || v[0].DepartureTime == nil || *v[0].DepartureTime != 1030 {
+		t.Errorf("station_visits %+v, want the restored visit closed at 1030", v)
+	}
+}
+
+func TestStoppedWorkerFinishesAndTakesNoMore(t *testing.T) {
+	f := newGeofenceFlow(t, nil)
+
+	f.report(t, "bus-1", "", harmoni.Latitude, harmoni.Longitude, 1000)
+	var entry events.GeofenceEntry
+	f.next(t, &entry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := f.worker.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.worker.Wait(ctx); err != nil {
+		t.Fatalf("delivery loop still running after Stop: %v", err)
+	}
+
+	f.report(t, "bus-1", "", harmoni.Latitude+0.005, harmoni.Longitude, 1030)
+	select {
+	case d := <-f.out:
+		t.Errorf("unexpected %s after Stop", d.RoutingKey)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stored := f.store.GeofenceEvents.All(); len(stored) != 1 {
+		t.Errorf("geofence_events %+v, want only the entry", stored)
+	}
+}
+
+// failingEvents fails the first n inserts, as during a database failover.
+type failingEvents struct {
+	*memory.GeofenceEvents
+	n     int
+	calls atomic.Int32
+}
+
+func (r *failingEvents) Create(ctx context.Context, ev *model.GeofenceEvent) error {
+	if int(r.calls.Add(1)) <= r.n {
+		return errors.New("connection reset")
+	}
+	return r.GeofenceEvents.Create(ctx, ev)
+}
+
+func TestEntryIsRetriedWhenItCantBeStored(t *testing.T) {
+	store := memory.New()
+	store.Stations.Add(harmoni)
+	failing := &failingEvents{GeofenceEvents: store.GeofenceEvents, n: 1}
+	repos := store.Repositories()
+	repos.GeofenceEvents = failing
+	f := startGeofenceFlow(t, store, repos)
+
+	f.report(t, "bus-1", "", harmoni.Latitude, harmoni.Longitude, 1000)
+
+	var entry events.GeofenceEntry
+	f.next(t, &entry)
+	if entry.EventId == 0 || entry.Timestamp != 1000 {
+		t.Errorf("entry %+v, want the stored event of the redelivered point", entry)
+	}
+	select {
+	case d := <-f.out:
+		t.Fatalf("unexpected %s: %s", d.RoutingKey, d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if n := failing.calls.Load(); n != 2 {
+		t.Errorf("%d inserts, want the failed one and its retry", n)
+	}
+	if rows := store.GeofenceEvents.All(); len(rows) != 1 {
+		t.Errorf("geofence_events %+v, want one entry", rows)
+	}
+	if visits := store.Visits.All(); len(visits) != 1 || visits[0].DepartureTime != nil {
+		t.Errorf("station_visits %+v, want one open visit", visits)
+	}
+}
+
+func TestOpenTripsAreSavedOnFlush(t *testing.T) {
+	f := newGeofenceFlow(t, nil)
+
+	f.report(t, "bus-2", op2, kota.Latitude+0.006, kota.Longitude, 1000)
+	f.report(t, "bus-2", op2, kota.Latitude+0.003, kota.Longitude, 1030)
+	f.report(t, "bus-2", op2, kota.Latitude, kota.Longitude, 1060)
+	var entry events.GeofenceEntry
+	f.next(t, &entry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := f.worker.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.worker.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.worker.FlushTrips(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	trips := f.store.Trips.All()
+	if len(trips) != 1 {
+		t.Fatalf("vehicle_trips %+v, want the open trip", trips)
+	}
+	tr := trips[0]
+	if tr.StartTime != 1000 || tr.EndTime != 1060 || tr.EndStationId == nil || *tr.EndStationId != kota.Id ||
+		tr.OperatorId == nil || *tr.OperatorId != op2 || tr.EndReason != trip.EndShutdown {
+		t.Errorf("trip %+v", tr)
+	}
+}
+
+func TestRestartedWorkerKeepsCountingAStop(t *testing.T) {
+	lat, lon := harmoni.Latitude+0.02, harmoni.Longitude
+	f := newGeofenceFlow(t, func(s *memory.Store) {
+		// stored and handled by the previous run
+		for ts := int64(1000); ts <= 1060; ts += 30 {
+			s.Locations.Add(model.MQTTLocationStruct{VehicleId: "bus-3", Latitude: lat, Longitude: lon, Timestamp: ts})
+		}
+	})
+	f.broker.Bind("fleet.events", "test", "vehicle.*")
+
+	f.report(t, "bus-3", "", lat, lon, 1150)
+	var stopped events.VehicleStopped
+	f.next(t, &stopped)
+	if stopped.StoppedSince != 1000 || stopped.DurationS != 150 {
+		t.Errorf("vehicle.stopped %+v, want the stop counted from 1000", stopped)
+	}
+}
+
+// The whole path a tracker's point takes: MQTT, the subscriber, location.raw on the
+// exchange, the worker, geofence.entry.
+func TestTrackerPointEndsAsGeofenceEntry(t *testing.T) {
+	f := newGeofenceFlow(t, func(s *memory.Store) {
+		s.Operators.Add(model.Operator{OperatorId: op2})
+	})
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	devices := mqttpkg.NewMemoryBroker()
+	if err := subscribertest.Listen(devices, f.broker, rdb, f.store.Repositories()); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(model.MQTTLocationStruct{Latitude: kota.Latitude, Longitude: kota.Longitude, Timestamp: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := devices.Publish(mqttpkg.LocationTopic(op2, "bus-2"), body); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry events.GeofenceEntry
+	env := f.next(t, &entry)
+	if entry.VehicleId != "bus-2" || entry.Station.Name != "Kota" || env.CorrelationId == "" {
+		t.Errorf("geofence.entry %+v, correlation %q", entry, env.CorrelationId)
+	}
+	if stored := f.store.GeofenceEvents.All(); len(stored) != 1 || stored[0].OperatorId == nil || *stored[0].OperatorId != op2 {
+		t.Errorf("geofence_events %+v, want the entry of op2", stored)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tj/pkg/events"
+	cache "tj/pkg/redis"
+)
+
+// HeartbeatMonitor periodically compares each vehicle's last-seen time (written by the
+// subscriber on ingest) against threshold and publishes vehicle.offline / vehicle.online on
+// change. It also drops vehicles silent for longer than maxAge (0 = never) from the
+// latest-location cache. It runs until ctx is done.
+func (w *Worker) HeartbeatMonitor(ctx context.Context, interval, threshold, maxAge time.Duration) error {
+	log.Printf("Heartbeat monitor started, interval=%s threshold=%s", interval, threshold)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.checkHeartbeats(ctx, threshold)
+			if maxAge > 0 {
+				w.trimLatestLocations(ctx, maxAge)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (w *Worker) checkHeartbeats(ctx context.Context, threshold time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	lastSeen, err := cache.GetLastSeen(ctx, w.rdb)
+	if err != nil {
+		log.Printf("heartbeat: load last seen error: %v", err)
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-threshold).Unix()
+
+	for vehicleId, seen := range lastSeen {
+		st := cache.ConnectivityStatus{Status: cache.StatusOnline, Since: seen}
+		if seen < cutoff {
+			st = cache.ConnectivityStatus{Status: cache.StatusOffline, Since: now.Unix()}
+		}
+
+		changed, first, err := cache.SetStatus(ctx, w.rdb, vehicleId, st)
+		if err != nil {
+			log.Printf("heartbeat: set status error vehicle=%s: %v", vehicleId, err)
+			continue
+		}
+		// a vehicle seen for the first time isn't "back" online, so only announce real transitions
+		if changed && (!first || st.Status == cache.StatusOffline) {
+			w.publishConnectivity(vehicleId, st, seen)
+		}
+	}
+}
+
+func (w *Worker) trimLatestLocations(ctx context.Context, maxAge time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	n, err := cache.TrimLatestLocations(ctx, w.rdb, time.Now().Add(-maxAge).Unix())
+	if err != nil {
+		log.Printf("heartbeat: trim latest locations error: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("dropped %d vehicles silent for %s from the latest locations", n, maxAge)
+	}
+}
+
+func (w *Worker) publishConnectivity(vehicleId string, st cache.ConnectivityStatus, lastSeen int64) {
+	payload := events.Connectivity{VehicleId: vehicleId, LastSeen: lastSeen, Timestamp: st.Since}
+
+	var evt events.Event = &events.VehicleOnline{Connectivity: payload}
+	if st.Status == cache.StatusOffline {
+		evt = &events.VehicleOffline{Connectivity: payload}
+	}
+
+	if err := w.publish(evt, ""); err != nil {
+		log.Printf("publish %s error: %v", evt.EventType(), err)
+		return
+	}
+
+	log.Printf("vehicle_%s vehicle=%s last_seen=%d", st.Status, vehicleId, lastSeen)
+}
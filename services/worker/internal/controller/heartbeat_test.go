@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	model "tj/pkg/model"
+	cache "tj/pkg/redis"
+)
+
+func TestSilentVehiclesLeaveTheLatestLocations(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+
+	now := time.Now().Unix()
+	for _, loc := range []model.MQTTLocationStruct{
+		{VehicleId: "bus-1", Timestamp: now - 60},
+		{VehicleId: "retired", Timestamp: now - 3*24*3600},
+	} {
+		if err := cache.SetLatestLocation(ctx, rdb, loc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := &Worker{rdb: rdb}
+	w.trimLatestLocations(ctx, 24*time.Hour)
+
+	locs, err := cache.GetLatestLocations(ctx, rdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 || locs[0].VehicleId != "bus-1" {
+		t.Errorf("latest locations %+v, want only bus-1", locs)
+	}
+}
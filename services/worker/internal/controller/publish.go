@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"tj/pkg/events"
+	rmq "tj/pkg/rabbitmq"
+)
+
+// publish wraps e in an envelope and sends it to fleet.events. correlationId links derived
+// events to the location.raw event they came from; empty for events with no single source.
+func (w *Worker) publish(e events.Event, correlationId string) error {
+	env, err := events.New(events.SourceWorker, e, correlationId)
+	if err != nil {
+		return err
+	}
+
+	return rmq.PublishEvent(w.rmq, "fleet.events", events.RoutingKey(e), env)
+}
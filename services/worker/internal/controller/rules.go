@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	"tj/pkg/rules"
+)
+
+// rules are managed through the API; the worker picks up changes on the next reload
+const (
+	rulesReloadInterval  = 30 * time.Second
+	groupsReloadInterval = time.Minute
+)
+
+func (w *Worker) handleRules(ctx context.Context, loc *model.VehicleLocation, stations []model.BusStation, corr string) {
+	w.reloadRules(ctx)
+	w.reloadVehicleGroups(ctx)
+
+	for _, f := range w.rules.Evaluate(w.rulesInput(loc, stations)) {
+		w.publishAlert(f, corr)
+	}
+}
+
+func (w *Worker) rulesInput(loc *model.VehicleLocation, stations []model.BusStation) rules.Input {
+	in := rules.Input{
+		VehicleId: loc.VehicleId,
+		Group:     w.groups[loc.VehicleId],
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Timestamp: loc.Timestamp,
+		Ignition:  loc.Ignition,
+		Stations:  stations,
+	}
+	if speed, ok := w.speeding.Speed(loc.VehicleId, speedPoint(loc)); ok {
+		in.SpeedKmh = &speed
+	}
+
+	return in
+}
+
+func (w *Worker) reloadRules(ctx context.Context) {
+	if time.Since(w.rulesLoadedAt) < rulesReloadInterval {
+		return
+	}
+
+	list, err := w.repos.Rules.Enabled(ctx)
+	if err != nil {
+		log.Printf("load alert_rules error: %v", err)
+		return
+	}
+	w.rules.SetRules(list)
+	w.rulesLoadedAt = time.Now()
+}
+
+func (w *Worker) reloadVehicleGroups(ctx context.Context) {
+	if time.Since(w.groupsLoadedAt) < groupsReloadInterval {
+		return
+	}
+
+	vehicles, err := w.repos.Vehicles.List(ctx)
+	if err != nil {
+		log.Printf("load vehicle groups error: %v", err)
+		return
+	}
+
+	groups := make(map[string]string, len(vehicles))
+	for _, v := range vehicles {
+		if v.GroupName != nil {
+			groups[v.VehicleId] = *v.GroupName
+		}
+	}
+	w.groups = groups
+	w.groupsLoadedAt = time.Now()
+}
+
+func (w *Worker) publishAlert(f rules.Firing, corr string) {
+	evt := &events.Alert{
+		VehicleId: f.VehicleId,
+		Rule: events.AlertRuleRef{
+			Id:       f.Rule.Id,
+			Key:      f.Rule.Key,
+			Name:     f.Rule.Name,
+			Severity: f.Rule.Severity,
+		},
+		Location:  events.Position{Latitude: f.Input.Latitude, Longitude: f.Input.Longitude},
+		SpeedKmh:  f.Input.SpeedKmh,
+		Since:     f.Since,
+		Timestamp: f.Input.Timestamp,
+	}
+	if err := w.publish(evt, corr); err != nil {
+		log.Printf("publish %s error: %v", events.RoutingKey(evt), err)
+		return
+	}
+
+	log.Printf("alert rule=%s vehicle=%s since=%d", f.Rule.Key, f.VehicleId, f.Since)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tj/config"
+	"tj/pkg/events"
+	geopkg "tj/pkg/geofence"
+	model "tj/pkg/model"
+	"tj/pkg/speeding"
+)
+
+const speedZonesReloadInterval = time.Minute
+
+func (w *Worker) handleSpeeding(ctx context.Context, loc *model.VehicleLocation, stations []model.BusStation, corr string) {
+	ep := w.speeding.Add(loc.VehicleId, speedPoint(loc), w.speedLimitAt(ctx, loc.Latitude, loc.Longitude, stations))
+	if ep == nil {
+		return
+	}
+
+	w.publishSpeeding(ep, corr)
+}
+
+func speedPoint(loc *model.VehicleLocation) speeding.Point {
+	return speeding.Point{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Timestamp: loc.Timestamp,
+		Speed:     loc.Speed,
+	}
+}
+
+// speedLimitAt returns the strictest zone limit covering the point, or the global limit.
+func (w *Worker) speedLimitAt(ctx context.Context, lat, lon float64, stations []model.BusStation) speeding.Limit {
+	limit := speeding.Limit{Kmh: config.Cfg.SpeedLimitKmh, Zone: "global"}
+
+	for _, z := range w.loadSpeedZones(ctx) {
+		var centerLat, centerLon float64
+		switch {
+		case z.StationId != nil:
+			st := findStation(stations, *z.StationId)
+			if st == nil {
+				continue
+			}
+			centerLat, centerLon = st.Latitude, st.Longitude
+		case z.Latitude != nil && z.Longitude != nil:
+			centerLat, centerLon = *z.Latitude, *z.Longitude
+		default:
+			continue
+		}
+
+		if geopkg.HaversineMeters(lat, lon, centerLat, centerLon) <= z.RadiusM && z.LimitKmh < limit.Kmh {
+			limit = speeding.Limit{Kmh: z.LimitKmh, Zone: z.Name}
+		}
+	}
+
+	return limit
+}
+
+// zones change rarely, so they are cached instead of queried for every point
+func (w *Worker) loadSpeedZones(ctx context.Context) []model.SpeedZone {
+	if time.Since(w.zonesLoadedAt) < speedZonesReloadInterval {
+		return w.zones
+	}
+
+	zones, err := w.repos.SpeedZones.List(ctx)
+	if err != nil {
+		log.Printf("load speed_zones error: %v", err)
+		return w.zones
+	}
+	w.zones = zones
+	w.zonesLoadedAt = time.Now()
+
+	return w.zones
+}
+
+func (w *Worker) publishSpeeding(ep *speeding.Episode, corr string) {
+	evt := &events.VehicleSpeeding{
+		VehicleId: ep.VehicleId,
+		Start:     ep.Start.Timestamp,
+		End:       ep.End.Timestamp,
+		DurationS: ep.DurationSec,
+		PeakKmh:   ep.PeakKmh,
+		LimitKmh:  ep.Limit.Kmh,
+		Zone:      ep.Limit.Zone,
+		Location:  events.Position{Latitude: ep.End.Latitude, Longitude: ep.End.Longitude},
+		Timestamp: ep.End.Timestamp,
+	}
+	if err := w.publish(evt, corr); err != nil {
+		log.Printf("publish vehicle.speeding error: %v", err)
+		return
+	}
+
+	log.Printf("vehicle_speeding vehicle=%s peak=%.1f km/h limit=%.0f (%s) duration=%ds",
+		ep.VehicleId, ep.PeakKmh, ep.Limit.Kmh, ep.Limit.Zone, ep.DurationSec)
+}
+
+func findStation(stations []model.BusStation, id int64) *model.BusStation {
+	for i := range stations {
+		if stations[i].Id == id {
+			return &stations[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"tj/pkg/events"
+	model "tj/pkg/model"
+	rmq "tj/pkg/rabbitmq"
+	"tj/pkg/repository/memory"
+)
+
+// reportSpeed publishes a location.raw event with a device-reported speed.
+func (f *geofenceFlow) reportSpeed(t *testing.T, vehicleId string, lat, lon float64, ts int64, speed float64) {
+	t.Helper()
+	f.publishRaw(t, &events.LocationRaw{VehicleId: vehicleId, Latitude: lat, Longitude: lon, Timestamp: ts, Speed: &speed})
+}
+
+func TestSpeedingIsPublishedOnceItEndsAgainstTheZoneLimit(t *testing.T) {
+	lat, lon := harmoni.Latitude, harmoni.Longitude
+	f := newGeofenceFlow(t, func(s *memory.Store) {
+		s.SpeedZones.Add(model.SpeedZone{Id: 1, Name: "Harmoni", Latitude: &lat, Longitude: &lon, RadiusM: 1000, LimitKmh: 40})
+	})
+	f.broker.Bind("fleet.events", "speeding", events.TypeVehicleSpeeding)
+	out, err := f.broker.Consume(rmq.RabbitConfig{QueueName: "speeding"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 60 km/h for 90 s: under the global limit of 200 away from the zone, over 40 inside it
+	for _, ts := range []int64{1000, 1030, 1060, 1090} {
+		f.reportSpeed(t, "bus-1", lat-0.05, lon, ts, 60)
+		f.reportSpeed(t, "bus-2", lat, lon, ts, 60)
+	}
+	f.reportSpeed(t, "bus-1", lat-0.05, lon, 1120, 20)
+	f.reportSpeed(t, "bus-2", lat, lon, 1120, 20)
+
+	select {
+	case d := <-out:
+		env, err := rmq.DecodeEvent(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sp events.VehicleSpeeding
+		if err := env.DecodeData(&sp); err != nil {
+			t.Fatal(err)
+		}
+		if sp.VehicleId != "bus-2" || sp.LimitKmh != 40 || sp.Zone != "Harmoni" {
+			t.Errorf("speeding %+v, want bus-2 over the Harmoni zone's 40 km/h", sp)
+		}
+		if sp.Start != 1000 || sp.End != 1090 || sp.DurationS != 90 || sp.PeakKmh != 60 {
+			t.Errorf("speeding %+v, want 1000-1090 at 60 km/h", sp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no vehicle.speeding")
+	}
+
+	select {
+	case d := <-out:
+		t.Errorf("second vehicle.speeding %s", d.Body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"log"
+
+	"tj/pkg/events"
+	geopkg "tj/pkg/geofence"
+	model "tj/pkg/model"
+	"tj/pkg/stop"
+)
+
+// a stop anchored within this distance of a station is a scheduled station stop
+const stopStationRadius = 50.0
+
+const (
+	stopAtStation   = "at_station"
+	stopUnscheduled = "unscheduled"
+)
+
+func (w *Worker) handleStop(loc *model.VehicleLocation, stations []model.BusStation, corr string) {
+	t := w.stops.Add(loc.VehicleId, stopPoint(loc))
+	if t == nil {
+		return
+	}
+
+	w.publishStopTransition(loc.VehicleId, t, stations, corr)
+}
+
+func stopPoint(loc *model.VehicleLocation) stop.Point {
+	return stop.Point{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Timestamp: loc.Timestamp,
+		Ignition:  loc.Ignition,
+	}
+}
+
+func (w *Worker) publishStopTransition(vehicleId string, t *stop.Transition, stations []model.BusStation, corr string) {
+	payload := events.StopTransition{
+		VehicleId:      vehicleId,
+		Classification: stopUnscheduled,
+		Location:       events.Position{Latitude: t.Anchor.Latitude, Longitude: t.Anchor.Longitude},
+		StoppedSince:   t.Anchor.Timestamp,
+		DurationS:      t.DurationSec,
+		EngineOnS:      t.EngineOnSec,
+		Ignition:       t.Ignition,
+		Timestamp:      t.At.Timestamp,
+	}
+	if st, _ := geopkg.NearestStation(stations, t.Anchor.Latitude, t.Anchor.Longitude, stopStationRadius); st != nil {
+		payload.Classification = stopAtStation
+		payload.Station = &events.StationRef{Id: st.Id, Name: st.Name}
+	}
+	// idling = engine running while stopped away from a station
+	payload.Idling = payload.Classification == stopUnscheduled && t.Ignition != nil && *t.Ignition
+
+	var evt events.Event = &events.VehicleStopped{StopTransition: payload}
+	if t.Kind == stop.Moving {
+		evt = &events.VehicleMoving{StopTransition: payload}
+	}
+
+	if err := w.publish(evt, corr); err != nil {
+		log.Printf("publish %s error: %v", evt.EventType(), err)
+		return
+	}
+
+	log.Printf("%s vehicle=%s class=%s duration=%ds", evt.EventType(), vehicleId, payload.Classification, t.DurationSec)
+}
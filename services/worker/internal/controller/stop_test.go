@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"tj/pkg/events"
+	rmq "tj/pkg/rabbitmq"
+)
+
+func TestStopsAreClassifiedByStationAndIgnition(t *testing.T) {
+	f := newGeofenceFlow(t, nil)
+	f.broker.Bind("fleet.events", "stops", events.TypeVehicleStopped)
+	out, err := f.broker.Consume(rmq.RabbitConfig{QueueName: "stops"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	on, off := true, false
+	away := harmoni.Latitude + 0.02
+	cases := []struct {
+		vehicleId string
+		lat       float64
+		ignition  *bool
+		class     string
+		station   bool
+		idling    bool
+	}{
+		// engine running at a station is boarding, not idling
+		{"bus-1", harmoni.Latitude + 0.0002, &on, stopAtStation, true, false},
+		{"bus-2", away, &on, stopUnscheduled, false, true},
+		{"bus-3", away, &off, stopUnscheduled, false, false},
+		{"bus-4", away, nil, stopUnscheduled, false, false},
+	}
+	for _, tc := range cases {
+		for ts := int64(1000); ts <= 1120; ts += 60 {
+			f.publishRaw(t, &events.LocationRaw{VehicleId: tc.vehicleId, Latitude: tc.lat, Longitude: harmoni.Longitude, Timestamp: ts, Ignition: tc.ignition})
+		}
+	}
+
+	got := make(map[string]events.VehicleStopped)
+	for len(got) < len(cases) {
+		select {
+		case d := <-out:
+			env, err := rmq.DecodeEvent(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stopped events.VehicleStopped
+			if err := env.DecodeData(&stopped); err != nil {
+				t.Fatal(err)
+			}
+			got[stopped.VehicleId] = stopped
+		case <-time.After(time.Second):
+			t.Fatalf("%d vehicle.stopped, want %d", len(got), len(cases))
+		}
+	}
+
+	for _, tc := range cases {
+		s := got[tc.vehicleId]
+		if s.Classification != tc.class || (s.Station != nil) != tc.station || s.Idling != tc.idling {
+			t.Errorf("%s: %+v, want %s, station %v, idling %v", tc.vehicleId, s, tc.class, tc.station, tc.idling)
+		}
+		if s.StoppedSince != 1000 || s.DurationS != 120 {
+			t.Errorf("%s: stopped since %d for %d s, want since 1000 for 120 s", tc.vehicleId, s.StoppedSince, s.DurationS)
+		}
+	}
+	if s := got["bus-1"].Station; s == nil || s.Id != harmoni.Id {
+		t.Errorf("bus-1 stopped at %+v, want Harmoni", s)
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	geopkg "tj/pkg/geofence"
+	model "tj/pkg/model"
+	"tj/pkg/trip"
+)
+
+// trips starting or ending this close to a station are attributed to it
+const tripStationRadius = 100.0
+
+func (w *Worker) handleTrip(ctx context.Context, loc *model.VehicleLocation, stations []model.BusStation) {
+	t := w.trips.Add(loc.VehicleId, trip.Point{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Timestamp: loc.Timestamp,
+		Ignition:  loc.Ignition,
+	})
+	if t == nil {
+		w.tripOperators[loc.VehicleId] = loc.OperatorId
+		return
+	}
+	delete(w.tripOperators, loc.VehicleId)
+
+	w.saveTrip(ctx, t, stations, loc.OperatorId)
+}
+
+// FlushTrips saves the trips still open, ended at their last point. It is a Flush hook and
+// needs the delivery loop to have ended, the builder isn't safe for concurrent use.
+func (w *Worker) FlushTrips(ctx context.Context) error {
+	select {
+	case <-w.done:
+	default:
+		return errors.New("location handler still running, open trips not saved")
+	}
+
+	for _, t := range w.trips.Flush() {
+		operatorId := w.tripOperators[t.VehicleId]
+		stations, err := w.repos.Stations.Visible(ctx, operatorId)
+		if err != nil {
+			return fmt.Errorf("load bus_stations: %w", err)
+		}
+		w.saveTrip(ctx, t, stations, operatorId)
+	}
+	clear(w.tripOperators)
+
+	return nil
+}
+
+func (w *Worker) saveTrip(ctx context.Context, t *trip.Trip, stations []model.BusStation, operatorId *string) {
+	record := model.VehicleTrip{
+		VehicleId:      t.VehicleId,
+		StartTime:      t.Start.Timestamp,
+		EndTime:        t.End.Timestamp,
+		StartLatitude:  t.Start.Latitude,
+		StartLongitude: t.Start.Longitude,
+		EndLatitude:    t.End.Latitude,
+		EndLongitude:   t.End.Longitude,
+		DistanceM:      t.DistanceM,
+		DurationS:      t.DurationSec(),
+		MaxSpeedKmh:    t.MaxSpeedKmh,
+		PointCount:     t.Points,
+		EndReason:      t.EndReason,
+		OperatorId:     operatorId,
+	}
+	if st, _ := geopkg.NearestStation(stations, t.Start.Latitude, t.Start.Longitude, tripStationRadius); st != nil {
+		record.StartStationId = &st.Id
+	}
+	if st, _ := geopkg.NearestStation(stations, t.End.Latitude, t.End.Longitude, tripStationRadius); st != nil {
+		record.EndStationId = &st.Id
+	}
+
+	if err := w.repos.Trips.Create(ctx, &record); err != nil {
+		log.Printf("insert vehicle_trip error: %v", err)
+		return
+	}
+
+	log.Printf("trip vehicle=%s start=%d end=%d dist=%.0f m reason=%s",
+		t.VehicleId, t.Start.Timestamp, t.End.Timestamp, t.DistanceM, t.EndReason)
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"tj/pkg/events"
+	geopkg "tj/pkg/geofence"
+	model "tj/pkg/model"
+)
+
+// handleVisits stores the entries and exits a point caused, then publishes them. When
+// the database fails the tracker is put back as it was and the error returned, so the
+// redelivered point produces the same events again. Events of the point stored before
+// the failure are then stored twice; nothing is published twice.
+func (w *Worker) handleVisits(ctx context.Context, loc *model.VehicleLocation, stations []model.BusStation, corr string) error {
+	cp := w.visits.Checkpoint(loc.VehicleId)
+	evs := w.visits.Add(loc.VehicleId, stations, loc.Latitude, loc.Longitude, loc.Timestamp)
+
+	ids := make([]int64, len(evs))
+	for i := range evs {
+		ev := &evs[i]
+		id, err := w.saveGeofenceEvent(ctx, ev, loc.OperatorId)
+		if err == nil {
+			if ev.Type == model.GeofenceEntry {
+				err = w.openVisit(ctx, ev, loc.OperatorId)
+			} else {
+				err = w.closeVisit(ctx, ev)
+			}
+		}
+		if err != nil {
+			w.visits.Rollback(cp)
+			return err
+		}
+		ids[i] = id
+	}
+
+	for i := range evs {
+		switch evs[i].Type {
+		case model.GeofenceEntry:
+			w.publishGeofenceEntry(&evs[i], ids[i], corr)
+		case model.GeofenceExit:
+			w.publishGeofenceExit(&evs[i], ids[i], corr)
+		}
+	}
+
+	return nil
+}
+
+// restoreVisits loads visits left open by the previous run into the tracker.
+func (w *Worker) restoreVisits(ctx context.Context) {
+	open, err := w.repos.Visits.Open(ctx)
+	if err != nil {
+		log.Printf("load open station_visits error: %v", err)
+		return
+	}
+
+	for _, v := range open {
+		w.visits.Restore(geopkg.Visit{
+			VehicleId:    v.VehicleId,
+			StationId:    v.StationId,
+			StationName:  v.StationName,
+			Arrival:      v.ArrivalTime,
+			LastInside:   v.ArrivalTime,
+			MinDistanceM: v.MinDistanceM,
+			Points:       v.PointCount,
+		})
+	}
+	if len(open) > 0 {
+		log.Printf("restored %d open station visits", len(open))
+	}
+}
+
+func (w *Worker) saveGeofenceEvent(ctx context.Context, ev *geopkg.VisitEvent, operatorId *string) (int64, error) {
+	record := model.GeofenceEvent{
+		VehicleId:  ev.Visit.VehicleId,
+		StationId:  ev.Visit.StationId,
+		EventType:  ev.Type,
+		Timestamp:  ev.Timestamp,
+		Latitude:   ev.Latitude,
+		Longitude:  ev.Longitude,
+		DistanceM:  ev.DistanceM,
+		OperatorId: operatorId,
+	}
+	if err := w.repos.GeofenceEvents.Create(ctx, &record); err != nil {
+		return 0, fmt.Errorf("save geofence_events: %w", err)
+	}
+
+	return record.Id, nil
+}
+
+func (w *Worker) openVisit(ctx context.Context, ev *geopkg.VisitEvent, operatorId *string) error {
+	record := model.StationVisit{
+		VehicleId:    ev.Visit.VehicleId,
+		StationId:    ev.Visit.StationId,
+		ArrivalTime:  ev.Visit.Arrival,
+		MinDistanceM: ev.Visit.MinDistanceM,
+		PointCount:   ev.Visit.Points,
+		OperatorId:   operatorId,
+	}
+	if err := w.repos.Visits.Create(ctx, &record); err != nil {
+		return fmt.Errorf("save station_visits: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) closeVisit(ctx context.Context, ev *geopkg.VisitEvent) error {
+	dwell := ev.Timestamp - ev.Visit.Arrival
+	err := w.repos.Visits.Close(ctx, &model.StationVisit{
+		VehicleId:     ev.Visit.VehicleId,
+		StationId:     ev.Visit.StationId,
+		DepartureTime: &ev.Timestamp,
+		DwellS:        &dwell,
+		MinDistanceM:  ev.Visit.MinDistanceM,
+		PointCount:    ev.Visit.Points,
+	})
+	if err != nil {
+		return fmt.Errorf("close station_visits: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Worker) publishGeofenceEntry(ev *geopkg.VisitEvent, eventId int64, corr string) {
+	evt := &events.GeofenceEntry{
+		VehicleId: ev.Visit.VehicleId,
+		EventId:   eventId,
+		Location:  events.Position{Latitude: ev.Latitude, Longitude: ev.Longitude},
+		Timestamp: ev.Timestamp,
+		Station:   events.StationRef{Id: ev.Visit.StationId, Name: ev.Visit.StationName},
+		DistanceM: ev.DistanceM,
+	}
+	if err := w.publish(evt, corr); err != nil {
+		log.Printf("publish geofence.entry error: %v", err)
+		return
+	}
+
+	log.Printf("geofence_entry vehicle=%s station=%s dist=%.1f m",
+		ev.Visit.VehicleId, ev.Visit.StationName, ev.DistanceM)
+}
+
+func (w *Worker) publishGeofenceExit(ev *geopkg.VisitEvent, eventId int64, corr string) {
+	evt := &events.GeofenceExit{
+		VehicleId:     ev.Visit.VehicleId,
+		EventId:       eventId,
+		Location:      events.Position{Latitude: ev.Latitude, Longitude: ev.Longitude},
+		Timestamp:     ev.Timestamp,
+		Station:       events.StationRef{Id: ev.Visit.StationId, Name: ev.Visit.StationName},
+		ArrivalTime:   ev.Visit.Arrival,
+		DepartureTime: ev.Timestamp,
+		DwellS:        ev.Timestamp - ev.Visit.Arrival,
+	}
+	if err := w.publish(evt, corr); err != nil {
+		log.Printf("publish geofence.exit error: %v", err)
+		return
+	}
+
+	log.Printf("geofence_exit vehicle=%s station=%s dwell=%ds",
+		ev.Visit.VehicleId, ev.Visit.StationName, evt.DwellS)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	model "tj/pkg/model"
+)
+
+// how far back warmUp replays: past the stop dwell and the speeding debounce, so only rule
+// durations longer than this start over after a restart
+const warmUpWindow = 15 * time.Minute
+
+// warmUp replays the vehicle's stored points from before loc, the first of its points this
+// run handles, into the stop and speeding detectors and the rules engine. Nothing is
+// published: the previous run handled those points already, the ones it didn't went back
+// to the queue and come after loc. Trips aren't replayed, FlushTrips saved the open ones.
+func (w *Worker) warmUp(ctx context.Context, loc *model.VehicleLocation, stations []model.BusStation) {
+	points, err := w.repos.Locations.Track(ctx, loc.VehicleId, loc.Timestamp-int64(warmUpWindow.Seconds()), loc.Timestamp)
+	if err != nil {
+		log.Printf("load vehicle_locations for warm-up error: %v", err)
+		return
+	}
+	if len(points) == 0 {
+		return
+	}
+
+	w.reloadRules(ctx)
+	w.reloadVehicleGroups(ctx)
+	for _, p := range points {
+		past := &model.VehicleLocation{MQTTLocationStruct: p}
+		w.stops.Add(p.VehicleId, stopPoint(past))
+		w.rules.Evaluate(w.rulesInput(past, stations))
+		w.speeding.Add(p.VehicleId, speedPoint(past), w.speedLimitAt(ctx, p.Latitude, p.Longitude, stations))
+	}
+
+	log.Printf("warmed up vehicle=%s from %d stored points", loc.VehicleId, len(points))
+}